@@ -0,0 +1,33 @@
+package main
+
+import (
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/services"
+)
+
+// client smtpctl的操作接口，本地模式直接调用services，远程模式调用HTTP API
+type client interface {
+	ListConfigs() ([]models.SMTPConfig, error)
+	GetConfig(id uint) (*models.SMTPConfig, error)
+	DefaultConfig() (*models.SMTPConfig, error)
+	CreateConfig(config *models.SMTPConfig) (*models.SMTPConfig, error)
+	DeleteConfig(id uint) error
+	SetDefaultConfig(id uint) error
+	TestConfig(id uint) error
+	SendTestEmail(id uint, to string) error
+
+	ListTemplates() ([]models.EmailTemplate, error)
+	GetTemplate(id uint) (*models.EmailTemplate, error)
+	CreateTemplate(template *models.EmailTemplate) (*models.EmailTemplate, error)
+	DeleteTemplate(id uint) error
+
+	ListHistory(page, pageSize int, status string) (*services.HistoryListResponse, error)
+	GetHistory(id uint) (*models.EmailHistory, error)
+	DeleteHistory(id uint) error
+	Statistics() (*services.StatisticsResponse, error)
+
+	Send(req *services.SendEmailRequest) (*models.EmailHistory, error)
+
+	ListBackups() ([]services.BackupInfo, error)
+	CreateBackup() (*services.BackupInfo, error)
+}
@@ -0,0 +1,598 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/services"
+)
+
+// stringList 可重复、可逗号分隔的参数
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// newFlagSet 创建子命令参数解析器
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseFlags 解析子命令参数，参数错误时附带命令名称
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%s: %w", fs.Name(), err)
+	}
+	return nil
+}
+
+// parseID 解析唯一的位置参数ID
+func parseID(name string, args []string) (uint, error) {
+	if len(args) != 1 {
+		return 0, fmt.Errorf("用法: %s <id>", name)
+	}
+	id, err := strconv.ParseUint(args[0], 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("无效的ID: %s", args[0])
+	}
+	return uint(id), nil
+}
+
+// subcommand 拆分子命令和参数
+func subcommand(args []string) (string, []string) {
+	if len(args) == 0 {
+		return "list", nil
+	}
+	return args[0], args[1:]
+}
+
+// readInput 读取文件内容，"-" 表示标准输入
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// runSMTP SMTP配置管理
+func runSMTP(c client, out *printer, args []string) error {
+	sub, args := subcommand(args)
+	switch sub {
+	case "list":
+		configs, err := c.ListConfigs()
+		if err != nil {
+			return err
+		}
+		return printConfigs(out, configs)
+	case "get":
+		id, err := parseID("smtp get", args)
+		if err != nil {
+			return err
+		}
+		config, err := c.GetConfig(id)
+		if err != nil {
+			return err
+		}
+		return printConfigs(out, []models.SMTPConfig{*config})
+	case "create":
+		return createConfig(c, out, args)
+	case "delete":
+		id, err := parseID("smtp delete", args)
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteConfig(id); err != nil {
+			return err
+		}
+		return out.message("已删除SMTP配置 %d", id)
+	case "set-default":
+		id, err := parseID("smtp set-default", args)
+		if err != nil {
+			return err
+		}
+		if err := c.SetDefaultConfig(id); err != nil {
+			return err
+		}
+		return out.message("已将SMTP配置 %d 设置为默认配置", id)
+	case "test":
+		id, err := parseID("smtp test", args)
+		if err != nil {
+			return err
+		}
+		if err := c.TestConfig(id); err != nil {
+			return err
+		}
+		return out.message("SMTP配置 %d 连接测试成功", id)
+	case "send-test":
+		fs := newFlagSet("smtp send-test")
+		to := fs.String("to", "", "")
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		id, err := parseID("smtp send-test -to <email>", fs.Args())
+		if err != nil {
+			return err
+		}
+		if *to == "" {
+			return errors.New("请使用 -to 指定收件人")
+		}
+		if err := c.SendTestEmail(id, *to); err != nil {
+			return err
+		}
+		return out.message("测试邮件已发送到 %s", *to)
+	}
+	return fmt.Errorf("未知的子命令: smtp %s", sub)
+}
+
+// createConfig 创建SMTP配置，密码通过标准输入传入以免出现在命令历史中
+func createConfig(c client, out *printer, args []string) error {
+	config := &models.SMTPConfig{}
+	var encryption string
+	var passwordStdin bool
+	fs := newFlagSet("smtp create")
+	fs.StringVar(&config.Name, "name", "", "")
+	fs.StringVar(&config.Host, "host", "", "")
+	fs.IntVar(&config.Port, "port", 0, "")
+	fs.StringVar(&config.Username, "username", "", "")
+	fs.BoolVar(&passwordStdin, "password-stdin", false, "")
+	fs.StringVar(&config.FromEmail, "from", "", "")
+	fs.StringVar(&config.FromName, "from-name", "", "")
+	fs.StringVar(&encryption, "encryption", string(models.EncryptionNone), "")
+	fs.BoolVar(&config.IsDefault, "default", false, "")
+	fs.BoolVar(&config.Shared, "shared", false, "")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	config.Encryption = models.EncryptionType(encryption)
+	switch {
+	case config.Name == "":
+		return errors.New("配置名称不能为空")
+	case config.Host == "":
+		return errors.New("SMTP服务器地址不能为空")
+	case config.Port <= 0 || config.Port > 65535:
+		return errors.New("SMTP端口无效")
+	case config.FromEmail == "":
+		return errors.New("发件人邮箱不能为空")
+	case config.Encryption != models.EncryptionNone && config.Encryption != models.EncryptionTLS && config.Encryption != models.EncryptionStartTLS:
+		return fmt.Errorf("无效的加密类型: %s", encryption)
+	}
+	if passwordStdin {
+		password, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		config.Password = strings.TrimRight(string(password), "\r\n")
+	}
+
+	created, err := c.CreateConfig(config)
+	if err != nil {
+		return err
+	}
+	return printConfigs(out, []models.SMTPConfig{*created})
+}
+
+// printConfigs 打印SMTP配置
+func printConfigs(out *printer, configs []models.SMTPConfig) error {
+	rows := make([][]string, len(configs))
+	for i, cfg := range configs {
+		rows[i] = []string{
+			strconv.FormatUint(uint64(cfg.ID), 10),
+			cfg.Name,
+			fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+			string(cfg.Encryption),
+			cfg.FromEmail,
+			yesNo(cfg.IsDefault),
+			yesNo(cfg.Shared),
+		}
+	}
+	return out.table(configs, []string{"ID", "NAME", "SERVER", "ENCRYPTION", "FROM", "DEFAULT", "SHARED"}, rows)
+}
+
+// runTemplate 邮件模板管理
+func runTemplate(c client, out *printer, args []string) error {
+	sub, args := subcommand(args)
+	switch sub {
+	case "list":
+		templates, err := c.ListTemplates()
+		if err != nil {
+			return err
+		}
+		return printTemplates(out, templates)
+	case "get":
+		id, err := parseID("template get", args)
+		if err != nil {
+			return err
+		}
+		template, err := c.GetTemplate(id)
+		if err != nil {
+			return err
+		}
+		if out.format == outputJSON {
+			return out.json(template)
+		}
+		fmt.Printf("ID:      %d\n名称:    %s\n主题:    %s\n共享:    %s\n更新时间: %s\n\n%s\n",
+			template.ID, template.Name, template.Subject, yesNo(template.Shared), formatTime(template.UpdatedAt), template.Body)
+		return nil
+	case "create":
+		template := &models.EmailTemplate{}
+		var bodyFile string
+		fs := newFlagSet("template create")
+		fs.StringVar(&template.Name, "name", "", "")
+		fs.StringVar(&template.Subject, "subject", "", "")
+		fs.StringVar(&template.Body, "body", "", "")
+		fs.StringVar(&bodyFile, "body-file", "", "")
+		fs.BoolVar(&template.Shared, "shared", false, "")
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		if bodyFile != "" {
+			body, err := readInput(bodyFile)
+			if err != nil {
+				return fmt.Errorf("读取正文失败: %w", err)
+			}
+			template.Body = string(body)
+		}
+		if template.Name == "" || template.Subject == "" || template.Body == "" {
+			return errors.New("模板名称、主题和正文不能为空")
+		}
+		created, err := c.CreateTemplate(template)
+		if err != nil {
+			return err
+		}
+		return printTemplates(out, []models.EmailTemplate{*created})
+	case "delete":
+		id, err := parseID("template delete", args)
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteTemplate(id); err != nil {
+			return err
+		}
+		return out.message("已删除模板 %d", id)
+	}
+	return fmt.Errorf("未知的子命令: template %s", sub)
+}
+
+// printTemplates 打印模板列表
+func printTemplates(out *printer, templates []models.EmailTemplate) error {
+	rows := make([][]string, len(templates))
+	for i, t := range templates {
+		rows[i] = []string{
+			strconv.FormatUint(uint64(t.ID), 10),
+			t.Name,
+			truncate(t.Subject, 40),
+			yesNo(t.Shared),
+			formatTime(t.UpdatedAt),
+		}
+	}
+	return out.table(templates, []string{"ID", "NAME", "SUBJECT", "SHARED", "UPDATED"}, rows)
+}
+
+// runHistory 发送历史
+func runHistory(c client, out *printer, args []string) error {
+	sub, args := subcommand(args)
+	switch sub {
+	case "list":
+		fs := newFlagSet("history list")
+		page := fs.Int("page", 1, "")
+		pageSize := fs.Int("page-size", 20, "")
+		status := fs.String("status", "all", "")
+		if err := parseFlags(fs, args); err != nil {
+			return err
+		}
+		result, err := c.ListHistory(*page, *pageSize, *status)
+		if err != nil {
+			return err
+		}
+		if out.format == outputJSON {
+			return out.json(result)
+		}
+		if err := printHistory(out, result.List); err != nil {
+			return err
+		}
+		fmt.Printf("\n第 %d 页，每页 %d 条，共 %d 条\n", result.Page, result.PageSize, result.Total)
+		return nil
+	case "get":
+		id, err := parseID("history get", args)
+		if err != nil {
+			return err
+		}
+		history, err := c.GetHistory(id)
+		if err != nil {
+			return err
+		}
+		if out.format == outputJSON {
+			return out.json(history)
+		}
+		fmt.Printf("ID:      %d\n状态:    %s\n收件人:  %s\n抄送:    %s\n密送:    %s\n主题:    %s\n附件:    %d 个\n发送时间: %s\n",
+			history.ID, history.Status, history.ToEmail, strings.Join(history.CcEmail, ", "), strings.Join(history.BccEmail, ", "),
+			history.Subject, len(history.Attachments), formatTime(history.SentAt))
+		if history.ErrorMessage != "" {
+			fmt.Printf("错误:    %s\n", history.ErrorMessage)
+		}
+		fmt.Printf("\n%s\n", history.Body)
+		return nil
+	case "delete":
+		id, err := parseID("history delete", args)
+		if err != nil {
+			return err
+		}
+		if err := c.DeleteHistory(id); err != nil {
+			return err
+		}
+		return out.message("已删除发送记录 %d", id)
+	case "stats":
+		stats, err := c.Statistics()
+		if err != nil {
+			return err
+		}
+		return out.table(stats, []string{"TOTAL", "SUCCESS", "FAILED"}, [][]string{{
+			strconv.FormatInt(stats.Total, 10),
+			strconv.FormatInt(stats.Success, 10),
+			strconv.FormatInt(stats.Failed, 10),
+		}})
+	}
+	return fmt.Errorf("未知的子命令: history %s", sub)
+}
+
+// printHistory 打印发送记录
+func printHistory(out *printer, list []models.EmailHistory) error {
+	rows := make([][]string, len(list))
+	for i, h := range list {
+		rows[i] = []string{
+			strconv.FormatUint(uint64(h.ID), 10),
+			string(h.Status),
+			truncate(h.ToEmail, 40),
+			truncate(h.Subject, 40),
+			formatTime(h.SentAt),
+		}
+	}
+	return out.table(list, []string{"ID", "STATUS", "TO", "SUBJECT", "SENT AT"}, rows)
+}
+
+// runSend 发送邮件
+// 正文来源依次为 -eml、-body、-body-file、-template，都未指定时从标准输入读取
+func runSend(c client, out *printer, args []string) error {
+	var to, cc, bcc, attach stringList
+	var configID, templateID uint
+	var subject, body, bodyFile, eml string
+	var text bool
+	fs := newFlagSet("send")
+	fs.Var(&to, "to", "")
+	fs.Var(&cc, "cc", "")
+	fs.Var(&bcc, "bcc", "")
+	fs.Var(&attach, "attach", "")
+	fs.UintVar(&configID, "config", 0, "")
+	fs.UintVar(&templateID, "template", 0, "")
+	fs.StringVar(&subject, "subject", "", "")
+	fs.StringVar(&body, "body", "", "")
+	fs.StringVar(&bodyFile, "body-file", "", "")
+	fs.StringVar(&eml, "eml", "", "")
+	fs.BoolVar(&text, "text", false, "")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	req := &services.SendEmailRequest{}
+	switch {
+	case eml != "":
+		data, err := readInput(eml)
+		if err != nil {
+			return fmt.Errorf("读取邮件失败: %w", err)
+		}
+		if req, err = services.ParseRawMessage(strings.NewReader(string(data))); err != nil {
+			return err
+		}
+	case body != "":
+		req.Body = body
+	case bodyFile != "":
+		data, err := readInput(bodyFile)
+		if err != nil {
+			return fmt.Errorf("读取正文失败: %w", err)
+		}
+		req.Body = string(data)
+	case templateID != 0:
+		template, err := c.GetTemplate(templateID)
+		if err != nil {
+			return err
+		}
+		req.Subject = template.Subject
+		req.Body = template.Body
+	default:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("读取正文失败: %w", err)
+		}
+		req.Body = string(data)
+	}
+	if text && eml == "" {
+		req.Text, req.Body = req.Body, ""
+	}
+
+	// 命令行参数补充或覆盖邮件中的收件人和主题
+	req.To = append(req.To, to...)
+	req.Cc = append(req.Cc, cc...)
+	req.Bcc = append(req.Bcc, bcc...)
+	if subject != "" {
+		req.Subject = subject
+	}
+	for _, path := range attach {
+		attachment, err := readAttachment(path)
+		if err != nil {
+			return err
+		}
+		req.Attachments = append(req.Attachments, *attachment)
+	}
+
+	switch {
+	case len(req.To) == 0:
+		return errors.New("收件人列表不能为空")
+	case req.Subject == "":
+		return errors.New("邮件主题不能为空")
+	case strings.TrimSpace(req.Body) == "":
+		return errors.New("邮件正文不能为空")
+	}
+
+	if configID == 0 {
+		config, err := c.DefaultConfig()
+		if err != nil {
+			return fmt.Errorf("未指定 -config 且没有默认SMTP配置: %w", err)
+		}
+		configID = config.ID
+	}
+	req.SmtpConfigID = configID
+
+	history, err := c.Send(req)
+	if err != nil {
+		return err
+	}
+	return printHistory(out, []models.EmailHistory{*history})
+}
+
+// readAttachment 读取附件文件，内容类型按扩展名判断
+func readAttachment(path string) (*services.Attachment, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取附件失败: %w", err)
+	}
+	return &services.Attachment{
+		Filename:    filepath.Base(path),
+		Content:     base64.StdEncoding.EncodeToString(data),
+		ContentType: mime.TypeByExtension(filepath.Ext(path)),
+	}, nil
+}
+
+// localOnly 只能在本地执行、不需要操作者身份的数据库维护命令
+var localOnly = map[string]bool{
+	"migrate":     true,
+	"restore":     true,
+	"rotate-keys": true,
+}
+
+// runDB 数据库备份（本地和远程模式均可用）
+func runDB(c client, out *printer, args []string) error {
+	sub, _ := subcommand(args)
+	switch sub {
+	case "backup":
+		info, err := c.CreateBackup()
+		if err != nil {
+			return err
+		}
+		return printBackups(out, []services.BackupInfo{*info})
+	case "backups", "list":
+		backups, err := c.ListBackups()
+		if err != nil {
+			return err
+		}
+		return printBackups(out, backups)
+	}
+	return fmt.Errorf("未知的子命令: db %s", sub)
+}
+
+// printBackups 打印备份列表
+func printBackups(out *printer, backups []services.BackupInfo) error {
+	rows := make([][]string, len(backups))
+	for i, b := range backups {
+		rows[i] = []string{b.Name, strconv.FormatInt(b.Size, 10), yesNo(b.Compressed), yesNo(b.Encrypted), formatTime(b.CreatedAt)}
+	}
+	return out.table(backups, []string{"NAME", "SIZE", "COMPRESSED", "ENCRYPTED", "CREATED"}, rows)
+}
+
+// runMaintenance 本地数据库维护：迁移、恢复和密钥轮换
+func runMaintenance(out *printer, sub string, args []string) error {
+	db := database.GetDB()
+	switch sub {
+	case "migrate":
+		action := "status"
+		if len(args) > 0 {
+			action = args[0]
+		}
+		n := 0
+		if len(args) > 1 {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 0 {
+				return fmt.Errorf("无效的参数: %s", args[1])
+			}
+		}
+		switch action {
+		case "status":
+			statuses, err := database.MigrationStatuses(db)
+			if err != nil {
+				return err
+			}
+			rows := make([][]string, len(statuses))
+			for i, s := range statuses {
+				appliedAt := "-"
+				if s.AppliedAt != nil {
+					appliedAt = s.AppliedAt.Format(time.RFC3339)
+				}
+				rows[i] = []string{strconv.Itoa(s.Version), s.Name, s.State, appliedAt}
+			}
+			return out.table(statuses, []string{"VERSION", "NAME", "STATE", "APPLIED AT"}, rows)
+		case "up":
+			if err := database.Migrate(db, n); err != nil {
+				return err
+			}
+		case "down":
+			if n == 0 {
+				n = 1
+			}
+			if err := database.Rollback(db, n); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("未知的迁移操作: %s（可选 status、up、down）", action)
+		}
+		version, err := database.SchemaVersion(db)
+		if err != nil {
+			return err
+		}
+		return out.message("当前结构版本: %d", version)
+	case "restore":
+		if len(args) != 1 {
+			return errors.New("用法: db restore <备份文件路径>")
+		}
+		version, err := services.NewBackupService().Restore(args[0])
+		if err != nil {
+			return err
+		}
+		return out.message("恢复完成，备份的结构版本为 %d，下次启动时将执行未执行的迁移", version)
+	case "rotate-keys":
+		if err := database.Migrate(db, 0); err != nil {
+			return err
+		}
+		result, err := services.NewSMTPService().RotateKeys()
+		if err != nil {
+			return err
+		}
+		if out.format == outputJSON {
+			return out.json(result)
+		}
+		return out.message("当前密钥: %s，共 %d 个加密保存的密码和密钥，重新加密 %d 个", result.ActiveKeyID, result.Total, result.Rotated)
+	}
+	return fmt.Errorf("未知的子命令: db %s", sub)
+}
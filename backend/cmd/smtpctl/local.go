@@ -0,0 +1,198 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/services"
+)
+
+// localClient 直接读写本地数据库
+type localClient struct {
+	p                *services.Principal
+	smtpService      *services.SMTPService
+	templateService  *services.TemplateService
+	historyService   *services.HistoryService
+	emailService     *services.EmailService
+	backupService    *services.BackupService
+	workspaceService *services.WorkspaceService
+}
+
+// newLocalClient 以指定用户或API密钥的身份创建本地客户端
+// workspace为空时使用用户加入的第一个工作区
+func newLocalClient(username, apiKey, workspace string) (*localClient, error) {
+	var p *services.Principal
+	var err error
+	if apiKey != "" {
+		p, err = services.NewAPIKeyService().Authenticate(apiKey, "smtpctl")
+	} else {
+		p, err = services.NewAuthService().PrincipalForUser(username)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c := &localClient{
+		p:                p,
+		smtpService:      services.NewSMTPService(),
+		templateService:  services.NewTemplateService(),
+		historyService:   services.NewHistoryService(),
+		emailService:     services.NewEmailService(),
+		backupService:    services.NewBackupService(),
+		workspaceService: services.NewWorkspaceService(),
+	}
+	if err := c.workspaceService.Resolve(p, workspace); err != nil {
+		return nil, fmt.Errorf("切换工作区失败: %w", err)
+	}
+	p.ClientIP = "smtpctl"
+	return c, nil
+}
+
+func (c *localClient) ListConfigs() ([]models.SMTPConfig, error) {
+	if err := c.p.Authorize(services.PermSMTPRead); err != nil {
+		return nil, err
+	}
+	return c.smtpService.GetAllConfigs(c.p)
+}
+
+func (c *localClient) GetConfig(id uint) (*models.SMTPConfig, error) {
+	if err := c.p.Authorize(services.PermSMTPRead); err != nil {
+		return nil, err
+	}
+	return c.smtpService.GetConfigByID(c.p, id)
+}
+
+func (c *localClient) DefaultConfig() (*models.SMTPConfig, error) {
+	if err := c.p.Authorize(services.PermSMTPRead); err != nil {
+		return nil, err
+	}
+	return c.smtpService.GetDefaultConfig(c.p)
+}
+
+func (c *localClient) CreateConfig(config *models.SMTPConfig) (*models.SMTPConfig, error) {
+	if err := c.p.Authorize(services.PermSMTPWrite); err != nil {
+		return nil, err
+	}
+	if err := c.smtpService.CreateConfig(c.p, config); err != nil {
+		return nil, err
+	}
+	return c.smtpService.GetConfigByID(c.p, config.ID)
+}
+
+func (c *localClient) DeleteConfig(id uint) error {
+	if err := c.p.Authorize(services.PermSMTPWrite); err != nil {
+		return err
+	}
+	return c.smtpService.DeleteConfig(c.p, id)
+}
+
+func (c *localClient) SetDefaultConfig(id uint) error {
+	if err := c.p.Authorize(services.PermSMTPSetDefault); err != nil {
+		return err
+	}
+	return c.smtpService.SetDefaultConfig(c.p, id)
+}
+
+func (c *localClient) TestConfig(id uint) error {
+	if err := c.p.Authorize(services.PermSMTPWrite); err != nil {
+		return err
+	}
+	config, err := c.smtpService.GetConfigByIDWithPassword(c.p, id)
+	if err != nil {
+		return err
+	}
+	return c.smtpService.TestConnection(config, "")
+}
+
+func (c *localClient) SendTestEmail(id uint, to string) error {
+	if err := c.p.Authorize(services.PermEmailSend); err != nil {
+		return err
+	}
+	config, err := c.smtpService.GetConfigByIDWithPassword(c.p, id)
+	if err != nil {
+		return err
+	}
+	return c.smtpService.SendTestEmail(c.p, config, to, "")
+}
+
+func (c *localClient) ListTemplates() ([]models.EmailTemplate, error) {
+	if err := c.p.Authorize(services.PermTemplateRead); err != nil {
+		return nil, err
+	}
+	return c.templateService.GetAllTemplates(c.p)
+}
+
+func (c *localClient) GetTemplate(id uint) (*models.EmailTemplate, error) {
+	if err := c.p.Authorize(services.PermTemplateRead); err != nil {
+		return nil, err
+	}
+	return c.templateService.GetTemplateByID(c.p, id)
+}
+
+func (c *localClient) CreateTemplate(template *models.EmailTemplate) (*models.EmailTemplate, error) {
+	if err := c.p.Authorize(services.PermTemplateWrite); err != nil {
+		return nil, err
+	}
+	exists, err := c.templateService.NameExists(c.p, template.Name, 0)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("模板名称已存在")
+	}
+	if err := c.templateService.CreateTemplate(c.p, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func (c *localClient) DeleteTemplate(id uint) error {
+	if err := c.p.Authorize(services.PermTemplateWrite); err != nil {
+		return err
+	}
+	return c.templateService.DeleteTemplate(c.p, id)
+}
+
+func (c *localClient) ListHistory(page, pageSize int, status string) (*services.HistoryListResponse, error) {
+	if err := c.p.Authorize(services.PermHistoryRead); err != nil {
+		return nil, err
+	}
+	return c.historyService.GetAllHistory(c.p, page, pageSize, status)
+}
+
+func (c *localClient) GetHistory(id uint) (*models.EmailHistory, error) {
+	if err := c.p.Authorize(services.PermHistoryRead); err != nil {
+		return nil, err
+	}
+	return c.historyService.GetHistoryByID(c.p, id)
+}
+
+func (c *localClient) DeleteHistory(id uint) error {
+	if err := c.p.Authorize(services.PermHistoryDelete); err != nil {
+		return err
+	}
+	return c.historyService.DeleteHistory(c.p, id)
+}
+
+func (c *localClient) Statistics() (*services.StatisticsResponse, error) {
+	if err := c.p.Authorize(services.PermHistoryRead); err != nil {
+		return nil, err
+	}
+	return c.historyService.GetStatistics(c.p)
+}
+
+func (c *localClient) Send(req *services.SendEmailRequest) (*models.EmailHistory, error) {
+	if err := c.p.Authorize(services.PermEmailSend); err != nil {
+		return nil, err
+	}
+	return c.emailService.SendEmail(c.p, req)
+}
+
+func (c *localClient) ListBackups() ([]services.BackupInfo, error) {
+	return c.backupService.ListBackups(c.p)
+}
+
+func (c *localClient) CreateBackup() (*services.BackupInfo, error) {
+	return c.backupService.CreateBackup(c.p)
+}
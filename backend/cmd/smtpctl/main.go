@@ -0,0 +1,206 @@
+// smtpctl 命令行管理工具
+//
+// 本地模式直接读写配置文件中的数据库（以 --user 指定的用户或 --api-key 的身份操作），
+// 指定 --server 时通过HTTP API操作，需要API密钥。
+//
+//	smtpctl [全局参数] <命令> [子命令] [参数]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/services"
+	"smtp-mail/backend/utils"
+
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm/logger"
+)
+
+const usage = `smtpctl - SMTP Mail 命令行管理工具
+
+用法:
+  smtpctl [全局参数] <命令> [子命令] [参数]
+
+全局参数:
+  -server URL      服务地址（如 http://localhost:7700），为空时直接操作本地数据库  [SMTPCTL_SERVER]
+  -api-key KEY     API密钥，远程模式必填；本地模式下以该密钥的身份操作        [SMTPCTL_API_KEY]
+  -user NAME       本地模式的操作用户，默认为 security.admin_username          [SMTPCTL_USER]
+  -workspace ID    工作区ID，默认为用户加入的第一个工作区                     [SMTPCTL_WORKSPACE]
+  -o table|json    输出格式，默认 table
+  -v               输出详细日志（本地模式）
+
+命令:
+  smtp list                                  SMTP配置列表
+  smtp get <id>                              查看SMTP配置
+  smtp create -name N -host H -port P -from EMAIL [-username U] [-password-stdin]
+              [-from-name N] [-encryption none|tls|starttls] [-default] [-shared]
+  smtp delete <id>                           删除SMTP配置
+  smtp set-default <id>                      设置为默认配置
+  smtp test <id>                             测试连接
+  smtp send-test -to EMAIL <id>              发送测试邮件
+
+  template list                              模板列表
+  template get <id>                          查看模板
+  template create -name N -subject S (-body B | -body-file F) [-shared]
+  template delete <id>                       删除模板
+
+  history list [-page N] [-page-size N] [-status all|success|failed]
+  history get <id>                           查看发送记录
+  history delete <id>                        删除发送记录
+  history stats                              发送统计
+
+  send -to EMAIL[,EMAIL] [-cc ..] [-bcc ..] -subject S [-body B | -body-file F | -eml F]
+       [-config ID] [-template ID] [-attach FILE]... [-text]
+                                             发送邮件；未指定正文时从标准输入读取，
+                                             -eml - 表示从标准输入读取完整邮件
+
+  db backup                                  立即备份数据库
+  db backups                                 备份列表
+  db migrate status|up [版本]|down [步数]     数据库迁移（仅本地模式）
+  db restore <文件>                          从备份恢复，需先停止服务（仅本地模式）
+  db rotate-keys                             使用当前主密钥重新加密已保存的密码和密钥（仅本地模式）
+
+  sendmail [-config ID] [-t] [-i] [-f 发件人] [收件人...]
+                                             兼容sendmail，从标准输入读取完整邮件发送；
+                                             以 sendmail 为名称调用时等同于此命令
+`
+
+// options 全局参数
+type options struct {
+	server    string
+	apiKey    string
+	user      string
+	workspace string
+	output    string
+	verbose   bool
+}
+
+func main() {
+	args := os.Args[1:]
+	// 通过 sendmail 符号链接调用时，全部参数按sendmail参数解析
+	if filepath.Base(os.Args[0]) == "sendmail" {
+		args = append([]string{"sendmail"}, args...)
+	}
+
+	if err := run(args); err != nil {
+		fmt.Fprintf(os.Stderr, "smtpctl: %v\n", err)
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
+		os.Exit(1)
+	}
+}
+
+// run 解析全局参数并执行命令
+func run(args []string) error {
+	opts := options{}
+	fs := flag.NewFlagSet("smtpctl", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	fs.StringVar(&opts.server, "server", os.Getenv("SMTPCTL_SERVER"), "")
+	fs.StringVar(&opts.apiKey, "api-key", os.Getenv("SMTPCTL_API_KEY"), "")
+	fs.StringVar(&opts.user, "user", os.Getenv("SMTPCTL_USER"), "")
+	fs.StringVar(&opts.workspace, "workspace", os.Getenv("SMTPCTL_WORKSPACE"), "")
+	fs.StringVar(&opts.output, "o", outputTable, "")
+	fs.BoolVar(&opts.verbose, "v", false, "")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if opts.output != outputTable && opts.output != outputJSON {
+		return fmt.Errorf("无效的输出格式: %s（可选 table、json）", opts.output)
+	}
+
+	args = fs.Args()
+	if len(args) == 0 || args[0] == "help" {
+		fmt.Print(usage)
+		return nil
+	}
+	out := &printer{format: opts.output}
+
+	// 数据库维护命令不需要操作者身份，只能在本地执行
+	if args[0] == "db" && len(args) > 1 && localOnly[args[1]] {
+		if opts.server != "" {
+			return fmt.Errorf("db %s 仅支持本地模式", args[1])
+		}
+		if err := openLocal(opts.verbose); err != nil {
+			return err
+		}
+		defer database.Close()
+		return runMaintenance(out, args[1], args[2:])
+	}
+
+	var c client
+	if opts.server != "" {
+		remote, err := newRemoteClient(opts.server, opts.apiKey, opts.workspace)
+		if err != nil {
+			return err
+		}
+		c = remote
+	} else {
+		if err := openLocal(opts.verbose); err != nil {
+			return err
+		}
+		defer database.Close()
+		if err := database.Migrate(database.GetDB(), 0); err != nil {
+			return err
+		}
+		if opts.user == "" {
+			opts.user = config.GetConfig().Security.AdminUsername
+		}
+		local, err := newLocalClient(opts.user, opts.apiKey, opts.workspace)
+		if err != nil {
+			return err
+		}
+		c = local
+	}
+
+	switch args[0] {
+	case "smtp":
+		return runSMTP(c, out, args[1:])
+	case "template":
+		return runTemplate(c, out, args[1:])
+	case "history":
+		return runHistory(c, out, args[1:])
+	case "send":
+		return runSend(c, out, args[1:])
+	case "db":
+		return runDB(c, out, args[1:])
+	case "sendmail":
+		return runSendmail(c, args[1:])
+	}
+	return fmt.Errorf("未知的命令: %s（运行 smtpctl help 查看用法）", args[0])
+}
+
+// openLocal 连接本地数据库并加载加密密钥
+// 日志输出到标准错误，避免混入表格或JSON输出；未指定 -v 时只输出警告和错误
+func openLocal(verbose bool) error {
+	utils.InitLogger()
+	utils.Logger.SetOutput(os.Stderr)
+	if !verbose {
+		utils.Logger.SetLevel(logrus.WarnLevel)
+	}
+
+	if err := database.Open(); err != nil {
+		return err
+	}
+	if verbose {
+		database.DB.Logger = logger.New(log.New(os.Stderr, "\r\n", log.LstdFlags), logger.Config{LogLevel: logger.Info})
+	} else {
+		database.DB.Logger = logger.Default.LogMode(logger.Silent)
+	}
+
+	if _, err := services.LoadKeyring(); err != nil {
+		return fmt.Errorf("加载加密密钥失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// 输出格式
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer 按输出格式打印结果：JSON格式输出原始数据，表格格式输出指定的列
+type printer struct {
+	format string
+}
+
+// table 打印结果，data为JSON格式输出的数据，headers和rows为表格格式的列和行
+func (p *printer) table(data interface{}, headers []string, rows [][]string) error {
+	if p.format == outputJSON {
+		return p.json(data)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// json 以缩进的JSON格式打印
+func (p *printer) json(data interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// message 打印操作结果，JSON格式时输出 {"message": ...}
+func (p *printer) message(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if p.format == outputJSON {
+		return p.json(map[string]string{"message": msg})
+	}
+	fmt.Println(msg)
+	return nil
+}
+
+// formatTime 表格中的时间格式
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04:05")
+}
+
+// truncate 截断过长的文本，避免表格过宽
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// yesNo 布尔值的表格显示
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/services"
+)
+
+// remoteClient 通过HTTP API操作，使用API密钥认证
+type remoteClient struct {
+	baseURL   string
+	apiKey    string
+	workspace string
+	http      *http.Client
+}
+
+// newRemoteClient 创建远程客户端，server为服务地址（如 http://localhost:7700）
+func newRemoteClient(server, apiKey, workspace string) (*remoteClient, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("远程模式需要API密钥（--api-key 或环境变量 SMTPCTL_API_KEY）")
+	}
+	return &remoteClient{
+		baseURL:   strings.TrimRight(server, "/") + "/api",
+		apiKey:    apiKey,
+		workspace: workspace,
+		http:      &http.Client{Timeout: 2 * time.Minute},
+	}, nil
+}
+
+// apiResponse 统一响应格式
+type apiResponse struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+	Error   string          `json:"error"`
+}
+
+// do 发送请求并将响应中的data解析到out（out为nil时忽略）
+func (c *remoteClient) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-Key", c.apiKey)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.workspace != "" {
+		req.Header.Set("X-Workspace-ID", c.workspace)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("解析响应失败 (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		if result.Error != "" {
+			return fmt.Errorf("%s: %s (HTTP %d)", result.Message, result.Error, resp.StatusCode)
+		}
+		return fmt.Errorf("%s (HTTP %d)", result.Message, resp.StatusCode)
+	}
+	if out != nil && len(result.Data) > 0 {
+		return json.Unmarshal(result.Data, out)
+	}
+	return nil
+}
+
+// idPath 拼接资源路径
+func idPath(prefix string, id uint, suffix string) string {
+	return prefix + "/" + strconv.FormatUint(uint64(id), 10) + suffix
+}
+
+func (c *remoteClient) ListConfigs() ([]models.SMTPConfig, error) {
+	var configs []models.SMTPConfig
+	if err := c.do(http.MethodGet, "/smtp/configs", nil, &configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
+
+func (c *remoteClient) GetConfig(id uint) (*models.SMTPConfig, error) {
+	var config models.SMTPConfig
+	if err := c.do(http.MethodGet, idPath("/smtp/configs", id, ""), nil, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c *remoteClient) DefaultConfig() (*models.SMTPConfig, error) {
+	var config models.SMTPConfig
+	if err := c.do(http.MethodGet, "/smtp/configs/default", nil, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func (c *remoteClient) CreateConfig(config *models.SMTPConfig) (*models.SMTPConfig, error) {
+	var created models.SMTPConfig
+	if err := c.do(http.MethodPost, "/smtp/configs", config, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *remoteClient) DeleteConfig(id uint) error {
+	return c.do(http.MethodDelete, idPath("/smtp/configs", id, ""), nil, nil)
+}
+
+func (c *remoteClient) SetDefaultConfig(id uint) error {
+	return c.do(http.MethodPost, idPath("/smtp/configs", id, "/default"), nil, nil)
+}
+
+func (c *remoteClient) TestConfig(id uint) error {
+	return c.do(http.MethodPost, idPath("/smtp/configs", id, "/test"), map[string]string{}, nil)
+}
+
+func (c *remoteClient) SendTestEmail(id uint, to string) error {
+	return c.do(http.MethodPost, idPath("/smtp/configs", id, "/send-test"), map[string]string{"to_email": to}, nil)
+}
+
+func (c *remoteClient) ListTemplates() ([]models.EmailTemplate, error) {
+	var templates []models.EmailTemplate
+	if err := c.do(http.MethodGet, "/templates", nil, &templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (c *remoteClient) GetTemplate(id uint) (*models.EmailTemplate, error) {
+	var template models.EmailTemplate
+	if err := c.do(http.MethodGet, idPath("/templates", id, ""), nil, &template); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+func (c *remoteClient) CreateTemplate(template *models.EmailTemplate) (*models.EmailTemplate, error) {
+	var created models.EmailTemplate
+	if err := c.do(http.MethodPost, "/templates", template, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *remoteClient) DeleteTemplate(id uint) error {
+	return c.do(http.MethodDelete, idPath("/templates", id, ""), nil, nil)
+}
+
+func (c *remoteClient) ListHistory(page, pageSize int, status string) (*services.HistoryListResponse, error) {
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	query.Set("pageSize", strconv.Itoa(pageSize))
+	query.Set("status", status)
+	var result services.HistoryListResponse
+	if err := c.do(http.MethodGet, "/history?"+query.Encode(), nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *remoteClient) GetHistory(id uint) (*models.EmailHistory, error) {
+	var history models.EmailHistory
+	if err := c.do(http.MethodGet, idPath("/history", id, ""), nil, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+func (c *remoteClient) DeleteHistory(id uint) error {
+	return c.do(http.MethodDelete, idPath("/history", id, ""), nil, nil)
+}
+
+func (c *remoteClient) Statistics() (*services.StatisticsResponse, error) {
+	var stats services.StatisticsResponse
+	if err := c.do(http.MethodGet, "/history/statistics", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (c *remoteClient) Send(req *services.SendEmailRequest) (*models.EmailHistory, error) {
+	var history models.EmailHistory
+	if err := c.do(http.MethodPost, "/email/send", req, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+func (c *remoteClient) ListBackups() ([]services.BackupInfo, error) {
+	var backups []services.BackupInfo
+	if err := c.do(http.MethodGet, "/backups", nil, &backups); err != nil {
+		return nil, err
+	}
+	return backups, nil
+}
+
+func (c *remoteClient) CreateBackup() (*services.BackupInfo, error) {
+	var backup services.BackupInfo
+	if err := c.do(http.MethodPost, "/backups", nil, &backup); err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"smtp-mail/backend/services"
+)
+
+// sendmail 兼容的退出码（sysexits.h）
+const (
+	exUsage       = 64 // 参数错误
+	exDataErr     = 65 // 邮件格式错误
+	exNoUser      = 67 // 收件人地址无效
+	exUnavailable = 69 // 服务不可用或发送失败
+)
+
+// exitError 带退出码的错误
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// sendmailOptions sendmail命令行参数
+type sendmailOptions struct {
+	headerRecipients bool     // -t 从邮件头读取收件人
+	ignoreDots       bool     // -i / -oi 单独一行的 "." 不表示邮件结束
+	from             string   // -f 发件人，用于选择发件人邮箱相同的SMTP配置
+	configID         uint     // -config 指定SMTP配置
+	recipients       []string // 参数中的收件人
+}
+
+// parseSendmailArgs 解析sendmail风格的参数
+// 支持 -t、-i、-oi、-f <地址>、-F <名称>，其他 -o、-B、-b、-d、-v 等参数被忽略；
+// 第一个非选项参数及之后的参数都是收件人
+func parseSendmailArgs(args []string) (*sendmailOptions, error) {
+	opts := &sendmailOptions{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			opts.recipients = append(opts.recipients, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			opts.recipients = append(opts.recipients, args[i:]...)
+			break
+		}
+
+		// 带值的选项既可以写成 -f addr 也可以写成 -faddr
+		value := func() (string, error) {
+			if len(arg) > 2 {
+				return arg[2:], nil
+			}
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("参数 %s 缺少值", arg)
+			}
+			i++
+			return args[i], nil
+		}
+
+		switch {
+		case arg == "-config":
+			if i+1 >= len(args) {
+				return nil, errors.New("参数 -config 缺少值")
+			}
+			i++
+			id, err := strconv.ParseUint(args[i], 10, 32)
+			if err != nil || id == 0 {
+				return nil, fmt.Errorf("无效的SMTP配置ID: %s", args[i])
+			}
+			opts.configID = uint(id)
+		case arg == "-t":
+			opts.headerRecipients = true
+		case arg == "-i", arg == "-oi":
+			opts.ignoreDots = true
+		case arg == "-ti" || arg == "-it":
+			opts.headerRecipients, opts.ignoreDots = true, true
+		case strings.HasPrefix(arg, "-f"), strings.HasPrefix(arg, "-r"):
+			from, err := value()
+			if err != nil {
+				return nil, err
+			}
+			opts.from = from
+		case strings.HasPrefix(arg, "-F"):
+			// 发件人名称使用SMTP配置中的名称
+			if _, err := value(); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(arg, "-o"), strings.HasPrefix(arg, "-B"), strings.HasPrefix(arg, "-d"),
+			strings.HasPrefix(arg, "-N"), strings.HasPrefix(arg, "-R"), strings.HasPrefix(arg, "-V"),
+			arg == "-bm", arg == "-v", arg == "-U", arg == "-em", arg == "-ep", arg == "-eq":
+			// 投递方式、DSN等选项由SMTP服务器决定，忽略
+		default:
+			return nil, fmt.Errorf("不支持的参数: %s", arg)
+		}
+	}
+	return opts, nil
+}
+
+// readSendmailMessage 从标准输入读取邮件，未指定 -i 时单独一行的 "." 表示邮件结束
+func readSendmailMessage(r io.Reader, ignoreDots bool) ([]byte, error) {
+	if ignoreDots {
+		return io.ReadAll(r)
+	}
+	var buf bytes.Buffer
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if strings.TrimRight(line, "\r\n") == "." {
+			break
+		}
+		buf.WriteString(line)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// runSendmail 兼容sendmail的发送入口，从标准输入读取完整邮件并通过SMTP配置发送
+//
+//	smtpctl sendmail [-config ID] [-t] [-i] [-f 发件人] [收件人...]
+//
+// 以 sendmail 为名称调用（如 ln -s smtpctl /usr/sbin/sendmail）时等同于 smtpctl sendmail。
+// 不带域名的收件人（如 cron 发给 root）会补充环境变量 SMTPCTL_LOCAL_DOMAIN 指定的域名
+func runSendmail(c client, args []string) error {
+	opts, err := parseSendmailArgs(args)
+	if err != nil {
+		return &exitError{exUsage, err}
+	}
+
+	raw, err := readSendmailMessage(os.Stdin, opts.ignoreDots)
+	if err != nil {
+		return &exitError{exDataErr, fmt.Errorf("读取邮件失败: %w", err)}
+	}
+	req, err := services.ParseRawMessage(bytes.NewReader(raw))
+	if err != nil {
+		return &exitError{exDataErr, err}
+	}
+
+	// -t 时收件人为邮件头中的 To/Cc/Bcc 加上参数中的收件人，否则只发送给参数中的收件人
+	if opts.headerRecipients {
+		req.To = append(req.To, opts.recipients...)
+	} else {
+		req.To, req.Cc, req.Bcc = opts.recipients, nil, nil
+	}
+	domain := os.Getenv("SMTPCTL_LOCAL_DOMAIN")
+	for _, list := range [][]string{req.To, req.Cc, req.Bcc} {
+		for i, addr := range list {
+			if !strings.Contains(addr, "@") {
+				if domain == "" {
+					return &exitError{exNoUser, fmt.Errorf("收件人 %s 没有域名，请设置 SMTPCTL_LOCAL_DOMAIN", addr)}
+				}
+				list[i] = addr + "@" + domain
+			}
+		}
+	}
+	if len(req.To)+len(req.Cc)+len(req.Bcc) == 0 {
+		return &exitError{exUsage, errors.New("没有收件人（使用 -t 从邮件头读取或在参数中指定）")}
+	}
+	if len(req.To) == 0 {
+		req.To, req.Cc = req.Cc, nil
+		if len(req.To) == 0 {
+			req.To, req.Bcc = req.Bcc, nil
+		}
+	}
+	if req.Subject == "" {
+		req.Subject = "(无主题)"
+	}
+
+	if req.SmtpConfigID, err = sendmailConfig(c, opts); err != nil {
+		return &exitError{exUnavailable, err}
+	}
+	if _, err := c.Send(req); err != nil {
+		return &exitError{exUnavailable, err}
+	}
+	return nil
+}
+
+// sendmailConfig 选择SMTP配置：-config 指定的配置、发件人邮箱与 -f 相同的配置或默认配置
+func sendmailConfig(c client, opts *sendmailOptions) (uint, error) {
+	if opts.configID != 0 {
+		return opts.configID, nil
+	}
+	if opts.from != "" {
+		configs, err := c.ListConfigs()
+		if err != nil {
+			return 0, err
+		}
+		for _, config := range configs {
+			if strings.EqualFold(config.FromEmail, opts.from) {
+				return config.ID, nil
+			}
+		}
+	}
+	config, err := c.DefaultConfig()
+	if err != nil {
+		return 0, fmt.Errorf("没有默认SMTP配置: %w", err)
+	}
+	return config.ID, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/services"
+)
+
+// runCommand 执行命令行子命令，返回是否为已知的子命令
+// 子命令在连接数据库之后、执行迁移和启动HTTP服务之前执行，执行完成后程序退出
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	var err error
+	switch args[0] {
+	case "migrate":
+		err = migrate(args[1:])
+	case "rotate-keys":
+		err = rotateKeys()
+	case "backup":
+		err = backup()
+	case "restore":
+		err = restore(args[1:])
+	default:
+		return false
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s 执行失败: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// migrate 查看迁移状态、执行或回滚迁移
+//
+//	migrate status        查看每个迁移的状态
+//	migrate up [版本号]    执行迁移到指定版本，默认最新版本
+//	migrate down [步数]    回滚最近执行的迁移，默认1个
+func migrate(args []string) error {
+	db := database.GetDB()
+	action := "status"
+	if len(args) > 0 {
+		action = args[0]
+	}
+	n := 0
+	if len(args) > 1 {
+		var err error
+		if n, err = strconv.Atoi(args[1]); err != nil || n < 0 {
+			return fmt.Errorf("无效的参数: %s", args[1])
+		}
+	}
+
+	switch action {
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, status.State, appliedAt)
+		}
+		return w.Flush()
+	case "up":
+		return database.Migrate(db, n)
+	case "down":
+		if n == 0 {
+			n = 1
+		}
+		return database.Rollback(db, n)
+	}
+	return fmt.Errorf("未知的迁移操作: %s（可选 status、up、down）", action)
+}
+
+// rotateKeys 使用当前主密钥重新加密所有加密保存的密码和密钥
+func rotateKeys() error {
+	if err := database.Migrate(database.GetDB(), 0); err != nil {
+		return err
+	}
+	result, err := services.NewSMTPService().RotateKeys()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("当前密钥: %s，共 %d 个加密保存的密码和密钥，重新加密 %d 个\n", result.ActiveKeyID, result.Total, result.Rotated)
+	return nil
+}
+
+// backup 立即创建数据库备份
+func backup() error {
+	if err := database.Migrate(database.GetDB(), 0); err != nil {
+		return err
+	}
+	info, err := services.NewBackupService().CreateBackup(nil)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("备份完成: %s（%d 字节，结构版本 %d）\n", info.Name, info.Size, info.SchemaVersion)
+	return nil
+}
+
+// restore 从备份文件恢复数据库，需先停止服务
+//
+//	restore <备份文件路径>
+func restore(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("用法: restore <备份文件路径>")
+	}
+	version, err := services.NewBackupService().Restore(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("恢复完成，备份的结构版本为 %d，下次启动时将执行未执行的迁移\n", version)
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -15,17 +16,30 @@ type Config struct {
 	Upload   UploadConfig   `mapstructure:"upload"`
 	Security SecurityConfig `mapstructure:"security"`
 	SMTP     SMTPConfig     `mapstructure:"smtp"`
+	Backup   BackupConfig   `mapstructure:"backup"`
+
+	Submission SubmissionConfig `mapstructure:"submission"`
+	Scanner    ScannerConfig    `mapstructure:"scanner"`
+	Webhook    WebhookConfig    `mapstructure:"webhook"`
+	Bounce     BounceConfig     `mapstructure:"bounce"`
+	Tracking   TrackingConfig   `mapstructure:"tracking"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port int    `mapstructure:"port"`
-	Mode string `mapstructure:"mode"`
+	Port      int    `mapstructure:"port"`
+	Mode      string `mapstructure:"mode"`
+	PublicURL string `mapstructure:"public_url"` // 外部访问地址，如 https://mail.example.com，用于生成退订链接
 }
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
-	Path string `mapstructure:"path"`
+	Driver          string        `mapstructure:"driver"` // sqlite、postgres、mysql
+	DSN             string        `mapstructure:"dsn"`    // PostgreSQL/MySQL连接字符串
+	Path            string        `mapstructure:"path"`   // SQLite数据库文件路径
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"` // 连接最长存活时间，0表示不限制
 }
 
 // UploadConfig 上传配置
@@ -42,13 +56,76 @@ type SecurityConfig struct {
 	CORSEnabled    bool     `mapstructure:"cors_enabled"`
 	CORSOrigins    []string `mapstructure:"cors_origins"`
 	BcryptCost     int      `mapstructure:"bcrypt_cost"`
+	AdminUsername  string   `mapstructure:"admin_username"`   // 首次启动时创建的管理员用户名
+	AdminPassword  string   `mapstructure:"admin_password"`   // 首次启动时创建的管理员密码，为空时随机生成
+	MasterKey      string   `mapstructure:"master_key"`       // SMTP密码加密主密钥，格式为 <密钥ID>:<base64密钥>，多个以逗号分隔
+	MasterKeyFile  string   `mapstructure:"master_key_file"`  // 主密钥文件，每行一个 <密钥ID>:<base64密钥>
+	ActiveKeyID    string   `mapstructure:"active_key_id"`    // 用于加密的密钥ID，为空时使用最后一个密钥
+	AllowLegacyKey bool     `mapstructure:"allow_legacy_key"` // 未配置主密钥时允许使用JWT密钥派生的旧密钥加密（不用于签名）
 }
 
 // SMTPConfig SMTP默认配置
 type SMTPConfig struct {
-	DefaultHost  string `mapstructure:"default_host"`
-	DefaultPort  int    `mapstructure:"default_port"`
-	DefaultUseTLS bool   `mapstructure:"default_use_tls"`
+	DefaultHost    string `mapstructure:"default_host"`
+	DefaultPort    int    `mapstructure:"default_port"`
+	DefaultUseTLS  bool   `mapstructure:"default_use_tls"`
+	Capture        bool   `mapstructure:"capture"`          // 全局捕获模式：所有邮件只保存不投递（用于测试环境）
+	MaxMessageSize int64  `mapstructure:"max_message_size"` // 邮件大小上限（字节），预览时超过则给出警告
+}
+
+// BackupConfig 数据库备份配置
+type BackupConfig struct {
+	Dir      string        `mapstructure:"dir"`      // 备份文件目录
+	Compress bool          `mapstructure:"compress"` // 是否gzip压缩
+	Encrypt  bool          `mapstructure:"encrypt"`  // 是否使用主密钥加密
+	Interval time.Duration `mapstructure:"interval"` // 定时备份间隔，0表示不启用
+	Keep     int           `mapstructure:"keep"`     // 保留的备份数量，0表示不清理
+}
+
+// SubmissionConfig 内置SMTP提交服务配置
+// 应用通过SMTP提交邮件，使用API密钥或用户名密码认证，邮件经上游SMTP配置发送并记录历史
+type SubmissionConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	Addr            string        `mapstructure:"addr"`              // 监听地址
+	Hostname        string        `mapstructure:"hostname"`          // 问候语和EHLO响应中的主机名
+	TLSCert         string        `mapstructure:"tls_cert"`          // STARTTLS证书文件，为空时不支持STARTTLS
+	TLSKey          string        `mapstructure:"tls_key"`           // STARTTLS私钥文件
+	AllowInsecure   bool          `mapstructure:"allow_insecure"`    // 是否允许未加密连接上的认证
+	SMTPConfigID    uint          `mapstructure:"smtp_config_id"`    // 上游SMTP配置，0表示按发件人匹配或使用工作区默认配置
+	MaxMessageBytes int64         `mapstructure:"max_message_bytes"` // 单封邮件最大字节数
+	MaxRecipients   int           `mapstructure:"max_recipients"`    // 单封邮件最多收件人数
+	Timeout         time.Duration `mapstructure:"timeout"`           // 读取命令和数据的超时时间
+}
+
+// ScannerConfig 附件病毒扫描配置
+type ScannerConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	Engine   string        `mapstructure:"engine"`    // 扫描引擎，目前支持 clamav
+	Address  string        `mapstructure:"address"`   // clamd地址，如 unix:/var/run/clamav/clamd.ctl 或 tcp:127.0.0.1:3310
+	Timeout  time.Duration `mapstructure:"timeout"`   // 单个附件的扫描超时时间
+	FailOpen bool          `mapstructure:"fail_open"` // 扫描服务不可用时是否仍然发送
+}
+
+// WebhookConfig 出站Webhook投递配置
+type WebhookConfig struct {
+	MaxAttempts int           `mapstructure:"max_attempts"` // 最多投递次数（包括第一次）
+	Backoff     time.Duration `mapstructure:"backoff"`      // 第一次重试的等待时间，之后每次加倍，最长1小时
+	Timeout     time.Duration `mapstructure:"timeout"`      // 单次请求超时时间
+
+	// AllowPrivateNetworks 允许向回环、内网和链路本地地址投递，默认拒绝，防止借Webhook访问内部服务
+	AllowPrivateNetworks bool `mapstructure:"allow_private_networks"`
+}
+
+// BounceConfig 退信邮箱轮询配置
+type BounceConfig struct {
+	Interval  time.Duration `mapstructure:"interval"`   // 轮询间隔，0表示不自动轮询
+	Timeout   time.Duration `mapstructure:"timeout"`    // 连接和读取邮箱的超时时间
+	BatchSize int           `mapstructure:"batch_size"` // 每次轮询每个邮箱最多处理的邮件数
+}
+
+// TrackingConfig 打开和点击追踪配置
+type TrackingConfig struct {
+	Enabled bool `mapstructure:"enabled"` // 全局开关，关闭后忽略发送请求中的track，也不再记录已发出邮件的打开和点击
 }
 
 var appConfig *Config
@@ -72,11 +149,34 @@ func loadConfig() *Config {
 	// 设置默认值
 	viper.SetDefault("server.port", 7700)
 	viper.SetDefault("server.mode", "debug")
+	viper.SetDefault("database.driver", "sqlite")
 	viper.SetDefault("database.path", "./data/smtp-mail.db")
+	viper.SetDefault("database.max_idle_conns", 10)
+	viper.SetDefault("database.max_open_conns", 100)
 	viper.SetDefault("upload.max_size", 10485760)
 	viper.SetDefault("upload.upload_dir", "./data/uploads")
+	viper.SetDefault("smtp.max_message_size", 26214400)
 	viper.SetDefault("security.jwt_expire_hours", 24)
 	viper.SetDefault("security.cors_enabled", true)
+	viper.SetDefault("security.admin_username", "admin")
+	viper.SetDefault("backup.dir", "./data/backups")
+	viper.SetDefault("backup.compress", true)
+	viper.SetDefault("backup.keep", 7)
+	viper.SetDefault("submission.addr", "127.0.0.1:2525")
+	viper.SetDefault("submission.hostname", "localhost")
+	viper.SetDefault("submission.max_message_bytes", 26214400)
+	viper.SetDefault("submission.max_recipients", 100)
+	viper.SetDefault("submission.timeout", "5m")
+	viper.SetDefault("scanner.engine", "clamav")
+	viper.SetDefault("scanner.address", "tcp:127.0.0.1:3310")
+	viper.SetDefault("scanner.timeout", "30s")
+	viper.SetDefault("webhook.max_attempts", 6)
+	viper.SetDefault("webhook.backoff", "30s")
+	viper.SetDefault("webhook.timeout", "10s")
+	viper.SetDefault("bounce.interval", "5m")
+	viper.SetDefault("bounce.timeout", "60s")
+	viper.SetDefault("bounce.batch_size", 100)
+	viper.SetDefault("tracking.enabled", true)
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
@@ -96,15 +196,47 @@ func loadConfig() *Config {
 		var p int
 		if _, err := fmt.Sscanf(port, "%d", &p); err == nil {
 			config.Server.Port = p
-			log.Printf("环境变量覆盖: SERVER_PORT=%d", port)
+			log.Printf("环境变量覆盖: SERVER_PORT=%d", p)
 		}
 	}
 	if mode := os.Getenv("SERVER_MODE"); mode != "" {
 		config.Server.Mode = mode
 		log.Printf("环境变量覆盖: SERVER_MODE=%s", mode)
 	}
+	if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+		config.Server.PublicURL = publicURL
+		log.Printf("环境变量覆盖: PUBLIC_URL=%s", publicURL)
+	}
+	if driver := os.Getenv("DATABASE_DRIVER"); driver != "" {
+		config.Database.Driver = driver
+		log.Printf("环境变量覆盖: DATABASE_DRIVER=%s", driver)
+	}
+	if dsn := os.Getenv("DATABASE_DSN"); dsn != "" {
+		config.Database.DSN = dsn
+		log.Println("环境变量覆盖: DATABASE_DSN")
+	}
+	if capture := os.Getenv("SMTP_CAPTURE"); capture != "" {
+		config.SMTP.Capture = capture == "true" || capture == "1"
+		log.Printf("环境变量覆盖: SMTP_CAPTURE=%v", config.SMTP.Capture)
+	}
+	if password := os.Getenv("ADMIN_PASSWORD"); password != "" {
+		config.Security.AdminPassword = password
+		log.Println("环境变量覆盖: ADMIN_PASSWORD")
+	}
+	if masterKey := os.Getenv("MASTER_KEY"); masterKey != "" {
+		config.Security.MasterKey = masterKey
+		log.Println("环境变量覆盖: MASTER_KEY")
+	}
+	if keyFile := os.Getenv("MASTER_KEY_FILE"); keyFile != "" {
+		config.Security.MasterKeyFile = keyFile
+		log.Printf("环境变量覆盖: MASTER_KEY_FILE=%s", keyFile)
+	}
+	if allowLegacy := os.Getenv("ALLOW_LEGACY_KEY"); allowLegacy != "" {
+		config.Security.AllowLegacyKey = allowLegacy == "true" || allowLegacy == "1"
+		log.Printf("环境变量覆盖: ALLOW_LEGACY_KEY=%v", config.Security.AllowLegacyKey)
+	}
 
-	fmt.Printf("配置加载成功: 服务器端口=%d, 模式=%s\n", config.Server.Port, config.Server.Mode)
+	log.Printf("配置加载成功: 服务器端口=%d, 模式=%s", config.Server.Port, config.Server.Mode)
 
 	return &config
-}
\ No newline at end of file
+}
@@ -7,8 +7,9 @@ import (
 	"path/filepath"
 
 	"smtp-mail/backend/config"
-	"smtp-mail/backend/models"
 
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -16,14 +17,33 @@ import (
 
 var DB *gorm.DB
 
-// Initialize 初始化数据库连接
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// Initialize 初始化数据库连接并执行未执行的迁移
 func Initialize() error {
+	if err := Open(); err != nil {
+		return err
+	}
+
+	// 数据库结构版本超前于程序时拒绝启动
+	if err := Migrate(DB, 0); err != nil {
+		return fmt.Errorf("数据库迁移失败: %w", err)
+	}
+	return nil
+}
+
+// Open 连接数据库（不执行迁移）
+func Open() error {
 	cfg := config.GetConfig()
-	
-	// 确保数据库目录存在
-	dbDir := filepath.Dir(cfg.Database.Path)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		return fmt.Errorf("创建数据库目录失败: %w", err)
+
+	dialector, err := openDialector(&cfg.Database)
+	if err != nil {
+		return err
 	}
 
 	// 配置GORM日志
@@ -31,8 +51,8 @@ func Initialize() error {
 		Logger: logger.Default.LogMode(logger.Info),
 	}
 
-	// 连接SQLite数据库
-	db, err := gorm.Open(sqlite.Open(cfg.Database.Path), gormConfig)
+	// 连接数据库
+	db, err := gorm.Open(dialector, gormConfig)
 	if err != nil {
 		return fmt.Errorf("连接数据库失败: %w", err)
 	}
@@ -44,27 +64,43 @@ func Initialize() error {
 	}
 
 	// 配置连接池
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
-
-	// 自动迁移所有模型
-	if err := autoMigrate(db); err != nil {
-		return fmt.Errorf("数据库迁移失败: %w", err)
-	}
+	sqlDB.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
 
 	DB = db
-	log.Printf("数据库初始化成功: %s", cfg.Database.Path)
+	log.Printf("数据库连接成功: driver=%s", db.Dialector.Name())
 
 	return nil
 }
 
-// autoMigrate 自动迁移所有模型
-func autoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&models.SMTPConfig{},
-		&models.EmailTemplate{},
-		&models.EmailHistory{},
-	)
+// openDialector 根据配置选择数据库驱动
+// SQLite使用path（dsn不为空时优先使用dsn），PostgreSQL和MySQL使用dsn
+func openDialector(cfg *config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case "", DriverSQLite:
+		dsn := cfg.DSN
+		if dsn == "" {
+			// 确保数据库目录存在
+			dbDir := filepath.Dir(cfg.Path)
+			if err := os.MkdirAll(dbDir, 0755); err != nil {
+				return nil, fmt.Errorf("创建数据库目录失败: %w", err)
+			}
+			dsn = cfg.Path
+		}
+		return sqlite.Open(dsn), nil
+	case DriverPostgres:
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("使用 %s 驱动时必须配置 database.dsn", cfg.Driver)
+		}
+		return postgres.Open(cfg.DSN), nil
+	case DriverMySQL:
+		if cfg.DSN == "" {
+			return nil, fmt.Errorf("使用 %s 驱动时必须配置 database.dsn", cfg.Driver)
+		}
+		return mysql.Open(cfg.DSN), nil
+	}
+	return nil, fmt.Errorf("不支持的数据库驱动: %s", cfg.Driver)
 }
 
 // GetDB 获取数据库实例
@@ -84,4 +120,4 @@ func Close() error {
 	}
 
 	return sqlDB.Close()
-}
\ No newline at end of file
+}
@@ -0,0 +1,205 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"smtp-mail/backend/database/migrations"
+
+	"gorm.io/gorm"
+)
+
+// 迁移错误定义
+var (
+	ErrSchemaAhead      = errors.New("数据库结构版本高于当前程序，请升级程序后再启动")
+	ErrChecksumMismatch = errors.New("已执行迁移的校验和与当前程序不一致")
+)
+
+// 迁移状态
+const (
+	MigrationApplied  = "applied"  // 已执行
+	MigrationPending  = "pending"  // 未执行
+	MigrationModified = "modified" // 已执行但源文件已修改
+	MigrationUnknown  = "unknown"  // 数据库中有记录但程序中不存在（数据库版本更新）
+)
+
+// SchemaMigration 已执行的迁移记录
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"type:varchar(100);not null" json:"name"`
+	Checksum  string    `gorm:"type:varchar(64);not null" json:"checksum"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// TableName 指定表名
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus 单个迁移的状态
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	State     string     `json:"state"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+// loadApplied 读取已执行的迁移记录（按版本号索引）
+func loadApplied(db *gorm.DB) (map[int]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("创建迁移记录表失败: %w", err)
+	}
+
+	var records []SchemaMigration
+	if err := db.Order("version").Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("读取迁移记录失败: %w", err)
+	}
+	applied := make(map[int]SchemaMigration, len(records))
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// SchemaVersion 返回数据库当前的结构版本（已执行的最大版本号）
+func SchemaVersion(db *gorm.DB) (int, error) {
+	applied, err := loadApplied(db)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// MigrationStatuses 返回全部迁移的状态，包括数据库中存在但程序不认识的版本
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	applied, err := loadApplied(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	known := map[int]bool{}
+	for _, m := range migrations.All() {
+		known[m.Version] = true
+		status := MigrationStatus{Version: m.Version, Name: m.Name, State: MigrationPending}
+		if record, ok := applied[m.Version]; ok {
+			appliedAt := record.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.State = MigrationApplied
+			if record.Checksum != m.Checksum {
+				status.State = MigrationModified
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	for version, record := range applied {
+		if !known[version] {
+			appliedAt := record.AppliedAt
+			statuses = append(statuses, MigrationStatus{Version: version, Name: record.Name, State: MigrationUnknown, AppliedAt: &appliedAt})
+		}
+	}
+	return statuses, nil
+}
+
+// checkApplied 检查数据库是否超前于程序以及已执行迁移的校验和
+func checkApplied(db *gorm.DB) error {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		switch status.State {
+		case MigrationUnknown:
+			return fmt.Errorf("%w: 数据库包含版本 %d (%s)，程序最高支持版本 %d", ErrSchemaAhead, status.Version, status.Name, migrations.Latest())
+		case MigrationModified:
+			return fmt.Errorf("%w: 版本 %d (%s)", ErrChecksumMismatch, status.Version, status.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateSchema 检查数据库结构可以被当前程序使用，返回当前结构版本
+func ValidateSchema(db *gorm.DB) (int, error) {
+	if err := checkApplied(db); err != nil {
+		return 0, err
+	}
+	return SchemaVersion(db)
+}
+
+// Migrate 执行未执行的迁移直到target版本（target<=0表示最新版本）
+// 数据库版本超前或已执行迁移被修改时拒绝执行
+func Migrate(db *gorm.DB, target int) error {
+	if err := checkApplied(db); err != nil {
+		return err
+	}
+	applied, err := loadApplied(db)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations.All() {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{
+				Version:   m.Version,
+				Name:      m.Name,
+				Checksum:  m.Checksum,
+				AppliedAt: time.Now(),
+			}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("执行迁移 %d (%s) 失败: %w", m.Version, m.Name, err)
+		}
+		log.Printf("已执行迁移: %d %s", m.Version, m.Name)
+	}
+	return nil
+}
+
+// Rollback 按版本号倒序回滚最近执行的steps个迁移
+func Rollback(db *gorm.DB, steps int) error {
+	if err := checkApplied(db); err != nil {
+		return err
+	}
+	applied, err := loadApplied(db)
+	if err != nil {
+		return err
+	}
+
+	all := migrations.All()
+	for i := len(all) - 1; i >= 0 && steps > 0; i-- {
+		m := all[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return fmt.Errorf("回滚迁移 %d (%s) 失败: %w", m.Version, m.Name, err)
+		}
+		log.Printf("已回滚迁移: %d %s", m.Version, m.Name)
+		steps--
+	}
+	return nil
+}
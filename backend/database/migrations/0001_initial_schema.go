@@ -0,0 +1,189 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0001 初始表结构
+// 对已有数据库（由AutoMigrate创建）执行时只补充缺少的列和索引，因此可以直接作为升级起点
+// 这里使用冻结的结构体定义，之后修改models不会影响本迁移
+
+type userV1 struct {
+	ID           uint   `gorm:"primaryKey"`
+	Username     string `gorm:"type:varchar(100);not null;uniqueIndex"`
+	PasswordHash string `gorm:"type:varchar(255);not null"`
+	Role         string `gorm:"type:varchar(20);not null;default:'viewer'"`
+	Disabled     bool   `gorm:"default:false"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (userV1) TableName() string { return "users" }
+
+type workspaceV1 struct {
+	ID           uint   `gorm:"primaryKey"`
+	Name         string `gorm:"type:varchar(100);not null"`
+	Slug         string `gorm:"type:varchar(100);not null;uniqueIndex"`
+	DailyQuota   int    `gorm:"default:0"`
+	MonthlyQuota int    `gorm:"default:0"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (workspaceV1) TableName() string { return "workspaces" }
+
+type workspaceMemberV1 struct {
+	ID          uint    `gorm:"primaryKey"`
+	WorkspaceID uint    `gorm:"not null;uniqueIndex:idx_workspace_member"`
+	UserID      uint    `gorm:"not null;uniqueIndex:idx_workspace_member;index"`
+	User        *userV1 `gorm:"foreignKey:UserID"`
+	Role        string  `gorm:"type:varchar(20);not null;default:'viewer'"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (workspaceMemberV1) TableName() string { return "workspace_members" }
+
+type workspaceInvitationV1 struct {
+	ID          uint   `gorm:"primaryKey"`
+	WorkspaceID uint   `gorm:"not null;index"`
+	Email       string `gorm:"type:varchar(255)"`
+	Role        string `gorm:"type:varchar(20);not null;default:'viewer'"`
+	TokenHash   string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	InvitedBy   uint
+	ExpiresAt   time.Time
+	AcceptedBy  *uint
+	AcceptedAt  *time.Time
+	CreatedAt   time.Time
+}
+
+func (workspaceInvitationV1) TableName() string { return "workspace_invitations" }
+
+type apiKeyV1 struct {
+	ID            uint   `gorm:"primaryKey"`
+	WorkspaceID   uint   `gorm:"not null;default:0;index"`
+	Name          string `gorm:"type:varchar(100);not null"`
+	Prefix        string `gorm:"type:varchar(32);not null;uniqueIndex"`
+	SecretHash    string `gorm:"type:varchar(64);not null"`
+	UserID        uint   `gorm:"not null;index"`
+	Scopes        string `gorm:"type:text"`
+	SMTPConfigIDs string `gorm:"type:text"`
+	ExpiresAt     *time.Time
+	LastUsedAt    *time.Time
+	LastUsedIP    string `gorm:"type:varchar(64)"`
+	RevokedAt     *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (apiKeyV1) TableName() string { return "api_keys" }
+
+type smtpConfigV1 struct {
+	ID          uint   `gorm:"primaryKey"`
+	WorkspaceID uint   `gorm:"not null;default:0;index"`
+	Name        string `gorm:"type:varchar(100);not null"`
+	Host        string `gorm:"type:varchar(255);not null"`
+	Port        int    `gorm:"not null"`
+	Username    string `gorm:"type:varchar(255)"`
+	Password    string `gorm:"type:varchar(255)"`
+	FromEmail   string `gorm:"type:varchar(255);not null"`
+	FromName    string `gorm:"type:varchar(100)"`
+	Encryption  string `gorm:"type:varchar(20);default:'none'"`
+	IsDefault   bool   `gorm:"default:false"`
+	OwnerID     uint   `gorm:"index"`
+	Shared      bool   `gorm:"default:false"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (smtpConfigV1) TableName() string { return "smtp_configs" }
+
+type emailTemplateV1 struct {
+	ID          uint   `gorm:"primaryKey"`
+	WorkspaceID uint   `gorm:"not null;default:0;uniqueIndex:idx_template_workspace_name"`
+	Name        string `gorm:"type:varchar(100);not null;uniqueIndex:idx_template_workspace_name"`
+	Subject     string `gorm:"type:varchar(255);not null"`
+	Body        string `gorm:"type:text;not null"`
+	OwnerID     uint   `gorm:"index"`
+	Shared      bool   `gorm:"default:false"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (emailTemplateV1) TableName() string { return "email_templates" }
+
+type emailHistoryV1 struct {
+	ID           uint         `gorm:"primaryKey"`
+	WorkspaceID  uint         `gorm:"not null;default:0;index"`
+	SmtpConfigID uint         `gorm:"not null;index"`
+	SmtpConfig   smtpConfigV1 `gorm:"foreignKey:SmtpConfigID"`
+	UserID       uint         `gorm:"index"`
+	APIKeyID     *uint        `gorm:"index"`
+	ToEmail      string       `gorm:"type:varchar(255);not null"`
+	CcEmail      string       `gorm:"type:text"`
+	BccEmail     string       `gorm:"type:text"`
+	Subject      string       `gorm:"type:varchar(255);not null"`
+	Body         string       `gorm:"type:text;not null"`
+	Attachments  string       `gorm:"type:text"`
+	Status       string       `gorm:"type:varchar(20);not null;default:'failed'"`
+	ErrorMessage string       `gorm:"type:text"`
+	SentAt       time.Time
+	CreatedAt    time.Time
+}
+
+func (emailHistoryV1) TableName() string { return "email_histories" }
+
+type auditEventV1 struct {
+	ID          uint      `gorm:"primaryKey"`
+	WorkspaceID uint      `gorm:"index"`
+	ActorID     uint      `gorm:"index"`
+	ActorName   string    `gorm:"type:varchar(100)"`
+	APIKeyID    *uint     `gorm:"index"`
+	Action      string    `gorm:"type:varchar(50);not null;index"`
+	EntityType  string    `gorm:"type:varchar(50);not null;index:idx_audit_entity"`
+	EntityID    uint      `gorm:"index:idx_audit_entity"`
+	Changes     string    `gorm:"type:text"`
+	ClientIP    string    `gorm:"type:varchar(64)"`
+	CreatedAt   time.Time `gorm:"index"`
+}
+
+func (auditEventV1) TableName() string { return "audit_events" }
+
+// initialTables 按依赖顺序排列，回滚时逆序删除
+var initialTables = []interface{}{
+	&userV1{},
+	&workspaceV1{},
+	&workspaceMemberV1{},
+	&workspaceInvitationV1{},
+	&apiKeyV1{},
+	&smtpConfigV1{},
+	&emailTemplateV1{},
+	&emailHistoryV1{},
+	&auditEventV1{},
+}
+
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "initial_schema",
+		Up: func(tx *gorm.DB) error {
+			// 模板名称由全局唯一改为工作区内唯一，移除旧的唯一索引
+			if tx.Migrator().HasIndex(&emailTemplateV1{}, "idx_email_templates_name") {
+				if err := tx.Migrator().DropIndex(&emailTemplateV1{}, "idx_email_templates_name"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(initialTables...)
+		},
+		Down: func(tx *gorm.DB) error {
+			for i := len(initialTables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(initialTables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
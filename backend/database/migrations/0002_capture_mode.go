@@ -0,0 +1,52 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0002 捕获模式：SMTP配置增加capture列，新增captured_messages表
+
+type smtpConfigCaptureV2 struct {
+	Capture bool `gorm:"default:false"`
+}
+
+func (smtpConfigCaptureV2) TableName() string { return "smtp_configs" }
+
+type capturedMessageV2 struct {
+	ID           uint   `gorm:"primaryKey"`
+	WorkspaceID  uint   `gorm:"not null;default:0;index"`
+	SmtpConfigID uint   `gorm:"index"`
+	HistoryID    uint   `gorm:"index"`
+	UserID       uint   `gorm:"index"`
+	FromEmail    string `gorm:"type:varchar(255)"`
+	Recipients   string `gorm:"type:text"`
+	Subject      string `gorm:"type:varchar(255)"`
+	Size         int
+	Raw          string    `gorm:"not null"`
+	CreatedAt    time.Time `gorm:"index"`
+}
+
+func (capturedMessageV2) TableName() string { return "captured_messages" }
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "capture_mode",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&smtpConfigCaptureV2{}, "capture") {
+				if err := tx.Migrator().AddColumn(&smtpConfigCaptureV2{}, "Capture"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&capturedMessageV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&capturedMessageV2{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&smtpConfigCaptureV2{}, "capture")
+		},
+	})
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0003 附件上传：新增uploaded_files表
+
+type uploadedFileV3 struct {
+	ID          uint   `gorm:"primaryKey"`
+	WorkspaceID uint   `gorm:"not null;default:0;index"`
+	UserID      uint   `gorm:"index"`
+	Filename    string `gorm:"type:varchar(255);not null"`
+	ContentType string `gorm:"type:varchar(100)"`
+	Size        int64
+	Checksum    string `gorm:"type:varchar(64);index"`
+	Path        string `gorm:"type:varchar(500);not null"`
+	CreatedAt   time.Time
+}
+
+func (uploadedFileV3) TableName() string { return "uploaded_files" }
+
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "uploaded_files",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&uploadedFileV3{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&uploadedFileV3{})
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0004 附件扫描：发送历史增加scan_result列
+
+type emailHistoryScanV4 struct {
+	ScanResult string `gorm:"type:varchar(255)"`
+}
+
+func (emailHistoryScanV4) TableName() string { return "email_histories" }
+
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "scan_result",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&emailHistoryScanV4{}, "scan_result") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&emailHistoryScanV4{}, "ScanResult")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&emailHistoryScanV4{}, "scan_result")
+		},
+	})
+}
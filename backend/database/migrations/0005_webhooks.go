@@ -0,0 +1,55 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0005 出站Webhook：新增webhooks和webhook_deliveries表
+
+type webhookV5 struct {
+	ID          uint   `gorm:"primaryKey"`
+	WorkspaceID uint   `gorm:"not null;default:0;index"`
+	URL         string `gorm:"type:varchar(500);not null"`
+	Events      string `gorm:"type:text"`
+	Secret      string `gorm:"type:text;not null"`
+	Enabled     bool   `gorm:"default:true"`
+	CreatedBy   uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (webhookV5) TableName() string { return "webhooks" }
+
+type webhookDeliveryV5 struct {
+	ID             uint   `gorm:"primaryKey"`
+	WebhookID      uint   `gorm:"not null;index"`
+	WorkspaceID    uint   `gorm:"not null;default:0;index"`
+	Event          string `gorm:"type:varchar(50);not null"`
+	Payload        string `gorm:"type:text;not null"`
+	Status         string `gorm:"type:varchar(20);not null;index"`
+	Attempts       int
+	LastStatusCode int
+	LastError      string     `gorm:"type:text"`
+	LastResponse   string     `gorm:"type:text"`
+	NextAttemptAt  *time.Time `gorm:"index"`
+	DeliveredAt    *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (webhookDeliveryV5) TableName() string { return "webhook_deliveries" }
+
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "webhooks",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&webhookV5{}, &webhookDeliveryV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&webhookDeliveryV5{}, &webhookV5{})
+		},
+	})
+}
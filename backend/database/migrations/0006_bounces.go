@@ -0,0 +1,90 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0006 退信处理：发送历史增加message_id、bounce_type列，新增bounce_mailboxes和bounces表
+
+type emailHistoryBounceV6 struct {
+	MessageID  string `gorm:"type:varchar(255);index"`
+	BounceType string `gorm:"type:varchar(10)"`
+}
+
+func (emailHistoryBounceV6) TableName() string { return "email_histories" }
+
+type bounceMailboxV6 struct {
+	ID           uint   `gorm:"primaryKey"`
+	WorkspaceID  uint   `gorm:"not null;default:0;index"`
+	SmtpConfigID uint   `gorm:"not null;uniqueIndex"`
+	Protocol     string `gorm:"type:varchar(10);not null"`
+	Host         string `gorm:"type:varchar(255);not null"`
+	Port         int    `gorm:"not null"`
+	Username     string `gorm:"type:varchar(255)"`
+	Password     string `gorm:"type:varchar(255)"`
+	TLS          bool   `gorm:"default:true"`
+	Folder       string `gorm:"type:varchar(100)"`
+	VERP         bool   `gorm:"default:false"`
+	Enabled      bool   `gorm:"default:true"`
+	LastPolledAt *time.Time
+	LastError    string `gorm:"type:text"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (bounceMailboxV6) TableName() string { return "bounce_mailboxes" }
+
+type bounceV6 struct {
+	ID           uint      `gorm:"primaryKey"`
+	WorkspaceID  uint      `gorm:"not null;default:0;index"`
+	SmtpConfigID uint      `gorm:"index"`
+	HistoryID    uint      `gorm:"index"`
+	UserID       uint      `gorm:"index"`
+	Recipient    string    `gorm:"type:varchar(255);index"`
+	Type         string    `gorm:"type:varchar(10)"`
+	Status       string    `gorm:"type:varchar(20)"`
+	Action       string    `gorm:"type:varchar(20)"`
+	Diagnostic   string    `gorm:"type:text"`
+	MessageID    string    `gorm:"type:varchar(255)"`
+	CreatedAt    time.Time `gorm:"index"`
+}
+
+func (bounceV6) TableName() string { return "bounces" }
+
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "bounces",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, field := range []string{"MessageID", "BounceType"} {
+				if !m.HasColumn(&emailHistoryBounceV6{}, field) {
+					if err := m.AddColumn(&emailHistoryBounceV6{}, field); err != nil {
+						return err
+					}
+				}
+			}
+			if !m.HasIndex(&emailHistoryBounceV6{}, "MessageID") {
+				if err := m.CreateIndex(&emailHistoryBounceV6{}, "MessageID"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&bounceMailboxV6{}, &bounceV6{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropTable(&bounceV6{}, &bounceMailboxV6{}); err != nil {
+				return err
+			}
+			if err := m.DropIndex(&emailHistoryBounceV6{}, "MessageID"); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&emailHistoryBounceV6{}, "bounce_type"); err != nil {
+				return err
+			}
+			return m.DropColumn(&emailHistoryBounceV6{}, "message_id")
+		},
+	})
+}
@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0007 抑制列表：新增suppressions表，发送历史增加suppressed列
+
+type suppressionV7 struct {
+	ID          uint       `gorm:"primaryKey"`
+	WorkspaceID uint       `gorm:"not null;default:0;uniqueIndex:idx_suppressions_workspace_address"`
+	Address     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_suppressions_workspace_address"`
+	Reason      string     `gorm:"type:text"`
+	Source      string     `gorm:"type:varchar(20);not null;index"`
+	ExpiresAt   *time.Time `gorm:"index"`
+	CreatedBy   uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (suppressionV7) TableName() string { return "suppressions" }
+
+type emailHistorySuppressedV7 struct {
+	Suppressed string `gorm:"type:text"`
+}
+
+func (emailHistorySuppressedV7) TableName() string { return "email_histories" }
+
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "suppressions",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&emailHistorySuppressedV7{}, "Suppressed") {
+				if err := tx.Migrator().AddColumn(&emailHistorySuppressedV7{}, "Suppressed"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&suppressionV7{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&suppressionV7{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&emailHistorySuppressedV7{}, "suppressed")
+		},
+	})
+}
@@ -0,0 +1,63 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0008 打开和点击追踪：发送历史增加tracked列，新增email_recipients和tracking_events表
+
+type emailHistoryTrackedV8 struct {
+	Tracked bool `gorm:"default:false"`
+}
+
+func (emailHistoryTrackedV8) TableName() string { return "email_histories" }
+
+type emailRecipientV8 struct {
+	ID         uint   `gorm:"primaryKey"`
+	HistoryID  uint   `gorm:"not null;index"`
+	Address    string `gorm:"type:varchar(255);not null"`
+	Kind       string `gorm:"type:varchar(10);not null"`
+	OpenCount  int    `gorm:"not null;default:0"`
+	ClickCount int    `gorm:"not null;default:0"`
+	OpenedAt   *time.Time
+	ClickedAt  *time.Time
+}
+
+func (emailRecipientV8) TableName() string { return "email_recipients" }
+
+type trackingEventV8 struct {
+	ID          uint      `gorm:"primaryKey"`
+	WorkspaceID uint      `gorm:"not null;default:0;index"`
+	HistoryID   uint      `gorm:"not null;index"`
+	RecipientID *uint     `gorm:"index"`
+	Type        string    `gorm:"type:varchar(10);not null;index"`
+	URL         string    `gorm:"type:text"`
+	UserAgent   string    `gorm:"type:varchar(512)"`
+	IP          string    `gorm:"type:varchar(64)"`
+	CreatedAt   time.Time `gorm:"index"`
+}
+
+func (trackingEventV8) TableName() string { return "tracking_events" }
+
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "tracking",
+		Up: func(tx *gorm.DB) error {
+			if !tx.Migrator().HasColumn(&emailHistoryTrackedV8{}, "Tracked") {
+				if err := tx.Migrator().AddColumn(&emailHistoryTrackedV8{}, "Tracked"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&emailRecipientV8{}, &trackingEventV8{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(&trackingEventV8{}, &emailRecipientV8{}); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&emailHistoryTrackedV8{}, "tracked")
+		},
+	})
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0009 联系人：新增contacts、contact_groups和contact_group_members表
+
+type contactV9 struct {
+	ID          uint   `gorm:"primaryKey"`
+	WorkspaceID uint   `gorm:"not null;default:0;uniqueIndex:idx_contacts_workspace_email"`
+	Email       string `gorm:"type:varchar(255);not null;uniqueIndex:idx_contacts_workspace_email"`
+	Name        string `gorm:"type:varchar(255)"`
+	Attributes  string `gorm:"type:text"`
+	Tags        string `gorm:"type:text"`
+	CreatedBy   uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (contactV9) TableName() string { return "contacts" }
+
+type contactGroupV9 struct {
+	ID          uint   `gorm:"primaryKey"`
+	WorkspaceID uint   `gorm:"not null;default:0;uniqueIndex:idx_contact_groups_workspace_name"`
+	Name        string `gorm:"type:varchar(100);not null;uniqueIndex:idx_contact_groups_workspace_name"`
+	Description string `gorm:"type:text"`
+	CreatedBy   uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (contactGroupV9) TableName() string { return "contact_groups" }
+
+type contactGroupMemberV9 struct {
+	GroupID   uint `gorm:"primaryKey"`
+	ContactID uint `gorm:"primaryKey;index"`
+	CreatedAt time.Time
+}
+
+func (contactGroupMemberV9) TableName() string { return "contact_group_members" }
+
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "contacts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&contactV9{}, &contactGroupV9{}, &contactGroupMemberV9{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&contactGroupMemberV9{}, &contactGroupV9{}, &contactV9{})
+		},
+	})
+}
@@ -0,0 +1,84 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0010 营销活动：新增campaigns和campaign_recipients表，发送历史增加campaign_id列
+
+type campaignV10 struct {
+	ID            uint       `gorm:"primaryKey"`
+	WorkspaceID   uint       `gorm:"not null;default:0;index"`
+	Name          string     `gorm:"type:varchar(100);not null"`
+	TemplateID    uint       `gorm:"not null;index"`
+	SmtpConfigID  uint       `gorm:"not null"`
+	GroupID       *uint      `gorm:"index"`
+	Bulk          bool       `gorm:"default:false"`
+	Track         bool       `gorm:"default:false"`
+	RatePerMinute int        `gorm:"not null;default:0"`
+	Status        string     `gorm:"type:varchar(20);not null;default:'draft';index"`
+	ScheduledAt   *time.Time `gorm:"index"`
+	Subject       string     `gorm:"type:varchar(255)"`
+	Body          string     `gorm:"type:text"`
+	LastError     string     `gorm:"type:text"`
+	NextSendAt    *time.Time
+	StartedAt     *time.Time
+	CompletedAt   *time.Time
+	CreatedBy     uint
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+func (campaignV10) TableName() string { return "campaigns" }
+
+type campaignRecipientV10 struct {
+	ID         uint   `gorm:"primaryKey"`
+	CampaignID uint   `gorm:"not null;uniqueIndex:idx_campaign_recipients_email;index:idx_campaign_recipients_status"`
+	Email      string `gorm:"type:varchar(255);not null;uniqueIndex:idx_campaign_recipients_email"`
+	Variables  string `gorm:"type:text"`
+	Source     string `gorm:"type:varchar(10);not null"`
+	Status     string `gorm:"type:varchar(20);not null;default:'pending';index:idx_campaign_recipients_status"`
+	HistoryID  *uint  `gorm:"index"`
+	Error      string `gorm:"type:text"`
+	SentAt     *time.Time
+}
+
+func (campaignRecipientV10) TableName() string { return "campaign_recipients" }
+
+type emailHistoryCampaignV10 struct {
+	CampaignID *uint `gorm:"index"`
+}
+
+func (emailHistoryCampaignV10) TableName() string { return "email_histories" }
+
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "campaigns",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&campaignV10{}, &campaignRecipientV10{}); err != nil {
+				return err
+			}
+			if !tx.Migrator().HasColumn(&emailHistoryCampaignV10{}, "CampaignID") {
+				if err := tx.Migrator().AddColumn(&emailHistoryCampaignV10{}, "CampaignID"); err != nil {
+					return err
+				}
+			}
+			if !tx.Migrator().HasIndex(&emailHistoryCampaignV10{}, "CampaignID") {
+				return tx.Migrator().CreateIndex(&emailHistoryCampaignV10{}, "CampaignID")
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropIndex(&emailHistoryCampaignV10{}, "CampaignID"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().DropColumn(&emailHistoryCampaignV10{}, "campaign_id"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(&campaignRecipientV10{}, &campaignV10{})
+		},
+	})
+}
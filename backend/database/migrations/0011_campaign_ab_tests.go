@@ -0,0 +1,88 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0011 营销活动A/B测试：新增campaign_variants表，活动增加测试设置和获胜版本，收件人增加分配的版本
+
+type campaignABTestV11 struct {
+	TestPercent       int    `gorm:"not null;default:0"`
+	WinnerMetric      string `gorm:"type:varchar(10)"`
+	WinnerWaitMinutes int    `gorm:"not null;default:0"`
+	WinnerPickAt      *time.Time
+	WinnerVariantID   *uint
+}
+
+func (campaignABTestV11) TableName() string { return "campaigns" }
+
+type campaignRecipientVariantV11 struct {
+	VariantID *uint `gorm:"index"`
+}
+
+func (campaignRecipientVariantV11) TableName() string { return "campaign_recipients" }
+
+type campaignVariantV11 struct {
+	ID         uint   `gorm:"primaryKey"`
+	CampaignID uint   `gorm:"not null;index"`
+	Name       string `gorm:"type:varchar(50);not null"`
+	TemplateID *uint
+	Subject    string `gorm:"type:varchar(255)"`
+	Body       string `gorm:"type:text"`
+	Sent       int64  `gorm:"not null;default:0"`
+	Opened     int64  `gorm:"not null;default:0"`
+	Clicked    int64  `gorm:"not null;default:0"`
+}
+
+func (campaignVariantV11) TableName() string { return "campaign_variants" }
+
+// campaignABTestColumnsV11 活动表新增的列
+var campaignABTestColumnsV11 = []string{"TestPercent", "WinnerMetric", "WinnerWaitMinutes", "WinnerPickAt", "WinnerVariantID"}
+
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "campaign_ab_tests",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			for _, column := range campaignABTestColumnsV11 {
+				if !m.HasColumn(&campaignABTestV11{}, column) {
+					if err := m.AddColumn(&campaignABTestV11{}, column); err != nil {
+						return err
+					}
+				}
+			}
+			if !m.HasColumn(&campaignRecipientVariantV11{}, "VariantID") {
+				if err := m.AddColumn(&campaignRecipientVariantV11{}, "VariantID"); err != nil {
+					return err
+				}
+			}
+			if !m.HasIndex(&campaignRecipientVariantV11{}, "VariantID") {
+				if err := m.CreateIndex(&campaignRecipientVariantV11{}, "VariantID"); err != nil {
+					return err
+				}
+			}
+			return tx.AutoMigrate(&campaignVariantV11{})
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropTable(&campaignVariantV11{}); err != nil {
+				return err
+			}
+			if err := m.DropIndex(&campaignRecipientVariantV11{}, "VariantID"); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&campaignRecipientVariantV11{}, "VariantID"); err != nil {
+				return err
+			}
+			for _, column := range campaignABTestColumnsV11 {
+				if err := m.DropColumn(&campaignABTestV11{}, column); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	})
+}
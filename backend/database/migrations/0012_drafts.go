@@ -0,0 +1,44 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0012 邮件草稿：新增drafts表
+
+type draftV12 struct {
+	ID                 uint `gorm:"primaryKey"`
+	WorkspaceID        uint `gorm:"not null;default:0;index:idx_drafts_owner"`
+	UserID             uint `gorm:"not null;default:0;index:idx_drafts_owner"`
+	SmtpConfigID       uint
+	To                 string `gorm:"type:text"`
+	Cc                 string `gorm:"type:text"`
+	Bcc                string `gorm:"type:text"`
+	Subject            string `gorm:"type:varchar(255)"`
+	Body               string `gorm:"type:text"`
+	Attachments        string `gorm:"type:text"`
+	IgnoreSuppressions bool   `gorm:"default:false"`
+	Bulk               bool   `gorm:"default:false"`
+	Track              bool   `gorm:"default:false"`
+	GroupIDs           string `gorm:"type:text"`
+	Version            int    `gorm:"not null;default:1"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+func (draftV12) TableName() string { return "drafts" }
+
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "drafts",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&draftV12{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&draftV12{})
+		},
+	})
+}
@@ -0,0 +1,114 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 0013 模板版本：新增template_versions表，模板增加当前发布的版本号，
+// 发送历史、营销活动、A/B测试版本和草稿记录使用的模板版本；已有模板的内容作为版本1
+
+type templateVersionV13 struct {
+	ID         uint   `gorm:"primaryKey"`
+	TemplateID uint   `gorm:"not null;uniqueIndex:idx_template_versions_version"`
+	Version    int    `gorm:"not null;uniqueIndex:idx_template_versions_version"`
+	Subject    string `gorm:"type:varchar(255);not null"`
+	Body       string `gorm:"type:text;not null"`
+	AuthorID   uint
+	CreatedAt  time.Time
+}
+
+func (templateVersionV13) TableName() string { return "template_versions" }
+
+type emailTemplateVersionV13 struct {
+	Version int `gorm:"not null;default:0"`
+}
+
+func (emailTemplateVersionV13) TableName() string { return "email_templates" }
+
+type emailHistoryTemplateV13 struct {
+	TemplateID      *uint `gorm:"index"`
+	TemplateVersion int   `gorm:"not null;default:0"`
+}
+
+func (emailHistoryTemplateV13) TableName() string { return "email_histories" }
+
+type campaignTemplateVersionV13 struct {
+	TemplateVersion int `gorm:"not null;default:0"`
+}
+
+func (campaignTemplateVersionV13) TableName() string { return "campaigns" }
+
+type campaignVariantTemplateVersionV13 struct {
+	TemplateVersion int `gorm:"not null;default:0"`
+}
+
+func (campaignVariantTemplateVersionV13) TableName() string { return "campaign_variants" }
+
+type draftTemplateV13 struct {
+	TemplateID      *uint
+	TemplateVersion int `gorm:"not null;default:0"`
+}
+
+func (draftTemplateV13) TableName() string { return "drafts" }
+
+// templateColumnsV13 各表新增的列
+var templateColumnsV13 = []struct {
+	model   interface{}
+	columns []string
+}{
+	{&emailTemplateVersionV13{}, []string{"Version"}},
+	{&emailHistoryTemplateV13{}, []string{"TemplateID", "TemplateVersion"}},
+	{&campaignTemplateVersionV13{}, []string{"TemplateVersion"}},
+	{&campaignVariantTemplateVersionV13{}, []string{"TemplateVersion"}},
+	{&draftTemplateV13{}, []string{"TemplateID", "TemplateVersion"}},
+}
+
+func init() {
+	register(Migration{
+		Version: 13,
+		Name:    "template_versions",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := tx.AutoMigrate(&templateVersionV13{}); err != nil {
+				return err
+			}
+			for _, table := range templateColumnsV13 {
+				for _, column := range table.columns {
+					if !m.HasColumn(table.model, column) {
+						if err := m.AddColumn(table.model, column); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			if !m.HasIndex(&emailHistoryTemplateV13{}, "TemplateID") {
+				if err := m.CreateIndex(&emailHistoryTemplateV13{}, "TemplateID"); err != nil {
+					return err
+				}
+			}
+
+			// 已有模板的当前内容作为版本1，作者为模板所有者
+			if err := tx.Exec("INSERT INTO template_versions (template_id, version, subject, body, author_id, created_at) " +
+				"SELECT id, 1, subject, body, owner_id, updated_at FROM email_templates WHERE version = 0").Error; err != nil {
+				return err
+			}
+			return tx.Exec("UPDATE email_templates SET version = 1 WHERE version = 0").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&emailHistoryTemplateV13{}, "TemplateID"); err != nil {
+				return err
+			}
+			for _, table := range templateColumnsV13 {
+				for _, column := range table.columns {
+					if err := m.DropColumn(table.model, column); err != nil {
+						return err
+					}
+				}
+			}
+			return m.DropTable(&templateVersionV13{})
+		},
+	})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0014 变量替换改为显式开启：草稿记录是否按联系人属性替换变量
+
+type draftPersonalizeV14 struct {
+	Personalize bool `gorm:"default:false"`
+}
+
+func (draftPersonalizeV14) TableName() string { return "drafts" }
+
+func init() {
+	register(Migration{
+		Version: 14,
+		Name:    "draft_personalize",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&draftPersonalizeV14{}, "personalize") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&draftPersonalizeV14{}, "Personalize")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&draftPersonalizeV14{}, "personalize")
+		},
+	})
+}
@@ -0,0 +1,44 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0015 模板软删除：删除模板时只记录删除时间，保留模板的全部版本
+
+type emailTemplateDeletedAtV15 struct {
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (emailTemplateDeletedAtV15) TableName() string { return "email_templates" }
+
+func init() {
+	register(Migration{
+		Version: 15,
+		Name:    "template_soft_delete",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if !m.HasColumn(&emailTemplateDeletedAtV15{}, "DeletedAt") {
+				if err := m.AddColumn(&emailTemplateDeletedAtV15{}, "DeletedAt"); err != nil {
+					return err
+				}
+			}
+			if !m.HasIndex(&emailTemplateDeletedAtV15{}, "DeletedAt") {
+				return m.CreateIndex(&emailTemplateDeletedAtV15{}, "DeletedAt")
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&emailTemplateDeletedAtV15{}, "DeletedAt"); err != nil {
+				return err
+			}
+			// 回滚前清除已删除的模板，它们的版本一并删除
+			if err := tx.Exec("DELETE FROM template_versions WHERE template_id IN (SELECT id FROM email_templates WHERE deleted_at IS NOT NULL)").Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM email_templates WHERE deleted_at IS NOT NULL").Error; err != nil {
+				return err
+			}
+			return m.DropColumn(&emailTemplateDeletedAtV15{}, "deleted_at")
+		},
+	})
+}
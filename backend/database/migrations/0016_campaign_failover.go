@@ -0,0 +1,27 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0016 营销活动备用SMTP配置：按顺序保存的配置ID列表（JSON）
+
+type campaignFailoverV16 struct {
+	FallbackSmtpConfigIDs string `gorm:"type:text"`
+}
+
+func (campaignFailoverV16) TableName() string { return "campaigns" }
+
+func init() {
+	register(Migration{
+		Version: 16,
+		Name:    "campaign_failover",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&campaignFailoverV16{}, "fallback_smtp_config_ids") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&campaignFailoverV16{}, "FallbackSmtpConfigIDs")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&campaignFailoverV16{}, "fallback_smtp_config_ids")
+		},
+	})
+}
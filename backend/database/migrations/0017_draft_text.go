@@ -0,0 +1,27 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// 0017 草稿保存纯文本正文，与发送邮件请求的text字段相同
+
+type draftTextV17 struct {
+	Text string `gorm:"type:text"`
+}
+
+func (draftTextV17) TableName() string { return "drafts" }
+
+func init() {
+	register(Migration{
+		Version: 17,
+		Name:    "draft_text",
+		Up: func(tx *gorm.DB) error {
+			if tx.Migrator().HasColumn(&draftTextV17{}, "text") {
+				return nil
+			}
+			return tx.Migrator().AddColumn(&draftTextV17{}, "Text")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&draftTextV17{}, "text")
+		},
+	})
+}
@@ -0,0 +1,68 @@
+// Package migrations 定义按版本顺序执行的数据库迁移
+// 每个迁移放在单独的文件中（文件名以版本号开头），在init中调用register注册
+// 迁移的校验和为定义它的源文件内容的SHA-256，已执行的迁移不应再修改
+package migrations
+
+import (
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+//go:embed [0-9]*.go
+var sources embed.FS
+
+// Migration 单个数据库迁移
+type Migration struct {
+	Version  int                     // 版本号，严格递增
+	Name     string                  // 简短描述
+	Up       func(tx *gorm.DB) error // 升级
+	Down     func(tx *gorm.DB) error // 回滚
+	Checksum string                  // 源文件校验和，注册时自动计算
+}
+
+var registry []Migration
+
+// register 注册迁移，由各迁移文件的init调用
+func register(m Migration) {
+	_, file, _, ok := runtime.Caller(1)
+	if !ok {
+		panic(fmt.Sprintf("迁移 %d 无法确定源文件", m.Version))
+	}
+	source, err := sources.ReadFile(filepath.Base(file))
+	if err != nil {
+		panic(fmt.Sprintf("迁移 %d 读取源文件失败: %v", m.Version, err))
+	}
+	sum := sha256.Sum256(source)
+	m.Checksum = hex.EncodeToString(sum[:])
+
+	for _, existing := range registry {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf("迁移版本重复: %d", m.Version))
+		}
+	}
+	registry = append(registry, m)
+}
+
+// All 按版本号升序返回全部迁移
+func All() []Migration {
+	all := make([]Migration, len(registry))
+	copy(all, registry)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+	return all
+}
+
+// Latest 返回当前程序支持的最新版本号
+func Latest() int {
+	all := All()
+	if len(all) == 0 {
+		return 0
+	}
+	return all[len(all)-1].Version
+}
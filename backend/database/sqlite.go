@@ -0,0 +1,118 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"smtp-mail/backend/config"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// ErrDatabaseInUse SQLite数据库正在被其他进程使用
+var ErrDatabaseInUse = errors.New("数据库正在被使用，请先停止服务")
+
+// SQLiteFile 获取SQLite数据库文件路径，与openDialector一致：dsn不为空时优先使用dsn（去掉file:前缀和查询参数）
+func SQLiteFile(cfg *config.DatabaseConfig) (string, error) {
+	path := cfg.Path
+	if cfg.DSN != "" {
+		path = strings.TrimPrefix(cfg.DSN, "file:")
+		if i := strings.Index(path, "?"); i >= 0 {
+			if strings.Contains(path[i:], "mode=memory") {
+				path = ":memory:"
+			}
+			path = path[:i]
+		}
+	}
+	if path == "" || path == ":memory:" {
+		return "", errors.New("内存数据库没有数据库文件")
+	}
+	return path, nil
+}
+
+// LockServer 服务运行期间在 <数据库文件>.lock 中记录进程ID，返回释放函数
+// 用于阻止服务运行时从命令行恢复数据库；非SQLite数据库不需要
+func LockServer() (func(), error) {
+	cfg := &config.GetConfig().Database
+	if cfg.Driver != "" && cfg.Driver != DriverSQLite {
+		return func() {}, nil
+	}
+	path, err := SQLiteFile(cfg)
+	if err != nil {
+		return func() {}, nil
+	}
+
+	lock := path + ".lock"
+	if pid, ok := lockOwner(lock); ok && pid != os.Getpid() {
+		return nil, fmt.Errorf("%w（进程 %d，锁文件 %s）", ErrDatabaseInUse, pid, lock)
+	}
+	if err := os.WriteFile(lock, []byte(strconv.Itoa(os.Getpid())), 0600); err != nil {
+		return nil, fmt.Errorf("创建锁文件失败: %w", err)
+	}
+	return func() { os.Remove(lock) }, nil
+}
+
+// CheckNotInUse 检查SQLite数据库文件没有被其他进程使用，调用前需关闭本进程的连接
+// 依次检查服务锁文件、能否获得排他锁，以及关闭连接后是否仍残留 -wal/-shm 文件（WAL模式下其他连接仍打开）
+func CheckNotInUse(path string) error {
+	if pid, ok := lockOwner(path + ".lock"); ok {
+		return fmt.Errorf("%w（进程 %d）", ErrDatabaseInUse, pid)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil
+	}
+
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return fmt.Errorf("打开数据库失败: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.Exec("BEGIN EXCLUSIVE").Error; err != nil {
+		sqlDB.Close()
+		return fmt.Errorf("%w: %v", ErrDatabaseInUse, err)
+	}
+	db.Exec("ROLLBACK")
+	if err := sqlDB.Close(); err != nil {
+		return err
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if _, err := os.Stat(path + suffix); err == nil {
+			return fmt.Errorf("%w：存在 %s，请确认所有使用该数据库的进程都已退出", ErrDatabaseInUse, path+suffix)
+		}
+	}
+	return nil
+}
+
+// lockOwner 读取锁文件中的进程ID，进程仍在运行时返回true；进程已退出的锁文件视为无效
+func lockOwner(lock string) (int, bool) {
+	data, err := os.ReadFile(lock)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return 0, false
+	}
+	// Windows上FindProcess在进程不存在时返回错误，不支持信号0
+	if runtime.GOOS == "windows" {
+		return pid, true
+	}
+	err = proc.Signal(syscall.Signal(0))
+	return pid, err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+	"smtp-mail/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHandler API密钥处理器
+type APIKeyHandler struct {
+	apiKeyService *services.APIKeyService
+}
+
+// NewAPIKeyHandler 创建API密钥处理器实例
+func NewAPIKeyHandler() *APIKeyHandler {
+	return &APIKeyHandler{
+		apiKeyService: services.NewAPIKeyService(),
+	}
+}
+
+// ListAPIKeys 获取API密钥列表
+// GET /api/api-keys
+func (h *APIKeyHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeyService.ListAPIKeys(middleware.CurrentPrincipal(c))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取API密钥列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", keys)
+}
+
+// GetAPIKeyByID 获取单个API密钥
+// GET /api/api-keys/:id
+func (h *APIKeyHandler) GetAPIKeyByID(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的API密钥ID", err)
+		return
+	}
+
+	key, err := h.apiKeyService.GetAPIKeyByID(middleware.CurrentPrincipal(c), uint(id))
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取API密钥失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", key)
+}
+
+// CreateAPIKey 创建API密钥，完整密钥只在响应中返回一次
+// POST /api/api-keys
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	var req services.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	result, err := h.apiKeyService.CreateAPIKey(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "创建API密钥失败", err)
+		return
+	}
+
+	utils.Infof("创建API密钥成功: ID=%d, Name=%s", result.APIKey.ID, result.APIKey.Name)
+	successResponse(c, http.StatusCreated, "创建成功，请妥善保存密钥，之后将无法再次查看", result)
+}
+
+// RevokeAPIKey 吊销API密钥
+// DELETE /api/api-keys/:id
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的API密钥ID", err)
+		return
+	}
+
+	if err := h.apiKeyService.RevokeAPIKey(middleware.CurrentPrincipal(c), uint(id)); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "吊销API密钥失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "吊销成功", nil)
+}
+
+// RegisterRoutes 注册路由
+func (h *APIKeyHandler) RegisterRoutes(router *gin.RouterGroup) {
+	keyGroup := router.Group("/api-keys", middleware.RequirePermission(services.PermAPIKeyManage))
+	{
+		keyGroup.GET("", h.ListAPIKeys)         // 获取API密钥列表
+		keyGroup.POST("", h.CreateAPIKey)       // 创建API密钥
+		keyGroup.GET("/:id", h.GetAPIKeyByID)   // 获取单个API密钥
+		keyGroup.DELETE("/:id", h.RevokeAPIKey) // 吊销API密钥
+	}
+}
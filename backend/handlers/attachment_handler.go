@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttachmentHandler 附件上传处理器
+type AttachmentHandler struct {
+	attachmentService *services.AttachmentService
+}
+
+// NewAttachmentHandler 创建附件上传处理器实例
+func NewAttachmentHandler() *AttachmentHandler {
+	return &AttachmentHandler{
+		attachmentService: services.NewAttachmentService(),
+	}
+}
+
+// UploadAttachment 上传附件（multipart/form-data，文件字段名为file）
+// 请求体以流的方式读取，不在内存中缓存整个文件
+// POST /api/attachments
+func (h *AttachmentHandler) UploadAttachment(c *gin.Context) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求必须是multipart/form-data", err)
+		return
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			errorResponse(c, http.StatusBadRequest, "缺少文件字段file", nil)
+			return
+		}
+		if err != nil {
+			errorResponse(c, http.StatusBadRequest, "读取上传内容失败", err)
+			return
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		file, err := h.attachmentService.Upload(middleware.CurrentPrincipal(c), part.FileName(), part)
+		part.Close()
+		if err != nil {
+			code := statusForError(err, http.StatusBadRequest)
+			switch {
+			case errors.Is(err, services.ErrUploadTooLarge):
+				code = http.StatusRequestEntityTooLarge
+			case errors.Is(err, services.ErrUploadTypeNotAllowed):
+				code = http.StatusUnsupportedMediaType
+			}
+			errorResponse(c, code, "上传附件失败", err)
+			return
+		}
+
+		successResponse(c, http.StatusCreated, "上传成功", file)
+		return
+	}
+}
+
+// ListAttachments 获取已上传的附件
+// GET /api/attachments
+func (h *AttachmentHandler) ListAttachments(c *gin.Context) {
+	files, err := h.attachmentService.ListFiles(middleware.CurrentPrincipal(c))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取附件列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", files)
+}
+
+// GetAttachment 获取附件信息
+// GET /api/attachments/:id
+func (h *AttachmentHandler) GetAttachment(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的附件ID", err)
+		return
+	}
+
+	file, err := h.attachmentService.GetFile(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取附件失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", file)
+}
+
+// DeleteAttachment 删除附件
+// DELETE /api/attachments/:id
+func (h *AttachmentHandler) DeleteAttachment(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的附件ID", err)
+		return
+	}
+
+	if err := h.attachmentService.DeleteFile(middleware.CurrentPrincipal(c), id); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除附件失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// RegisterRoutes 注册路由
+func (h *AttachmentHandler) RegisterRoutes(router *gin.RouterGroup) {
+	attachmentGroup := router.Group("/attachments", middleware.RequirePermission(services.PermEmailSend))
+	{
+		attachmentGroup.POST("", h.UploadAttachment)       // 上传附件
+		attachmentGroup.GET("", h.ListAttachments)         // 获取附件列表
+		attachmentGroup.GET("/:id", h.GetAttachment)       // 获取附件信息
+		attachmentGroup.DELETE("/:id", h.DeleteAttachment) // 删除附件
+	}
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditHandler 审计日志处理器
+type AuditHandler struct {
+	auditService *services.AuditService
+}
+
+// NewAuditHandler 创建审计日志处理器实例
+func NewAuditHandler() *AuditHandler {
+	return &AuditHandler{
+		auditService: services.NewAuditService(),
+	}
+}
+
+// GetAuditEvents 查询审计事件（支持分页和筛选）
+// GET /api/audit?actor_id=1&action=update&entity_type=smtp_config&entity_id=1&from=...&to=...&page=1&pageSize=20
+func (h *AuditHandler) GetAuditEvents(c *gin.Context) {
+	var filter services.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的筛选参数", err)
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if err != nil || pageSize < 1 {
+		pageSize = 20
+	}
+
+	result, err := h.auditService.ListEvents(middleware.CurrentPrincipal(c), &filter, page, pageSize)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取审计日志失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// ExportAuditEvents 导出审计事件
+// GET /api/audit/export?format=csv|json（筛选参数同查询接口）
+func (h *AuditHandler) ExportAuditEvents(c *gin.Context) {
+	var filter services.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的筛选参数", err)
+		return
+	}
+
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		errorResponse(c, http.StatusBadRequest, "无效的导出格式，必须是 csv/json", nil)
+		return
+	}
+
+	events, err := h.auditService.ExportEvents(middleware.CurrentPrincipal(c), &filter)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "导出审计日志失败", err)
+		return
+	}
+
+	filename := fmt.Sprintf("audit-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+
+	if format == "json" {
+		c.Header("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(c.Writer).Encode(events)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"id", "created_at", "actor_id", "actor_name", "api_key_id", "action", "entity_type", "entity_id", "client_ip", "changes"})
+	for _, event := range events {
+		apiKeyID := ""
+		if event.APIKeyID != nil {
+			apiKeyID = strconv.FormatUint(uint64(*event.APIKeyID), 10)
+		}
+		changes, _ := json.Marshal(event.Changes)
+		writer.Write([]string{
+			strconv.FormatUint(uint64(event.ID), 10),
+			event.CreatedAt.Format(time.RFC3339),
+			strconv.FormatUint(uint64(event.ActorID), 10),
+			event.ActorName,
+			apiKeyID,
+			event.Action,
+			event.EntityType,
+			strconv.FormatUint(uint64(event.EntityID), 10),
+			event.ClientIP,
+			string(changes),
+		})
+	}
+	writer.Flush()
+}
+
+// RegisterRoutes 注册路由
+func (h *AuditHandler) RegisterRoutes(router *gin.RouterGroup) {
+	auditGroup := router.Group("/audit", middleware.RequirePermission(services.PermAuditRead))
+	{
+		auditGroup.GET("", h.GetAuditEvents)           // 查询审计日志
+		auditGroup.GET("/export", h.ExportAuditEvents) // 导出审计日志
+	}
+}
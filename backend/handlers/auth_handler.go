@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+	"smtp-mail/backend/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AuthHandler 认证与用户管理处理器
+type AuthHandler struct {
+	authService *services.AuthService
+}
+
+// NewAuthHandler 创建认证处理器实例
+func NewAuthHandler() *AuthHandler {
+	return &AuthHandler{
+		authService: services.NewAuthService(),
+	}
+}
+
+// statusForError 根据服务层错误确定HTTP状态码
+func statusForError(err error, fallback int) int {
+	switch {
+	case errors.Is(err, services.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrAttachmentInfected), errors.Is(err, services.ErrAllRecipientsSuppressed):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, services.ErrScannerUnavailable):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrCampaignState), errors.Is(err, services.ErrDraftConflict):
+		return http.StatusConflict
+	}
+	return fallback
+}
+
+// Login 用户登录
+// POST /api/auth/login
+func (h *AuthHandler) Login(c *gin.Context) {
+	var req services.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	result, err := h.authService.Login(&req)
+	if err != nil {
+		errorResponse(c, http.StatusUnauthorized, "登录失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "登录成功", result)
+}
+
+// Me 获取当前登录用户
+// GET /api/auth/me
+func (h *AuthHandler) Me(c *gin.Context) {
+	successResponse(c, http.StatusOK, "获取成功", middleware.CurrentPrincipal(c))
+}
+
+// ListUsers 获取所有用户
+// GET /api/users
+func (h *AuthHandler) ListUsers(c *gin.Context) {
+	users, err := h.authService.ListUsers()
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取用户列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", users)
+}
+
+// CreateUser 创建用户
+// POST /api/users
+func (h *AuthHandler) CreateUser(c *gin.Context) {
+	var req services.UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	user, err := h.authService.CreateUser(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "创建用户失败", err)
+		return
+	}
+
+	utils.Infof("创建用户成功: ID=%d, Username=%s", user.ID, user.Username)
+	successResponse(c, http.StatusCreated, "创建成功", user)
+}
+
+// UpdateUser 更新用户
+// PUT /api/users/:id
+func (h *AuthHandler) UpdateUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的用户ID", err)
+		return
+	}
+
+	var req services.UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	user, err := h.authService.UpdateUser(middleware.CurrentPrincipal(c), uint(id), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "更新用户失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "更新成功", user)
+}
+
+// DeleteUser 删除用户
+// DELETE /api/users/:id
+func (h *AuthHandler) DeleteUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的用户ID", err)
+		return
+	}
+
+	if err := h.authService.DeleteUser(middleware.CurrentPrincipal(c), uint(id)); err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "删除用户失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// RegisterPublicRoutes 注册无需认证的路由
+func (h *AuthHandler) RegisterPublicRoutes(router *gin.RouterGroup) {
+	router.POST("/auth/login", h.Login) // 登录
+}
+
+// RegisterRoutes 注册路由
+func (h *AuthHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/auth/me", h.Me) // 当前用户
+
+	userGroup := router.Group("/users", middleware.RequirePermission(services.PermUserManage))
+	{
+		userGroup.GET("", h.ListUsers)         // 获取所有用户
+		userGroup.POST("", h.CreateUser)       // 创建用户
+		userGroup.PUT("/:id", h.UpdateUser)    // 更新用户
+		userGroup.DELETE("/:id", h.DeleteUser) // 删除用户
+	}
+}
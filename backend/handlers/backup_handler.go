@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackupHandler 数据库备份处理器
+type BackupHandler struct {
+	backupService *services.BackupService
+}
+
+// NewBackupHandler 创建备份处理器实例
+func NewBackupHandler() *BackupHandler {
+	return &BackupHandler{
+		backupService: services.NewBackupService(),
+	}
+}
+
+// ListBackups 获取备份列表
+// GET /api/backups
+func (h *BackupHandler) ListBackups(c *gin.Context) {
+	backups, err := h.backupService.ListBackups(middleware.CurrentPrincipal(c))
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取备份列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", backups)
+}
+
+// CreateBackup 立即创建数据库备份
+// POST /api/backups
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	backup, err := h.backupService.CreateBackup(middleware.CurrentPrincipal(c))
+	if err != nil {
+		code := statusForError(err, http.StatusInternalServerError)
+		if errors.Is(err, services.ErrBackupUnsupported) {
+			code = http.StatusBadRequest
+		}
+		errorResponse(c, code, "创建备份失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusCreated, "备份成功", backup)
+}
+
+// DownloadBackup 下载备份文件
+// GET /api/backups/:name
+func (h *BackupHandler) DownloadBackup(c *gin.Context) {
+	name := c.Param("name")
+	path, err := h.backupService.BackupPath(middleware.CurrentPrincipal(c), name)
+	if err != nil {
+		code := statusForError(err, http.StatusInternalServerError)
+		if errors.Is(err, services.ErrBackupNotFound) {
+			code = http.StatusNotFound
+		}
+		errorResponse(c, code, "下载备份失败", err)
+		return
+	}
+
+	c.FileAttachment(path, name)
+}
+
+// RegisterRoutes 注册路由
+func (h *BackupHandler) RegisterRoutes(router *gin.RouterGroup) {
+	backupGroup := router.Group("/backups", middleware.RequirePermission(services.PermBackupManage))
+	{
+		backupGroup.GET("", h.ListBackups)          // 获取备份列表
+		backupGroup.POST("", h.CreateBackup)        // 创建备份
+		backupGroup.GET("/:name", h.DownloadBackup) // 下载备份
+	}
+}
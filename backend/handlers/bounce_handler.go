@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BounceHandler 退信处理器
+type BounceHandler struct {
+	bounceService *services.BounceService
+}
+
+// NewBounceHandler 创建退信处理器实例
+func NewBounceHandler() *BounceHandler {
+	return &BounceHandler{
+		bounceService: services.NewBounceService(),
+	}
+}
+
+// GetMailbox 获取SMTP配置的退信邮箱
+// GET /api/smtp/configs/:id/bounce-mailbox
+func (h *BounceHandler) GetMailbox(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的配置ID", err)
+		return
+	}
+
+	mailbox, err := h.bounceService.GetMailbox(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取退信邮箱失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", mailbox)
+}
+
+// SaveMailbox 创建或更新SMTP配置的退信邮箱
+// PUT /api/smtp/configs/:id/bounce-mailbox
+func (h *BounceHandler) SaveMailbox(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的配置ID", err)
+		return
+	}
+
+	var req services.BounceMailboxRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	mailbox, err := h.bounceService.SaveMailbox(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "保存退信邮箱失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "保存成功", mailbox)
+}
+
+// DeleteMailbox 删除SMTP配置的退信邮箱
+// DELETE /api/smtp/configs/:id/bounce-mailbox
+func (h *BounceHandler) DeleteMailbox(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的配置ID", err)
+		return
+	}
+
+	if err := h.bounceService.DeleteMailbox(middleware.CurrentPrincipal(c), id); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除退信邮箱失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// PollMailbox 立即轮询退信邮箱
+// POST /api/smtp/configs/:id/bounce-mailbox/poll
+func (h *BounceHandler) PollMailbox(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的配置ID", err)
+		return
+	}
+
+	result, err := h.bounceService.PollMailbox(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadGateway), "轮询退信邮箱失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "轮询完成", result)
+}
+
+// ListBounces 获取退信记录
+// GET /api/bounces?page=1&pageSize=20&history_id=1&type=hard
+func (h *BounceHandler) ListBounces(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	historyID, _ := strconv.ParseUint(c.Query("history_id"), 10, 32)
+
+	result, err := h.bounceService.ListBounces(middleware.CurrentPrincipal(c), page, pageSize, uint(historyID), c.Query("type"))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取退信列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// RegisterRoutes 注册路由
+func (h *BounceHandler) RegisterRoutes(router *gin.RouterGroup) {
+	mailbox := router.Group("/smtp/configs/:id/bounce-mailbox")
+	{
+		read := middleware.RequirePermission(services.PermSMTPRead)
+		write := middleware.RequirePermission(services.PermSMTPWrite)
+
+		mailbox.GET("", read, h.GetMailbox)         // 获取退信邮箱
+		mailbox.PUT("", write, h.SaveMailbox)       // 设置退信邮箱
+		mailbox.DELETE("", write, h.DeleteMailbox)  // 删除退信邮箱
+		mailbox.POST("/poll", write, h.PollMailbox) // 立即轮询
+	}
+
+	router.GET("/bounces", middleware.RequirePermission(services.PermHistoryRead), h.ListBounces)
+}
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CampaignHandler 营销活动处理器
+type CampaignHandler struct {
+	campaignService *services.CampaignService
+}
+
+// NewCampaignHandler 创建营销活动处理器实例
+func NewCampaignHandler() *CampaignHandler {
+	return &CampaignHandler{
+		campaignService: services.NewCampaignService(),
+	}
+}
+
+// ListCampaigns 获取营销活动列表
+// GET /api/campaigns?page=1&pageSize=20&status=sending
+func (h *CampaignHandler) ListCampaigns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	result, err := h.campaignService.ListCampaigns(middleware.CurrentPrincipal(c), page, pageSize, c.Query("status"))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取营销活动列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// GetCampaign 获取营销活动
+// GET /api/campaigns/:id
+func (h *CampaignHandler) GetCampaign(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+
+	campaign, err := h.campaignService.GetCampaign(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取营销活动失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", campaign)
+}
+
+// CreateCampaign 创建营销活动草稿
+// POST /api/campaigns
+func (h *CampaignHandler) CreateCampaign(c *gin.Context) {
+	var req services.CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	campaign, err := h.campaignService.CreateCampaign(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "创建营销活动失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusCreated, "创建成功", campaign)
+}
+
+// UpdateCampaign 更新营销活动
+// PUT /api/campaigns/:id
+func (h *CampaignHandler) UpdateCampaign(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+
+	var req services.CampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	campaign, err := h.campaignService.UpdateCampaign(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "更新营销活动失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "更新成功", campaign)
+}
+
+// DeleteCampaign 删除营销活动
+// DELETE /api/campaigns/:id
+func (h *CampaignHandler) DeleteCampaign(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+
+	if err := h.campaignService.DeleteCampaign(middleware.CurrentPrincipal(c), id); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除营销活动失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// GetStats 获取营销活动统计
+// GET /api/campaigns/:id/stats
+func (h *CampaignHandler) GetStats(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+
+	stats, err := h.campaignService.Stats(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取营销活动统计失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", stats)
+}
+
+// ListRecipients 获取营销活动的收件人
+// GET /api/campaigns/:id/recipients?page=1&pageSize=20&status=failed
+func (h *CampaignHandler) ListRecipients(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	result, err := h.campaignService.ListRecipients(middleware.CurrentPrincipal(c), id, page, pageSize, c.Query("status"))
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取收件人列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// UploadRecipients 上传收件人列表（multipart/form-data，CSV文件字段名为file）
+// POST /api/campaigns/:id/recipients
+func (h *CampaignHandler) UploadRecipients(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "缺少文件字段file", err)
+		return
+	}
+	reader, err := file.Open()
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "读取上传内容失败", err)
+		return
+	}
+	defer reader.Close()
+
+	result, err := h.campaignService.UploadRecipients(middleware.CurrentPrincipal(c), id, reader)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "上传收件人失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "上传完成", result)
+}
+
+// ClearRecipients 清空上传的收件人列表
+// DELETE /api/campaigns/:id/recipients
+func (h *CampaignHandler) ClearRecipients(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+
+	removed, err := h.campaignService.ClearRecipients(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "清空收件人失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "清空成功", gin.H{"removed": removed})
+}
+
+// Schedule 计划发送，scheduled_at为空时立即开始
+// POST /api/campaigns/:id/schedule
+func (h *CampaignHandler) Schedule(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+
+	var req services.CampaignScheduleRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+			return
+		}
+	}
+
+	campaign, err := h.campaignService.Schedule(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "计划发送失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "已计划发送", campaign)
+}
+
+// Pause 暂停营销活动
+// POST /api/campaigns/:id/pause
+func (h *CampaignHandler) Pause(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+
+	campaign, err := h.campaignService.Pause(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "暂停营销活动失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "已暂停", campaign)
+}
+
+// Resume 恢复营销活动
+// POST /api/campaigns/:id/resume
+func (h *CampaignHandler) Resume(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的营销活动ID", err)
+		return
+	}
+
+	campaign, err := h.campaignService.Resume(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "恢复营销活动失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "已恢复", campaign)
+}
+
+// RegisterRoutes 注册路由
+// 计划和恢复发送还需要发送邮件权限
+func (h *CampaignHandler) RegisterRoutes(router *gin.RouterGroup) {
+	read := middleware.RequirePermission(services.PermCampaignRead)
+	write := middleware.RequirePermission(services.PermCampaignWrite)
+	send := middleware.RequirePermission(services.PermEmailSend)
+
+	campaigns := router.Group("/campaigns")
+	{
+		campaigns.GET("", read, h.ListCampaigns)
+		campaigns.POST("", write, h.CreateCampaign)
+		campaigns.GET("/:id", read, h.GetCampaign)
+		campaigns.PUT("/:id", write, h.UpdateCampaign)
+		campaigns.DELETE("/:id", write, h.DeleteCampaign)
+		campaigns.GET("/:id/stats", read, h.GetStats)
+		campaigns.GET("/:id/recipients", read, h.ListRecipients)
+		campaigns.POST("/:id/recipients", write, h.UploadRecipients)
+		campaigns.DELETE("/:id/recipients", write, h.ClearRecipients)
+		campaigns.POST("/:id/schedule", write, send, h.Schedule)
+		campaigns.POST("/:id/pause", write, h.Pause)
+		campaigns.POST("/:id/resume", write, send, h.Resume)
+	}
+}
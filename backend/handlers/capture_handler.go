@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CaptureHandler 捕获邮件处理器
+type CaptureHandler struct {
+	captureService *services.CaptureService
+}
+
+// NewCaptureHandler 创建捕获邮件处理器实例
+func NewCaptureHandler() *CaptureHandler {
+	return &CaptureHandler{
+		captureService: services.NewCaptureService(),
+	}
+}
+
+// ListCaptures 获取捕获的邮件列表
+// GET /api/captures?page=1&pageSize=20&q=关键字
+func (h *CaptureHandler) ListCaptures(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	result, err := h.captureService.ListMessages(middleware.CurrentPrincipal(c), page, pageSize, c.Query("q"))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取捕获邮件失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// GetCapture 获取捕获邮件详情（解析后的邮件头、正文、附件列表和MIME结构）
+// GET /api/captures/:id
+func (h *CaptureHandler) GetCapture(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的捕获邮件ID", err)
+		return
+	}
+
+	detail, err := h.captureService.GetMessage(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取捕获邮件失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", detail)
+}
+
+// DownloadRaw 下载捕获邮件的原始内容（.eml）
+// GET /api/captures/:id/raw
+func (h *CaptureHandler) DownloadRaw(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的捕获邮件ID", err)
+		return
+	}
+
+	captured, err := h.captureService.GetRaw(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取捕获邮件失败", err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"capture-%d.eml\"", id))
+	c.Data(http.StatusOK, "message/rfc822", []byte(captured.Raw))
+}
+
+// PreviewCapture 渲染捕获邮件的HTML预览
+// GET /api/captures/:id/preview
+func (h *CaptureHandler) PreviewCapture(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的捕获邮件ID", err)
+		return
+	}
+
+	preview, err := h.captureService.Preview(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取捕获邮件失败", err)
+		return
+	}
+
+	// 邮件HTML不可信：禁止脚本执行，只允许内联样式和图片
+	c.Header("Content-Security-Policy", "sandbox; default-src 'none'; img-src data: https: http:; style-src 'unsafe-inline'")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(preview))
+}
+
+// DownloadAttachment 下载捕获邮件中的附件
+// GET /api/captures/:id/attachments/:index
+func (h *CaptureHandler) DownloadAttachment(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的捕获邮件ID", err)
+		return
+	}
+	index, err := strconv.Atoi(c.Param("index"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的附件序号", err)
+		return
+	}
+
+	part, err := h.captureService.GetAttachment(middleware.CurrentPrincipal(c), id, index)
+	if err != nil {
+		code := statusForError(err, http.StatusInternalServerError)
+		if errors.Is(err, services.ErrCaptureAttachmentNotFound) {
+			code = http.StatusNotFound
+		}
+		errorResponse(c, code, "获取附件失败", err)
+		return
+	}
+
+	filename := part.Filename
+	if filename == "" {
+		filename = fmt.Sprintf("attachment-%d", index)
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	c.Data(http.StatusOK, part.ContentType, part.Body())
+}
+
+// DeleteCapture 删除捕获的邮件
+// DELETE /api/captures/:id
+func (h *CaptureHandler) DeleteCapture(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的捕获邮件ID", err)
+		return
+	}
+
+	if err := h.captureService.DeleteMessage(middleware.CurrentPrincipal(c), id); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除捕获邮件失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// ClearCaptures 清空捕获的邮件
+// DELETE /api/captures
+func (h *CaptureHandler) ClearCaptures(c *gin.Context) {
+	deleted, err := h.captureService.ClearMessages(middleware.CurrentPrincipal(c))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "清空捕获邮件失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "清空成功", gin.H{"deleted": deleted})
+}
+
+// RegisterRoutes 注册路由
+func (h *CaptureHandler) RegisterRoutes(router *gin.RouterGroup) {
+	captureGroup := router.Group("/captures")
+	{
+		read := middleware.RequirePermission(services.PermHistoryRead)
+		remove := middleware.RequirePermission(services.PermHistoryDelete)
+
+		captureGroup.GET("", read, h.ListCaptures)                              // 获取捕获邮件列表
+		captureGroup.DELETE("", remove, h.ClearCaptures)                        // 清空捕获邮件
+		captureGroup.GET("/:id", read, h.GetCapture)                            // 获取捕获邮件详情
+		captureGroup.GET("/:id/raw", read, h.DownloadRaw)                       // 下载原始邮件
+		captureGroup.GET("/:id/preview", read, h.PreviewCapture)                // HTML预览
+		captureGroup.GET("/:id/attachments/:index", read, h.DownloadAttachment) // 下载附件
+		captureGroup.DELETE("/:id", remove, h.DeleteCapture)                    // 删除捕获邮件
+	}
+}
@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contactExports 导出格式对应的文件扩展名和Content-Type
+var contactExports = map[string][2]string{
+	services.ContactFormatCSV:   {"csv", "text/csv; charset=utf-8"},
+	services.ContactFormatVCard: {"vcf", "text/vcard; charset=utf-8"},
+}
+
+// ContactHandler 联系人和分组处理器
+type ContactHandler struct {
+	contactService *services.ContactService
+}
+
+// NewContactHandler 创建联系人处理器实例
+func NewContactHandler() *ContactHandler {
+	return &ContactHandler{
+		contactService: services.NewContactService(),
+	}
+}
+
+// parseGroupID 解析可选的group_id参数，未提供时返回0
+func parseGroupID(value string) (uint, error) {
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("无效的分组ID: %s", value)
+	}
+	return uint(id), nil
+}
+
+// ListContacts 获取联系人列表
+// GET /api/contacts?page=1&pageSize=20&q=alice&tag=vip&group_id=1
+func (h *ContactHandler) ListContacts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	groupID, err := parseGroupID(c.Query("group_id"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	result, err := h.contactService.ListContacts(middleware.CurrentPrincipal(c), page, pageSize, c.Query("q"), c.Query("tag"), groupID)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取联系人列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// GetContact 获取联系人
+// GET /api/contacts/:id
+func (h *ContactHandler) GetContact(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的联系人ID", err)
+		return
+	}
+
+	contact, err := h.contactService.GetContact(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取联系人失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", contact)
+}
+
+// CreateContact 创建联系人
+// POST /api/contacts
+func (h *ContactHandler) CreateContact(c *gin.Context) {
+	var req services.ContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	contact, err := h.contactService.CreateContact(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "创建联系人失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusCreated, "创建成功", contact)
+}
+
+// UpdateContact 更新联系人
+// PUT /api/contacts/:id
+func (h *ContactHandler) UpdateContact(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的联系人ID", err)
+		return
+	}
+
+	var req services.ContactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	contact, err := h.contactService.UpdateContact(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "更新联系人失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "更新成功", contact)
+}
+
+// DeleteContact 删除联系人
+// DELETE /api/contacts/:id
+func (h *ContactHandler) DeleteContact(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的联系人ID", err)
+		return
+	}
+
+	if err := h.contactService.DeleteContact(middleware.CurrentPrincipal(c), id); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除联系人失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// ImportContacts 导入联系人（multipart/form-data，文件字段名为file）
+// format为csv或vcard，未提供时按文件扩展名判断；group_id可选，导入的联系人同时加入该分组
+// POST /api/contacts/import
+func (h *ContactHandler) ImportContacts(c *gin.Context) {
+	file, err := c.FormFile("file")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "缺少文件字段file", err)
+		return
+	}
+	groupID, err := parseGroupID(c.PostForm("group_id"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	format := c.PostForm("format")
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(file.Filename)) {
+		case ".csv":
+			format = services.ContactFormatCSV
+		case ".vcf", ".vcard":
+			format = services.ContactFormatVCard
+		default:
+			errorResponse(c, http.StatusBadRequest, "无法从文件名判断格式，请指定format为 csv/vcard", nil)
+			return
+		}
+	}
+
+	reader, err := file.Open()
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "读取上传内容失败", err)
+		return
+	}
+	defer reader.Close()
+
+	result, err := h.contactService.ImportContacts(middleware.CurrentPrincipal(c), reader, format, groupID)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "导入联系人失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "导入完成", result)
+}
+
+// ExportContacts 导出联系人
+// GET /api/contacts/export?format=csv&group_id=1
+func (h *ContactHandler) ExportContacts(c *gin.Context) {
+	format := c.DefaultQuery("format", services.ContactFormatCSV)
+	export, ok := contactExports[format]
+	if !ok {
+		errorResponse(c, http.StatusBadRequest, "无效的导出格式，必须是 csv/vcard", nil)
+		return
+	}
+	groupID, err := parseGroupID(c.Query("group_id"))
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	// 先写入缓冲区，导出失败时仍能返回错误响应
+	var buf bytes.Buffer
+	if err := h.contactService.ExportContacts(middleware.CurrentPrincipal(c), &buf, format, groupID); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "导出联系人失败", err)
+		return
+	}
+
+	filename := fmt.Sprintf("contacts-%s.%s", time.Now().Format("20060102-150405"), export[0])
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	c.Data(http.StatusOK, export[1], buf.Bytes())
+}
+
+// ListGroups 获取分组列表（含成员数）
+// GET /api/contact-groups
+func (h *ContactHandler) ListGroups(c *gin.Context) {
+	groups, err := h.contactService.ListGroups(middleware.CurrentPrincipal(c))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取分组列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", groups)
+}
+
+// CreateGroup 创建分组
+// POST /api/contact-groups
+func (h *ContactHandler) CreateGroup(c *gin.Context) {
+	var req services.ContactGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	group, err := h.contactService.CreateGroup(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "创建分组失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusCreated, "创建成功", group)
+}
+
+// UpdateGroup 更新分组
+// PUT /api/contact-groups/:id
+func (h *ContactHandler) UpdateGroup(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的分组ID", err)
+		return
+	}
+
+	var req services.ContactGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	group, err := h.contactService.UpdateGroup(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "更新分组失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "更新成功", group)
+}
+
+// DeleteGroup 删除分组，分组中的联系人保留
+// DELETE /api/contact-groups/:id
+func (h *ContactHandler) DeleteGroup(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的分组ID", err)
+		return
+	}
+
+	if err := h.contactService.DeleteGroup(middleware.CurrentPrincipal(c), id); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除分组失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// AddMembers 将联系人加入分组
+// POST /api/contact-groups/:id/members
+func (h *ContactHandler) AddMembers(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的分组ID", err)
+		return
+	}
+
+	var req services.ContactMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	added, err := h.contactService.AddMembers(middleware.CurrentPrincipal(c), id, req.ContactIDs)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "添加分组成员失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "添加成功", gin.H{"added": added})
+}
+
+// RemoveMembers 将联系人移出分组
+// DELETE /api/contact-groups/:id/members
+func (h *ContactHandler) RemoveMembers(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的分组ID", err)
+		return
+	}
+
+	var req services.ContactMembersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	removed, err := h.contactService.RemoveMembers(middleware.CurrentPrincipal(c), id, req.ContactIDs)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "移出分组成员失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "移出成功", gin.H{"removed": removed})
+}
+
+// RegisterRoutes 注册路由
+func (h *ContactHandler) RegisterRoutes(router *gin.RouterGroup) {
+	read := middleware.RequirePermission(services.PermContactRead)
+	write := middleware.RequirePermission(services.PermContactWrite)
+
+	contacts := router.Group("/contacts")
+	{
+		contacts.GET("", read, h.ListContacts)
+		contacts.POST("", write, h.CreateContact)
+		contacts.GET("/export", read, h.ExportContacts)
+		contacts.POST("/import", write, h.ImportContacts)
+		contacts.GET("/:id", read, h.GetContact)
+		contacts.PUT("/:id", write, h.UpdateContact)
+		contacts.DELETE("/:id", write, h.DeleteContact)
+	}
+
+	groups := router.Group("/contact-groups")
+	{
+		groups.GET("", read, h.ListGroups)
+		groups.POST("", write, h.CreateGroup)
+		groups.PUT("/:id", write, h.UpdateGroup)
+		groups.DELETE("/:id", write, h.DeleteGroup)
+		groups.POST("/:id/members", write, h.AddMembers)
+		groups.DELETE("/:id/members", write, h.RemoveMembers)
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DraftHandler 邮件草稿处理器
+type DraftHandler struct {
+	draftService *services.DraftService
+}
+
+// NewDraftHandler 创建草稿处理器实例
+func NewDraftHandler() *DraftHandler {
+	return &DraftHandler{
+		draftService: services.NewDraftService(),
+	}
+}
+
+// ListDrafts 获取自己的草稿列表
+// GET /api/drafts?page=1&pageSize=20
+func (h *DraftHandler) ListDrafts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	result, err := h.draftService.ListDrafts(middleware.CurrentPrincipal(c), page, pageSize)
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取草稿列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// GetDraft 获取草稿
+// GET /api/drafts/:id
+func (h *DraftHandler) GetDraft(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的草稿ID", err)
+		return
+	}
+
+	draft, err := h.draftService.GetDraft(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取草稿失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", draft)
+}
+
+// CreateDraft 创建草稿
+// POST /api/drafts
+func (h *DraftHandler) CreateDraft(c *gin.Context) {
+	var req services.DraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	draft, err := h.draftService.CreateDraft(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "创建草稿失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusCreated, "创建成功", draft)
+}
+
+// UpdateDraft 保存草稿，version与当前版本不一致时返回409
+// PUT /api/drafts/:id
+func (h *DraftHandler) UpdateDraft(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的草稿ID", err)
+		return
+	}
+
+	var req services.DraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	draft, err := h.draftService.UpdateDraft(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "保存草稿失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "保存成功", draft)
+}
+
+// DeleteDraft 删除草稿
+// DELETE /api/drafts/:id
+func (h *DraftHandler) DeleteDraft(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的草稿ID", err)
+		return
+	}
+
+	if err := h.draftService.DeleteDraft(middleware.CurrentPrincipal(c), id); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除草稿失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// SendDraft 发送草稿，发送前删除草稿，失败时恢复
+// POST /api/drafts/:id/send
+func (h *DraftHandler) SendDraft(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的草稿ID", err)
+		return
+	}
+
+	var req services.DraftSendRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+			return
+		}
+	}
+
+	history, result, err := h.draftService.SendDraft(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "发送草稿失败", err)
+		return
+	}
+
+	// 按分组发送时返回分组发送结果，与发送邮件接口相同
+	if result != nil {
+		successResponse(c, http.StatusOK, "发送完成", result)
+		return
+	}
+	successResponse(c, http.StatusOK, "邮件发送成功", history)
+}
+
+// RegisterRoutes 注册路由
+// 草稿用于撰写邮件，需要发送邮件权限
+func (h *DraftHandler) RegisterRoutes(router *gin.RouterGroup) {
+	draftGroup := router.Group("/drafts", middleware.RequirePermission(services.PermEmailSend))
+	{
+		draftGroup.GET("", h.ListDrafts)          // 获取草稿列表
+		draftGroup.POST("", h.CreateDraft)        // 创建草稿
+		draftGroup.GET("/:id", h.GetDraft)        // 获取草稿
+		draftGroup.PUT("/:id", h.UpdateDraft)     // 保存草稿
+		draftGroup.DELETE("/:id", h.DeleteDraft)  // 删除草稿
+		draftGroup.POST("/:id/send", h.SendDraft) // 发送草稿
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"net/http"
 
+	"smtp-mail/backend/middleware"
 	"smtp-mail/backend/services"
 
 	"github.com/gin-gonic/gin"
@@ -20,57 +21,74 @@ func NewEmailHandler() *EmailHandler {
 	}
 }
 
-// SendEmail 发送邮件
-// POST /api/email/send
-func (h *EmailHandler) SendEmail(c *gin.Context) {
-	var req services.SendEmailRequest
-
+// bindSendRequest 绑定并验证发送邮件请求，失败时已写入错误响应
+func bindSendRequest(c *gin.Context, req *services.SendEmailRequest) bool {
 	// 绑定JSON请求体
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(req); err != nil {
 		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
-		return
+		return false
 	}
 
 	// 验证必填字段
 	if req.SmtpConfigID == 0 {
 		errorResponse(c, http.StatusBadRequest, "SMTP配置ID不能为空", nil)
-		return
+		return false
 	}
-	if len(req.To) == 0 {
+	if len(req.To) == 0 && len(req.GroupIDs) == 0 {
 		errorResponse(c, http.StatusBadRequest, "收件人列表不能为空", nil)
-		return
+		return false
 	}
-	if req.Subject == "" {
+	// 使用模板时主题和正文可以取自模板
+	if req.Subject == "" && req.TemplateID == nil {
 		errorResponse(c, http.StatusBadRequest, "邮件主题不能为空", nil)
-		return
+		return false
 	}
-	if req.Body == "" {
+	if req.Body == "" && req.Text == "" && req.TemplateID == nil {
 		errorResponse(c, http.StatusBadRequest, "邮件正文不能为空", nil)
-		return
+		return false
 	}
 
 	// 验证附件
-	for i, attachment := range req.Attachments {
+	for _, attachment := range req.Attachments {
+		if attachment.ID != 0 {
+			// 引用已上传的文件
+			continue
+		}
 		if attachment.Filename == "" {
 			errorResponse(c, http.StatusBadRequest, "附件文件名不能为空", nil)
-			return
+			return false
 		}
 		if attachment.Content == "" {
 			errorResponse(c, http.StatusBadRequest, "附件内容不能为空", nil)
-			return
+			return false
 		}
-		// 验证base64编码
-		if len(attachment.Content) == 0 {
-			errorResponse(c, http.StatusBadRequest, "附件内容无效", nil)
+	}
+	return true
+}
+
+// SendEmail 发送邮件
+// POST /api/email/send
+func (h *EmailHandler) SendEmail(c *gin.Context) {
+	var req services.SendEmailRequest
+	if !bindSendRequest(c, &req) {
+		return
+	}
+
+	// 按联系人分组发送：每个收件人单独发送
+	if len(req.GroupIDs) > 0 {
+		result, err := h.emailService.SendToGroups(middleware.CurrentPrincipal(c), &req)
+		if err != nil {
+			errorResponse(c, statusForError(err, http.StatusBadRequest), "发送邮件失败", err)
 			return
 		}
-		_ = i // 避免未使用变量警告
+		successResponse(c, http.StatusOK, "发送完成", result)
+		return
 	}
 
 	// 调用服务层发送邮件
-	history, err := h.emailService.SendEmail(&req)
+	history, err := h.emailService.SendEmail(middleware.CurrentPrincipal(c), &req)
 	if err != nil {
-		errorResponse(c, http.StatusInternalServerError, "发送邮件失败", err)
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "发送邮件失败", err)
 		return
 	}
 
@@ -78,10 +96,30 @@ func (h *EmailHandler) SendEmail(c *gin.Context) {
 	successResponse(c, http.StatusOK, "邮件发送成功", history)
 }
 
+// PreviewEmail 预览邮件（不发送）：返回将要发送的原始邮件、MIME结构和警告
+// POST /api/email/preview
+func (h *EmailHandler) PreviewEmail(c *gin.Context) {
+	var req services.SendEmailRequest
+	if !bindSendRequest(c, &req) {
+		return
+	}
+
+	preview, err := h.emailService.PreviewEmail(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "预览邮件失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "预览成功", preview)
+}
+
 // RegisterRoutes 注册路由
 func (h *EmailHandler) RegisterRoutes(router *gin.RouterGroup) {
 	emailGroup := router.Group("/email")
 	{
-		emailGroup.POST("/send", h.SendEmail) // 发送邮件
+		send := middleware.RequirePermission(services.PermEmailSend)
+
+		emailGroup.POST("/send", send, h.SendEmail)       // 发送邮件
+		emailGroup.POST("/preview", send, h.PreviewEmail) // 预览邮件（不发送）
 	}
 }
\ No newline at end of file
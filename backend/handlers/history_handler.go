@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"smtp-mail/backend/middleware"
 	"smtp-mail/backend/services"
 	"smtp-mail/backend/utils"
 
@@ -43,13 +44,13 @@ func (h *HistoryHandler) GetAllHistory(c *gin.Context) {
 	}
 
 	// 验证状态参数
-	if status != "all" && status != "success" && status != "failed" {
-		errorResponse(c, http.StatusBadRequest, "无效的状态参数，必须是 all/success/failed", nil)
+	if status != "all" && status != "success" && status != "failed" && status != "captured" && status != "bounced" {
+		errorResponse(c, http.StatusBadRequest, "无效的状态参数，必须是 all/success/failed/captured/bounced", nil)
 		return
 	}
 
 	// 获取历史记录
-	result, err := h.historyService.GetAllHistory(page, pageSize, status)
+	result, err := h.historyService.GetAllHistory(middleware.CurrentPrincipal(c), page, pageSize, status)
 	if err != nil {
 		errorResponse(c, http.StatusInternalServerError, "获取历史记录失败", err)
 		return
@@ -70,7 +71,7 @@ func (h *HistoryHandler) GetHistoryByID(c *gin.Context) {
 	}
 
 	// 获取历史记录
-	history, err := h.historyService.GetHistoryByID(uint(id))
+	history, err := h.historyService.GetHistoryByID(middleware.CurrentPrincipal(c), uint(id))
 	if err != nil {
 		errorResponse(c, http.StatusNotFound, "历史记录不存在", err)
 		return
@@ -91,8 +92,8 @@ func (h *HistoryHandler) DeleteHistory(c *gin.Context) {
 	}
 
 	// 删除历史记录
-	if err := h.historyService.DeleteHistory(uint(id)); err != nil {
-		errorResponse(c, http.StatusInternalServerError, "删除历史记录失败", err)
+	if err := h.historyService.DeleteHistory(middleware.CurrentPrincipal(c), uint(id)); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除历史记录失败", err)
 		return
 	}
 
@@ -104,7 +105,7 @@ func (h *HistoryHandler) DeleteHistory(c *gin.Context) {
 // GET /api/history/statistics
 func (h *HistoryHandler) GetStatistics(c *gin.Context) {
 	// 获取统计信息
-	stats, err := h.historyService.GetStatistics()
+	stats, err := h.historyService.GetStatistics(middleware.CurrentPrincipal(c))
 	if err != nil {
 		errorResponse(c, http.StatusInternalServerError, "获取统计信息失败", err)
 		return
@@ -117,9 +118,11 @@ func (h *HistoryHandler) GetStatistics(c *gin.Context) {
 func (h *HistoryHandler) RegisterRoutes(router *gin.RouterGroup) {
 	historyGroup := router.Group("/history")
 	{
-		historyGroup.GET("", h.GetAllHistory)           // 获取历史记录列表
-		historyGroup.GET("/statistics", h.GetStatistics) // 获取统计信息
-		historyGroup.GET("/:id", h.GetHistoryByID)       // 获取单条历史记录
-		historyGroup.DELETE("/:id", h.DeleteHistory)     // 删除历史记录
+		read := middleware.RequirePermission(services.PermHistoryRead)
+
+		historyGroup.GET("", read, h.GetAllHistory)           // 获取历史记录列表
+		historyGroup.GET("/statistics", read, h.GetStatistics) // 获取统计信息
+		historyGroup.GET("/:id", read, h.GetHistoryByID)       // 获取单条历史记录
+		historyGroup.DELETE("/:id", middleware.RequirePermission(services.PermHistoryDelete), h.DeleteHistory) // 删除历史记录
 	}
 }
\ No newline at end of file
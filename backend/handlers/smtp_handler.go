@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"smtp-mail/backend/middleware"
 	"smtp-mail/backend/models"
 	"smtp-mail/backend/services"
 	"smtp-mail/backend/utils"
@@ -61,7 +62,7 @@ func errorResponse(c *gin.Context, code int, message string, err error) {
 // GetAllConfigs 获取所有SMTP配置
 // GET /api/smtp/configs
 func (h *SMTPHandler) GetAllConfigs(c *gin.Context) {
-	configs, err := h.smtpService.GetAllConfigs()
+	configs, err := h.smtpService.GetAllConfigs(middleware.CurrentPrincipal(c))
 	if err != nil {
 		errorResponse(c, http.StatusInternalServerError, "获取SMTP配置失败", err)
 		return
@@ -82,7 +83,7 @@ func (h *SMTPHandler) GetConfigByID(c *gin.Context) {
 	}
 
 	// 获取配置
-	config, err := h.smtpService.GetConfigByID(uint(id))
+	config, err := h.smtpService.GetConfigByID(middleware.CurrentPrincipal(c), uint(id))
 	if err != nil {
 		errorResponse(c, http.StatusNotFound, "SMTP配置不存在", err)
 		return
@@ -129,7 +130,7 @@ func (h *SMTPHandler) CreateConfig(c *gin.Context) {
 	}
 
 	// 创建配置
-	if err := h.smtpService.CreateConfig(&config); err != nil {
+	if err := h.smtpService.CreateConfig(middleware.CurrentPrincipal(c), &config); err != nil {
 		utils.Errorf("创建SMTP配置失败: %v", err)
 		errorResponse(c, http.StatusInternalServerError, "创建SMTP配置失败", err)
 		return
@@ -185,13 +186,13 @@ func (h *SMTPHandler) UpdateConfig(c *gin.Context) {
 	}
 
 	// 更新配置
-	if err := h.smtpService.UpdateConfig(uint(id), &config); err != nil {
-		errorResponse(c, http.StatusInternalServerError, "更新SMTP配置失败", err)
+	if err := h.smtpService.UpdateConfig(middleware.CurrentPrincipal(c), uint(id), &config); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "更新SMTP配置失败", err)
 		return
 	}
 
 	// 获取更新后的配置
-	updatedConfig, err := h.smtpService.GetConfigByID(uint(id))
+	updatedConfig, err := h.smtpService.GetConfigByID(middleware.CurrentPrincipal(c), uint(id))
 	if err != nil {
 		errorResponse(c, http.StatusInternalServerError, "获取更新后的配置失败", err)
 		return
@@ -213,8 +214,8 @@ func (h *SMTPHandler) DeleteConfig(c *gin.Context) {
 	}
 
 	// 删除配置
-	if err := h.smtpService.DeleteConfig(uint(id)); err != nil {
-		errorResponse(c, http.StatusInternalServerError, "删除SMTP配置失败", err)
+	if err := h.smtpService.DeleteConfig(middleware.CurrentPrincipal(c), uint(id)); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除SMTP配置失败", err)
 		return
 	}
 
@@ -233,23 +234,21 @@ func (h *SMTPHandler) TestConnection(c *gin.Context) {
 		return
 	}
 
-	// 获取配置
-	config, err := h.smtpService.GetConfigByID(uint(id))
+	// 获取配置（包含加密的密码）
+	config, err := h.smtpService.GetConfigByIDWithPassword(middleware.CurrentPrincipal(c), uint(id))
 	if err != nil {
 		errorResponse(c, http.StatusNotFound, "SMTP配置不存在", err)
 		return
 	}
 
-	// 从请求体获取密码（如果需要重新测试）
+	// 从请求体获取密码（如果需要使用新密码测试）
 	var requestData struct {
 		Password string `json:"password"`
 	}
-	if err := c.ShouldBindJSON(&requestData); err == nil && requestData.Password != "" {
-		config.Password = requestData.Password
-	}
+	_ = c.ShouldBindJSON(&requestData)
 
 	// 测试连接
-	if err := h.smtpService.TestConnection(config); err != nil {
+	if err := h.smtpService.TestConnection(config, requestData.Password); err != nil {
 		errorResponse(c, http.StatusBadRequest, "SMTP连接测试失败", err)
 		return
 	}
@@ -270,8 +269,8 @@ func (h *SMTPHandler) SetDefaultConfig(c *gin.Context) {
 	}
 
 	// 设置默认配置
-	if err := h.smtpService.SetDefaultConfig(uint(id)); err != nil {
-		errorResponse(c, http.StatusInternalServerError, "设置默认配置失败", err)
+	if err := h.smtpService.SetDefaultConfig(middleware.CurrentPrincipal(c), uint(id)); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "设置默认配置失败", err)
 		return
 	}
 
@@ -282,7 +281,7 @@ func (h *SMTPHandler) SetDefaultConfig(c *gin.Context) {
 // GetDefaultConfig 获取默认SMTP配置
 // GET /api/smtp/configs/default
 func (h *SMTPHandler) GetDefaultConfig(c *gin.Context) {
-	config, err := h.smtpService.GetDefaultConfig()
+	config, err := h.smtpService.GetDefaultConfig(middleware.CurrentPrincipal(c))
 	if err != nil {
 		errorResponse(c, http.StatusNotFound, "未找到默认SMTP配置", err)
 		return
@@ -302,8 +301,8 @@ func (h *SMTPHandler) SendTestEmail(c *gin.Context) {
 		return
 	}
 
-	// 获取配置
-	config, err := h.smtpService.GetConfigByID(uint(id))
+	// 获取配置（包含加密的密码）
+	config, err := h.smtpService.GetConfigByIDWithPassword(middleware.CurrentPrincipal(c), uint(id))
 	if err != nil {
 		errorResponse(c, http.StatusNotFound, "SMTP配置不存在", err)
 		return
@@ -319,13 +318,8 @@ func (h *SMTPHandler) SendTestEmail(c *gin.Context) {
 		return
 	}
 
-	// 如果提供了密码，使用提供的密码
-	if requestData.Password != "" {
-		config.Password = requestData.Password
-	}
-
-	// 发送测试邮件
-	if err := h.smtpService.SendTestEmail(config, requestData.ToEmail); err != nil {
+	// 发送测试邮件，提供了密码时使用提供的密码
+	if err := h.smtpService.SendTestEmail(middleware.CurrentPrincipal(c), config, requestData.ToEmail, requestData.Password); err != nil {
 		errorResponse(c, http.StatusBadRequest, "发送测试邮件失败", err)
 		return
 	}
@@ -340,15 +334,18 @@ func (h *SMTPHandler) RegisterRoutes(router *gin.RouterGroup) {
 	{
 		configs := smtpGroup.Group("/configs")
 		{
-			configs.GET("", h.GetAllConfigs)           // 获取所有配置
-			configs.GET("/default", h.GetDefaultConfig) // 获取默认配置
-			configs.POST("", h.CreateConfig)           // 创建配置
-			configs.GET("/:id", h.GetConfigByID)       // 获取单个配置
-			configs.PUT("/:id", h.UpdateConfig)        // 更新配置
-			configs.DELETE("/:id", h.DeleteConfig)     // 删除配置
-			configs.POST("/:id/test", h.TestConnection) // 测试连接
-			configs.POST("/:id/default", h.SetDefaultConfig) // 设置为默认
-			configs.POST("/:id/send-test", h.SendTestEmail) // 发送测试邮件
+			read := middleware.RequirePermission(services.PermSMTPRead)
+			write := middleware.RequirePermission(services.PermSMTPWrite)
+
+			configs.GET("", read, h.GetAllConfigs)           // 获取所有配置
+			configs.GET("/default", read, h.GetDefaultConfig) // 获取默认配置
+			configs.POST("", write, h.CreateConfig)           // 创建配置
+			configs.GET("/:id", read, h.GetConfigByID)       // 获取单个配置
+			configs.PUT("/:id", write, h.UpdateConfig)        // 更新配置
+			configs.DELETE("/:id", write, h.DeleteConfig)     // 删除配置
+			configs.POST("/:id/test", write, h.TestConnection) // 测试连接
+			configs.POST("/:id/default", middleware.RequirePermission(services.PermSMTPSetDefault), h.SetDefaultConfig) // 设置为默认
+			configs.POST("/:id/send-test", middleware.RequirePermission(services.PermEmailSend), h.SendTestEmail) // 发送测试邮件
 		}
 	}
 }
\ No newline at end of file
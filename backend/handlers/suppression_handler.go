@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SuppressionHandler 抑制列表处理器
+type SuppressionHandler struct {
+	suppressionService *services.SuppressionService
+}
+
+// NewSuppressionHandler 创建抑制列表处理器实例
+func NewSuppressionHandler() *SuppressionHandler {
+	return &SuppressionHandler{
+		suppressionService: services.NewSuppressionService(),
+	}
+}
+
+// ListSuppressions 获取抑制列表
+// GET /api/suppressions?page=1&pageSize=20&q=example.com&source=bounce
+func (h *SuppressionHandler) ListSuppressions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	result, err := h.suppressionService.ListSuppressions(middleware.CurrentPrincipal(c), page, pageSize, c.Query("q"), c.Query("source"))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取抑制列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// CreateSuppression 添加抑制条目
+// POST /api/suppressions
+func (h *SuppressionHandler) CreateSuppression(c *gin.Context) {
+	var req services.SuppressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	suppression, err := h.suppressionService.CreateSuppression(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "添加抑制条目失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusCreated, "添加成功", suppression)
+}
+
+// DeleteSuppression 删除抑制条目
+// DELETE /api/suppressions/:id
+func (h *SuppressionHandler) DeleteSuppression(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的抑制条目ID", err)
+		return
+	}
+
+	if err := h.suppressionService.DeleteSuppression(middleware.CurrentPrincipal(c), id); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除抑制条目失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// RegisterRoutes 注册路由
+// 能发送邮件的用户都可以添加条目，移除条目会恢复向该地址发送，需要工作区管理员
+func (h *SuppressionHandler) RegisterRoutes(router *gin.RouterGroup) {
+	suppressions := router.Group("/suppressions")
+	{
+		suppressions.GET("", middleware.RequirePermission(services.PermHistoryRead), h.ListSuppressions)
+		suppressions.POST("", middleware.RequirePermission(services.PermEmailSend), h.CreateSuppression)
+		suppressions.DELETE("/:id", middleware.RequirePermission(services.PermWorkspaceManage), h.DeleteSuppression)
+	}
+}
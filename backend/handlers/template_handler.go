@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"strconv"
 
+	"smtp-mail/backend/middleware"
 	"smtp-mail/backend/models"
 	"smtp-mail/backend/services"
 	"smtp-mail/backend/utils"
@@ -26,7 +27,7 @@ func NewTemplateHandler() *TemplateHandler {
 // GetAllTemplates 获取所有邮件模板
 // GET /api/templates
 func (h *TemplateHandler) GetAllTemplates(c *gin.Context) {
-	templates, err := h.templateService.GetAllTemplates()
+	templates, err := h.templateService.GetAllTemplates(middleware.CurrentPrincipal(c))
 	if err != nil {
 		errorResponse(c, http.StatusInternalServerError, "获取模板列表失败", err)
 		return
@@ -47,7 +48,7 @@ func (h *TemplateHandler) GetTemplateByID(c *gin.Context) {
 	}
 
 	// 获取模板
-	template, err := h.templateService.GetTemplateByID(uint(id))
+	template, err := h.templateService.GetTemplateByID(middleware.CurrentPrincipal(c), uint(id))
 	if err != nil {
 		errorResponse(c, http.StatusNotFound, "模板不存在", err)
 		return
@@ -82,7 +83,7 @@ func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
 	}
 
 	// 检查名称是否已存在
-	exists, err := h.templateService.NameExists(template.Name, 0)
+	exists, err := h.templateService.NameExists(middleware.CurrentPrincipal(c), template.Name, 0)
 	if err != nil {
 		errorResponse(c, http.StatusInternalServerError, "检查模板名称失败", err)
 		return
@@ -93,7 +94,7 @@ func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
 	}
 
 	// 创建模板
-	if err := h.templateService.CreateTemplate(&template); err != nil {
+	if err := h.templateService.CreateTemplate(middleware.CurrentPrincipal(c), &template); err != nil {
 		// 检查是否是验证错误
 		if err == models.ErrTemplateNameRequired || 
 		   err == models.ErrTemplateSubjectRequired || 
@@ -109,8 +110,8 @@ func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
 	successResponse(c, http.StatusCreated, "创建成功", template)
 }
 
-// UpdateTemplate 更新模板
-// PUT /api/templates/:id
+// UpdateTemplate 更新模板，每次保存新增一个版本并发布，publish=false时只保存版本
+// PUT /api/templates/:id?publish=false
 func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
 	// 解析ID参数
 	idStr := c.Param("id")
@@ -143,7 +144,7 @@ func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
 	}
 
 	// 验证更新数据（包括名称唯一性检查）
-	if err := h.templateService.ValidateTemplateForUpdate(uint(id), &template); err != nil {
+	if err := h.templateService.ValidateTemplateForUpdate(middleware.CurrentPrincipal(c), uint(id), &template); err != nil {
 		if err == models.ErrTemplateNameRequired || 
 		   err == models.ErrTemplateSubjectRequired || 
 		   err == models.ErrTemplateBodyRequired {
@@ -155,13 +156,14 @@ func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
 	}
 
 	// 更新模板
-	if err := h.templateService.UpdateTemplate(uint(id), &template); err != nil {
-		errorResponse(c, http.StatusInternalServerError, "更新模板失败", err)
+	publish := c.DefaultQuery("publish", "true") != "false"
+	if err := h.templateService.UpdateTemplate(middleware.CurrentPrincipal(c), uint(id), &template, publish); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "更新模板失败", err)
 		return
 	}
 
 	// 获取更新后的模板
-	updatedTemplate, err := h.templateService.GetTemplateByID(uint(id))
+	updatedTemplate, err := h.templateService.GetTemplateByID(middleware.CurrentPrincipal(c), uint(id))
 	if err != nil {
 		errorResponse(c, http.StatusInternalServerError, "获取更新后的模板失败", err)
 		return
@@ -183,8 +185,8 @@ func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
 	}
 
 	// 删除模板
-	if err := h.templateService.DeleteTemplate(uint(id)); err != nil {
-		errorResponse(c, http.StatusInternalServerError, "删除模板失败", err)
+	if err := h.templateService.DeleteTemplate(middleware.CurrentPrincipal(c), uint(id)); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除模板失败", err)
 		return
 	}
 
@@ -192,14 +194,143 @@ func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
 	successResponse(c, http.StatusOK, "删除成功", nil)
 }
 
+// ListVersions 获取模板的版本列表
+// GET /api/templates/:id/versions?page=1&pageSize=20
+func (h *TemplateHandler) ListVersions(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的模板ID", err)
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	result, err := h.templateService.ListVersions(middleware.CurrentPrincipal(c), id, page, pageSize)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取模板版本失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// GetVersion 获取模板的一个版本
+// GET /api/templates/:id/versions/:version
+func (h *TemplateHandler) GetVersion(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的模板ID", err)
+		return
+	}
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number < 1 {
+		errorResponse(c, http.StatusBadRequest, "无效的版本号", err)
+		return
+	}
+
+	version, err := h.templateService.GetVersion(middleware.CurrentPrincipal(c), id, number)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取模板版本失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", version)
+}
+
+// PublishVersion 发布模板的指定版本
+// POST /api/templates/:id/versions/:version/publish
+func (h *TemplateHandler) PublishVersion(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的模板ID", err)
+		return
+	}
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number < 1 {
+		errorResponse(c, http.StatusBadRequest, "无效的版本号", err)
+		return
+	}
+
+	template, err := h.templateService.PublishVersion(middleware.CurrentPrincipal(c), id, number)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "发布模板版本失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "发布成功", template)
+}
+
+// Rollback 回滚模板，version为空时回滚到上一个版本
+// POST /api/templates/:id/rollback
+func (h *TemplateHandler) Rollback(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的模板ID", err)
+		return
+	}
+
+	var req services.TemplateRollbackRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+			return
+		}
+	}
+
+	template, err := h.templateService.Rollback(middleware.CurrentPrincipal(c), id, req.Version)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "回滚模板失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "回滚成功", template)
+}
+
+// DiffVersions 比较模板的两个版本，from或to为空时为当前发布的版本
+// GET /api/templates/:id/diff?from=1&to=2
+func (h *TemplateHandler) DiffVersions(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的模板ID", err)
+		return
+	}
+	from, err := strconv.Atoi(c.DefaultQuery("from", "0"))
+	if err != nil || from < 0 {
+		errorResponse(c, http.StatusBadRequest, "无效的版本号from", err)
+		return
+	}
+	to, err := strconv.Atoi(c.DefaultQuery("to", "0"))
+	if err != nil || to < 0 {
+		errorResponse(c, http.StatusBadRequest, "无效的版本号to", err)
+		return
+	}
+
+	diff, err := h.templateService.DiffVersions(middleware.CurrentPrincipal(c), id, from, to)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "比较模板版本失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", diff)
+}
+
 // RegisterRoutes 注册路由
 func (h *TemplateHandler) RegisterRoutes(router *gin.RouterGroup) {
 	templateGroup := router.Group("/templates")
 	{
-		templateGroup.GET("", h.GetAllTemplates)       // 获取所有模板
-		templateGroup.POST("", h.CreateTemplate)       // 创建模板
-		templateGroup.GET("/:id", h.GetTemplateByID)   // 获取单个模板
-		templateGroup.PUT("/:id", h.UpdateTemplate)    // 更新模板
-		templateGroup.DELETE("/:id", h.DeleteTemplate) // 删除模板
+		read := middleware.RequirePermission(services.PermTemplateRead)
+		write := middleware.RequirePermission(services.PermTemplateWrite)
+
+		templateGroup.GET("", read, h.GetAllTemplates)        // 获取所有模板
+		templateGroup.POST("", write, h.CreateTemplate)       // 创建模板
+		templateGroup.GET("/:id", read, h.GetTemplateByID)    // 获取单个模板
+		templateGroup.PUT("/:id", write, h.UpdateTemplate)    // 更新模板
+		templateGroup.DELETE("/:id", write, h.DeleteTemplate) // 删除模板
+
+		templateGroup.GET("/:id/versions", read, h.ListVersions)                      // 获取版本列表
+		templateGroup.GET("/:id/versions/:version", read, h.GetVersion)               // 获取版本
+		templateGroup.POST("/:id/versions/:version/publish", write, h.PublishVersion) // 发布版本
+		templateGroup.POST("/:id/rollback", write, h.Rollback)                        // 回滚
+		templateGroup.GET("/:id/diff", read, h.DiffVersions)                          // 比较两个版本
 	}
 }
\ No newline at end of file
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trackingPixel 1x1透明GIF
+var trackingPixel = []byte{
+	0x47, 0x49, 0x46, 0x38, 0x39, 0x61, 0x01, 0x00, 0x01, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00, 0x00,
+	0xff, 0xff, 0xff, 0x21, 0xf9, 0x04, 0x01, 0x00, 0x00, 0x00, 0x00, 0x2c, 0x00, 0x00, 0x00, 0x00,
+	0x01, 0x00, 0x01, 0x00, 0x00, 0x02, 0x02, 0x44, 0x01, 0x00, 0x3b,
+}
+
+// TrackingHandler 打开和点击追踪处理器
+type TrackingHandler struct {
+	trackingService *services.TrackingService
+}
+
+// NewTrackingHandler 创建追踪处理器实例
+func NewTrackingHandler() *TrackingHandler {
+	return &TrackingHandler{
+		trackingService: services.NewTrackingService(),
+	}
+}
+
+// Open 记录打开并返回追踪像素；令牌无效时同样返回像素，避免邮件中显示破图
+// GET /t/o/:token
+func (h *TrackingHandler) Open(c *gin.Context) {
+	h.trackingService.Open(c.Param("token"), c.Request.UserAgent(), c.ClientIP())
+
+	c.Header("Cache-Control", "no-store, no-cache, must-revalidate, private")
+	c.Header("Pragma", "no-cache")
+	c.Data(http.StatusOK, "image/gif", trackingPixel)
+}
+
+// Click 记录点击并跳转到原链接
+// GET /t/c/:token
+func (h *TrackingHandler) Click(c *gin.Context) {
+	target, err := h.trackingService.Click(c.Param("token"), c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		c.String(http.StatusBadRequest, "无效的链接")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, target)
+}
+
+// ListEvents 获取发送历史的打开和点击事件
+// GET /api/history/:id/events
+func (h *TrackingHandler) ListEvents(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的历史记录ID", err)
+		return
+	}
+
+	events, err := h.trackingService.ListEvents(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取追踪事件失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", events)
+}
+
+// RegisterPublicRoutes 注册无需认证的路由，供邮件客户端访问
+func (h *TrackingHandler) RegisterPublicRoutes(router *gin.RouterGroup) {
+	router.GET("/t/o/:token", h.Open)  // 追踪像素
+	router.GET("/t/c/:token", h.Click) // 点击跳转
+}
+
+// RegisterRoutes 注册路由
+func (h *TrackingHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/history/:id/events", middleware.RequirePermission(services.PermHistoryRead), h.ListEvents)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unsubscribePage 退订页面：确认表单、退订成功和链接无效三种状态
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>退订邮件</title>
+<style>
+body { font-family: -apple-system, "Helvetica Neue", Arial, sans-serif; background: #f5f7fa; color: #303133; }
+.card { max-width: 420px; margin: 80px auto; padding: 32px; background: #fff; border-radius: 8px; box-shadow: 0 2px 12px rgba(0,0,0,.1); text-align: center; }
+button { padding: 10px 24px; border: none; border-radius: 4px; background: #409eff; color: #fff; font-size: 14px; cursor: pointer; }
+</style>
+</head>
+<body>
+<div class="card">
+{{if .Invalid}}
+  <h2>链接无效</h2>
+  <p>该退订链接无效或已损坏。</p>
+{{else if .Done}}
+  <h2>已退订</h2>
+  <p>{{.Email}} 将不再收到此类邮件。</p>
+{{else}}
+  <h2>退订邮件</h2>
+  <p>确认后 {{.Email}} 将不再收到此类邮件。</p>
+  <form method="post">
+    <input type="hidden" name="List-Unsubscribe" value="One-Click">
+    <button type="submit">确认退订</button>
+  </form>
+{{end}}
+</div>
+</body>
+</html>`))
+
+// unsubscribePageData 退订页面数据
+type unsubscribePageData struct {
+	Email   string
+	Done    bool
+	Invalid bool
+}
+
+// UnsubscribeHandler 退订处理器，供邮件收件人使用，无需认证
+type UnsubscribeHandler struct {
+	unsubscribeService *services.UnsubscribeService
+}
+
+// NewUnsubscribeHandler 创建退订处理器实例
+func NewUnsubscribeHandler() *UnsubscribeHandler {
+	return &UnsubscribeHandler{
+		unsubscribeService: services.NewUnsubscribeService(),
+	}
+}
+
+// renderPage 渲染退订页面
+func (h *UnsubscribeHandler) renderPage(c *gin.Context, code int, data unsubscribePageData) {
+	var buf bytes.Buffer
+	if err := unsubscribePage.Execute(&buf, data); err != nil {
+		c.String(http.StatusInternalServerError, "渲染页面失败")
+		return
+	}
+	c.Data(code, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// Confirm 显示退订确认页面；只有POST才会退订，避免邮件安全网关预取链接时误退订
+// GET /u/:token
+func (h *UnsubscribeHandler) Confirm(c *gin.Context) {
+	_, email, err := h.unsubscribeService.ParseToken(c.Param("token"))
+	if err != nil {
+		h.renderPage(c, http.StatusBadRequest, unsubscribePageData{Invalid: true})
+		return
+	}
+	h.renderPage(c, http.StatusOK, unsubscribePageData{Email: email})
+}
+
+// Unsubscribe 退订：邮件客户端的一键退订（RFC 8058）和确认页面的表单都提交到这里
+// POST /u/:token
+func (h *UnsubscribeHandler) Unsubscribe(c *gin.Context) {
+	result, err := h.unsubscribeService.Unsubscribe(c.Param("token"))
+	if err != nil {
+		h.renderPage(c, http.StatusBadRequest, unsubscribePageData{Invalid: true})
+		return
+	}
+	h.renderPage(c, http.StatusOK, unsubscribePageData{Email: result.Email, Done: true})
+}
+
+// RegisterPublicRoutes 注册无需认证的路由
+func (h *UnsubscribeHandler) RegisterPublicRoutes(router *gin.RouterGroup) {
+	router.GET("/u/:token", h.Confirm)      // 退订确认页面
+	router.POST("/u/:token", h.Unsubscribe) // 一键退订
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler Webhook处理器
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+// NewWebhookHandler 创建Webhook处理器实例
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: services.NewWebhookService(),
+	}
+}
+
+// ListWebhooks 获取当前工作区的Webhook
+// GET /api/webhooks
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.webhookService.ListWebhooks(middleware.CurrentPrincipal(c))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取Webhook列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", webhooks)
+}
+
+// CreateWebhook 创建Webhook，签名密钥只在响应中返回一次
+// POST /api/webhooks
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req services.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	result, err := h.webhookService.CreateWebhook(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "创建Webhook失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusCreated, "创建成功", result)
+}
+
+// GetWebhook 获取Webhook
+// GET /api/webhooks/:id
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的Webhook ID", err)
+		return
+	}
+
+	webhook, err := h.webhookService.GetWebhook(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取Webhook失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", webhook)
+}
+
+// UpdateWebhook 更新Webhook
+// PUT /api/webhooks/:id
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的Webhook ID", err)
+		return
+	}
+	var req services.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	webhook, err := h.webhookService.UpdateWebhook(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "更新Webhook失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "更新成功", webhook)
+}
+
+// DeleteWebhook 删除Webhook
+// DELETE /api/webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的Webhook ID", err)
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(middleware.CurrentPrincipal(c), id); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "删除Webhook失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "删除成功", nil)
+}
+
+// TestWebhook 发送测试事件
+// POST /api/webhooks/:id/test
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的Webhook ID", err)
+		return
+	}
+
+	delivery, err := h.webhookService.TestWebhook(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "发送测试事件失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "已发送测试事件", delivery)
+}
+
+// ListDeliveries 获取投递记录
+// GET /api/webhooks/:id/deliveries?page=1&pageSize=20
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的Webhook ID", err)
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+
+	result, err := h.webhookService.ListDeliveries(middleware.CurrentPrincipal(c), id, page, pageSize)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取投递记录失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", result)
+}
+
+// RegisterRoutes 注册路由
+func (h *WebhookHandler) RegisterRoutes(router *gin.RouterGroup) {
+	webhookGroup := router.Group("/webhooks", middleware.RequirePermission(services.PermWorkspaceManage))
+	{
+		webhookGroup.GET("", h.ListWebhooks)                  // 获取Webhook列表
+		webhookGroup.POST("", h.CreateWebhook)                // 创建Webhook
+		webhookGroup.GET("/:id", h.GetWebhook)                // 获取Webhook
+		webhookGroup.PUT("/:id", h.UpdateWebhook)             // 更新Webhook
+		webhookGroup.DELETE("/:id", h.DeleteWebhook)          // 删除Webhook
+		webhookGroup.POST("/:id/test", h.TestWebhook)         // 发送测试事件
+		webhookGroup.GET("/:id/deliveries", h.ListDeliveries) // 投递记录
+	}
+}
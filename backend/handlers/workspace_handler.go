@@ -0,0 +1,322 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
+	"smtp-mail/backend/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkspaceHandler 工作区处理器
+type WorkspaceHandler struct {
+	workspaceService *services.WorkspaceService
+}
+
+// NewWorkspaceHandler 创建工作区处理器实例
+func NewWorkspaceHandler() *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceService: services.NewWorkspaceService(),
+	}
+}
+
+// parseUintParam 解析路径中的ID参数
+func parseUintParam(c *gin.Context, name string) (uint, error) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	return uint(id), err
+}
+
+// ListWorkspaces 获取当前用户所属的工作区
+// GET /api/workspaces
+func (h *WorkspaceHandler) ListWorkspaces(c *gin.Context) {
+	workspaces, err := h.workspaceService.ListWorkspaces(middleware.CurrentPrincipal(c))
+	if err != nil {
+		errorResponse(c, http.StatusInternalServerError, "获取工作区列表失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", workspaces)
+}
+
+// CreateWorkspace 创建工作区
+// POST /api/workspaces
+func (h *WorkspaceHandler) CreateWorkspace(c *gin.Context) {
+	var req services.WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	workspace, err := h.workspaceService.CreateWorkspace(middleware.CurrentPrincipal(c), &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "创建工作区失败", err)
+		return
+	}
+
+	utils.Infof("创建工作区成功: ID=%d, Name=%s", workspace.ID, workspace.Name)
+	successResponse(c, http.StatusCreated, "创建成功", workspace)
+}
+
+// GetWorkspace 获取单个工作区
+// GET /api/workspaces/:id
+func (h *WorkspaceHandler) GetWorkspace(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+
+	workspace, err := h.workspaceService.GetWorkspace(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取工作区失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", workspace)
+}
+
+// UpdateWorkspace 更新工作区名称和配额
+// PUT /api/workspaces/:id
+func (h *WorkspaceHandler) UpdateWorkspace(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+
+	var req services.WorkspaceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	workspace, err := h.workspaceService.UpdateWorkspace(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "更新工作区失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "更新成功", workspace)
+}
+
+// GetUsage 获取工作区配额使用情况
+// GET /api/workspaces/:id/usage
+func (h *WorkspaceHandler) GetUsage(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+
+	usage, err := h.workspaceService.GetUsage(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取配额使用情况失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", usage)
+}
+
+// ListMembers 获取工作区成员
+// GET /api/workspaces/:id/members
+func (h *WorkspaceHandler) ListMembers(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+
+	members, err := h.workspaceService.ListMembers(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取工作区成员失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", members)
+}
+
+// AddMember 添加工作区成员
+// POST /api/workspaces/:id/members
+func (h *WorkspaceHandler) AddMember(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+
+	var req services.MemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	member, err := h.workspaceService.AddMember(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "添加工作区成员失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusCreated, "添加成功", member)
+}
+
+// UpdateMember 修改成员角色
+// PUT /api/workspaces/:id/members/:user_id
+func (h *WorkspaceHandler) UpdateMember(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+	userID, err := parseUintParam(c, "user_id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的用户ID", err)
+		return
+	}
+
+	var req services.MemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	member, err := h.workspaceService.UpdateMember(middleware.CurrentPrincipal(c), id, userID, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "更新工作区成员失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "更新成功", member)
+}
+
+// RemoveMember 移除工作区成员
+// DELETE /api/workspaces/:id/members/:user_id
+func (h *WorkspaceHandler) RemoveMember(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+	userID, err := parseUintParam(c, "user_id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的用户ID", err)
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(middleware.CurrentPrincipal(c), id, userID); err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "移除工作区成员失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "移除成功", nil)
+}
+
+// ListInvitations 获取工作区邀请
+// GET /api/workspaces/:id/invitations
+func (h *WorkspaceHandler) ListInvitations(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+
+	invitations, err := h.workspaceService.ListInvitations(middleware.CurrentPrincipal(c), id)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "获取工作区邀请失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "获取成功", invitations)
+}
+
+// CreateInvitation 创建工作区邀请，完整令牌只在响应中返回一次
+// POST /api/workspaces/:id/invitations
+func (h *WorkspaceHandler) CreateInvitation(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+
+	var req services.InvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	result, err := h.workspaceService.CreateInvitation(middleware.CurrentPrincipal(c), id, &req)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "创建工作区邀请失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusCreated, "创建成功，请将邀请令牌发送给被邀请人", result)
+}
+
+// RevokeInvitation 撤销工作区邀请
+// DELETE /api/workspaces/:id/invitations/:invitation_id
+func (h *WorkspaceHandler) RevokeInvitation(c *gin.Context) {
+	id, err := parseUintParam(c, "id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的工作区ID", err)
+		return
+	}
+	invitationID, err := parseUintParam(c, "invitation_id")
+	if err != nil {
+		errorResponse(c, http.StatusBadRequest, "无效的邀请ID", err)
+		return
+	}
+
+	if err := h.workspaceService.RevokeInvitation(middleware.CurrentPrincipal(c), id, invitationID); err != nil {
+		errorResponse(c, statusForError(err, http.StatusInternalServerError), "撤销工作区邀请失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "撤销成功", nil)
+}
+
+// AcceptInvitation 接受工作区邀请
+// POST /api/invitations/accept
+func (h *WorkspaceHandler) AcceptInvitation(c *gin.Context) {
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		errorResponse(c, http.StatusBadRequest, "请求参数错误", err)
+		return
+	}
+
+	member, err := h.workspaceService.AcceptInvitation(middleware.CurrentPrincipal(c), req.Token)
+	if err != nil {
+		errorResponse(c, statusForError(err, http.StatusBadRequest), "接受邀请失败", err)
+		return
+	}
+
+	successResponse(c, http.StatusOK, "已加入工作区", member)
+}
+
+// RegisterRoutes 注册路由
+// 工作区内的权限由服务层按成员角色判断
+func (h *WorkspaceHandler) RegisterRoutes(router *gin.RouterGroup) {
+	workspaceGroup := router.Group("/workspaces")
+	{
+		workspaceGroup.GET("", h.ListWorkspaces)                                                               // 获取工作区列表
+		workspaceGroup.POST("", middleware.RequirePermission(services.PermWorkspaceCreate), h.CreateWorkspace) // 创建工作区
+		workspaceGroup.GET("/:id", h.GetWorkspace)                                                             // 获取单个工作区
+		workspaceGroup.PUT("/:id", h.UpdateWorkspace)                                                          // 更新工作区
+		workspaceGroup.GET("/:id/usage", h.GetUsage)                                                           // 获取配额使用情况
+
+		workspaceGroup.GET("/:id/members", h.ListMembers)              // 获取成员列表
+		workspaceGroup.POST("/:id/members", h.AddMember)               // 添加成员
+		workspaceGroup.PUT("/:id/members/:user_id", h.UpdateMember)    // 修改成员角色
+		workspaceGroup.DELETE("/:id/members/:user_id", h.RemoveMember) // 移除成员
+
+		workspaceGroup.GET("/:id/invitations", h.ListInvitations)                    // 获取邀请列表
+		workspaceGroup.POST("/:id/invitations", h.CreateInvitation)                  // 创建邀请
+		workspaceGroup.DELETE("/:id/invitations/:invitation_id", h.RevokeInvitation) // 撤销邀请
+	}
+
+	router.POST("/invitations/accept", h.AcceptInvitation) // 接受邀请
+}
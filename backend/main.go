@@ -14,6 +14,7 @@ import (
 	"smtp-mail/backend/database"
 	"smtp-mail/backend/handlers"
 	"smtp-mail/backend/middleware"
+	"smtp-mail/backend/services"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,12 +27,37 @@ func main() {
 	// 设置Gin运行模式
 	gin.SetMode(cfg.Server.Mode)
 
-	// 初始化数据库
-	if err := database.Initialize(); err != nil {
+	// 连接数据库
+	if err := database.Open(); err != nil {
 		log.Fatalf("数据库初始化失败: %v", err)
 	}
 	defer database.Close()
 
+	// 加载加密密钥，主密钥配置错误时拒绝启动
+	if _, err := services.LoadKeyring(); err != nil {
+		log.Fatalf("加载加密密钥失败: %v", err)
+	}
+
+	// 执行子命令（如 migrate、rotate-keys）后退出
+	if runCommand(os.Args[1:]) {
+		return
+	}
+
+	// 执行未执行的迁移，数据库结构版本超前于程序时拒绝启动
+	if err := database.Migrate(database.GetDB(), 0); err != nil {
+		log.Fatalf("数据库迁移失败: %v", err)
+	}
+
+	// 没有任何用户时创建初始管理员
+	if err := services.NewAuthService().EnsureInitialAdmin(); err != nil {
+		log.Fatalf("创建初始管理员失败: %v", err)
+	}
+
+	// 没有任何工作区时创建默认工作区并迁移已有数据
+	if err := services.NewWorkspaceService().EnsureDefaultWorkspace(); err != nil {
+		log.Fatalf("创建默认工作区失败: %v", err)
+	}
+
 	// 创建Gin路由实例
 	router := gin.New()
 
@@ -40,10 +66,25 @@ func main() {
 	router.Use(middleware.CORS())
 
 	// 创建处理器实例
+	authHandler := handlers.NewAuthHandler()
+	apiKeyHandler := handlers.NewAPIKeyHandler()
+	workspaceHandler := handlers.NewWorkspaceHandler()
+	auditHandler := handlers.NewAuditHandler()
+	backupHandler := handlers.NewBackupHandler()
 	smtpHandler := handlers.NewSMTPHandler()
 	emailHandler := handlers.NewEmailHandler()
+	attachmentHandler := handlers.NewAttachmentHandler()
+	draftHandler := handlers.NewDraftHandler()
 	templateHandler := handlers.NewTemplateHandler()
 	historyHandler := handlers.NewHistoryHandler()
+	webhookHandler := handlers.NewWebhookHandler()
+	bounceHandler := handlers.NewBounceHandler()
+	suppressionHandler := handlers.NewSuppressionHandler()
+	captureHandler := handlers.NewCaptureHandler()
+	unsubscribeHandler := handlers.NewUnsubscribeHandler()
+	trackingHandler := handlers.NewTrackingHandler()
+	contactHandler := handlers.NewContactHandler()
+	campaignHandler := handlers.NewCampaignHandler()
 
 	// 注册健康检查端点
 	router.GET("/health", func(c *gin.Context) {
@@ -53,20 +94,75 @@ func main() {
 		})
 	})
 
+	// 退订链接（无需认证，供邮件收件人使用）
+	unsubscribeHandler.RegisterPublicRoutes(&router.RouterGroup)
+
+	// 打开和点击追踪（无需认证，供邮件客户端访问）
+	trackingHandler.RegisterPublicRoutes(&router.RouterGroup)
+
 	// 注册API路由
 	api := router.Group("/api")
 	{
+		// 登录路由（无需认证）
+		authHandler.RegisterPublicRoutes(api)
+	}
+
+	// 需要认证的API路由
+	api = api.Group("", middleware.Auth())
+	{
+		// 当前用户与用户管理路由
+		authHandler.RegisterRoutes(api)
+
+		// 工作区、成员和邀请路由
+		workspaceHandler.RegisterRoutes(api)
+
+		// API密钥管理路由
+		apiKeyHandler.RegisterRoutes(api)
+
 		// SMTP配置管理路由
 		smtpHandler.RegisterRoutes(api)
 
 		// 邮件发送路由
 		emailHandler.RegisterRoutes(api)
 
+		// 附件上传路由
+		attachmentHandler.RegisterRoutes(api)
+
+		// 草稿路由
+		draftHandler.RegisterRoutes(api)
+
 		// 邮件模板管理路由
 		templateHandler.RegisterRoutes(api)
 
 		// 发送历史记录路由
 		historyHandler.RegisterRoutes(api)
+
+		// 打开和点击追踪事件路由
+		trackingHandler.RegisterRoutes(api)
+
+		// 联系人和分组路由
+		contactHandler.RegisterRoutes(api)
+
+		// 营销活动路由
+		campaignHandler.RegisterRoutes(api)
+
+		// 捕获邮件（沙箱收件箱）路由
+		captureHandler.RegisterRoutes(api)
+
+		// 退信邮箱和退信记录路由
+		bounceHandler.RegisterRoutes(api)
+
+		// 抑制列表路由
+		suppressionHandler.RegisterRoutes(api)
+
+		// Webhook订阅路由
+		webhookHandler.RegisterRoutes(api)
+
+		// 审计日志路由
+		auditHandler.RegisterRoutes(api)
+
+		// 数据库备份路由
+		backupHandler.RegisterRoutes(api)
 	}
 
 	// 配置静态文件服务
@@ -101,6 +197,43 @@ func main() {
 		Handler: router,
 	}
 
+	// SQLite数据库记录运行中的服务进程，防止服务运行期间从命令行恢复数据库
+	unlock, err := database.LockServer()
+	if err != nil {
+		log.Fatalf("数据库锁定失败: %v", err)
+	}
+	defer unlock()
+
+	// 后台任务在退出时停止
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	// 启动定时备份
+	services.NewBackupService().StartSchedule(workerCtx)
+
+	// 启动Webhook投递
+	services.NewWebhookService().StartWorker(workerCtx)
+
+	// 启动退信邮箱轮询
+	services.NewBounceService().StartPoller(workerCtx)
+
+	// 启动营销活动发送
+	services.NewCampaignService().StartWorker(workerCtx)
+
+	// 启动SMTP提交服务
+	var submission *services.SubmissionServer
+	if cfg.Submission.Enabled {
+		var err error
+		if submission, err = services.NewSubmissionServer(); err != nil {
+			log.Fatalf("SMTP提交服务初始化失败: %v", err)
+		}
+		go func() {
+			if err := submission.ListenAndServe(); err != nil {
+				log.Fatalf("SMTP提交服务启动失败: %v", err)
+			}
+		}()
+	}
+
 	// 在goroutine中启动服务器
 	go func() {
 		log.Printf("服务器启动成功，监听端口: %d", cfg.Server.Port)
@@ -121,6 +254,11 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// 停止SMTP提交服务
+	if submission != nil {
+		submission.Close()
+	}
+
 	// 优雅关闭服务器
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("服务器强制关闭: %v", err)
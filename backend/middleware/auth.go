@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"smtp-mail/backend/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// principalKey 操作者在gin上下文中的键名
+const principalKey = "principal"
+
+// noWorkspaceRoutes 不属于任何工作区的用户可以访问的接口
+var noWorkspaceRoutes = map[string]bool{
+	"GET /api/auth/me":             true,
+	"GET /api/workspaces":          true,
+	"POST /api/workspaces":         true,
+	"POST /api/invitations/accept": true,
+}
+
+// Auth 认证中间件：校验Bearer令牌（JWT或API密钥）并将操作者写入上下文
+// API密钥也可以通过 X-API-Key 请求头传递，当前工作区通过 X-Workspace-ID 请求头指定
+func Auth() gin.HandlerFunc {
+	authService := services.NewAuthService()
+	apiKeyService := services.NewAPIKeyService()
+	workspaceService := services.NewWorkspaceService()
+
+	return func(c *gin.Context) {
+		token := c.GetHeader("X-API-Key")
+		if token == "" {
+			header := c.GetHeader("Authorization")
+			token = strings.TrimPrefix(header, "Bearer ")
+			if token == header {
+				token = ""
+			}
+		}
+		if token == "" {
+			abortWithError(c, http.StatusUnauthorized, services.ErrUnauthorized)
+			return
+		}
+
+		var principal *services.Principal
+		var err error
+		if services.IsAPIKey(token) {
+			principal, err = apiKeyService.Authenticate(token, c.ClientIP())
+		} else {
+			principal, err = authService.Authenticate(token)
+		}
+		if err != nil {
+			abortWithError(c, http.StatusUnauthorized, err)
+			return
+		}
+
+		// 确定当前工作区，可通过 X-Workspace-ID 请求头切换
+		// 不属于任何工作区的用户只能访问不需要工作区的接口（查看自己、接受邀请等）
+		if err := workspaceService.Resolve(principal, c.GetHeader("X-Workspace-ID")); err != nil {
+			if !errors.Is(err, services.ErrNoWorkspace) || !noWorkspaceRoutes[c.Request.Method+" "+c.FullPath()] {
+				abortWithError(c, http.StatusForbidden, err)
+				return
+			}
+		}
+
+		principal.ClientIP = c.ClientIP()
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+// RequirePermission 权限中间件：要求当前操作者拥有指定权限
+func RequirePermission(perm services.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := CurrentPrincipal(c).Authorize(perm); err != nil {
+			abortWithError(c, http.StatusForbidden, err)
+			return
+		}
+		c.Next()
+	}
+}
+
+// CurrentPrincipal 获取当前请求的操作者，未认证时返回nil
+func CurrentPrincipal(c *gin.Context) *services.Principal {
+	value, ok := c.Get(principalKey)
+	if !ok {
+		return nil
+	}
+	principal, _ := value.(*services.Principal)
+	return principal
+}
+
+// abortWithError 以统一的错误响应格式终止请求
+func abortWithError(c *gin.Context, code int, err error) {
+	c.AbortWithStatusJSON(code, gin.H{
+		"code":    code,
+		"message": err.Error(),
+	})
+}
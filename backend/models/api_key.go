@@ -0,0 +1,68 @@
+package models
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// UintSlice 用于存储JSON无符号整数切片
+type UintSlice []uint
+
+// Scan 实现sql.Scanner接口
+func (u *UintSlice) Scan(value interface{}) error {
+	if value == nil {
+		*u = nil
+		return nil
+	}
+	return scanJSON(value, u)
+}
+
+// Value 实现driver.Valuer接口
+func (u UintSlice) Value() (driver.Value, error) {
+	if u == nil {
+		return nil, nil
+	}
+	return jsonValue(u)
+}
+
+// Contains 检查是否包含指定值
+func (u UintSlice) Contains(id uint) bool {
+	for _, v := range u {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey API密钥模型，用于脚本和CI等机器调用
+// 完整密钥只在创建时返回一次，数据库中仅保存前缀和哈希
+type APIKey struct {
+	ID            uint        `gorm:"primaryKey" json:"id"`
+	WorkspaceID   uint        `gorm:"not null;default:0;index" json:"workspace_id"` // 密钥只能访问所属工作区
+	Name          string      `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix        string      `gorm:"type:varchar(32);not null;uniqueIndex" json:"prefix"` // 用于识别密钥的公开前缀
+	SecretHash    string      `gorm:"type:varchar(64);not null" json:"-"`                  // 完整密钥的SHA-256哈希
+	UserID        uint        `gorm:"not null;index" json:"user_id"`                       // 密钥所属用户，调用时以该用户身份执行
+	Scopes        StringSlice `gorm:"type:text" json:"scopes"`                             // 授权范围，如 email:send、history:read
+	SMTPConfigIDs UintSlice   `gorm:"type:text" json:"smtp_config_ids"`                    // 允许使用的SMTP配置，为空表示不限制
+	ExpiresAt     *time.Time  `json:"expires_at"`
+	LastUsedAt    *time.Time  `json:"last_used_at"`
+	LastUsedIP    string      `gorm:"type:varchar(64)" json:"last_used_ip"`
+	RevokedAt     *time.Time  `json:"revoked_at"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// IsActive 检查密钥是否可用（未吊销且未过期）
+func (k *APIKey) IsActive() bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || time.Now().Before(*k.ExpiresAt)
+}
@@ -0,0 +1,101 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 审计操作类型
+const (
+	AuditActionCreate     = "create"
+	AuditActionUpdate     = "update"
+	AuditActionDelete     = "delete"
+	AuditActionSetDefault = "set_default"
+	AuditActionRevoke     = "revoke"
+	AuditActionRotateKey  = "rotate_key"
+	AuditActionImport     = "import"
+	AuditActionPublish    = "publish"
+)
+
+// 审计实体类型
+const (
+	AuditEntitySMTPConfig    = "smtp_config"
+	AuditEntityEmailTemplate = "email_template"
+	AuditEntityEmailHistory  = "email_history"
+	AuditEntityUser          = "user"
+	AuditEntityAPIKey        = "api_key"
+	AuditEntityWorkspace     = "workspace"
+	AuditEntityMember        = "workspace_member"
+	AuditEntityInvitation    = "workspace_invitation"
+	AuditEntityBackup        = "backup"
+	AuditEntityCapture       = "captured_message"
+	AuditEntityUploadedFile  = "uploaded_file"
+	AuditEntityWebhook       = "webhook"
+	AuditEntityBounceMailbox = "bounce_mailbox"
+	AuditEntitySuppression   = "suppression"
+	AuditEntityContact       = "contact"
+	AuditEntityContactGroup  = "contact_group"
+	AuditEntityCampaign      = "campaign"
+)
+
+// ErrAuditEventImmutable 审计事件只允许追加
+var ErrAuditEventImmutable = errors.New("审计事件不允许修改或删除")
+
+// FieldChange 单个字段的变更前后值
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges 字段名到变更的映射，以JSON存储
+type AuditChanges map[string]FieldChange
+
+// Scan 实现sql.Scanner接口
+func (a *AuditChanges) Scan(value interface{}) error {
+	if value == nil {
+		*a = nil
+		return nil
+	}
+	return scanJSON(value, a)
+}
+
+// Value 实现driver.Valuer接口
+func (a AuditChanges) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return jsonValue(a)
+}
+
+// AuditEvent 审计事件模型（只追加）
+type AuditEvent struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint         `gorm:"index" json:"workspace_id"` // 0表示系统级事件（如用户管理）
+	ActorID     uint         `gorm:"index" json:"actor_id"`     // 操作者用户ID，0表示系统
+	ActorName   string       `gorm:"type:varchar(100)" json:"actor_name"`
+	APIKeyID    *uint        `gorm:"index" json:"api_key_id"`
+	Action      string       `gorm:"type:varchar(50);not null;index" json:"action"`
+	EntityType  string       `gorm:"type:varchar(50);not null;index:idx_audit_entity" json:"entity_type"`
+	EntityID    uint         `gorm:"index:idx_audit_entity" json:"entity_id"`
+	Changes     AuditChanges `gorm:"type:text" json:"changes"` // 变更字段，敏感字段已脱敏
+	ClientIP    string       `gorm:"type:varchar(64)" json:"client_ip"`
+	CreatedAt   time.Time    `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// BeforeUpdate GORM钩子：禁止修改审计事件
+func (a *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+// BeforeDelete GORM钩子：禁止删除审计事件
+func (a *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// 退信类型
+const (
+	BounceHard = "hard" // 永久失败，如地址不存在
+	BounceSoft = "soft" // 临时失败，如邮箱已满、延迟投递
+)
+
+// 退信邮箱协议
+const (
+	MailboxIMAP = "imap"
+	MailboxPOP3 = "pop3"
+)
+
+// BounceMailbox SMTP配置对应的退信邮箱，定期轮询并解析其中的退信
+type BounceMailbox struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	WorkspaceID  uint       `gorm:"not null;default:0;index" json:"workspace_id"`
+	SmtpConfigID uint       `gorm:"not null;uniqueIndex" json:"smtp_config_id"`
+	Protocol     string     `gorm:"type:varchar(10);not null" json:"protocol"` // imap、pop3
+	Host         string     `gorm:"type:varchar(255);not null" json:"host"`
+	Port         int        `gorm:"not null" json:"port"`
+	Username     string     `gorm:"type:varchar(255)" json:"username"`
+	Password     string     `gorm:"type:varchar(255)" json:"-"`      // 加密保存
+	TLS          bool       `gorm:"default:true" json:"tls"`         // 使用隐式TLS（IMAPS 993、POP3S 995）
+	Folder       string     `gorm:"type:varchar(100)" json:"folder"` // IMAP文件夹，默认INBOX
+	VERP         bool       `gorm:"default:false" json:"verp"`       // 发信时使用VERP信封发件人
+	Enabled      bool       `gorm:"default:true" json:"enabled"`
+	LastPolledAt *time.Time `json:"last_polled_at"`
+	LastError    string     `gorm:"type:text" json:"last_error"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (BounceMailbox) TableName() string {
+	return "bounce_mailboxes"
+}
+
+// Bounce 解析出的退信记录，每个退信收件人一条
+type Bounce struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID  uint      `gorm:"not null;default:0;index" json:"workspace_id"`
+	SmtpConfigID uint      `gorm:"index" json:"smtp_config_id"`
+	HistoryID    uint      `gorm:"index" json:"history_id"` // 匹配到的发送历史，0表示未匹配
+	UserID       uint      `gorm:"index" json:"user_id"`    // 原邮件的发送用户
+	Recipient    string    `gorm:"type:varchar(255);index" json:"recipient"`
+	Type         string    `gorm:"type:varchar(10)" json:"type"`   // hard、soft
+	Status       string    `gorm:"type:varchar(20)" json:"status"` // DSN状态码，如 5.1.1
+	Action       string    `gorm:"type:varchar(20)" json:"action"` // failed、delayed
+	Diagnostic   string    `gorm:"type:text" json:"diagnostic"`
+	MessageID    string    `gorm:"type:varchar(255)" json:"message_id"` // 原邮件的Message-ID
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (Bounce) TableName() string {
+	return "bounces"
+}
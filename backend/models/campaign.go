@@ -0,0 +1,124 @@
+package models
+
+import "time"
+
+// CampaignStatus 营销活动状态
+type CampaignStatus string
+
+// 营销活动的生命周期：draft → scheduled → sending → completed，发送中和已计划的活动可以暂停和恢复
+const (
+	CampaignDraft     CampaignStatus = "draft"
+	CampaignScheduled CampaignStatus = "scheduled"
+	CampaignSending   CampaignStatus = "sending"
+	CampaignPaused    CampaignStatus = "paused"
+	CampaignCompleted CampaignStatus = "completed"
+)
+
+// 营销活动收件人的发送状态
+const (
+	CampaignRecipientPending    = "pending"
+	CampaignRecipientSending    = "sending" // 已被发送协程认领，正在发送
+	CampaignRecipientSent       = "sent"
+	CampaignRecipientFailed     = "failed"
+	CampaignRecipientSuppressed = "suppressed" // 在抑制列表中，已跳过
+)
+
+// A/B测试选出获胜版本的指标
+const (
+	CampaignMetricOpen  = "open"  // 打开率
+	CampaignMetricClick = "click" // 点击率
+)
+
+// 营销活动收件人的来源
+const (
+	CampaignSourceGroup  = "group"  // 开始发送时从联系人分组展开
+	CampaignSourceUpload = "upload" // 上传的收件人列表
+)
+
+// Campaign 营销活动：使用模板，按计划时间和限速向受众（联系人分组和/或上传的列表）逐个发送
+type Campaign struct {
+	ID            uint           `gorm:"primaryKey" json:"id"`
+	WorkspaceID   uint           `gorm:"not null;default:0;index" json:"workspace_id"`
+	Name          string         `gorm:"type:varchar(100);not null" json:"name"`
+	TemplateID    uint           `gorm:"not null;index" json:"template_id"`
+	SmtpConfigID  uint           `gorm:"not null" json:"smtp_config_id"`
+	GroupID       *uint          `gorm:"index" json:"group_id"`                     // 受众分组，为空时只发送给上传的列表
+	Bulk          bool           `gorm:"default:false" json:"bulk"`                 // 按批量邮件发送，添加一键退订头
+	Track         bool           `gorm:"default:false" json:"track"`                // 开启打开和点击追踪
+	RatePerMinute int            `gorm:"not null;default:0" json:"rate_per_minute"` // 每分钟最多发送的邮件数，0表示不限速
+	Status        CampaignStatus `gorm:"type:varchar(20);not null;default:'draft';index" json:"status"`
+	ScheduledAt   *time.Time     `gorm:"index" json:"scheduled_at"`
+	Subject       string         `gorm:"type:varchar(255)" json:"subject"` // 开始发送时从模板复制，之后修改模板不影响本活动
+	Body          string         `gorm:"type:text" json:"body"`
+	LastError     string         `gorm:"type:text" json:"last_error"` // 最近一次自动暂停的原因
+	NextSendAt    *time.Time     `json:"-"`                           // 限速：下一封邮件最早的发送时间
+	StartedAt     *time.Time     `json:"started_at"`
+	CompletedAt   *time.Time     `json:"completed_at"`
+	CreatedBy     uint           `json:"created_by"` // 发送时以创建者的身份和权限发送
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+
+	// 开始发送时复制的模板版本，记录到发送历史
+	TemplateVersion int `gorm:"not null;default:0" json:"template_version"`
+
+	// 备用SMTP配置，按顺序使用：SmtpConfigID连接失败或服务器返回临时错误（4xx）时改用下一个
+	FallbackSmtpConfigIDs UintSlice `gorm:"type:text" json:"fallback_smtp_config_ids"`
+
+	// A/B测试：有两个以上版本时，先将测试比例的受众平均分配给各版本，等待后按指标选出获胜版本发送给其余收件人
+	Variants          []CampaignVariant `gorm:"foreignKey:CampaignID" json:"variants,omitempty"`
+	TestPercent       int               `gorm:"not null;default:0" json:"test_percent"`        // 测试受众占全部收件人的百分比
+	WinnerMetric      string            `gorm:"type:varchar(10)" json:"winner_metric"`         // open、click
+	WinnerWaitMinutes int               `gorm:"not null;default:0" json:"winner_wait_minutes"` // 测试邮件发送完后等待的时间
+	WinnerPickAt      *time.Time        `json:"winner_pick_at"`                                // 测试邮件发送完后设置，到时选出获胜版本
+	WinnerVariantID   *uint             `json:"winner_variant_id"`
+}
+
+// TableName 指定表名
+func (Campaign) TableName() string {
+	return "campaigns"
+}
+
+// CampaignRecipient 营销活动的收件人，每人单独发送一封邮件
+type CampaignRecipient struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	CampaignID uint       `gorm:"not null;uniqueIndex:idx_campaign_recipients_email;index:idx_campaign_recipients_status" json:"campaign_id"`
+	Email      string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_campaign_recipients_email" json:"email"` // 小写
+	VariantID  *uint      `gorm:"index" json:"variant_id"`                                                           // A/B测试中分配的版本，为空表示不在测试受众中
+	Variables  StringMap  `gorm:"type:text" json:"variables"`                                                        // 模板变量
+	Source     string     `gorm:"type:varchar(10);not null" json:"source"`
+	Status     string     `gorm:"type:varchar(20);not null;default:'pending';index:idx_campaign_recipients_status" json:"status"`
+	HistoryID  *uint      `gorm:"index" json:"history_id"`
+	Error      string     `gorm:"type:text" json:"error,omitempty"`
+	SentAt     *time.Time `json:"sent_at"`
+}
+
+// TableName 指定表名
+func (CampaignRecipient) TableName() string {
+	return "campaign_recipients"
+}
+
+// CampaignVariant A/B测试版本，主题和模板为空时使用活动的模板
+type CampaignVariant struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	CampaignID uint   `gorm:"not null;index" json:"campaign_id"`
+	Name       string `gorm:"type:varchar(50);not null" json:"name"`
+	TemplateID *uint  `json:"template_id"`
+	Subject    string `gorm:"type:varchar(255)" json:"subject"`
+	Body       string `gorm:"type:text" json:"body,omitempty"` // 开始发送时从模板复制
+
+	// 开始发送时复制的模板版本，记录到发送历史
+	TemplateVersion int `gorm:"not null;default:0" json:"template_version"`
+
+	// 备用SMTP配置，按顺序使用：SmtpConfigID连接失败或服务器返回临时错误（4xx）时改用下一个
+	FallbackSmtpConfigIDs UintSlice `gorm:"type:text" json:"fallback_smtp_config_ids"`
+
+	// 选出获胜版本时的测试结果
+	Sent    int64 `gorm:"not null;default:0" json:"sent"`
+	Opened  int64 `gorm:"not null;default:0" json:"opened"`
+	Clicked int64 `gorm:"not null;default:0" json:"clicked"`
+}
+
+// TableName 指定表名
+func (CampaignVariant) TableName() string {
+	return "campaign_variants"
+}
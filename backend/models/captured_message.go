@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// CapturedMessage 捕获模式下保存的邮件（未实际投递）
+type CapturedMessage struct {
+	ID           uint        `gorm:"primaryKey" json:"id"`
+	WorkspaceID  uint        `gorm:"not null;default:0;index" json:"workspace_id"`
+	SmtpConfigID uint        `gorm:"index" json:"smtp_config_id"`
+	HistoryID    uint        `gorm:"index" json:"history_id"` // 对应的发送历史记录
+	UserID       uint        `gorm:"index" json:"user_id"`
+	FromEmail    string      `gorm:"type:varchar(255)" json:"from_email"`
+	Recipients   StringSlice `gorm:"type:text" json:"recipients"` // 信封收件人（包括密送）
+	Subject      string      `gorm:"type:varchar(255)" json:"subject"`
+	Size         int         `json:"size"`
+	Raw          string      `gorm:"not null" json:"-"` // 完整的原始邮件
+	CreatedAt    time.Time   `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (CapturedMessage) TableName() string {
+	return "captured_messages"
+}
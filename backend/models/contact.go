@@ -0,0 +1,73 @@
+package models
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// StringMap 用于存储JSON字符串映射
+type StringMap map[string]string
+
+// Scan 实现sql.Scanner接口
+func (m *StringMap) Scan(value interface{}) error {
+	if value == nil {
+		*m = nil
+		return nil
+	}
+	return scanJSON(value, m)
+}
+
+// Value 实现driver.Valuer接口
+func (m StringMap) Value() (driver.Value, error) {
+	if m == nil {
+		return nil, nil
+	}
+	return jsonValue(m)
+}
+
+// Contact 联系人，工作区内按邮箱地址唯一
+type Contact struct {
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint        `gorm:"not null;default:0;uniqueIndex:idx_contacts_workspace_email" json:"workspace_id"`
+	Email       string      `gorm:"type:varchar(255);not null;uniqueIndex:idx_contacts_workspace_email" json:"email"` // 小写
+	Name        string      `gorm:"type:varchar(255)" json:"name"`
+	Attributes  StringMap   `gorm:"type:text" json:"attributes"` // 自定义属性，可作为模板变量
+	Tags        StringSlice `gorm:"type:text" json:"tags"`
+	CreatedBy   uint        `json:"created_by"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Contact) TableName() string {
+	return "contacts"
+}
+
+// ContactGroup 联系人分组（邮件列表），发送时可按分组展开为成员
+type ContactGroup struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint      `gorm:"not null;default:0;uniqueIndex:idx_contact_groups_workspace_name" json:"workspace_id"`
+	Name        string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_contact_groups_workspace_name" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	MemberCount int64     `gorm:"-" json:"member_count"`
+	CreatedBy   uint      `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (ContactGroup) TableName() string {
+	return "contact_groups"
+}
+
+// ContactGroupMember 分组成员
+type ContactGroupMember struct {
+	GroupID   uint      `gorm:"primaryKey" json:"group_id"`
+	ContactID uint      `gorm:"primaryKey;index" json:"contact_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (ContactGroupMember) TableName() string {
+	return "contact_group_members"
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"database/sql/driver"
+	"time"
+)
+
+// DraftAttachment 草稿引用的已上传附件
+type DraftAttachment struct {
+	ID       uint   `json:"id"`                 // 上传文件的ID
+	Filename string `json:"filename,omitempty"` // 为空时使用上传时的文件名
+}
+
+// DraftAttachments 用于存储JSON附件引用列表
+type DraftAttachments []DraftAttachment
+
+// Scan 实现sql.Scanner接口
+func (a *DraftAttachments) Scan(value interface{}) error {
+	if value == nil {
+		*a = nil
+		return nil
+	}
+	return scanJSON(value, a)
+}
+
+// Value 实现driver.Valuer接口
+func (a DraftAttachments) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return jsonValue(a)
+}
+
+// Draft 未发送的邮件草稿，字段与发送邮件请求相同，附件只能引用已上传的文件
+// 草稿属于创建者本人，UserID为0的草稿没有所有者（没有登录用户时创建），工作区内共用
+type Draft struct {
+	ID                 uint             `gorm:"primaryKey" json:"id"`
+	WorkspaceID        uint             `gorm:"not null;default:0;index:idx_drafts_owner" json:"workspace_id"`
+	UserID             uint             `gorm:"not null;default:0;index:idx_drafts_owner" json:"user_id"`
+	SmtpConfigID       uint             `json:"smtp_config_id"` // 0表示还未选择
+	To                 StringSlice      `gorm:"type:text" json:"to"`
+	Cc                 StringSlice      `gorm:"type:text" json:"cc"`
+	Bcc                StringSlice      `gorm:"type:text" json:"bcc"`
+	Subject            string           `gorm:"type:varchar(255)" json:"subject"`
+	Body               string           `gorm:"type:text" json:"body"`
+	Text               string           `gorm:"type:text" json:"text"`
+	Attachments        DraftAttachments `gorm:"type:text" json:"attachments"`
+	IgnoreSuppressions bool             `gorm:"default:false" json:"ignore_suppressions"`
+	Bulk               bool             `gorm:"default:false" json:"bulk"`
+	Track              bool             `gorm:"default:false" json:"track"`
+	GroupIDs           UintSlice        `gorm:"type:text" json:"group_ids"`
+	TemplateID         *uint            `json:"template_id"`
+	TemplateVersion    int              `gorm:"not null;default:0" json:"template_version"`
+	Personalize        bool             `gorm:"default:false" json:"personalize"`
+	Version            int              `gorm:"not null;default:1" json:"version"` // 每次保存加1，用于检测并发修改
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Draft) TableName() string {
+	return "drafts"
+}
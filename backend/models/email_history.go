@@ -13,10 +13,32 @@ import (
 type EmailStatus string
 
 const (
-	EmailStatusSuccess EmailStatus = "success"
-	EmailStatusFailed  EmailStatus = "failed"
+	EmailStatusSuccess  EmailStatus = "success"
+	EmailStatusFailed   EmailStatus = "failed"
+	EmailStatusCaptured EmailStatus = "captured" // 捕获模式下未实际投递
+	EmailStatusBounced  EmailStatus = "bounced"  // 已发送，但收到退信
 )
 
+// scanJSON 解析数据库中的JSON列，不同驱动返回[]byte或string
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	}
+	return errors.New("类型断言失败")
+}
+
+// jsonValue 序列化为JSON字符串，以文本形式写入text列（PostgreSQL不接受将[]byte写入text列）
+func jsonValue(v interface{}) (driver.Value, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
 // StringSlice 用于存储JSON字符串切片
 type StringSlice []string
 
@@ -26,11 +48,7 @@ func (s *StringSlice) Scan(value interface{}) error {
 		*s = nil
 		return nil
 	}
-	bytes, ok := value.([]byte)
-	if !ok {
-		return errors.New("类型断言失败")
-	}
-	return json.Unmarshal(bytes, s)
+	return scanJSON(value, s)
 }
 
 // Value 实现driver.Valuer接口
@@ -38,7 +56,7 @@ func (s StringSlice) Value() (driver.Value, error) {
 	if s == nil {
 		return nil, nil
 	}
-	return json.Marshal(s)
+	return jsonValue(s)
 }
 
 // Attachment 附件信息
@@ -57,11 +75,7 @@ func (a *AttachmentSlice) Scan(value interface{}) error {
 		*a = nil
 		return nil
 	}
-	bytes, ok := value.([]byte)
-	if !ok {
-		return errors.New("类型断言失败")
-	}
-	return json.Unmarshal(bytes, a)
+	return scanJSON(value, a)
 }
 
 // Value 实现driver.Valuer接口
@@ -69,24 +83,38 @@ func (a AttachmentSlice) Value() (driver.Value, error) {
 	if a == nil {
 		return nil, nil
 	}
-	return json.Marshal(a)
+	return jsonValue(a)
 }
 
 // EmailHistory 邮件发送历史模型
 type EmailHistory struct {
-	ID           uint           `gorm:"primaryKey" json:"id"`
-	SmtpConfigID uint           `gorm:"not null;index" json:"smtp_config_id"`
-	SmtpConfig   SMTPConfig     `gorm:"foreignKey:SmtpConfigID" json:"smtp_config,omitempty"`
-	ToEmail      string         `gorm:"type:varchar(255);not null" json:"to_email"`
-	CcEmail      StringSlice    `gorm:"type:text" json:"cc_email"`
-	BccEmail     StringSlice    `gorm:"type:text" json:"bcc_email"`
-	Subject      string         `gorm:"type:varchar(255);not null" json:"subject"`
-	Body         string         `gorm:"type:text;not null" json:"body"`
-	Attachments  AttachmentSlice `gorm:"type:text" json:"attachments"`
-	Status       EmailStatus    `gorm:"type:varchar(20);not null;default:'failed'" json:"status"`
-	ErrorMessage string         `gorm:"type:text" json:"error_message"`
-	SentAt       time.Time      `json:"sent_at"`
-	CreatedAt    time.Time      `json:"created_at"`
+	ID           uint             `gorm:"primaryKey" json:"id"`
+	WorkspaceID  uint             `gorm:"not null;default:0;index" json:"workspace_id"`
+	SmtpConfigID uint             `gorm:"not null;index" json:"smtp_config_id"`
+	SmtpConfig   SMTPConfig       `gorm:"foreignKey:SmtpConfigID" json:"smtp_config,omitempty"`
+	UserID       uint             `gorm:"index" json:"user_id"`    // 发送邮件的用户ID
+	APIKeyID     *uint            `gorm:"index" json:"api_key_id"` // 通过API密钥发送时记录密钥ID
+	ToEmail      string           `gorm:"type:varchar(255);not null" json:"to_email"`
+	CcEmail      StringSlice      `gorm:"type:text" json:"cc_email"`
+	BccEmail     StringSlice      `gorm:"type:text" json:"bcc_email"`
+	Subject      string           `gorm:"type:varchar(255);not null" json:"subject"`
+	Body         string           `gorm:"type:text;not null" json:"body"`
+	Attachments  AttachmentSlice  `gorm:"type:text" json:"attachments"`
+	Status       EmailStatus      `gorm:"type:varchar(20);not null;default:'failed'" json:"status"`
+	ErrorMessage string           `gorm:"type:text" json:"error_message"`
+	ScanResult   string           `gorm:"type:varchar(255)" json:"scan_result,omitempty"`   // 附件扫描结论：clean、infected: 病毒名 (文件名)、unscanned等
+	MessageID    string           `gorm:"type:varchar(255);index" json:"message_id"`        // 邮件的Message-ID，用于匹配退信
+	BounceType   string           `gorm:"type:varchar(10)" json:"bounce_type,omitempty"`    // 退信类型：hard、soft
+	Suppressed   StringSlice      `gorm:"type:text" json:"suppressed,omitempty"`            // 因在抑制列表中而跳过的收件人
+	Tracked      bool             `gorm:"default:false" json:"tracked"`                     // 是否开启了打开和点击追踪
+	Recipients   []EmailRecipient `gorm:"foreignKey:HistoryID" json:"recipients,omitempty"` // 开启追踪时的收件人及其打开、点击统计
+	CampaignID   *uint            `gorm:"index" json:"campaign_id,omitempty"`               // 所属的营销活动
+	SentAt       time.Time        `json:"sent_at"`
+	CreatedAt    time.Time        `json:"created_at"`
+
+	// 使用模板发送时记录模板和实际使用的版本号
+	TemplateID      *uint `gorm:"index" json:"template_id,omitempty"`
+	TemplateVersion int   `gorm:"not null;default:0" json:"template_version,omitempty"`
 }
 
 // TableName 指定表名
@@ -110,4 +138,4 @@ func (e *EmailHistory) IsSuccess() bool {
 // IsFailed 检查邮件是否发送失败
 func (e *EmailHistory) IsFailed() bool {
 	return e.Status == EmailStatusFailed
-}
\ No newline at end of file
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,12 +9,17 @@ import (
 
 // EmailTemplate 邮件模板模型
 type EmailTemplate struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"name"`
-	Subject   string    `gorm:"type:varchar(255);not null" json:"subject"`
-	Body      string    `gorm:"type:text;not null" json:"body"` // 支持HTML内容
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint           `gorm:"not null;default:0;uniqueIndex:idx_template_workspace_name" json:"workspace_id"`
+	Name        string         `gorm:"type:varchar(100);not null;uniqueIndex:idx_template_workspace_name" json:"name"` // 工作区内唯一
+	Subject     string         `gorm:"type:varchar(255);not null" json:"subject"`
+	Body        string         `gorm:"type:text;not null" json:"body"`    // 支持HTML内容
+	OwnerID     uint           `gorm:"index" json:"owner_id"`             // 创建者用户ID，0表示启用认证前创建的模板
+	Shared      bool           `gorm:"default:false" json:"shared"`       // 是否共享给其他用户使用
+	Version     int            `gorm:"not null;default:0" json:"version"` // 当前发布的版本号，Subject和Body为该版本的内容
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"` // 软删除，保留模板的全部版本
 }
 
 // TableName 指定表名
@@ -45,11 +51,35 @@ func (e *EmailTemplate) validate() error {
 	return nil
 }
 
+// TemplateVersion 模板的一个版本，每次保存模板都会新增一个版本，创建后不可修改
+type TemplateVersion struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TemplateID uint      `gorm:"not null;uniqueIndex:idx_template_versions_version" json:"template_id"`
+	Version    int       `gorm:"not null;uniqueIndex:idx_template_versions_version" json:"version"` // 模板内从1开始递增
+	Subject    string    `gorm:"type:varchar(255);not null" json:"subject"`
+	Body       string    `gorm:"type:text;not null" json:"body,omitempty"`
+	AuthorID   uint      `json:"author_id"` // 保存该版本的用户ID
+	CreatedAt  time.Time `json:"created_at"`
+	Current    bool      `gorm:"-" json:"current"` // 是否为当前发布的版本
+}
+
+// TableName 指定表名
+func (TemplateVersion) TableName() string {
+	return "template_versions"
+}
+
+// BeforeUpdate GORM钩子：版本不可修改
+func (v *TemplateVersion) BeforeUpdate(tx *gorm.DB) error {
+	return ErrTemplateVersionImmutable
+}
+
 // 错误定义
 var (
 	ErrTemplateNameRequired    = &ValidationError{Field: "name", Message: "模板名称不能为空"}
 	ErrTemplateSubjectRequired = &ValidationError{Field: "subject", Message: "模板主题不能为空"}
 	ErrTemplateBodyRequired    = &ValidationError{Field: "body", Message: "模板内容不能为空"}
+
+	ErrTemplateVersionImmutable = errors.New("模板版本创建后不能修改")
 )
 
 // ValidationError 验证错误
@@ -60,4 +90,4 @@ type ValidationError struct {
 
 func (e *ValidationError) Error() string {
 	return e.Message
-}
\ No newline at end of file
+}
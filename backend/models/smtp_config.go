@@ -10,25 +10,29 @@ import (
 type EncryptionType string
 
 const (
-	EncryptionNone    EncryptionType = "none"
-	EncryptionTLS     EncryptionType = "tls"
+	EncryptionNone     EncryptionType = "none"
+	EncryptionTLS      EncryptionType = "tls"
 	EncryptionStartTLS EncryptionType = "starttls"
 )
 
 // SMTPConfig SMTP配置模型
 type SMTPConfig struct {
-	ID        uint           `gorm:"primaryKey" json:"id"`
-	Name      string         `gorm:"type:varchar(100);not null" json:"name"`
-	Host      string         `gorm:"type:varchar(255);not null" json:"host"`
-	Port      int            `gorm:"not null" json:"port"`
-	Username  string         `gorm:"type:varchar(255)" json:"username"`
-	Password  string         `gorm:"type:varchar(255)" json:"password"` // 接收时使用，响应时由代码清除
-	FromEmail string         `gorm:"type:varchar(255);not null" json:"from_email"`
-	FromName  string         `gorm:"type:varchar(100)" json:"from_name"`
-	Encryption EncryptionType `gorm:"type:varchar(20);default:'none'" json:"encryption"`
-	IsDefault bool           `gorm:"default:false" json:"is_default"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint           `gorm:"not null;default:0;index" json:"workspace_id"`
+	Name        string         `gorm:"type:varchar(100);not null" json:"name"`
+	Host        string         `gorm:"type:varchar(255);not null" json:"host"`
+	Port        int            `gorm:"not null" json:"port"`
+	Username    string         `gorm:"type:varchar(255)" json:"username"`
+	Password    string         `gorm:"type:varchar(255)" json:"password"` // 接收时使用，响应时由代码清除
+	FromEmail   string         `gorm:"type:varchar(255);not null" json:"from_email"`
+	FromName    string         `gorm:"type:varchar(100)" json:"from_name"`
+	Encryption  EncryptionType `gorm:"type:varchar(20);default:'none'" json:"encryption"`
+	IsDefault   bool           `gorm:"default:false" json:"is_default"` // 每个工作区只有一个默认配置
+	OwnerID     uint           `gorm:"index" json:"owner_id"`           // 创建者用户ID，0表示启用认证前创建的配置
+	Shared      bool           `gorm:"default:false" json:"shared"`     // 是否共享给其他用户使用
+	Capture     bool           `gorm:"default:false" json:"capture"`    // 捕获模式：邮件只保存不投递
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
 }
 
 // TableName 指定表名
@@ -36,30 +40,30 @@ func (SMTPConfig) TableName() string {
 	return "smtp_configs"
 }
 
-// BeforeCreate GORM钩子：创建前确保只有一个默认配置
+// BeforeCreate GORM钩子：创建前确保工作区内只有一个默认配置
 func (s *SMTPConfig) BeforeCreate(tx *gorm.DB) error {
 	if s.IsDefault {
-		// 将其他配置的IsDefault设置为false
-		tx.Model(&SMTPConfig{}).Where("is_default = ?", true).Update("is_default", false)
+		// 将同一工作区其他配置的IsDefault设置为false
+		tx.Model(&SMTPConfig{}).Where("workspace_id = ? AND is_default = ?", s.WorkspaceID, true).Update("is_default", false)
 	}
 	return nil
 }
 
-// BeforeUpdate GORM钩子：更新前确保只有一个默认配置
+// BeforeUpdate GORM钩子：更新前确保工作区内只有一个默认配置
 func (s *SMTPConfig) BeforeUpdate(tx *gorm.DB) error {
 	if tx.Statement.Changed("IsDefault") && s.IsDefault {
-		// 将其他配置的IsDefault设置为false
-		tx.Model(&SMTPConfig{}).Where("is_default = ? AND id != ?", true, s.ID).Update("is_default", false)
+		// 将同一工作区其他配置的IsDefault设置为false
+		tx.Model(&SMTPConfig{}).Where("workspace_id = ? AND is_default = ? AND id != ?", s.WorkspaceID, true, s.ID).Update("is_default", false)
 	}
 	return nil
 }
 
-// GetDefaultConfig 获取默认SMTP配置
-func GetDefaultConfig(db *gorm.DB) (*SMTPConfig, error) {
+// GetDefaultConfig 获取工作区的默认SMTP配置
+func GetDefaultConfig(db *gorm.DB, workspaceID uint) (*SMTPConfig, error) {
 	var config SMTPConfig
-	err := db.Where("is_default = ?", true).First(&config).Error
+	err := db.Where("workspace_id = ? AND is_default = ?", workspaceID, true).First(&config).Error
 	if err != nil {
 		return nil, err
 	}
 	return &config, nil
-}
\ No newline at end of file
+}
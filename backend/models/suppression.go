@@ -0,0 +1,38 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// 抑制来源
+const (
+	SuppressionSourceManual        = "manual"         // 通过API手动添加
+	SuppressionSourceSMTPRejection = "smtp_rejection" // 上游SMTP服务器对RCPT返回5xx永久错误
+	SuppressionSourceBounce        = "bounce"         // 收到硬退信
+	SuppressionSourceUnsubscribe   = "unsubscribe"    // 收件人通过退订链接退订
+)
+
+// Suppression 抑制列表条目，发送时跳过匹配的收件人
+// Address为完整邮箱地址或域名（不含@，匹配该域名下的所有地址），统一保存为小写
+type Suppression struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint       `gorm:"not null;default:0;uniqueIndex:idx_suppressions_workspace_address" json:"workspace_id"`
+	Address     string     `gorm:"type:varchar(255);not null;uniqueIndex:idx_suppressions_workspace_address" json:"address"`
+	Reason      string     `gorm:"type:text" json:"reason"`
+	Source      string     `gorm:"type:varchar(20);not null;index" json:"source"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at"` // 为空表示永久
+	CreatedBy   uint       `json:"created_by"`              // 自动添加时为0
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Suppression) TableName() string {
+	return "suppressions"
+}
+
+// IsDomain 是否为域名条目
+func (s *Suppression) IsDomain() bool {
+	return !strings.Contains(s.Address, "@")
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// 追踪事件类型
+const (
+	TrackingOpen  = "open"  // 打开：加载了追踪像素
+	TrackingClick = "click" // 点击：通过追踪跳转访问了链接
+)
+
+// 收件人类型
+const (
+	RecipientTo  = "to"
+	RecipientCc  = "cc"
+	RecipientBcc = "bcc"
+)
+
+// EmailRecipient 开启追踪的发送历史中的收件人，汇总该收件人的打开和点击
+type EmailRecipient struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	HistoryID  uint       `gorm:"not null;index" json:"history_id"`
+	Address    string     `gorm:"type:varchar(255);not null" json:"address"`
+	Kind       string     `gorm:"type:varchar(10);not null" json:"kind"` // to、cc、bcc
+	OpenCount  int        `gorm:"not null;default:0" json:"open_count"`
+	ClickCount int        `gorm:"not null;default:0" json:"click_count"`
+	OpenedAt   *time.Time `json:"opened_at"`  // 第一次打开时间
+	ClickedAt  *time.Time `json:"clicked_at"` // 第一次点击时间
+}
+
+// TableName 指定表名
+func (EmailRecipient) TableName() string {
+	return "email_recipients"
+}
+
+// TrackingEvent 一次打开或点击
+type TrackingEvent struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint      `gorm:"not null;default:0;index" json:"workspace_id"`
+	HistoryID   uint      `gorm:"not null;index" json:"history_id"`
+	RecipientID *uint     `gorm:"index" json:"recipient_id"`                   // 多个收件人的邮件无法区分是谁打开的，为空
+	Type        string    `gorm:"type:varchar(10);not null;index" json:"type"` // open、click
+	URL         string    `gorm:"type:text" json:"url,omitempty"`              // 点击的链接
+	UserAgent   string    `gorm:"type:varchar(512)" json:"user_agent"`
+	IP          string    `gorm:"type:varchar(64)" json:"ip"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// TableName 指定表名
+func (TrackingEvent) TableName() string {
+	return "tracking_events"
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// UploadedFile 上传的附件文件，内容按SHA-256保存在上传目录中，发送邮件时按ID引用
+type UploadedFile struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint      `gorm:"not null;default:0;index" json:"workspace_id"`
+	UserID      uint      `gorm:"index" json:"user_id"`
+	Filename    string    `gorm:"type:varchar(255);not null" json:"filename"`
+	ContentType string    `gorm:"type:varchar(100)" json:"content_type"` // 根据内容检测的类型
+	Size        int64     `json:"size"`
+	Checksum    string    `gorm:"type:varchar(64);index" json:"checksum"` // SHA-256（十六进制）
+	Path        string    `gorm:"type:varchar(500);not null" json:"-"`    // 相对于上传目录的路径
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (UploadedFile) TableName() string {
+	return "uploaded_files"
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Role 用户角色
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  // 管理员：管理所有资源和用户
+	RoleSender Role = "sender" // 发件人：管理自己的配置和模板并发送邮件
+	RoleViewer Role = "viewer" // 只读用户：查看可见的配置、模板和自己的历史
+)
+
+// IsValid 检查角色是否有效
+func (r Role) IsValid() bool {
+	switch r {
+	case RoleAdmin, RoleSender, RoleViewer:
+		return true
+	}
+	return false
+}
+
+// User 用户模型
+type User struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Username     string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"username"`
+	PasswordHash string    `gorm:"type:varchar(255);not null" json:"-"`
+	Role         Role      `gorm:"type:varchar(20);not null;default:'viewer'" json:"role"`
+	Disabled     bool      `gorm:"default:false" json:"disabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (User) TableName() string {
+	return "users"
+}
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// Webhook 事件类型
+const (
+	WebhookEventEmailSent     = "email.sent"     // 邮件发送成功
+	WebhookEventEmailFailed   = "email.failed"   // 邮件发送失败（包括附件扫描拒绝）
+	WebhookEventEmailCaptured = "email.captured" // 捕获模式下邮件被保存
+	WebhookEventEmailBounced  = "email.bounced"  // 收到退信
+	WebhookEventEmailRetried  = "email.retried"  // 发送时改用备用SMTP配置重试，或收到延迟投递通知（对方服务器将重试投递）
+	WebhookEventTest          = "webhook.test"   // 测试事件
+)
+
+// WebhookEvents 可以订阅的事件
+var WebhookEvents = []string{WebhookEventEmailSent, WebhookEventEmailFailed, WebhookEventEmailCaptured, WebhookEventEmailBounced, WebhookEventEmailRetried}
+
+// Webhook 出站Webhook订阅，事件以带HMAC签名的POST请求推送到URL
+type Webhook struct {
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint        `gorm:"not null;default:0;index" json:"workspace_id"`
+	URL         string      `gorm:"type:varchar(500);not null" json:"url"`
+	Events      StringSlice `gorm:"type:text" json:"events"`     // 订阅的事件，为空表示全部
+	Secret      string      `gorm:"type:text;not null" json:"-"` // 加密保存的签名密钥
+	Enabled     bool        `gorm:"default:true" json:"enabled"`
+	CreatedBy   uint        `json:"created_by"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// Subscribes 是否订阅了指定事件
+func (w *Webhook) Subscribes(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus 投递状态
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending WebhookDeliveryStatus = "pending" // 等待投递或重试
+	WebhookDeliverySuccess WebhookDeliveryStatus = "success"
+	WebhookDeliveryFailed  WebhookDeliveryStatus = "failed" // 重试次数用完
+)
+
+// WebhookDelivery Webhook投递记录
+type WebhookDelivery struct {
+	ID             uint                  `gorm:"primaryKey" json:"id"`
+	WebhookID      uint                  `gorm:"not null;index" json:"webhook_id"`
+	WorkspaceID    uint                  `gorm:"not null;default:0;index" json:"workspace_id"`
+	Event          string                `gorm:"type:varchar(50);not null" json:"event"`
+	Payload        string                `gorm:"type:text;not null" json:"payload"`
+	Status         WebhookDeliveryStatus `gorm:"type:varchar(20);not null;index" json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastStatusCode int                   `json:"last_status_code"`
+	LastError      string                `gorm:"type:text" json:"last_error"`
+	LastResponse   string                `gorm:"type:text" json:"last_response"` // 响应内容（截断）
+	NextAttemptAt  *time.Time            `gorm:"index" json:"next_attempt_at"`
+	DeliveredAt    *time.Time            `json:"delivered_at"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
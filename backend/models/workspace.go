@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// Workspace 工作区模型，每个团队拥有独立的SMTP配置、模板、历史和配额
+type Workspace struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Name         string    `gorm:"type:varchar(100);not null" json:"name"`
+	Slug         string    `gorm:"type:varchar(100);not null;uniqueIndex" json:"slug"`
+	DailyQuota   int       `gorm:"default:0" json:"daily_quota"`   // 每日发送上限，0表示不限制
+	MonthlyQuota int       `gorm:"default:0" json:"monthly_quota"` // 每月发送上限，0表示不限制
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (Workspace) TableName() string {
+	return "workspaces"
+}
+
+// WorkspaceMember 工作区成员，Role为用户在该工作区内的角色
+type WorkspaceMember struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint      `gorm:"not null;uniqueIndex:idx_workspace_member" json:"workspace_id"`
+	UserID      uint      `gorm:"not null;uniqueIndex:idx_workspace_member;index" json:"user_id"`
+	User        *User     `gorm:"foreignKey:UserID" json:"user,omitempty"`
+	Role        Role      `gorm:"type:varchar(20);not null;default:'viewer'" json:"role"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (WorkspaceMember) TableName() string {
+	return "workspace_members"
+}
+
+// WorkspaceInvitation 工作区邀请，完整令牌只在创建时返回一次
+type WorkspaceInvitation struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	WorkspaceID uint       `gorm:"not null;index" json:"workspace_id"`
+	Email       string     `gorm:"type:varchar(255)" json:"email"` // 被邀请人邮箱，仅用于标识
+	Role        Role       `gorm:"type:varchar(20);not null;default:'viewer'" json:"role"`
+	TokenHash   string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	InvitedBy   uint       `json:"invited_by"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	AcceptedBy  *uint      `json:"accepted_by"`
+	AcceptedAt  *time.Time `json:"accepted_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// TableName 指定表名
+func (WorkspaceInvitation) TableName() string {
+	return "workspace_invitations"
+}
+
+// IsPending 检查邀请是否仍可接受
+func (i *WorkspaceInvitation) IsPending() bool {
+	return i.AcceptedAt == nil && time.Now().Before(i.ExpiresAt)
+}
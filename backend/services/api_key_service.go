@@ -0,0 +1,249 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyPrefix API密钥的固定前缀，格式为 stmp_<前缀>_<密钥>
+const APIKeyPrefix = "stmp_"
+
+// APIKeyService API密钥服务
+type APIKeyService struct {
+	auditService *AuditService
+}
+
+// NewAPIKeyService 创建API密钥服务实例
+func NewAPIKeyService() *APIKeyService {
+	return &APIKeyService{
+		auditService: NewAuditService(),
+	}
+}
+
+// CreateAPIKeyRequest 创建API密钥请求
+type CreateAPIKeyRequest struct {
+	Name          string       `json:"name" binding:"required"`
+	Scopes        []Permission `json:"scopes" binding:"required,min=1"`
+	SMTPConfigIDs []uint       `json:"smtp_config_ids"`
+	ExpiresAt     *time.Time   `json:"expires_at"`
+}
+
+// CreateAPIKeyResponse 创建API密钥响应，完整密钥只返回这一次
+type CreateAPIKeyResponse struct {
+	Key    string        `json:"key"`
+	APIKey models.APIKey `json:"api_key"`
+}
+
+// IsAPIKey 判断令牌是否为API密钥格式
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// hashAPIKey 计算API密钥哈希（密钥本身为高熵随机值，无需慢哈希）
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomHex 生成指定字节数的随机十六进制字符串
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// ListAPIKeys 获取API密钥列表，管理员可查看全部
+func (s *APIKeyService) ListAPIKeys(p *Principal) ([]models.APIKey, error) {
+	db := database.GetDB()
+	query := p.ScopeWorkspace(db).Order("created_at DESC")
+	if !p.IsAdmin() {
+		query = query.Where("user_id = ?", p.UserID)
+	}
+
+	var keys []models.APIKey
+	if err := query.Find(&keys).Error; err != nil {
+		utils.Errorf("获取API密钥列表失败: %v", err)
+		return nil, fmt.Errorf("获取API密钥列表失败: %w", err)
+	}
+	return keys, nil
+}
+
+// GetAPIKeyByID 获取单个API密钥
+func (s *APIKeyService) GetAPIKeyByID(p *Principal, id uint) (*models.APIKey, error) {
+	db := database.GetDB()
+
+	var key models.APIKey
+	if err := p.ScopeWorkspace(db).First(&key, id).Error; err != nil {
+		utils.Errorf("获取API密钥失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("获取API密钥失败: %w", err)
+	}
+	if err := p.AuthorizeModify(key.UserID); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateAPIKey 为当前用户创建API密钥
+// 授权范围不能超出用户自身权限，允许的SMTP配置必须对用户可见
+func (s *APIKeyService) CreateAPIKey(p *Principal, req *CreateAPIKeyRequest) (*CreateAPIKeyResponse, error) {
+	if req.Name == "" {
+		return nil, errors.New("密钥名称不能为空")
+	}
+	if len(req.Scopes) == 0 {
+		return nil, errors.New("授权范围不能为空")
+	}
+	for _, scope := range req.Scopes {
+		if !hasPermission(APIKeyScopes, scope) {
+			return nil, fmt.Errorf("不支持的授权范围: %s", scope)
+		}
+		if !p.Can(scope) {
+			return nil, fmt.Errorf("当前用户没有权限授予: %s", scope)
+		}
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		return nil, errors.New("过期时间必须晚于当前时间")
+	}
+
+	db := database.GetDB()
+	if len(req.SMTPConfigIDs) > 0 {
+		var count int64
+		if err := p.ScopeSMTPConfigs(db.Model(&models.SMTPConfig{})).Where("id IN ?", req.SMTPConfigIDs).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("检查SMTP配置失败: %w", err)
+		}
+		if int(count) != len(req.SMTPConfigIDs) {
+			return nil, errors.New("包含不存在或无权使用的SMTP配置")
+		}
+	}
+
+	prefix, err := randomHex(4)
+	if err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %w", err)
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, fmt.Errorf("生成密钥失败: %w", err)
+	}
+	fullKey := APIKeyPrefix + prefix + "_" + secret
+
+	scopes := make(models.StringSlice, len(req.Scopes))
+	for i, scope := range req.Scopes {
+		scopes[i] = string(scope)
+	}
+
+	key := models.APIKey{
+		WorkspaceID:   p.WorkspaceID,
+		Name:          req.Name,
+		Prefix:        APIKeyPrefix + prefix,
+		SecretHash:    hashAPIKey(fullKey),
+		UserID:        p.UserID,
+		Scopes:        scopes,
+		SMTPConfigIDs: req.SMTPConfigIDs,
+		ExpiresAt:     req.ExpiresAt,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&key).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityAPIKey, key.ID, nil, &key)
+	})
+	if err != nil {
+		utils.Errorf("创建API密钥失败: %v", err)
+		return nil, fmt.Errorf("创建API密钥失败: %w", err)
+	}
+
+	utils.Infof("创建API密钥成功: ID=%d, Prefix=%s, UserID=%d", key.ID, key.Prefix, key.UserID)
+	return &CreateAPIKeyResponse{Key: fullKey, APIKey: key}, nil
+}
+
+// RevokeAPIKey 吊销API密钥（保留记录以便历史记录追溯）
+func (s *APIKeyService) RevokeAPIKey(p *Principal, id uint) error {
+	key, err := s.GetAPIKeyByID(p, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	before := *key
+	now := time.Now()
+	key.RevokedAt = &now
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(key).Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionRevoke, models.AuditEntityAPIKey, id, &before, key)
+	})
+	if err != nil {
+		utils.Errorf("吊销API密钥失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("吊销API密钥失败: %w", err)
+	}
+
+	utils.Infof("吊销API密钥成功: ID=%d, Prefix=%s", id, key.Prefix)
+	return nil
+}
+
+// Authenticate 校验API密钥并返回操作者，同时记录最后使用时间和IP
+func (s *APIKeyService) Authenticate(rawKey, clientIP string) (*Principal, error) {
+	// 前缀为 stmp_<8位十六进制>，用于定位密钥记录
+	rest := strings.TrimPrefix(rawKey, APIKeyPrefix)
+	idx := strings.Index(rest, "_")
+	if !IsAPIKey(rawKey) || idx <= 0 {
+		return nil, ErrUnauthorized
+	}
+	prefix := APIKeyPrefix + rest[:idx]
+
+	db := database.GetDB()
+	var key models.APIKey
+	if err := db.Where("prefix = ?", prefix).First(&key).Error; err != nil {
+		return nil, ErrUnauthorized
+	}
+	if subtle.ConstantTimeCompare([]byte(key.SecretHash), []byte(hashAPIKey(rawKey))) != 1 || !key.IsActive() {
+		utils.Warnf("API密钥校验失败: Prefix=%s", prefix)
+		return nil, ErrUnauthorized
+	}
+
+	var user models.User
+	if err := db.First(&user, key.UserID).Error; err != nil || user.Disabled {
+		return nil, ErrUnauthorized
+	}
+
+	// 记录使用情况，不更新updated_at
+	now := time.Now()
+	if err := db.Model(&key).UpdateColumns(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": clientIP,
+	}).Error; err != nil {
+		utils.Warnf("记录API密钥使用时间失败 (ID: %d): %v", key.ID, err)
+	}
+
+	scopes := make([]Permission, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = Permission(scope)
+	}
+
+	return &Principal{
+		UserID:        user.ID,
+		Username:      user.Username,
+		Role:          user.Role,
+		SystemRole:    user.Role,
+		WorkspaceID:   key.WorkspaceID,
+		APIKeyID:      key.ID,
+		Scopes:        scopes,
+		SMTPConfigIDs: key.SMTPConfigIDs,
+	}, nil
+}
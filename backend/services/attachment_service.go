@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUploadTooLarge 上传文件超过 upload.max_size
+	ErrUploadTooLarge = errors.New("文件大小超过上限")
+	// ErrUploadTypeNotAllowed 上传文件类型不在 upload.allowed_types 中
+	ErrUploadTypeNotAllowed = errors.New("不允许上传该类型的文件")
+)
+
+// AttachmentService 附件上传服务
+// 文件以流的方式写入上传目录，按SHA-256内容寻址，相同内容只保存一份
+type AttachmentService struct {
+	cfg          config.UploadConfig
+	auditService *AuditService
+}
+
+// NewAttachmentService 创建附件服务实例
+func NewAttachmentService() *AttachmentService {
+	return &AttachmentService{
+		cfg:          config.GetConfig().Upload,
+		auditService: NewAuditService(),
+	}
+}
+
+// Upload 保存上传的文件：限制大小、根据内容检测类型并校验允许的类型，计算校验和后写入上传目录
+func (s *AttachmentService) Upload(p *Principal, filename string, r io.Reader) (*models.UploadedFile, error) {
+	filename = filepath.Base(strings.ReplaceAll(filename, "\\", "/"))
+	if filename == "" || filename == "." || filename == "/" {
+		return nil, fmt.Errorf("文件名不能为空")
+	}
+
+	// 根据前512字节检测内容类型
+	reader := bufio.NewReaderSize(r, 512)
+	head, err := reader.Peek(512)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, fmt.Errorf("读取上传文件失败: %w", err)
+	}
+	contentType := http.DetectContentType(head)
+	if !s.typeAllowed(contentType) {
+		utils.Warnf("拒绝上传文件 %s: 类型 %s 不允许", filename, contentType)
+		return nil, fmt.Errorf("%w: %s", ErrUploadTypeNotAllowed, contentType)
+	}
+
+	if err := os.MkdirAll(s.cfg.UploadDir, 0700); err != nil {
+		return nil, fmt.Errorf("创建上传目录失败: %w", err)
+	}
+	tmp, err := os.CreateTemp(s.cfg.UploadDir, ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// 写入临时文件，同时计算校验和；多读1字节用于判断是否超过上限
+	hash := sha256.New()
+	var src io.Reader = reader
+	if s.cfg.MaxSize > 0 {
+		src = io.LimitReader(reader, s.cfg.MaxSize+1)
+	}
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("保存上传文件失败: %w", err)
+	}
+	if s.cfg.MaxSize > 0 && size > s.cfg.MaxSize {
+		return nil, fmt.Errorf("%w: 最大 %d 字节", ErrUploadTooLarge, s.cfg.MaxSize)
+	}
+
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	relPath := filepath.Join(checksum[:2], checksum)
+	fullPath := filepath.Join(s.cfg.UploadDir, relPath)
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0700); err != nil {
+			return nil, fmt.Errorf("创建上传目录失败: %w", err)
+		}
+		if err := os.Rename(tmp.Name(), fullPath); err != nil {
+			return nil, fmt.Errorf("保存上传文件失败: %w", err)
+		}
+	}
+
+	file := &models.UploadedFile{
+		WorkspaceID: p.WorkspaceID,
+		UserID:      p.UserID,
+		Filename:    filename,
+		ContentType: contentType,
+		Size:        size,
+		Checksum:    checksum,
+		Path:        relPath,
+	}
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityUploadedFile, file.ID, nil, file)
+	})
+	if err != nil {
+		utils.Errorf("保存上传文件记录失败: %v", err)
+		return nil, fmt.Errorf("保存上传文件记录失败: %w", err)
+	}
+
+	utils.Infof("上传附件成功: ID=%d, Filename=%s, ContentType=%s, Size=%d", file.ID, filename, contentType, size)
+	return file, nil
+}
+
+// typeAllowed 检查内容类型是否在允许列表中，未配置时允许所有类型
+func (s *AttachmentService) typeAllowed(contentType string) bool {
+	if len(s.cfg.AllowedTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range s.cfg.AllowedTypes {
+		if strings.EqualFold(allowed, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// ListFiles 获取当前用户可见的上传文件
+func (s *AttachmentService) ListFiles(p *Principal) ([]models.UploadedFile, error) {
+	var files []models.UploadedFile
+	if err := p.ScopeHistory(database.GetDB()).Order("id DESC").Find(&files).Error; err != nil {
+		utils.Errorf("获取上传文件列表失败: %v", err)
+		return nil, fmt.Errorf("获取上传文件列表失败: %w", err)
+	}
+	return files, nil
+}
+
+// GetFile 获取上传文件记录
+func (s *AttachmentService) GetFile(p *Principal, id uint) (*models.UploadedFile, error) {
+	var file models.UploadedFile
+	if err := p.ScopeHistory(database.GetDB()).First(&file, id).Error; err != nil {
+		return nil, fmt.Errorf("附件不存在: %w", err)
+	}
+	return &file, nil
+}
+
+// Open 打开上传文件的内容
+func (s *AttachmentService) Open(file *models.UploadedFile) (*os.File, error) {
+	f, err := os.Open(filepath.Join(s.cfg.UploadDir, file.Path))
+	if err != nil {
+		return nil, fmt.Errorf("打开附件文件失败: %w", err)
+	}
+	return f, nil
+}
+
+// DeleteFile 删除上传文件记录，没有其他记录引用相同内容时同时删除磁盘文件
+func (s *AttachmentService) DeleteFile(p *Principal, id uint) error {
+	file, err := s.GetFile(p, id)
+	if err != nil {
+		return err
+	}
+
+	var remaining int64
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(file).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.UploadedFile{}).Where("checksum = ?", file.Checksum).Count(&remaining).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityUploadedFile, id, file, nil)
+	})
+	if err != nil {
+		utils.Errorf("删除上传文件失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("删除上传文件失败: %w", err)
+	}
+
+	if remaining == 0 {
+		if err := os.Remove(filepath.Join(s.cfg.UploadDir, file.Path)); err != nil && !os.IsNotExist(err) {
+			utils.Warnf("删除附件文件失败 (%s): %v", file.Path, err)
+		}
+	}
+	return nil
+}
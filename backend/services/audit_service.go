@@ -0,0 +1,242 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// redactedValue 敏感字段脱敏后的占位值
+const redactedValue = "******"
+
+// auditSecretFields 需要脱敏的字段（按JSON字段名）
+var auditSecretFields = map[string]bool{
+	"password":      true,
+	"password_hash": true,
+	"secret_hash":   true,
+	"secret":        true,
+}
+
+// auditIgnoredFields 不记录变更的字段
+var auditIgnoredFields = map[string]bool{
+	"created_at":  true,
+	"updated_at":  true,
+	"smtp_config": true,
+}
+
+// AuditService 审计服务
+type AuditService struct{}
+
+// NewAuditService 创建审计服务实例
+func NewAuditService() *AuditService {
+	return &AuditService{}
+}
+
+// AuditFilter 审计事件筛选条件
+type AuditFilter struct {
+	ActorID    uint      `form:"actor_id"`
+	Action     string    `form:"action"`
+	EntityType string    `form:"entity_type"`
+	EntityID   uint      `form:"entity_id"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+// AuditListResponse 审计事件列表响应
+type AuditListResponse struct {
+	List     []models.AuditEvent `json:"list"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"pageSize"`
+}
+
+// Record 在给定事务中记录一条审计事件
+// before/after 为变更前后的实体（创建时before为nil，删除时after为nil）
+func (s *AuditService) Record(tx *gorm.DB, p *Principal, action, entityType string, entityID uint, before, after interface{}) error {
+	changes, err := diffEntities(before, after)
+	if err != nil {
+		return fmt.Errorf("计算审计差异失败: %w", err)
+	}
+
+	event := models.AuditEvent{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Changes:    changes,
+	}
+	if p != nil {
+		// 用户管理为系统级操作，不归属任何工作区
+		if entityType != models.AuditEntityUser {
+			event.WorkspaceID = p.WorkspaceID
+		}
+		event.ActorID = p.UserID
+		event.ActorName = p.Username
+		event.ClientIP = p.ClientIP
+		if p.IsAPIKey() {
+			id := p.APIKeyID
+			event.APIKeyID = &id
+		}
+	}
+
+	if err := tx.Create(&event).Error; err != nil {
+		utils.Errorf("记录审计事件失败: %v", err)
+		return fmt.Errorf("记录审计事件失败: %w", err)
+	}
+	return nil
+}
+
+// ListEvents 分页查询当前工作区的审计事件
+func (s *AuditService) ListEvents(p *Principal, filter *AuditFilter, page, pageSize int) (*AuditListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	query := s.filterQuery(p, filter)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		utils.Errorf("获取审计事件总数失败: %v", err)
+		return nil, fmt.Errorf("获取审计事件总数失败: %w", err)
+	}
+
+	var events []models.AuditEvent
+	offset := (page - 1) * pageSize
+	if err := query.Order("created_at DESC, id DESC").Offset(offset).Limit(pageSize).Find(&events).Error; err != nil {
+		utils.Errorf("获取审计事件列表失败: %v", err)
+		return nil, fmt.Errorf("获取审计事件列表失败: %w", err)
+	}
+
+	return &AuditListResponse{
+		List:     events,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// ExportEvents 导出当前工作区符合条件的全部审计事件（按时间正序）
+func (s *AuditService) ExportEvents(p *Principal, filter *AuditFilter) ([]models.AuditEvent, error) {
+	var events []models.AuditEvent
+	if err := s.filterQuery(p, filter).Order("created_at ASC, id ASC").Find(&events).Error; err != nil {
+		utils.Errorf("导出审计事件失败: %v", err)
+		return nil, fmt.Errorf("导出审计事件失败: %w", err)
+	}
+
+	utils.Infof("导出审计事件成功，共 %d 条", len(events))
+	return events, nil
+}
+
+// filterQuery 根据筛选条件构建查询，系统管理员还可以看到系统级事件
+func (s *AuditService) filterQuery(p *Principal, filter *AuditFilter) *gorm.DB {
+	query := database.GetDB().Model(&models.AuditEvent{})
+	if p.IsSystemAdmin() {
+		query = query.Where("workspace_id IN ?", []uint{p.WorkspaceID, 0})
+	} else {
+		query = p.ScopeWorkspace(query)
+	}
+	if filter == nil {
+		return query
+	}
+	if filter.ActorID > 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.EntityType != "" {
+		query = query.Where("entity_type = ?", filter.EntityType)
+	}
+	if filter.EntityID > 0 {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at <= ?", filter.To)
+	}
+	return query
+}
+
+// diffEntities 比较两个实体的JSON表示，返回发生变化的字段（敏感字段脱敏）
+func diffEntities(before, after interface{}) (models.AuditChanges, error) {
+	beforeFields, err := entityFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := entityFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := models.AuditChanges{}
+	keys := map[string]bool{}
+	for k := range beforeFields {
+		keys[k] = true
+	}
+	for k := range afterFields {
+		keys[k] = true
+	}
+
+	for key := range keys {
+		if auditIgnoredFields[key] {
+			continue
+		}
+		oldValue, newValue := beforeFields[key], afterFields[key]
+		if reflect.DeepEqual(oldValue, newValue) {
+			continue
+		}
+		if auditSecretFields[key] {
+			oldValue, newValue = redact(oldValue), redact(newValue)
+		}
+		changes[key] = models.FieldChange{Before: oldValue, After: newValue}
+	}
+	return changes, nil
+}
+
+// entityFields 将实体转换为字段映射
+// 模型中以 json:"-" 隐藏的敏感字段（如密码哈希、Webhook签名密钥、退信邮箱密码）通过数据库列名补充，记录时脱敏
+func entityFields(entity interface{}) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if entity == nil || reflect.ValueOf(entity).IsNil() {
+		return fields, nil
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	switch v := entity.(type) {
+	case *models.User:
+		fields["password_hash"] = v.PasswordHash
+	case *models.APIKey:
+		fields["secret_hash"] = v.SecretHash
+	case *models.Webhook:
+		fields["secret"] = v.Secret
+	case *models.BounceMailbox:
+		fields["password"] = v.Password
+	}
+	return fields, nil
+}
+
+// redact 脱敏敏感字段，仅保留是否为空的信息
+func redact(value interface{}) interface{} {
+	if value == nil || value == "" {
+		return value
+	}
+	return redactedValue
+}
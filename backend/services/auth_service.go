@@ -0,0 +1,385 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCredentials 用户名或密码错误
+var ErrInvalidCredentials = errors.New("用户名或密码错误")
+
+// processJWTSecret 未配置JWT密钥时使用的进程级随机密钥
+var processJWTSecret []byte
+
+// AuthService 认证服务
+type AuthService struct {
+	cryptoService *CryptoService
+	auditService  *AuditService
+	jwtSecret     []byte
+	expireHours   int
+}
+
+// NewAuthService 创建认证服务实例
+func NewAuthService() *AuthService {
+	cfg := config.GetConfig()
+
+	expireHours := cfg.Security.JWTExpireHours
+	if expireHours <= 0 {
+		expireHours = 24
+	}
+
+	return &AuthService{
+		cryptoService: NewCryptoService(),
+		auditService:  NewAuditService(),
+		jwtSecret:     jwtSecret(cfg.Security.JWTSecret),
+		expireHours:   expireHours,
+	}
+}
+
+// jwtSecret 获取JWT签名密钥，未配置时生成进程级随机密钥（重启后令牌失效）
+func jwtSecret(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	if processJWTSecret == nil {
+		processJWTSecret = make([]byte, 32)
+		if _, err := rand.Read(processJWTSecret); err != nil {
+			panic(fmt.Sprintf("生成JWT密钥失败: %v", err))
+		}
+		utils.Warnf("未配置 security.jwt_secret，使用随机密钥，服务重启后需重新登录")
+	}
+	return processJWTSecret
+}
+
+// LoginRequest 登录请求
+type LoginRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// LoginResponse 登录响应
+type LoginResponse struct {
+	Token     string      `json:"token"`
+	ExpiresAt time.Time   `json:"expires_at"`
+	User      models.User `json:"user"`
+}
+
+// UserRequest 创建/更新用户请求
+type UserRequest struct {
+	Username string      `json:"username"`
+	Password string      `json:"password"`
+	Role     models.Role `json:"role"`
+	Disabled bool        `json:"disabled"`
+}
+
+// tokenClaims JWT声明
+type tokenClaims struct {
+	Username string      `json:"username"`
+	Role     models.Role `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// Login 用户名密码登录，返回JWT令牌
+func (s *AuthService) Login(req *LoginRequest) (*LoginResponse, error) {
+	user, err := s.checkCredentials(req.Username, req.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(time.Duration(s.expireHours) * time.Hour)
+	claims := tokenClaims{
+		Username: user.Username,
+		Role:     user.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.jwtSecret)
+	if err != nil {
+		utils.Errorf("签发令牌失败: %v", err)
+		return nil, fmt.Errorf("签发令牌失败: %w", err)
+	}
+
+	utils.Infof("用户登录成功: ID=%d, Username=%s", user.ID, user.Username)
+	return &LoginResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      *user,
+	}, nil
+}
+
+// checkCredentials 校验用户名和密码，禁用的用户视为校验失败
+func (s *AuthService) checkCredentials(username, password string) (*models.User, error) {
+	var user models.User
+	if err := database.GetDB().Where("username = ?", username).First(&user).Error; err != nil {
+		utils.Warnf("登录失败，用户不存在: %s", username)
+		return nil, ErrInvalidCredentials
+	}
+
+	if user.Disabled || !s.cryptoService.CheckPassword(password, user.PasswordHash) {
+		utils.Warnf("登录失败，密码错误或用户已禁用: %s", username)
+		return nil, ErrInvalidCredentials
+	}
+	return &user, nil
+}
+
+// AuthenticatePassword 使用用户名和密码认证并返回操作者（用于SMTP提交服务等不使用令牌的场景）
+func (s *AuthService) AuthenticatePassword(username, password string) (*Principal, error) {
+	user, err := s.checkCredentials(username, password)
+	if err != nil {
+		return nil, err
+	}
+	return &Principal{
+		UserID:     user.ID,
+		Username:   user.Username,
+		Role:       user.Role,
+		SystemRole: user.Role,
+	}, nil
+}
+
+// Authenticate 校验JWT令牌并返回操作者
+// 角色以数据库中的当前值为准，禁用或删除的用户立即失效
+func (s *AuthService) Authenticate(tokenString string) (*Principal, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, func(token *jwt.Token) (interface{}, error) {
+		return s.jwtSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	userID, err := strconv.ParseUint(claims.Subject, 10, 32)
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	var user models.User
+	if err := database.GetDB().First(&user, userID).Error; err != nil || user.Disabled {
+		return nil, ErrUnauthorized
+	}
+
+	return &Principal{
+		UserID:     user.ID,
+		Username:   user.Username,
+		Role:       user.Role,
+		SystemRole: user.Role,
+	}, nil
+}
+
+// EnsureInitialAdmin 没有任何用户时创建初始管理员
+func (s *AuthService) EnsureInitialAdmin() error {
+	db := database.GetDB()
+
+	var count int64
+	if err := db.Model(&models.User{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("统计用户数量失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	cfg := config.GetConfig()
+	username := cfg.Security.AdminUsername
+	if username == "" {
+		username = "admin"
+	}
+	password := cfg.Security.AdminPassword
+	generated := password == ""
+	if generated {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return fmt.Errorf("生成管理员密码失败: %w", err)
+		}
+		password = hex.EncodeToString(buf)
+	}
+
+	if _, err := s.CreateUser(nil, &UserRequest{Username: username, Password: password, Role: models.RoleAdmin}); err != nil {
+		return err
+	}
+
+	if generated {
+		utils.Warnf("已创建初始管理员: Username=%s, Password=%s，请登录后尽快修改密码", username, password)
+	} else {
+		utils.Infof("已创建初始管理员: Username=%s", username)
+	}
+	return nil
+}
+
+// ListUsers 获取所有用户
+func (s *AuthService) ListUsers() ([]models.User, error) {
+	var users []models.User
+	if err := database.GetDB().Order("id").Find(&users).Error; err != nil {
+		utils.Errorf("获取用户列表失败: %v", err)
+		return nil, fmt.Errorf("获取用户列表失败: %w", err)
+	}
+	return users, nil
+}
+
+// CreateUser 创建用户，p为nil表示系统操作
+func (s *AuthService) CreateUser(p *Principal, req *UserRequest) (*models.User, error) {
+	if req.Username == "" {
+		return nil, errors.New("用户名不能为空")
+	}
+	if req.Password == "" {
+		return nil, errors.New("密码不能为空")
+	}
+	if req.Role == "" {
+		req.Role = models.RoleViewer
+	}
+	if !req.Role.IsValid() {
+		return nil, errors.New("无效的用户角色")
+	}
+
+	hash, err := s.cryptoService.HashPassword(req.Password)
+	if err != nil {
+		return nil, fmt.Errorf("加密密码失败: %w", err)
+	}
+
+	user := &models.User{
+		Username:     req.Username,
+		PasswordHash: hash,
+		Role:         req.Role,
+		Disabled:     req.Disabled,
+	}
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		// 新用户以相同角色加入创建者当前所在的工作区
+		if p != nil && p.WorkspaceID != 0 {
+			member := models.WorkspaceMember{WorkspaceID: p.WorkspaceID, UserID: user.ID, Role: user.Role}
+			if err := tx.Create(&member).Error; err != nil {
+				return err
+			}
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityUser, user.ID, nil, user)
+	})
+	if err != nil {
+		utils.Errorf("创建用户失败: %v", err)
+		return nil, fmt.Errorf("创建用户失败: %w", err)
+	}
+
+	utils.Infof("创建用户成功: ID=%d, Username=%s, Role=%s", user.ID, user.Username, user.Role)
+	return user, nil
+}
+
+// UpdateUser 更新用户角色、状态或密码
+func (s *AuthService) UpdateUser(p *Principal, id uint, req *UserRequest) (*models.User, error) {
+	db := database.GetDB()
+
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		utils.Errorf("用户不存在 (ID: %d): %v", id, err)
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"disabled": req.Disabled,
+	}
+	if req.Role != "" {
+		if !req.Role.IsValid() {
+			return nil, errors.New("无效的用户角色")
+		}
+		updates["role"] = req.Role
+	}
+	if req.Password != "" {
+		hash, err := s.cryptoService.HashPassword(req.Password)
+		if err != nil {
+			return nil, fmt.Errorf("加密密码失败: %w", err)
+		}
+		updates["password_hash"] = hash
+	}
+
+	before := user
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+
+		var after models.User
+		if err := tx.First(&after, id).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityUser, id, &before, &after)
+	})
+	if err != nil {
+		utils.Errorf("更新用户失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("更新用户失败: %w", err)
+	}
+
+	utils.Infof("更新用户成功: ID=%d", id)
+	return s.GetUserByID(id)
+}
+
+// DeleteUser 删除用户（不能删除自己）
+func (s *AuthService) DeleteUser(p *Principal, id uint) error {
+	if p != nil && p.UserID == id {
+		return errors.New("不能删除当前登录的用户")
+	}
+
+	db := database.GetDB()
+	var user models.User
+	if err := db.First(&user, id).Error; err != nil {
+		utils.Errorf("用户不存在 (ID: %d): %v", id, err)
+		return err
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.WorkspaceMember{}).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityUser, id, &user, nil)
+	})
+	if err != nil {
+		utils.Errorf("删除用户失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("删除用户失败: %w", err)
+	}
+
+	utils.Infof("删除用户成功: ID=%d, Username=%s", id, user.Username)
+	return nil
+}
+
+// GetUserByID 获取单个用户
+func (s *AuthService) GetUserByID(id uint) (*models.User, error) {
+	var user models.User
+	if err := database.GetDB().First(&user, id).Error; err != nil {
+		return nil, fmt.Errorf("获取用户失败: %w", err)
+	}
+	return &user, nil
+}
+
+// PrincipalForUser 以指定用户身份创建操作者，用于命令行等本地操作（不校验密码）
+func (s *AuthService) PrincipalForUser(username string) (*Principal, error) {
+	var user models.User
+	if err := database.GetDB().Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("用户不存在: %s", username)
+	}
+	if user.Disabled {
+		return nil, fmt.Errorf("用户已禁用: %s", username)
+	}
+
+	return &Principal{
+		UserID:     user.ID,
+		Username:   user.Username,
+		Role:       user.Role,
+		SystemRole: user.Role,
+	}, nil
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"errors"
+
+	"smtp-mail/backend/models"
+
+	"gorm.io/gorm"
+)
+
+// 授权错误定义
+var (
+	ErrUnauthorized = errors.New("未登录或登录已过期")
+	ErrForbidden    = errors.New("无权执行此操作")
+)
+
+// Permission 权限标识
+type Permission string
+
+const (
+	PermSMTPRead       Permission = "smtp:read"
+	PermSMTPWrite      Permission = "smtp:write"
+	PermSMTPSetDefault Permission = "smtp:set_default"
+	PermTemplateRead   Permission = "template:read"
+	PermTemplateWrite  Permission = "template:write"
+	PermEmailSend      Permission = "email:send"
+	PermHistoryRead    Permission = "history:read"
+	PermHistoryDelete  Permission = "history:delete"
+	PermUserManage     Permission = "user:manage"
+	PermAPIKeyManage   Permission = "apikey:manage"
+	PermAuditRead      Permission = "audit:read"
+	PermContactRead    Permission = "contact:read"
+	PermContactWrite   Permission = "contact:write"
+	PermCampaignRead   Permission = "campaign:read"
+	PermCampaignWrite  Permission = "campaign:write"
+
+	PermWorkspaceManage Permission = "workspace:manage" // 管理当前工作区的设置、成员和邀请
+	PermWorkspaceCreate Permission = "workspace:create" // 创建工作区（系统级）
+	PermBackupManage    Permission = "backup:manage"    // 备份数据库（系统级）
+)
+
+// systemPermissions 系统级权限，按用户的全局角色判断，其余权限按工作区内角色判断
+var systemPermissions = map[Permission]bool{
+	PermUserManage:      true,
+	PermWorkspaceCreate: true,
+	PermBackupManage:    true,
+}
+
+// APIKeyScopes 可以授予API密钥的权限范围
+var APIKeyScopes = []Permission{
+	PermEmailSend,
+	PermHistoryRead,
+	PermSMTPRead,
+	PermTemplateRead,
+	PermContactRead,
+	PermContactWrite,
+	PermCampaignRead,
+}
+
+// rolePermissions 角色与权限的对应关系
+var rolePermissions = map[models.Role][]Permission{
+	models.RoleAdmin: {
+		PermSMTPRead, PermSMTPWrite, PermSMTPSetDefault,
+		PermTemplateRead, PermTemplateWrite,
+		PermContactRead, PermContactWrite,
+		PermCampaignRead, PermCampaignWrite,
+		PermEmailSend,
+		PermHistoryRead, PermHistoryDelete,
+		PermUserManage,
+		PermAPIKeyManage,
+		PermAuditRead,
+		PermWorkspaceManage, PermWorkspaceCreate,
+		PermBackupManage,
+	},
+	models.RoleSender: {
+		PermSMTPRead, PermSMTPWrite,
+		PermTemplateRead, PermTemplateWrite,
+		PermContactRead, PermContactWrite,
+		PermCampaignRead, PermCampaignWrite,
+		PermEmailSend,
+		PermHistoryRead, PermHistoryDelete,
+		PermAPIKeyManage,
+	},
+	models.RoleViewer: {
+		PermSMTPRead,
+		PermTemplateRead,
+		PermContactRead,
+		PermCampaignRead,
+		PermHistoryRead,
+		PermAPIKeyManage,
+	},
+}
+
+// Principal 当前请求的操作者
+// Role为用户在当前工作区内的角色，SystemRole为用户的全局角色（系统管理员在所有工作区内都是管理员）
+// 通过API密钥认证时，权限为用户角色权限与密钥授权范围的交集
+type Principal struct {
+	UserID        uint         `json:"user_id"`
+	Username      string       `json:"username"`
+	Role          models.Role  `json:"role"`
+	SystemRole    models.Role  `json:"system_role"`
+	WorkspaceID   uint         `json:"workspace_id"`
+	APIKeyID      uint         `json:"api_key_id,omitempty"`
+	Scopes        []Permission `json:"scopes,omitempty"`
+	SMTPConfigIDs []uint       `json:"smtp_config_ids,omitempty"`
+	ClientIP      string       `json:"-"` // 请求来源IP，用于审计
+}
+
+// IsAdmin 是否为当前工作区的管理员
+func (p *Principal) IsAdmin() bool {
+	return p != nil && p.Role == models.RoleAdmin
+}
+
+// IsSystemAdmin 是否为系统管理员
+func (p *Principal) IsSystemAdmin() bool {
+	return p != nil && p.SystemRole == models.RoleAdmin
+}
+
+// InWorkspace 返回切换到指定工作区的副本，角色为该工作区内的角色
+func (p *Principal) InWorkspace(workspaceID uint, role models.Role) *Principal {
+	if p == nil {
+		return nil
+	}
+	copied := *p
+	copied.WorkspaceID = workspaceID
+	copied.Role = role
+	if p.IsSystemAdmin() {
+		copied.Role = models.RoleAdmin
+	}
+	return &copied
+}
+
+// IsAPIKey 是否通过API密钥认证
+func (p *Principal) IsAPIKey() bool {
+	return p != nil && p.APIKeyID != 0
+}
+
+// Can 检查是否拥有指定权限
+func (p *Principal) Can(perm Permission) bool {
+	if p == nil {
+		return false
+	}
+	role := p.Role
+	if systemPermissions[perm] {
+		role = p.SystemRole
+	}
+	if !hasPermission(rolePermissions[role], perm) {
+		return false
+	}
+	return !p.IsAPIKey() || hasPermission(p.Scopes, perm)
+}
+
+// hasPermission 检查权限列表中是否包含指定权限
+func hasPermission(perms []Permission, perm Permission) bool {
+	for _, granted := range perms {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorize 检查权限，无权限时返回ErrForbidden
+func (p *Principal) Authorize(perm Permission) error {
+	if !p.Can(perm) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// CanModify 检查是否可以修改指定所有者的资源（管理员或所有者本人）
+func (p *Principal) CanModify(ownerID uint) bool {
+	if p == nil {
+		return false
+	}
+	return p.IsAdmin() || (ownerID != 0 && ownerID == p.UserID)
+}
+
+// AuthorizeModify 检查资源修改权限，无权限时返回ErrForbidden
+func (p *Principal) AuthorizeModify(ownerID uint) error {
+	if !p.CanModify(ownerID) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// ScopeWorkspace 将查询限制为当前工作区的数据
+// 适用于带有workspace_id字段的模型
+func (p *Principal) ScopeWorkspace(db *gorm.DB) *gorm.DB {
+	var workspaceID uint
+	if p != nil {
+		workspaceID = p.WorkspaceID
+	}
+	return db.Where("workspace_id = ?", workspaceID)
+}
+
+// ScopeOwned 将查询限制为当前工作区内可见的资源：自己创建的、共享的以及启用认证前创建的
+// 适用于带有owner_id和shared字段的模型（SMTP配置、邮件模板）
+func (p *Principal) ScopeOwned(db *gorm.DB) *gorm.DB {
+	db = p.ScopeWorkspace(db)
+	if p.IsAdmin() {
+		return db
+	}
+	var userID uint
+	if p != nil {
+		userID = p.UserID
+	}
+	return db.Where("(owner_id = ? OR shared = ? OR owner_id = ?)", userID, true, 0)
+}
+
+// CanUseSMTPConfig 检查是否允许使用指定SMTP配置（API密钥可限制可用配置）
+func (p *Principal) CanUseSMTPConfig(id uint) bool {
+	if p == nil {
+		return false
+	}
+	if len(p.SMTPConfigIDs) == 0 {
+		return true
+	}
+	return models.UintSlice(p.SMTPConfigIDs).Contains(id)
+}
+
+// ScopeSMTPConfigs 将SMTP配置查询限制为可见且允许使用的配置
+func (p *Principal) ScopeSMTPConfigs(db *gorm.DB) *gorm.DB {
+	db = p.ScopeOwned(db)
+	if p != nil && len(p.SMTPConfigIDs) > 0 {
+		db = db.Where("id IN ?", p.SMTPConfigIDs)
+	}
+	return db
+}
+
+// ScopeHistory 将查询限制为当前工作区内自己发送的历史记录，管理员可查看工作区内全部记录
+func (p *Principal) ScopeHistory(db *gorm.DB) *gorm.DB {
+	db = p.ScopeWorkspace(db)
+	if p.IsAdmin() {
+		return db
+	}
+	var userID uint
+	if p != nil {
+		userID = p.UserID
+	}
+	return db.Where("user_id = ?", userID)
+}
@@ -0,0 +1,574 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// 备份错误定义
+var (
+	ErrBackupUnsupported = errors.New("仅SQLite数据库支持内置备份，PostgreSQL/MySQL请使用 pg_dump/mysqldump")
+	ErrBackupNotFound    = errors.New("备份文件不存在")
+	ErrInvalidBackup     = errors.New("备份文件无效或已损坏")
+)
+
+// 备份文件命名：smtp-mail-<时间>.db[.gz][.enc]
+const (
+	backupPrefix     = "smtp-mail-"
+	backupTimeFormat = "20060102-150405"
+	backupExtGzip    = ".gz"
+	backupExtEncrypt = ".enc"
+)
+
+// encryptedBackupMagic 加密备份文件头
+var encryptedBackupMagic = []byte("SMBKENC1")
+
+// backupChunkSize 加密分块大小
+const backupChunkSize = 64 * 1024
+
+// BackupService 数据库备份服务
+type BackupService struct {
+	cfg          *config.BackupConfig
+	auditService *AuditService
+}
+
+// NewBackupService 创建备份服务实例
+func NewBackupService() *BackupService {
+	return &BackupService{
+		cfg:          &config.GetConfig().Backup,
+		auditService: NewAuditService(),
+	}
+}
+
+// BackupInfo 备份文件信息
+type BackupInfo struct {
+	Name          string    `json:"name"`
+	Size          int64     `json:"size"`
+	Compressed    bool      `json:"compressed"`
+	Encrypted     bool      `json:"encrypted"`
+	SchemaVersion int       `json:"schema_version,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// checkDriver 检查当前数据库是否支持内置备份
+func checkDriver() error {
+	driver := config.GetConfig().Database.Driver
+	if driver != "" && driver != database.DriverSQLite {
+		return ErrBackupUnsupported
+	}
+	return nil
+}
+
+// CreateBackup 在服务运行期间使用 VACUUM INTO 生成一致性快照，按配置压缩和加密
+// p为nil表示定时任务或命令行
+func (s *BackupService) CreateBackup(p *Principal) (*BackupInfo, error) {
+	if p != nil {
+		if err := p.Authorize(PermBackupManage); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkDriver(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.cfg.Dir, 0700); err != nil {
+		return nil, fmt.Errorf("创建备份目录失败: %w", err)
+	}
+
+	db := database.GetDB()
+	version, err := database.SchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	name := backupPrefix + now.Format(backupTimeFormat) + ".db"
+	snapshot := filepath.Join(s.cfg.Dir, "."+name+".tmp")
+	defer os.Remove(snapshot)
+
+	if err := db.Exec("VACUUM INTO ?", snapshot).Error; err != nil {
+		utils.Errorf("生成数据库快照失败: %v", err)
+		return nil, fmt.Errorf("生成数据库快照失败: %w", err)
+	}
+
+	if s.cfg.Compress {
+		name += backupExtGzip
+	}
+	if s.cfg.Encrypt {
+		name += backupExtEncrypt
+	}
+	target := filepath.Join(s.cfg.Dir, name)
+	if err := s.writeBackup(snapshot, target); err != nil {
+		os.Remove(target)
+		utils.Errorf("写入备份文件失败: %v", err)
+		return nil, fmt.Errorf("写入备份文件失败: %w", err)
+	}
+
+	info, err := s.backupInfo(name)
+	if err != nil {
+		return nil, err
+	}
+	info.SchemaVersion = version
+
+	if err := s.auditService.Record(db, p, models.AuditActionCreate, models.AuditEntityBackup, 0, nil, info); err != nil {
+		return nil, err
+	}
+
+	utils.Infof("创建数据库备份成功: %s (%d 字节, 结构版本 %d)", name, info.Size, version)
+	if err := s.Prune(); err != nil {
+		utils.Warnf("清理旧备份失败: %v", err)
+	}
+	return info, nil
+}
+
+// writeBackup 将快照按配置压缩、加密后写入目标文件
+func (s *BackupService) writeBackup(snapshot, target string) error {
+	src, err := os.Open(snapshot)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// 写入链：快照 -> gzip -> 加密 -> 文件
+	var w io.WriteCloser = nopWriteCloser{out}
+	if s.cfg.Encrypt {
+		keyring, err := LoadKeyring()
+		if err != nil {
+			return err
+		}
+		if w, err = newEncryptWriter(w, keyring); err != nil {
+			return err
+		}
+	}
+	encrypted := w
+	if s.cfg.Compress {
+		w = gzip.NewWriter(encrypted)
+	}
+
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	if s.cfg.Compress {
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+	if err := encrypted.Close(); err != nil {
+		return err
+	}
+	return out.Sync()
+}
+
+// ListBackups 列出备份文件（按时间倒序）
+func (s *BackupService) ListBackups(p *Principal) ([]BackupInfo, error) {
+	if err := p.Authorize(PermBackupManage); err != nil {
+		return nil, err
+	}
+	return s.listBackups()
+}
+
+// listBackups 读取备份目录
+func (s *BackupService) listBackups() ([]BackupInfo, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []BackupInfo{}, nil
+		}
+		return nil, fmt.Errorf("读取备份目录失败: %w", err)
+	}
+
+	backups := []BackupInfo{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), backupPrefix) {
+			continue
+		}
+		info, err := s.backupInfo(entry.Name())
+		if err != nil {
+			continue
+		}
+		backups = append(backups, *info)
+	}
+	// 文件名包含时间，按名称倒序即按时间倒序
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// backupInfo 获取单个备份文件信息
+func (s *BackupService) backupInfo(name string) (*BackupInfo, error) {
+	stat, err := os.Stat(filepath.Join(s.cfg.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+	return &BackupInfo{
+		Name:       name,
+		Size:       stat.Size(),
+		Compressed: strings.Contains(name, ".db"+backupExtGzip),
+		Encrypted:  strings.HasSuffix(name, backupExtEncrypt),
+		CreatedAt:  stat.ModTime(),
+	}, nil
+}
+
+// BackupPath 获取备份文件路径（用于下载），名称只能是备份目录中的文件
+func (s *BackupService) BackupPath(p *Principal, name string) (string, error) {
+	if err := p.Authorize(PermBackupManage); err != nil {
+		return "", err
+	}
+	if name != filepath.Base(name) || !strings.HasPrefix(name, backupPrefix) {
+		return "", ErrBackupNotFound
+	}
+	path := filepath.Join(s.cfg.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrBackupNotFound
+	}
+	return path, nil
+}
+
+// Prune 只保留最近的 keep 个备份
+func (s *BackupService) Prune() error {
+	if s.cfg.Keep <= 0 {
+		return nil
+	}
+	backups, err := s.listBackups()
+	if err != nil {
+		return err
+	}
+	for i := s.cfg.Keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(s.cfg.Dir, backups[i].Name)); err != nil {
+			return err
+		}
+		utils.Infof("已清理旧备份: %s", backups[i].Name)
+	}
+	return nil
+}
+
+// StartSchedule 按配置的间隔定时备份，ctx取消后停止
+func (s *BackupService) StartSchedule(ctx context.Context) {
+	if s.cfg.Interval <= 0 {
+		return
+	}
+	if err := checkDriver(); err != nil {
+		utils.Warnf("定时备份未启用: %v", err)
+		return
+	}
+
+	utils.Infof("定时备份已启用: 间隔=%s, 保留=%d", s.cfg.Interval, s.cfg.Keep)
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.CreateBackup(nil); err != nil {
+					utils.Errorf("定时备份失败: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Restore 从备份文件恢复数据库（仅用于命令行，需先停止服务）
+// 先解密解压到临时文件并校验结构版本，校验通过且数据库未被使用时替换当前数据库文件，原文件保留为 .before-restore-<时间>
+func (s *BackupService) Restore(path string) (int, error) {
+	if err := checkDriver(); err != nil {
+		return 0, err
+	}
+	dbPath, err := database.SQLiteFile(&config.GetConfig().Database)
+	if err != nil {
+		return 0, err
+	}
+	restored := dbPath + ".restore"
+	defer os.Remove(restored)
+
+	if err := extractBackup(path, restored); err != nil {
+		return 0, err
+	}
+
+	version, err := validateSnapshot(restored)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := database.Close(); err != nil {
+		return 0, fmt.Errorf("关闭当前数据库失败: %w", err)
+	}
+	// 服务仍在运行或残留 -wal/-shm 文件时替换数据库会导致数据损坏
+	if err := database.CheckNotInUse(dbPath); err != nil {
+		return 0, err
+	}
+	if _, err := os.Stat(dbPath); err == nil {
+		previous := dbPath + ".before-restore-" + time.Now().Format(backupTimeFormat)
+		if err := os.Rename(dbPath, previous); err != nil {
+			return 0, fmt.Errorf("保留当前数据库失败: %w", err)
+		}
+		utils.Infof("当前数据库已保留为: %s", previous)
+	}
+	if err := os.Rename(restored, dbPath); err != nil {
+		return 0, fmt.Errorf("替换数据库文件失败: %w", err)
+	}
+
+	utils.Infof("数据库已从备份恢复: %s (结构版本 %d)", path, version)
+	return version, nil
+}
+
+// extractBackup 根据文件头自动识别加密和压缩，还原为SQLite数据库文件
+func extractBackup(path, target string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("打开备份文件失败: %w", err)
+	}
+	defer in.Close()
+
+	var r io.Reader = bufio.NewReader(in)
+	if header, _ := r.(*bufio.Reader).Peek(len(encryptedBackupMagic)); bytes.Equal(header, encryptedBackupMagic) {
+		keyring, err := LoadKeyring()
+		if err != nil {
+			return err
+		}
+		if r, err = newDecryptReader(r, keyring); err != nil {
+			return err
+		}
+	}
+	buffered := bufio.NewReader(r)
+	r = buffered
+	if header, _ := buffered.Peek(2); len(header) == 2 && header[0] == 0x1f && header[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	if _, err := io.Copy(out, r); err != nil {
+		if errors.Is(err, ErrInvalidBackup) || errors.Is(err, ErrDecryptFailed) {
+			return err
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	return out.Sync()
+}
+
+// validateSnapshot 检查快照的结构版本不高于当前程序
+func validateSnapshot(path string) (int, error) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	if !db.Migrator().HasTable(&database.SchemaMigration{}) {
+		return 0, fmt.Errorf("%w: 缺少 schema_migrations 表", ErrInvalidBackup)
+	}
+	version, err := database.ValidateSchema(db)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// nopWriteCloser 为文件写入端提供空的Close，文件由调用方关闭
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// encryptWriter 分块AES-GCM加密写入器
+// 格式：文件头 | 密钥ID长度(1) | 密钥ID | nonce前缀(8) | 分块...
+// 每个分块为 标记(1) | 长度(4) | 密文，标记1表示最后一块，标记作为附加数据参与认证以防截断
+type encryptWriter struct {
+	w       io.WriteCloser
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+// newEncryptWriter 使用当前主密钥创建加密写入器
+func newEncryptWriter(w io.WriteCloser, keyring *Keyring) (*encryptWriter, error) {
+	keyID := keyring.ActiveKeyID()
+	key, err := keyring.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, gcm.NonceSize()-4)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, encryptedBackupMagic...)
+	header = append(header, byte(len(keyID)))
+	header = append(header, keyID...)
+	header = append(header, prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, gcm: gcm, prefix: prefix, buf: make([]byte, 0, backupChunkSize)}, nil
+}
+
+// nonce 第n个分块的nonce
+func chunkNonce(prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, len(prefix)+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(prefix):], counter)
+	return nonce
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := copy(e.buf[len(e.buf):cap(e.buf)], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+		if len(e.buf) == cap(e.buf) {
+			if err := e.flush(false); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush 加密并写出当前缓冲的分块
+func (e *encryptWriter) flush(final bool) error {
+	flag := byte(0)
+	if final {
+		flag = 1
+	}
+	sealed := e.gcm.Seal(nil, chunkNonce(e.prefix, e.counter), e.buf, []byte{flag})
+	e.counter++
+	e.buf = e.buf[:0]
+
+	header := make([]byte, 5)
+	header[0] = flag
+	binary.BigEndian.PutUint32(header[1:], uint32(len(sealed)))
+	if _, err := e.w.Write(header); err != nil {
+		return err
+	}
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+// Close 写出最后一块
+func (e *encryptWriter) Close() error {
+	if err := e.flush(true); err != nil {
+		return err
+	}
+	return e.w.Close()
+}
+
+// decryptReader 分块AES-GCM解密读取器
+type decryptReader struct {
+	r       io.Reader
+	gcm     cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	done    bool
+}
+
+// newDecryptReader 读取文件头并根据密钥ID选择密钥
+func newDecryptReader(r io.Reader, keyring *Keyring) (*decryptReader, error) {
+	header := make([]byte, len(encryptedBackupMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	keyID := make([]byte, header[len(header)-1])
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	key, err := keyring.key(string(keyID))
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, gcm.NonceSize()-4)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	return &decryptReader{r: r, gcm: gcm, prefix: prefix}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// next 读取并解密下一个分块
+func (d *decryptReader) next() error {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(d.r, header); err != nil {
+		return fmt.Errorf("%w: 备份文件不完整", ErrInvalidBackup)
+	}
+	// 分块长度来自文件，先检查上限再分配，损坏或伪造的文件不会导致分配大量内存
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > uint32(backupChunkSize+d.gcm.Overhead()) {
+		return fmt.Errorf("%w: 分块长度 %d 超过上限", ErrInvalidBackup, size)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return fmt.Errorf("%w: 备份文件不完整", ErrInvalidBackup)
+	}
+	plain, err := d.gcm.Open(nil, chunkNonce(d.prefix, d.counter), sealed, header[:1])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecryptFailed, err)
+	}
+	d.counter++
+	d.buf = plain
+	d.done = header[0] == 1
+	return nil
+}
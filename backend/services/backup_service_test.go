@@ -0,0 +1,75 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+
+	"smtp-mail/backend/config"
+)
+
+func TestBackupEncryption(t *testing.T) {
+	keyring, err := newKeyring(&config.SecurityConfig{MasterKey: testKey1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypt := func(content []byte) []byte {
+		var buf bytes.Buffer
+		w, err := newEncryptWriter(nopWriteCloser{&buf}, keyring)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	decrypt := func(encrypted []byte) ([]byte, error) {
+		r, err := newDecryptReader(bytes.NewReader(encrypted), keyring)
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+
+	content := bytes.Repeat([]byte("backup "), backupChunkSize/3)
+	plain, err := decrypt(encrypt(content))
+	if err != nil || !bytes.Equal(plain, content) {
+		t.Fatalf("解密结果 %d 字节 (%v)，want %d 字节", len(plain), err, len(content))
+	}
+
+	// 空内容只有最后一个分块；把它的长度改为超过上限，不分配内存直接拒绝
+	encrypted := encrypt(nil)
+	chunk := len(encrypted) - 5 - keyringOverhead(t, keyring)
+	binary.BigEndian.PutUint32(encrypted[chunk+1:], 0xFFFFFFF0)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = decrypt(encrypted)
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, ErrInvalidBackup) {
+		t.Errorf("超长分块: %v, want ErrInvalidBackup", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("拒绝超长分块前分配了 %d 字节", allocated)
+	}
+}
+
+// keyringOverhead 当前密钥的AES-GCM认证标签长度
+func keyringOverhead(t *testing.T, keyring *Keyring) int {
+	t.Helper()
+	key, err := keyring.key(keyring.ActiveKeyID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gcm.Overhead()
+}
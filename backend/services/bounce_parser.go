@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"smtp-mail/backend/models"
+)
+
+// verpTag VERP信封发件人中标识退信的标记：local+bounce-<token>@domain
+const verpTag = "+bounce-"
+
+// BounceRecipient 退信中的一个收件人
+type BounceRecipient struct {
+	Address    string `json:"address"`
+	Action     string `json:"action"` // failed、delayed
+	Status     string `json:"status"` // 如 5.1.1
+	Diagnostic string `json:"diagnostic"`
+	Type       string `json:"type"` // hard、soft
+}
+
+// BounceReport 解析出的退信
+type BounceReport struct {
+	MessageID  string            `json:"message_id"` // 原邮件的Message-ID
+	VERP       string            `json:"verp"`       // 从VERP地址还原的原邮件Message-ID
+	Recipients []BounceRecipient `json:"recipients"`
+}
+
+var (
+	bounceSubjectPattern = regexp.MustCompile(`(?i)(undeliver|delivery (status notification|failure|has failed|notification)|mail delivery (failed|failure|subsystem)|returned mail|failure notice|non[- ]?delivery|退信|无法投递|投递失败)`)
+	bounceSenderPattern  = regexp.MustCompile(`(?i)^(mailer-daemon|postmaster)@`)
+	emailPattern         = regexp.MustCompile(`[A-Za-z0-9._%+\-=]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	smtpCodePattern      = regexp.MustCompile(`\b([245]\d\d)[ \-]+(?:#?([245]\.\d{1,3}\.\d{1,3})\b)?`)
+	enhancedCodePattern  = regexp.MustCompile(`\b([245]\.\d{1,3}\.\d{1,3})\b`)
+	messageIDPattern     = regexp.MustCompile(`(?im)^Message-ID:\s*(<[^>\s]+>)`)
+	// 正文中附带的原邮件开始标记，之后的地址不再视为退信收件人
+	originalMarkerPattern = regexp.MustCompile(`(?im)^(-+ ?(original message|below this line|this is a copy)|received: |return-path: |message-id: )`)
+	softDiagnosticPattern = regexp.MustCompile(`(?i)(mailbox (is )?full|over quota|quota exceeded|insufficient (system )?storage|try again later|temporar)`)
+)
+
+// newMessageID 生成Message-ID，域名取发件人邮箱的域名
+func newMessageID(fromEmail string) string {
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	domain := "localhost"
+	if i := strings.LastIndex(fromEmail, "@"); i >= 0 {
+		domain = fromEmail[i+1:]
+	}
+	return "<" + hex.EncodeToString(buf) + "@" + domain + ">"
+}
+
+// verpAddress 根据发件人和Message-ID生成VERP信封发件人：local+bounce-<token>@domain
+func verpAddress(fromEmail, messageID string) string {
+	at := strings.LastIndex(fromEmail, "@")
+	token := strings.Trim(messageID, "<>")
+	if i := strings.Index(token, "@"); i >= 0 {
+		token = token[:i]
+	}
+	if at < 0 || token == "" {
+		return fromEmail
+	}
+	return fromEmail[:at] + verpTag + token + fromEmail[at:]
+}
+
+// parseVERP 从VERP地址还原原邮件的Message-ID
+func parseVERP(address string) (string, bool) {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return "", false
+	}
+	local, domain := address[:at], address[at+1:]
+	i := strings.Index(strings.ToLower(local), verpTag)
+	if i < 0 {
+		return "", false
+	}
+	token := local[i+len(verpTag):]
+	if token == "" {
+		return "", false
+	}
+	return "<" + strings.ToLower(token) + "@" + domain + ">", true
+}
+
+// ParseBounce 解析退信：优先按RFC 3464投递状态通知解析，否则按常见邮件服务器的退信正文识别
+// 不是退信时返回nil
+func ParseBounce(raw []byte) (*BounceReport, error) {
+	parsed, err := ParseMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BounceReport{}
+	for _, key := range []string{"To", "Delivered-To", "X-Original-To", "Envelope-To"} {
+		for _, value := range parsed.Header.Values(key) {
+			for _, addr := range emailPattern.FindAllString(value, -1) {
+				if messageID, ok := parseVERP(addr); ok && report.VERP == "" {
+					report.VERP = messageID
+				}
+			}
+		}
+	}
+
+	isReport := parsed.Root != nil && parsed.Root.ContentType == "multipart/report"
+	walkParts(parsed.Root, func(part *MIMEPart) {
+		switch part.ContentType {
+		case "message/delivery-status", "message/global-delivery-status":
+			isReport = true
+			report.Recipients = append(report.Recipients, parseDeliveryStatus(part.body)...)
+		case "message/rfc822", "text/rfc822-headers", "message/rfc822-headers", "message/global", "message/global-headers":
+			if report.MessageID == "" {
+				report.MessageID = originalMessageID(part.body)
+			}
+		}
+	})
+
+	if len(report.Recipients) == 0 {
+		if !isReport && !looksLikeBounce(parsed) {
+			return nil, nil
+		}
+		text := parsed.Text
+		if text == "" {
+			text = stripHTML(parsed.HTML)
+		}
+		if recipient, ok := parseBounceText(text); ok {
+			report.Recipients = append(report.Recipients, recipient)
+		}
+		if report.MessageID == "" {
+			if m := messageIDPattern.FindStringSubmatch(text); m != nil {
+				report.MessageID = m[1]
+			}
+		}
+	}
+	if len(report.Recipients) == 0 {
+		return nil, nil
+	}
+	return report, nil
+}
+
+// walkParts 遍历MIME树中的所有叶子部分
+func walkParts(part *MIMEPart, fn func(*MIMEPart)) {
+	if part == nil {
+		return
+	}
+	if len(part.Parts) == 0 {
+		fn(part)
+		return
+	}
+	for _, child := range part.Parts {
+		walkParts(child, fn)
+	}
+}
+
+// looksLikeBounce 根据发件人和主题判断非标准格式的邮件是否为退信
+func looksLikeBounce(parsed *ParsedMessage) bool {
+	if bounceSenderPattern.MatchString(parsed.From) {
+		return true
+	}
+	return bounceSubjectPattern.MatchString(parsed.Subject)
+}
+
+// parseDeliveryStatus 解析message/delivery-status：第一组为报文字段，之后每组对应一个收件人
+func parseDeliveryStatus(body []byte) []BounceRecipient {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(body)))
+	var recipients []BounceRecipient
+	for first := true; ; first = false {
+		fields, err := reader.ReadMIMEHeader()
+		if len(fields) > 0 && !first {
+			recipient := BounceRecipient{
+				Address:    dsnAddress(fields.Get("Final-Recipient")),
+				Action:     strings.ToLower(strings.TrimSpace(fields.Get("Action"))),
+				Status:     strings.TrimSpace(fields.Get("Status")),
+				Diagnostic: dsnAddressValue(fields.Get("Diagnostic-Code")),
+			}
+			if recipient.Address == "" {
+				recipient.Address = dsnAddress(fields.Get("Original-Recipient"))
+			}
+			if m := enhancedCodePattern.FindString(recipient.Status); m != "" {
+				recipient.Status = m
+			}
+			// 只记录失败和延迟，忽略delivered、relayed、expanded
+			if recipient.Address != "" && (recipient.Action == "failed" || recipient.Action == "delayed") {
+				recipient.Type = classifyBounce(recipient.Action, recipient.Status, recipient.Diagnostic)
+				recipients = append(recipients, recipient)
+			}
+		}
+		if err != nil {
+			return recipients
+		}
+	}
+}
+
+// dsnAddressValue 去掉DSN字段的类型前缀，如 "rfc822; user@example.com"、"smtp; 550 ..."
+func dsnAddressValue(value string) string {
+	if i := strings.Index(value, ";"); i >= 0 {
+		value = value[i+1:]
+	}
+	return strings.TrimSpace(value)
+}
+
+// dsnAddress 提取DSN收件人字段中的邮箱地址
+func dsnAddress(value string) string {
+	return strings.ToLower(strings.Trim(dsnAddressValue(value), "<>"))
+}
+
+// originalMessageID 从退信附带的原邮件或原邮件头中取Message-ID
+func originalMessageID(body []byte) string {
+	msg, err := mail.ReadMessage(io.MultiReader(bytes.NewReader(body), strings.NewReader("\r\n\r\n")))
+	if err != nil {
+		if m := messageIDPattern.FindSubmatch(body); m != nil {
+			return string(m[1])
+		}
+		return ""
+	}
+	return strings.TrimSpace(msg.Header.Get("Message-ID"))
+}
+
+// parseBounceText 从非标准退信的正文中识别退信收件人和原因
+// 取附带原邮件之前出现的第一个邮箱地址，原因取该地址之后第一行带SMTP状态码的内容
+func parseBounceText(text string) (BounceRecipient, bool) {
+	if loc := originalMarkerPattern.FindStringIndex(text); loc != nil {
+		text = text[:loc[0]]
+	}
+
+	var recipient BounceRecipient
+	var after string
+	for _, loc := range emailPattern.FindAllStringIndex(text, -1) {
+		addr := strings.ToLower(text[loc[0]:loc[1]])
+		if bounceSenderPattern.MatchString(addr) {
+			continue
+		}
+		recipient.Address = addr
+		after = text[loc[1]:]
+		break
+	}
+	if recipient.Address == "" {
+		return recipient, false
+	}
+
+	recipient.Action = "failed"
+	for _, line := range strings.Split(after, "\n") {
+		line = strings.TrimSpace(line)
+		if m := smtpCodePattern.FindStringSubmatch(line); m != nil {
+			recipient.Diagnostic = line
+			recipient.Status = m[2]
+			if recipient.Status == "" {
+				recipient.Status = m[1][:1] + ".0.0"
+			}
+			break
+		}
+	}
+	if recipient.Diagnostic == "" {
+		recipient.Diagnostic = strings.TrimSpace(firstLine(after))
+	}
+	if strings.HasPrefix(recipient.Status, "4") {
+		recipient.Action = "delayed"
+	}
+	recipient.Type = classifyBounce(recipient.Action, recipient.Status, recipient.Diagnostic)
+	return recipient, true
+}
+
+// classifyBounce 判断退信类型：延迟、4.x.x、邮箱已满等为软退信，其余失败为硬退信
+func classifyBounce(action, status, diagnostic string) string {
+	switch {
+	case action == "delayed", strings.HasPrefix(status, "4."):
+		return models.BounceSoft
+	case status == "5.2.2", softDiagnosticPattern.MatchString(diagnostic):
+		return models.BounceSoft
+	}
+	return models.BounceHard
+}
+
+// firstLine 返回第一行非空内容
+func firstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(strings.Trim(line, ":")); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// stripHTML 粗略去除HTML标签，用于从HTML退信中提取文本
+func stripHTML(html string) string {
+	text := regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</tr>`).ReplaceAllString(html, "\n")
+	return regexp.MustCompile(`<[^>]*>`).ReplaceAllString(text, "")
+}
+
+// String 便于日志输出
+func (r BounceRecipient) String() string {
+	return fmt.Sprintf("%s(%s %s)", r.Address, r.Type, r.Status)
+}
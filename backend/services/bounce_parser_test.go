@@ -0,0 +1,128 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"smtp-mail/backend/models"
+)
+
+// dsnBounce 生成RFC 3464投递状态通知，recipientFields为收件人的状态字段，附带原邮件头
+func dsnBounce(to, messageID, recipientFields string) []byte {
+	return []byte(strings.ReplaceAll(`From: Mail Delivery System <MAILER-DAEMON@mx.example.net>
+To: `+to+`
+Subject: Undelivered Mail Returned to Sender
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=delivery-status; boundary="b1"
+
+--b1
+Content-Type: text/plain
+
+This is the mail system at host mx.example.net.
+
+--b1
+Content-Type: message/delivery-status
+
+Reporting-MTA: dns; mx.example.net
+
+`+recipientFields+`
+
+--b1
+Content-Type: text/rfc822-headers
+
+Message-ID: `+messageID+`
+Subject: hello
+
+--b1--
+`, "\n", "\r\n"))
+}
+
+func TestParseBounce(t *testing.T) {
+	tests := []struct {
+		name          string
+		raw           []byte
+		wantMessageID string
+		wantVERP      string
+		want          []BounceRecipient
+	}{
+		{
+			name: "rfc3464 hard",
+			raw: dsnBounce("sender@example.com", "<abc123@example.com>",
+				"Final-Recipient: rfc822; Missing@example.org\nAction: failed\nStatus: 5.1.1\nDiagnostic-Code: smtp; 550 5.1.1 User unknown"),
+			wantMessageID: "<abc123@example.com>",
+			want: []BounceRecipient{{Address: "missing@example.org", Action: "failed", Status: "5.1.1",
+				Diagnostic: "550 5.1.1 User unknown", Type: models.BounceHard}},
+		},
+		{
+			name: "rfc3464 delayed with verp",
+			raw: dsnBounce("sender+bounce-def456@example.com", "<other@example.com>",
+				"Final-Recipient: rfc822; full@example.org\nAction: delayed\nStatus: 4.2.2\nDiagnostic-Code: smtp; 452 4.2.2 Mailbox full"),
+			wantMessageID: "<other@example.com>",
+			wantVERP:      "<def456@example.com>",
+			want: []BounceRecipient{{Address: "full@example.org", Action: "delayed", Status: "4.2.2",
+				Diagnostic: "452 4.2.2 Mailbox full", Type: models.BounceSoft}},
+		},
+		{
+			name: "rfc3464 delivered ignored",
+			raw: dsnBounce("sender@example.com", "<abc123@example.com>",
+				"Final-Recipient: rfc822; ok@example.org\nAction: delivered\nStatus: 2.0.0"),
+		},
+		{
+			name: "vendor text",
+			raw: []byte("From: postmaster@mail.example.net\r\nTo: sender@example.com\r\nSubject: Delivery Status Notification (Failure)\r\n\r\n" +
+				"Delivery to the following recipient failed permanently:\r\n\r\n     nobody@example.org\r\n\r\n" +
+				"The error that the other server returned was:\r\n550 5.1.1 The email account that you tried to reach does not exist.\r\n\r\n" +
+				"----- Original message -----\r\n\r\nMessage-ID: <ghi789@example.com>\r\nTo: nobody@example.org\r\n"),
+			wantMessageID: "<ghi789@example.com>",
+			want: []BounceRecipient{{Address: "nobody@example.org", Action: "failed", Status: "5.1.1",
+				Diagnostic: "550 5.1.1 The email account that you tried to reach does not exist.", Type: models.BounceHard}},
+		},
+		{
+			name: "not a bounce",
+			raw:  []byte("From: alice@example.org\r\nTo: sender@example.com\r\nSubject: Re: hello\r\n\r\nThanks, bob@example.org said hi.\r\n"),
+		},
+	}
+
+	for _, tt := range tests {
+		report, err := ParseBounce(tt.raw)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.want == nil {
+			if report != nil {
+				t.Errorf("%s: got %+v, want nil", tt.name, report)
+			}
+			continue
+		}
+		if report == nil {
+			t.Errorf("%s: 没有识别为退信", tt.name)
+			continue
+		}
+		if report.MessageID != tt.wantMessageID || report.VERP != tt.wantVERP {
+			t.Errorf("%s: MessageID=%q VERP=%q, want %q %q", tt.name, report.MessageID, report.VERP, tt.wantMessageID, tt.wantVERP)
+		}
+		if len(report.Recipients) != len(tt.want) {
+			t.Errorf("%s: recipients %+v, want %+v", tt.name, report.Recipients, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if report.Recipients[i] != tt.want[i] {
+				t.Errorf("%s: recipient %+v, want %+v", tt.name, report.Recipients[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestVERPRoundTrip(t *testing.T) {
+	address := verpAddress("sender@example.com", "<ABC123@example.com>")
+	if address != "sender+bounce-ABC123@example.com" {
+		t.Fatalf("verpAddress = %s", address)
+	}
+	messageID, ok := parseVERP(address)
+	if !ok || messageID != "<abc123@example.com>" {
+		t.Errorf("parseVERP = %s %v", messageID, ok)
+	}
+	if _, ok := parseVERP("sender@example.com"); ok {
+		t.Error("普通地址被识别为VERP地址")
+	}
+}
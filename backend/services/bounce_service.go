@@ -0,0 +1,474 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// bouncePollMu 防止定时轮询和手动轮询同时处理同一邮箱
+var bouncePollMu sync.Mutex
+
+// BounceService 退信处理服务：从SMTP配置对应的IMAP/POP3退信邮箱中读取退信，
+// 按Message-ID或VERP地址匹配原邮件，并将发送历史标记为退信
+type BounceService struct {
+	cfg                config.BounceConfig
+	smtpService        *SMTPService
+	cryptoService      *CryptoService
+	auditService       *AuditService
+	webhookService     *WebhookService
+	suppressionService *SuppressionService
+}
+
+// NewBounceService 创建退信服务实例
+func NewBounceService() *BounceService {
+	return &BounceService{
+		cfg:                config.GetConfig().Bounce,
+		smtpService:        NewSMTPService(),
+		cryptoService:      NewCryptoService(),
+		auditService:       NewAuditService(),
+		webhookService:     NewWebhookService(),
+		suppressionService: NewSuppressionService(),
+	}
+}
+
+// BounceMailboxRequest 设置退信邮箱请求
+type BounceMailboxRequest struct {
+	Protocol string `json:"protocol" binding:"required"` // imap、pop3
+	Host     string `json:"host" binding:"required"`
+	Port     int    `json:"port"` // 为0时按协议和TLS使用默认端口
+	Username string `json:"username"`
+	Password string `json:"password"` // 更新时为空表示不修改
+	TLS      *bool  `json:"tls"`      // 默认true
+	Folder   string `json:"folder"`   // IMAP文件夹，默认INBOX
+	VERP     bool   `json:"verp"`
+	Enabled  *bool  `json:"enabled"` // 默认true
+}
+
+// BouncePollResult 一次轮询的结果
+type BouncePollResult struct {
+	Fetched int `json:"fetched"` // 取回的邮件数
+	Bounces int `json:"bounces"` // 解析出的退信收件人数
+	Matched int `json:"matched"` // 匹配到发送历史的退信数
+}
+
+// BounceListResponse 退信列表响应
+type BounceListResponse struct {
+	List     []models.Bounce `json:"list"`
+	Total    int64           `json:"total"`
+	Page     int             `json:"page"`
+	PageSize int             `json:"pageSize"`
+}
+
+// verpEnabled SMTP配置是否启用了VERP信封发件人
+func verpEnabled(smtpConfigID uint) bool {
+	var count int64
+	database.GetDB().Model(&models.BounceMailbox{}).
+		Where("smtp_config_id = ? AND enabled = ? AND verp = ?", smtpConfigID, true, true).
+		Count(&count)
+	return count > 0
+}
+
+// defaultMailboxPort 协议的默认端口
+func defaultMailboxPort(protocol string, useTLS bool) int {
+	switch {
+	case protocol == models.MailboxPOP3 && useTLS:
+		return 995
+	case protocol == models.MailboxPOP3:
+		return 110
+	case useTLS:
+		return 993
+	default:
+		return 143
+	}
+}
+
+// smtpConfigForMailbox 获取可见的SMTP配置；修改退信邮箱需要SMTP配置的修改权限
+func (s *BounceService) smtpConfigForMailbox(p *Principal, smtpConfigID uint, modify bool) (*models.SMTPConfig, error) {
+	config, err := s.smtpService.GetConfigByID(p, smtpConfigID)
+	if err != nil {
+		return nil, err
+	}
+	if modify {
+		if err := p.AuthorizeModify(config.OwnerID); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// GetMailbox 获取SMTP配置的退信邮箱
+func (s *BounceService) GetMailbox(p *Principal, smtpConfigID uint) (*models.BounceMailbox, error) {
+	if _, err := s.smtpConfigForMailbox(p, smtpConfigID, false); err != nil {
+		return nil, err
+	}
+	var mailbox models.BounceMailbox
+	if err := database.GetDB().Where("smtp_config_id = ?", smtpConfigID).First(&mailbox).Error; err != nil {
+		return nil, fmt.Errorf("退信邮箱未设置: %w", err)
+	}
+	return &mailbox, nil
+}
+
+// SaveMailbox 创建或更新SMTP配置的退信邮箱
+func (s *BounceService) SaveMailbox(p *Principal, smtpConfigID uint, req *BounceMailboxRequest) (*models.BounceMailbox, error) {
+	config, err := s.smtpConfigForMailbox(p, smtpConfigID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Protocol = strings.ToLower(req.Protocol)
+	if req.Protocol != models.MailboxIMAP && req.Protocol != models.MailboxPOP3 {
+		return nil, fmt.Errorf("不支持的协议: %s", req.Protocol)
+	}
+	useTLS := req.TLS == nil || *req.TLS
+	enabled := req.Enabled == nil || *req.Enabled
+	if req.Port == 0 {
+		req.Port = defaultMailboxPort(req.Protocol, useTLS)
+	}
+
+	var existing models.BounceMailbox
+	err = database.GetDB().Where("smtp_config_id = ?", smtpConfigID).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取退信邮箱失败: %w", err)
+	}
+	isNew := err != nil
+	if isNew && req.Password == "" {
+		return nil, errors.New("密码不能为空")
+	}
+
+	updates := map[string]interface{}{
+		"protocol": req.Protocol,
+		"host":     req.Host,
+		"port":     req.Port,
+		"username": req.Username,
+		"tls":      useTLS,
+		"folder":   req.Folder,
+		"verp":     req.VERP,
+		"enabled":  enabled,
+	}
+	if req.Password != "" {
+		encrypted, err := s.cryptoService.EncryptPassword(req.Password)
+		if err != nil {
+			return nil, fmt.Errorf("加密密码失败: %w", err)
+		}
+		updates["password"] = encrypted
+	}
+
+	mailbox := existing
+	before := existing
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if isNew {
+			mailbox = models.BounceMailbox{WorkspaceID: config.WorkspaceID, SmtpConfigID: smtpConfigID, Protocol: req.Protocol, Host: req.Host, Port: req.Port}
+			if err := tx.Create(&mailbox).Error; err != nil {
+				return err
+			}
+		}
+		// 布尔列有默认值，统一通过map更新以保存零值
+		if err := tx.Model(&mailbox).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&mailbox, mailbox.ID).Error; err != nil {
+			return err
+		}
+		if isNew {
+			return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityBounceMailbox, mailbox.ID, nil, &mailbox)
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityBounceMailbox, mailbox.ID, &before, &mailbox)
+	})
+	if err != nil {
+		utils.Errorf("保存退信邮箱失败 (SmtpConfigID: %d): %v", smtpConfigID, err)
+		return nil, fmt.Errorf("保存退信邮箱失败: %w", err)
+	}
+
+	utils.Infof("保存退信邮箱成功: SmtpConfigID=%d, %s://%s:%d, VERP=%v", smtpConfigID, mailbox.Protocol, mailbox.Host, mailbox.Port, mailbox.VERP)
+	return &mailbox, nil
+}
+
+// DeleteMailbox 删除SMTP配置的退信邮箱，已记录的退信保留
+func (s *BounceService) DeleteMailbox(p *Principal, smtpConfigID uint) error {
+	if _, err := s.smtpConfigForMailbox(p, smtpConfigID, true); err != nil {
+		return err
+	}
+	mailbox, err := s.GetMailbox(p, smtpConfigID)
+	if err != nil {
+		return err
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(mailbox).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityBounceMailbox, mailbox.ID, mailbox, nil)
+	})
+	if err != nil {
+		utils.Errorf("删除退信邮箱失败 (SmtpConfigID: %d): %v", smtpConfigID, err)
+		return fmt.Errorf("删除退信邮箱失败: %w", err)
+	}
+	return nil
+}
+
+// PollMailbox 立即轮询SMTP配置的退信邮箱
+func (s *BounceService) PollMailbox(p *Principal, smtpConfigID uint) (*BouncePollResult, error) {
+	if _, err := s.smtpConfigForMailbox(p, smtpConfigID, true); err != nil {
+		return nil, err
+	}
+	mailbox, err := s.GetMailbox(p, smtpConfigID)
+	if err != nil {
+		return nil, err
+	}
+	return s.poll(mailbox)
+}
+
+// ListBounces 获取退信记录，可按发送历史和退信类型筛选
+func (s *BounceService) ListBounces(p *Principal, page, pageSize int, historyID uint, bounceType string) (*BounceListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	db := p.ScopeHistory(database.GetDB().Model(&models.Bounce{}))
+	if historyID > 0 {
+		db = db.Where("history_id = ?", historyID)
+	}
+	if bounceType != "" {
+		db = db.Where("type = ?", bounceType)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("获取退信总数失败: %w", err)
+	}
+	var bounces []models.Bounce
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&bounces).Error; err != nil {
+		return nil, fmt.Errorf("获取退信列表失败: %w", err)
+	}
+
+	return &BounceListResponse{
+		List:     bounces,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// StartPoller 按 bounce.interval 定时轮询所有启用的退信邮箱
+func (s *BounceService) StartPoller(ctx context.Context) {
+	if s.cfg.Interval <= 0 {
+		return
+	}
+	utils.Infof("退信邮箱轮询已启用: 间隔=%s", s.cfg.Interval)
+	go func() {
+		ticker := time.NewTicker(s.cfg.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.pollAll(ctx)
+			}
+		}
+	}()
+}
+
+// pollAll 轮询所有启用的退信邮箱
+func (s *BounceService) pollAll(ctx context.Context) {
+	var mailboxes []models.BounceMailbox
+	if err := database.GetDB().Where("enabled = ?", true).Find(&mailboxes).Error; err != nil {
+		utils.Errorf("查询退信邮箱失败: %v", err)
+		return
+	}
+	for i := range mailboxes {
+		if ctx.Err() != nil {
+			return
+		}
+		if _, err := s.poll(&mailboxes[i]); err != nil {
+			utils.Warnf("轮询退信邮箱失败 (SmtpConfigID: %d): %v", mailboxes[i].SmtpConfigID, err)
+		}
+	}
+}
+
+// poll 取回邮箱中未处理的邮件并处理退信，结果记录到邮箱的last_polled_at和last_error
+func (s *BounceService) poll(mailbox *models.BounceMailbox) (*BouncePollResult, error) {
+	bouncePollMu.Lock()
+	defer bouncePollMu.Unlock()
+
+	result := &BouncePollResult{}
+	err := s.fetchAndApply(mailbox, result)
+
+	now := time.Now()
+	lastError := ""
+	if err != nil {
+		lastError = err.Error()
+	}
+	database.GetDB().Model(mailbox).Updates(map[string]interface{}{"last_polled_at": now, "last_error": lastError})
+	mailbox.LastPolledAt = &now
+	mailbox.LastError = lastError
+
+	if result.Fetched > 0 {
+		utils.Infof("退信邮箱轮询完成 (SmtpConfigID: %d): 邮件=%d, 退信=%d, 匹配=%d",
+			mailbox.SmtpConfigID, result.Fetched, result.Bounces, result.Matched)
+	}
+	return result, err
+}
+
+// fetchAndApply 取回邮件，逐封解析退信并更新发送历史，处理完的邮件标记为已处理
+func (s *BounceService) fetchAndApply(mailbox *models.BounceMailbox, result *BouncePollResult) error {
+	password, err := s.cryptoService.DecryptPassword(mailbox.Password)
+	if err != nil {
+		return fmt.Errorf("解密密码失败: %w", err)
+	}
+	timeout := s.cfg.Timeout
+	if timeout <= 0 {
+		timeout = time.Minute
+	}
+	client, err := dialMailbox(mailbox, password, timeout)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	messages, err := client.Fetch(s.cfg.BatchSize)
+	for _, message := range messages {
+		result.Fetched++
+		report, parseErr := ParseBounce(message.Raw)
+		if parseErr != nil {
+			utils.Warnf("解析退信邮箱中的邮件失败 (%s): %v", message.ID, parseErr)
+		}
+		if report != nil {
+			result.Bounces += len(report.Recipients)
+			matched, applyErr := s.applyReport(mailbox, report)
+			if applyErr != nil {
+				// 保留邮件，下次轮询时重试
+				utils.Errorf("保存退信失败 (%s): %v", message.ID, applyErr)
+				continue
+			}
+			result.Matched += matched
+		}
+		// 非退信邮件同样标记为已处理，避免重复读取
+		if doneErr := client.Done(message.ID); doneErr != nil {
+			utils.Warnf("标记邮件 %s 为已处理失败: %v", message.ID, doneErr)
+		}
+	}
+	return err
+}
+
+// applyReport 记录退信并更新匹配到的发送历史；VERP地址优先于退信中附带的Message-ID
+// 返回匹配到发送历史的退信收件人数
+func (s *BounceService) applyReport(mailbox *models.BounceMailbox, report *BounceReport) (int, error) {
+	messageID := report.VERP
+	if messageID == "" {
+		messageID = report.MessageID
+	}
+
+	db := database.GetDB()
+	var history models.EmailHistory
+	found := false
+	if messageID != "" {
+		err := db.Where("workspace_id = ? AND message_id = ?", mailbox.WorkspaceID, messageID).First(&history).Error
+		if err == nil {
+			found = true
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+	}
+
+	matched := 0
+	changed := false
+	delayed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, recipient := range report.Recipients {
+			bounce := models.Bounce{
+				WorkspaceID:  mailbox.WorkspaceID,
+				SmtpConfigID: mailbox.SmtpConfigID,
+				Recipient:    recipient.Address,
+				Type:         recipient.Type,
+				Status:       recipient.Status,
+				Action:       recipient.Action,
+				Diagnostic:   recipient.Diagnostic,
+				MessageID:    messageID,
+			}
+			if found {
+				bounce.HistoryID = history.ID
+				bounce.UserID = history.UserID
+				bounce.SmtpConfigID = history.SmtpConfigID
+			}
+
+			// 同一封邮件、同一收件人的相同退信只记录一次
+			var count int64
+			if err := tx.Model(&models.Bounce{}).
+				Where("workspace_id = ? AND message_id = ? AND recipient = ? AND type = ? AND status = ?",
+					bounce.WorkspaceID, bounce.MessageID, bounce.Recipient, bounce.Type, bounce.Status).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 && messageID != "" {
+				continue
+			}
+			if err := tx.Create(&bounce).Error; err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+			matched++
+			if recipient.Action == "delayed" {
+				delayed = true
+			}
+
+			// 硬退信覆盖软退信，软退信不覆盖硬退信
+			if history.BounceType == models.BounceHard || history.BounceType == recipient.Type {
+				continue
+			}
+			if history.Status != models.EmailStatusSuccess && history.Status != models.EmailStatusBounced {
+				continue
+			}
+			history.Status = models.EmailStatusBounced
+			history.BounceType = recipient.Type
+			if err := tx.Model(&history).Updates(map[string]interface{}{
+				"status":      history.Status,
+				"bounce_type": history.BounceType,
+			}).Error; err != nil {
+				return err
+			}
+			changed = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// 硬退信的收件人加入抑制列表
+	for _, recipient := range report.Recipients {
+		if recipient.Type == models.BounceHard {
+			s.suppressionService.Add(mailbox.WorkspaceID, recipient.Address, models.SuppressionSourceBounce,
+				strings.TrimSpace(recipient.Status+" "+recipient.Diagnostic))
+		}
+	}
+
+	if !found {
+		utils.Warnf("退信未匹配到发送历史: MessageID=%s, 收件人=%v", messageID, report.Recipients)
+	}
+	if changed {
+		utils.Infof("发送历史 %d 标记为退信: %s", history.ID, history.BounceType)
+		s.webhookService.Enqueue(&history)
+	}
+	if delayed {
+		// 延迟投递通知表示对方服务器仍在重试
+		s.webhookService.EnqueueEvent(models.WebhookEventEmailRetried, &history)
+	}
+	return matched, nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+)
+
+func TestBouncePoll(t *testing.T) {
+	tests := []struct {
+		protocol   string
+		fields     string
+		wantType   string
+		suppressed bool
+	}{
+		{models.MailboxIMAP, "Final-Recipient: rfc822; missing@example.org\nAction: failed\nStatus: 5.1.1", models.BounceHard, true},
+		{models.MailboxPOP3, "Final-Recipient: rfc822; missing@example.org\nAction: delayed\nStatus: 4.2.2", models.BounceSoft, false},
+	}
+
+	forEachDriver(t, func(t *testing.T) {
+		useTestKeyring(t, testKey1)
+		for _, tt := range tests {
+			db := database.GetDB()
+			for _, table := range []string{"suppressions", "bounces", "bounce_mailboxes", "email_histories", "smtp_configs"} {
+				db.Exec("DELETE FROM " + table)
+			}
+			smtpConfig := models.SMTPConfig{WorkspaceID: 1, Name: "smtp", Host: "localhost", Port: 25, FromEmail: "sender@example.com"}
+			if err := db.Create(&smtpConfig).Error; err != nil {
+				t.Fatal(err)
+			}
+			history := models.EmailHistory{WorkspaceID: 1, SmtpConfigID: smtpConfig.ID, UserID: 1, ToEmail: "missing@example.org",
+				Subject: "hello", Body: "body", Status: models.EmailStatusSuccess, MessageID: "<abc123@example.com>"}
+			if err := db.Create(&history).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			// 一封退信和一封普通回复，两封都标记为已处理
+			fake := newFakeMailbox(t, tt.protocol, map[string][]byte{
+				"1": dsnBounce("sender@example.com", "<abc123@example.com>", tt.fields),
+				"2": []byte("From: bob@example.org\r\nSubject: Re: hello\r\n\r\nthanks\r\n"),
+			})
+			mailbox := fake.mailbox(tt.protocol)
+			mailbox.WorkspaceID = 1
+			mailbox.SmtpConfigID = smtpConfig.ID
+			password, err := NewCryptoService().EncryptPassword(fake.password)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mailbox.Password = password
+			if err := db.Create(mailbox).Error; err != nil {
+				t.Fatal(err)
+			}
+			// tls列默认为true，替身不支持TLS
+			if err := db.Model(mailbox).Update("tls", false).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			s := NewBounceService()
+			result, err := s.poll(mailbox)
+			if err != nil {
+				t.Fatalf("%s: 轮询失败: %v", tt.protocol, err)
+			}
+			if result.Fetched != 2 || result.Bounces != 1 || result.Matched != 1 {
+				t.Errorf("%s: got %+v, want 2 fetched, 1 bounce, 1 matched", tt.protocol, result)
+			}
+			if remaining := fake.uids(true); tt.protocol == models.MailboxIMAP && len(remaining) != 0 {
+				t.Errorf("%s: 仍有未读邮件 %v", tt.protocol, remaining)
+			}
+			if remaining := fake.uids(false); tt.protocol == models.MailboxPOP3 && len(remaining) != 0 {
+				t.Errorf("%s: 邮件没有删除 %v", tt.protocol, remaining)
+			}
+
+			db.First(&history, history.ID)
+			if history.Status != models.EmailStatusBounced || history.BounceType != tt.wantType {
+				t.Errorf("%s: 发送历史为 %s/%s，want bounced/%s", tt.protocol, history.Status, history.BounceType, tt.wantType)
+			}
+			var bounce models.Bounce
+			if err := db.Where("history_id = ?", history.ID).First(&bounce).Error; err != nil || bounce.Recipient != "missing@example.org" {
+				t.Errorf("%s: 退信记录 %+v (%v)", tt.protocol, bounce, err)
+			}
+			suppressed, err := NewSuppressionService().Suppressed(1, []string{"missing@example.org"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (len(suppressed) > 0) != tt.suppressed {
+				t.Errorf("%s: 抑制列表 %v，want suppressed=%v", tt.protocol, suppressed, tt.suppressed)
+			}
+
+			// 已处理的邮件不会重复计数
+			if result, err = s.poll(mailbox); err != nil || result.Fetched != 0 {
+				t.Errorf("%s: 第二次轮询 %+v (%v)", tt.protocol, result, err)
+			}
+		}
+	})
+}
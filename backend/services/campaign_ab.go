@@ -0,0 +1,287 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// A/B测试的默认设置
+const (
+	defaultTestPercent       = 20
+	defaultWinnerWaitMinutes = 60
+	maxCampaignVariants      = 10
+)
+
+// errABTestTrackingDisabled 追踪已全局关闭，无法统计打开和点击，A/B测试选不出获胜版本
+var errABTestTrackingDisabled = errors.New("A/B测试需要打开和点击追踪，但追踪已全局关闭")
+
+// CampaignVariantRequest A/B测试版本，主题和模板为空时使用活动的模板
+type CampaignVariantRequest struct {
+	Name       string `json:"name"`
+	Subject    string `json:"subject"`
+	TemplateID *uint  `json:"template_id"`
+}
+
+// CampaignVariantStats A/B测试版本的统计
+type CampaignVariantStats struct {
+	VariantID  uint    `json:"variant_id"`
+	Name       string  `json:"name"`
+	Recipients int64   `json:"recipients"` // 分配到该版本的测试收件人
+	Sent       int64   `json:"sent"`
+	Opened     int64   `json:"opened"`
+	Clicked    int64   `json:"clicked"`
+	OpenRate   float64 `json:"open_rate"`  // 打开数/发送数
+	ClickRate  float64 `json:"click_rate"` // 点击数/发送数
+	Winner     bool    `json:"winner"`
+}
+
+// normalizeABTest 校验A/B测试设置并填充默认值，没有版本时清空测试设置
+func (req *CampaignRequest) normalizeABTest() error {
+	if len(req.Variants) == 0 {
+		req.TestPercent, req.WinnerMetric, req.WinnerWaitMinutes = 0, "", 0
+		return nil
+	}
+	if len(req.Variants) < 2 || len(req.Variants) > maxCampaignVariants {
+		return fmt.Errorf("A/B测试需要2到%d个版本", maxCampaignVariants)
+	}
+	if !req.Track {
+		return errors.New("A/B测试按打开率或点击率选出获胜版本，需要开启track")
+	}
+	if !TrackingEnabled() {
+		return errABTestTrackingDisabled
+	}
+
+	seen := map[string]bool{}
+	for i := range req.Variants {
+		variant := &req.Variants[i]
+		if variant.Name == "" {
+			variant.Name = string(rune('A' + i))
+		}
+		if seen[variant.Name] {
+			return fmt.Errorf("版本名称 %s 重复", variant.Name)
+		}
+		seen[variant.Name] = true
+	}
+
+	if req.TestPercent == 0 {
+		req.TestPercent = defaultTestPercent
+	}
+	switch req.WinnerMetric {
+	case "":
+		req.WinnerMetric = models.CampaignMetricOpen
+	case models.CampaignMetricOpen, models.CampaignMetricClick:
+	default:
+		return fmt.Errorf("无效的获胜指标: %s，必须是 open/click", req.WinnerMetric)
+	}
+	if req.WinnerWaitMinutes == 0 {
+		req.WinnerWaitMinutes = defaultWinnerWaitMinutes
+	}
+	return nil
+}
+
+// variantRequests 活动已保存的版本，用于发送前以创建者身份重新检查
+func variantRequests(variants []models.CampaignVariant) []CampaignVariantRequest {
+	requests := make([]CampaignVariantRequest, len(variants))
+	for i, variant := range variants {
+		requests[i] = CampaignVariantRequest{Name: variant.Name, Subject: variant.Subject, TemplateID: variant.TemplateID}
+	}
+	return requests
+}
+
+// replaceVariants 用请求中的版本替换活动的全部版本
+func replaceVariants(tx *gorm.DB, campaignID uint, requests []CampaignVariantRequest) error {
+	if err := tx.Where("campaign_id = ?", campaignID).Delete(&models.CampaignVariant{}).Error; err != nil {
+		return err
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+	variants := make([]models.CampaignVariant, len(requests))
+	for i, req := range requests {
+		variants[i] = models.CampaignVariant{CampaignID: campaignID, Name: req.Name, Subject: req.Subject, TemplateID: req.TemplateID}
+	}
+	return tx.Create(&variants).Error
+}
+
+// resolveVariants 开始发送时确定每个版本实际使用的主题和正文
+func (s *CampaignService) resolveVariants(p *Principal, campaign *models.Campaign, template *models.EmailTemplate) ([]models.CampaignVariant, error) {
+	variants := make([]models.CampaignVariant, len(campaign.Variants))
+	for i, variant := range campaign.Variants {
+		source := template
+		if variant.TemplateID != nil {
+			var err error
+			if source, err = s.templateService.GetTemplateByID(p, *variant.TemplateID); err != nil {
+				return nil, fmt.Errorf("版本 %s 的模板: %w", variant.Name, err)
+			}
+		}
+		if variant.Subject == "" {
+			variant.Subject = source.Subject
+		}
+		variant.Body = source.Body
+		variant.TemplateVersion = source.Version
+		variants[i] = variant
+	}
+	return variants, nil
+}
+
+// assignTestGroup 从未发送的收件人中随机抽取测试比例的收件人，轮流分配给各版本
+func assignTestGroup(tx *gorm.DB, campaign *models.Campaign, variants []models.CampaignVariant) error {
+	var ids []uint
+	if err := tx.Model(&models.CampaignRecipient{}).Where("campaign_id = ? AND status = ?", campaign.ID, models.CampaignRecipientPending).
+		Order("id").Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+
+	// 测试受众向上取整，并且每个版本至少一个收件人
+	size := (len(ids)*campaign.TestPercent + 99) / 100
+	if size < len(variants) {
+		size = len(variants)
+	}
+	if size > len(ids) {
+		size = len(ids)
+	}
+	rand.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+
+	for i, variant := range variants {
+		var assigned []uint
+		for j := i; j < size; j += len(variants) {
+			assigned = append(assigned, ids[j])
+		}
+		for start := 0; start < len(assigned); start += 500 {
+			end := start + 500
+			if end > len(assigned) {
+				end = len(assigned)
+			}
+			if err := tx.Model(&models.CampaignRecipient{}).Where("id IN ?", assigned[start:end]).Update("variant_id", variant.ID).Error; err != nil {
+				return err
+			}
+		}
+	}
+	utils.Infof("营销活动A/B测试: ID=%d, 测试收件人=%d, 版本=%d", campaign.ID, size, len(variants))
+	return nil
+}
+
+// variantStats 按版本统计测试收件人的发送、打开和点击
+func variantStats(campaign *models.Campaign) ([]CampaignVariantStats, error) {
+	var rows []struct {
+		VariantID  uint
+		Recipients int64
+		Sent       int64
+		Opened     int64
+		Clicked    int64
+	}
+	err := database.GetDB().Table("campaign_recipients AS cr").
+		Select("cr.variant_id, COUNT(*) AS recipients, "+
+			"SUM(CASE WHEN cr.status = ? THEN 1 ELSE 0 END) AS sent, "+
+			"SUM(CASE WHEN er.open_count > 0 THEN 1 ELSE 0 END) AS opened, "+
+			"SUM(CASE WHEN er.click_count > 0 THEN 1 ELSE 0 END) AS clicked", models.CampaignRecipientSent).
+		Joins("LEFT JOIN email_recipients AS er ON er.history_id = cr.history_id").
+		Where("cr.campaign_id = ? AND cr.variant_id IS NOT NULL", campaign.ID).
+		Group("cr.variant_id").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计A/B测试版本失败: %w", err)
+	}
+
+	stats := make([]CampaignVariantStats, len(campaign.Variants))
+	for i, variant := range campaign.Variants {
+		stats[i] = CampaignVariantStats{
+			VariantID: variant.ID,
+			Name:      variant.Name,
+			Winner:    campaign.WinnerVariantID != nil && *campaign.WinnerVariantID == variant.ID,
+		}
+		for _, row := range rows {
+			if row.VariantID == variant.ID {
+				stats[i].Recipients, stats[i].Sent, stats[i].Opened, stats[i].Clicked = row.Recipients, row.Sent, row.Opened, row.Clicked
+			}
+		}
+		if stats[i].Sent > 0 {
+			stats[i].OpenRate = float64(stats[i].Opened) / float64(stats[i].Sent)
+			stats[i].ClickRate = float64(stats[i].Clicked) / float64(stats[i].Sent)
+		}
+	}
+	return stats, nil
+}
+
+// awaitWinner 测试邮件发送完后等待，到时选出获胜版本
+func (s *CampaignService) awaitWinner(campaign *models.Campaign) {
+	if campaign.WinnerPickAt == nil {
+		pickAt := time.Now().Add(time.Duration(campaign.WinnerWaitMinutes) * time.Minute)
+		if err := database.GetDB().Model(&models.Campaign{}).Where("id = ?", campaign.ID).Update("winner_pick_at", pickAt).Error; err != nil {
+			utils.Errorf("更新营销活动失败 (ID: %d): %v", campaign.ID, err)
+			return
+		}
+		utils.Infof("营销活动A/B测试邮件已发送完: ID=%d, 将于 %s 选出获胜版本", campaign.ID, pickAt.Format(time.RFC3339))
+		return
+	}
+	if time.Now().Before(*campaign.WinnerPickAt) {
+		return
+	}
+	s.pickWinner(campaign)
+}
+
+// pickWinner 按获胜指标选出比率最高的版本（相同时取靠前的版本），记录各版本的结果，其余收件人使用获胜版本发送
+func (s *CampaignService) pickWinner(campaign *models.Campaign) {
+	stats, err := variantStats(campaign)
+	if err != nil {
+		utils.Errorf("选出获胜版本失败 (ID: %d): %v", campaign.ID, err)
+		return
+	}
+
+	winner := 0
+	rate := func(stat CampaignVariantStats) float64 {
+		if campaign.WinnerMetric == models.CampaignMetricClick {
+			return stat.ClickRate
+		}
+		return stat.OpenRate
+	}
+	for i := range stats {
+		if rate(stats[i]) > rate(stats[winner]) {
+			winner = i
+		}
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		for _, stat := range stats {
+			if err := tx.Model(&models.CampaignVariant{}).Where("id = ?", stat.VariantID).Updates(map[string]interface{}{
+				"sent":    stat.Sent,
+				"opened":  stat.Opened,
+				"clicked": stat.Clicked,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Campaign{}).Where("id = ? AND winner_variant_id IS NULL", campaign.ID).
+			Update("winner_variant_id", stats[winner].VariantID).Error
+	})
+	if err != nil {
+		utils.Errorf("选出获胜版本失败 (ID: %d): %v", campaign.ID, err)
+		return
+	}
+	campaign.WinnerVariantID = &stats[winner].VariantID
+	utils.Infof("营销活动A/B测试获胜版本: ID=%d, 版本=%s, 打开率=%.3f, 点击率=%.3f",
+		campaign.ID, stats[winner].Name, stats[winner].OpenRate, stats[winner].ClickRate)
+}
+
+// recipientVariant 收件人使用的A/B测试版本：测试收件人使用分配的版本，其余收件人使用获胜版本，没有时为nil
+func recipientVariant(campaign *models.Campaign, recipient *models.CampaignRecipient) *models.CampaignVariant {
+	variantID := recipient.VariantID
+	if variantID == nil {
+		variantID = campaign.WinnerVariantID
+	}
+	if variantID != nil {
+		for i := range campaign.Variants {
+			if campaign.Variants[i].ID == *variantID {
+				return &campaign.Variants[i]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"smtp-mail/backend/config"
+)
+
+func TestNormalizeABTestRequiresTracking(t *testing.T) {
+	cfg := &config.GetConfig().Tracking
+	enabled := cfg.Enabled
+	t.Cleanup(func() { cfg.Enabled = enabled })
+
+	newRequest := func() *CampaignRequest {
+		return &CampaignRequest{Track: true, Variants: []CampaignVariantRequest{{Subject: "one"}, {Subject: "two"}}}
+	}
+
+	cfg.Enabled = true
+	req := newRequest()
+	if err := req.normalizeABTest(); err != nil {
+		t.Fatalf("追踪开启时: %v", err)
+	}
+	if req.Variants[0].Name != "A" || req.Variants[1].Name != "B" || req.TestPercent != defaultTestPercent {
+		t.Errorf("默认设置: %+v", req)
+	}
+
+	cfg.Enabled = false
+	if err := newRequest().normalizeABTest(); !errors.Is(err, errABTestTrackingDisabled) {
+		t.Errorf("追踪全局关闭时返回 %v", err)
+	}
+
+	// 没有版本时不需要追踪
+	if err := (&CampaignRequest{}).normalizeABTest(); err != nil {
+		t.Errorf("没有版本时: %v", err)
+	}
+}
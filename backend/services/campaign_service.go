@@ -0,0 +1,679 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrCampaignState 营销活动当前状态不允许此操作
+var ErrCampaignState = errors.New("营销活动当前状态不允许此操作")
+
+// CampaignService 营销活动服务：管理活动和受众，由后台协程按计划和限速发送
+type CampaignService struct {
+	auditService     *AuditService
+	templateService  *TemplateService
+	smtpService      *SMTPService
+	contactService   *ContactService
+	workspaceService *WorkspaceService
+	emailService     *EmailService
+}
+
+// NewCampaignService 创建营销活动服务实例
+func NewCampaignService() *CampaignService {
+	return &CampaignService{
+		auditService:     NewAuditService(),
+		templateService:  NewTemplateService(),
+		smtpService:      NewSMTPService(),
+		contactService:   NewContactService(),
+		workspaceService: NewWorkspaceService(),
+		emailService:     NewEmailService(),
+	}
+}
+
+// CampaignRequest 创建或更新营销活动请求
+type CampaignRequest struct {
+	Name          string `json:"name" binding:"required"`
+	TemplateID    uint   `json:"template_id" binding:"required"`
+	SmtpConfigID  uint   `json:"smtp_config_id" binding:"required"`
+	GroupID       *uint  `json:"group_id"`
+	Bulk          bool   `json:"bulk"`
+	Track         bool   `json:"track"`
+	RatePerMinute int    `json:"rate_per_minute" binding:"min=0"`
+
+	// 备用SMTP配置，按顺序使用，见models.Campaign
+	FallbackSmtpConfigIDs []uint `json:"fallback_smtp_config_ids"`
+
+	// A/B测试，见CampaignVariantRequest
+	Variants          []CampaignVariantRequest `json:"variants"`
+	TestPercent       int                      `json:"test_percent" binding:"min=0,max=100"`
+	WinnerMetric      string                   `json:"winner_metric"`
+	WinnerWaitMinutes int                      `json:"winner_wait_minutes" binding:"min=0"`
+}
+
+// CampaignScheduleRequest 计划发送请求，scheduled_at为空表示立即开始
+type CampaignScheduleRequest struct {
+	ScheduledAt *time.Time `json:"scheduled_at"`
+}
+
+// CampaignListResponse 营销活动列表响应
+type CampaignListResponse struct {
+	List     []models.Campaign `json:"list"`
+	Total    int64             `json:"total"`
+	Page     int               `json:"page"`
+	PageSize int               `json:"pageSize"`
+}
+
+// CampaignRecipientListResponse 营销活动收件人列表响应
+type CampaignRecipientListResponse struct {
+	List     []models.CampaignRecipient `json:"list"`
+	Total    int64                      `json:"total"`
+	Page     int                        `json:"page"`
+	PageSize int                        `json:"pageSize"`
+}
+
+// CampaignRecipientsResult 上传收件人列表的结果
+type CampaignRecipientsResult struct {
+	Added   int64    `json:"added"`
+	Skipped int      `json:"skipped"` // 无效或重复的行
+	Errors  []string `json:"errors"`
+}
+
+// CampaignStats 营销活动统计：收件人的发送进度，以及发送历史中的投递、退信、打开和点击
+type CampaignStats struct {
+	Total      int64 `json:"total"`
+	Pending    int64 `json:"pending"`
+	Sent       int64 `json:"sent"`
+	Failed     int64 `json:"failed"`
+	Suppressed int64 `json:"suppressed"`
+	Delivered  int64 `json:"delivered"` // 发送成功且没有退信
+	Captured   int64 `json:"captured"`
+	Bounced    int64 `json:"bounced"`
+	Opened     int64 `json:"opened"`  // 至少打开过一次的收件人
+	Clicked    int64 `json:"clicked"` // 至少点击过一次的收件人
+
+	Variants []CampaignVariantStats `json:"variants,omitempty"` // A/B测试各版本的结果
+}
+
+// orderByID 按ID排序预加载的关联记录
+func orderByID(db *gorm.DB) *gorm.DB {
+	return db.Order("id")
+}
+
+// campaignEditable 草稿，或者还没开始发送就暂停的活动可以修改
+func campaignEditable(campaign *models.Campaign) bool {
+	return campaign.Status == models.CampaignDraft || (campaign.Status == models.CampaignPaused && campaign.StartedAt == nil)
+}
+
+// validate 检查模板（包括A/B测试版本的模板）、SMTP配置（包括备用配置）和分组对操作者可见
+func (s *CampaignService) validate(p *Principal, req *CampaignRequest) error {
+	if _, err := s.templateService.GetTemplateByID(p, req.TemplateID); err != nil {
+		return err
+	}
+	for _, variant := range req.Variants {
+		if variant.TemplateID != nil {
+			if _, err := s.templateService.GetTemplateByID(p, *variant.TemplateID); err != nil {
+				return fmt.Errorf("版本 %s 的模板: %w", variant.Name, err)
+			}
+		}
+	}
+	if !p.CanUseSMTPConfig(req.SmtpConfigID) {
+		return ErrForbidden
+	}
+	if _, err := s.smtpService.GetConfigByID(p, req.SmtpConfigID); err != nil {
+		return fmt.Errorf("获取SMTP配置失败: %w", err)
+	}
+	used := map[uint]bool{req.SmtpConfigID: true}
+	for _, id := range req.FallbackSmtpConfigIDs {
+		if used[id] {
+			return fmt.Errorf("备用SMTP配置 %d 重复", id)
+		}
+		used[id] = true
+		if !p.CanUseSMTPConfig(id) {
+			return ErrForbidden
+		}
+		if _, err := s.smtpService.GetConfigByID(p, id); err != nil {
+			return fmt.Errorf("获取备用SMTP配置 %d 失败: %w", id, err)
+		}
+	}
+	if req.GroupID != nil {
+		if _, err := s.contactService.getGroup(p, *req.GroupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListCampaigns 获取当前工作区的营销活动，status为空时返回全部
+func (s *CampaignService) ListCampaigns(p *Principal, page, pageSize int, status string) (*CampaignListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	db := p.ScopeWorkspace(database.GetDB().Model(&models.Campaign{}))
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("获取营销活动总数失败: %w", err)
+	}
+	var campaigns []models.Campaign
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&campaigns).Error; err != nil {
+		utils.Errorf("获取营销活动列表失败: %v", err)
+		return nil, fmt.Errorf("获取营销活动列表失败: %w", err)
+	}
+
+	return &CampaignListResponse{
+		List:     campaigns,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// GetCampaign 获取单个营销活动及其A/B测试版本
+func (s *CampaignService) GetCampaign(p *Principal, id uint) (*models.Campaign, error) {
+	var campaign models.Campaign
+	if err := p.ScopeWorkspace(database.GetDB()).Preload("Variants", orderByID).First(&campaign, id).Error; err != nil {
+		return nil, fmt.Errorf("营销活动不存在: %w", err)
+	}
+	return &campaign, nil
+}
+
+// CreateCampaign 创建营销活动草稿
+func (s *CampaignService) CreateCampaign(p *Principal, req *CampaignRequest) (*models.Campaign, error) {
+	if err := req.normalizeABTest(); err != nil {
+		return nil, err
+	}
+	if err := s.validate(p, req); err != nil {
+		return nil, err
+	}
+
+	campaign := models.Campaign{
+		WorkspaceID:   p.WorkspaceID,
+		Name:          req.Name,
+		TemplateID:    req.TemplateID,
+		SmtpConfigID:  req.SmtpConfigID,
+		GroupID:       req.GroupID,
+		Bulk:          req.Bulk,
+		Track:         req.Track,
+		RatePerMinute: req.RatePerMinute,
+		Status:        models.CampaignDraft,
+		CreatedBy:     p.UserID,
+
+		FallbackSmtpConfigIDs: req.FallbackSmtpConfigIDs,
+
+		TestPercent:       req.TestPercent,
+		WinnerMetric:      req.WinnerMetric,
+		WinnerWaitMinutes: req.WinnerWaitMinutes,
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&campaign).Error; err != nil {
+			return err
+		}
+		if err := replaceVariants(tx, campaign.ID, req.Variants); err != nil {
+			return err
+		}
+		if err := tx.Preload("Variants", orderByID).First(&campaign, campaign.ID).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityCampaign, campaign.ID, nil, &campaign)
+	})
+	if err != nil {
+		utils.Errorf("创建营销活动失败: %v", err)
+		return nil, fmt.Errorf("创建营销活动失败: %w", err)
+	}
+
+	utils.Infof("创建营销活动成功: ID=%d, Name=%s", campaign.ID, campaign.Name)
+	return &campaign, nil
+}
+
+// UpdateCampaign 更新营销活动，只能修改草稿或未开始发送的活动
+func (s *CampaignService) UpdateCampaign(p *Principal, id uint, req *CampaignRequest) (*models.Campaign, error) {
+	campaign, err := s.GetCampaign(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if !campaignEditable(campaign) {
+		return nil, ErrCampaignState
+	}
+	if err := req.normalizeABTest(); err != nil {
+		return nil, err
+	}
+	if err := s.validate(p, req); err != nil {
+		return nil, err
+	}
+
+	before := *campaign
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(campaign).Updates(map[string]interface{}{
+			"name":            req.Name,
+			"template_id":     req.TemplateID,
+			"smtp_config_id":  req.SmtpConfigID,
+			"group_id":        req.GroupID,
+			"bulk":            req.Bulk,
+			"track":           req.Track,
+			"rate_per_minute": req.RatePerMinute,
+
+			"fallback_smtp_config_ids": models.UintSlice(req.FallbackSmtpConfigIDs),
+
+			"test_percent":        req.TestPercent,
+			"winner_metric":       req.WinnerMetric,
+			"winner_wait_minutes": req.WinnerWaitMinutes,
+		}).Error; err != nil {
+			return err
+		}
+		if err := replaceVariants(tx, id, req.Variants); err != nil {
+			return err
+		}
+		if err := tx.Preload("Variants", orderByID).First(campaign, id).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityCampaign, id, &before, campaign)
+	})
+	if err != nil {
+		utils.Errorf("更新营销活动失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("更新营销活动失败: %w", err)
+	}
+	return campaign, nil
+}
+
+// DeleteCampaign 删除营销活动及其收件人，已计划或发送中的活动需要先暂停
+// 已发出邮件的发送历史保留，不再关联到活动
+func (s *CampaignService) DeleteCampaign(p *Principal, id uint) error {
+	campaign, err := s.GetCampaign(p, id)
+	if err != nil {
+		return err
+	}
+	if campaign.Status == models.CampaignScheduled || campaign.Status == models.CampaignSending {
+		return ErrCampaignState
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.EmailHistory{}).Where("campaign_id = ?", id).Update("campaign_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("campaign_id = ?", id).Delete(&models.CampaignRecipient{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("campaign_id = ?", id).Delete(&models.CampaignVariant{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(campaign).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityCampaign, id, campaign, nil)
+	})
+	if err != nil {
+		utils.Errorf("删除营销活动失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("删除营销活动失败: %w", err)
+	}
+	return nil
+}
+
+// ListRecipients 获取营销活动的收件人，status为空时返回全部
+func (s *CampaignService) ListRecipients(p *Principal, id uint, page, pageSize int, status string) (*CampaignRecipientListResponse, error) {
+	if _, err := s.GetCampaign(p, id); err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	db := database.GetDB().Model(&models.CampaignRecipient{}).Where("campaign_id = ?", id)
+	if status != "" {
+		db = db.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("获取收件人总数失败: %w", err)
+	}
+	var recipients []models.CampaignRecipient
+	if err := db.Order("id").Offset((page - 1) * pageSize).Limit(pageSize).Find(&recipients).Error; err != nil {
+		return nil, fmt.Errorf("获取收件人列表失败: %w", err)
+	}
+
+	return &CampaignRecipientListResponse{
+		List:     recipients,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// UploadRecipients 从CSV上传收件人列表，格式与联系人导入相同，其他列作为模板变量
+// 上传的收件人不会保存为联系人；与已有收件人重复的地址忽略
+func (s *CampaignService) UploadRecipients(p *Principal, id uint, r io.Reader) (*CampaignRecipientsResult, error) {
+	campaign, err := s.GetCampaign(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if !campaignEditable(campaign) {
+		return nil, ErrCampaignState
+	}
+
+	requests, errs, err := parseContactsCSV(r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CampaignRecipientsResult{Errors: errs, Skipped: len(errs)}
+	recipients := make([]models.CampaignRecipient, 0, len(requests))
+	for i := range requests {
+		req := &requests[i]
+		if err := req.normalize(); err != nil {
+			result.Skipped++
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", req.Email, err))
+			continue
+		}
+		variables := models.StringMap{"email": req.Email, "name": req.Name}
+		for key, value := range req.Attributes {
+			variables[key] = value
+		}
+		recipients = append(recipients, models.CampaignRecipient{
+			CampaignID: id,
+			Email:      req.Email,
+			Variables:  variables,
+			Source:     models.CampaignSourceUpload,
+			Status:     models.CampaignRecipientPending,
+		})
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		added, err := addCampaignRecipients(tx, recipients)
+		if err != nil {
+			return err
+		}
+		result.Added = added
+		return s.auditService.Record(tx, p, models.AuditActionImport, models.AuditEntityCampaign, id, nil, result)
+	})
+	if err != nil {
+		utils.Errorf("上传营销活动收件人失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("上传收件人失败: %w", err)
+	}
+	result.Skipped += len(recipients) - int(result.Added)
+	return result, nil
+}
+
+// ClearRecipients 清空上传的收件人列表，只能在修改活动时使用
+func (s *CampaignService) ClearRecipients(p *Principal, id uint) (int64, error) {
+	campaign, err := s.GetCampaign(p, id)
+	if err != nil {
+		return 0, err
+	}
+	if !campaignEditable(campaign) {
+		return 0, ErrCampaignState
+	}
+
+	var removed int64
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("campaign_id = ? AND source = ?", id, models.CampaignSourceUpload).Delete(&models.CampaignRecipient{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityCampaign, id,
+			map[string]interface{}{"removed_recipients": removed}, nil)
+	})
+	if err != nil {
+		utils.Errorf("清空营销活动收件人失败 (ID: %d): %v", id, err)
+		return 0, fmt.Errorf("清空收件人失败: %w", err)
+	}
+	return removed, nil
+}
+
+// addCampaignRecipients 批量写入收件人，已存在的地址忽略，返回新加入的数量
+func addCampaignRecipients(tx *gorm.DB, recipients []models.CampaignRecipient) (int64, error) {
+	if len(recipients) == 0 {
+		return 0, nil
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(recipients, 500)
+	return result.RowsAffected, result.Error
+}
+
+// Schedule 计划发送：检查创建者仍可使用模板和SMTP配置、受众不为空，到达计划时间后开始发送
+func (s *CampaignService) Schedule(p *Principal, id uint, req *CampaignScheduleRequest) (*models.Campaign, error) {
+	campaign, err := s.GetCampaign(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if !campaignEditable(campaign) {
+		return nil, ErrCampaignState
+	}
+	if err := s.preflight(campaign); err != nil {
+		return nil, err
+	}
+
+	scheduledAt := time.Now()
+	if req.ScheduledAt != nil && req.ScheduledAt.After(scheduledAt) {
+		scheduledAt = *req.ScheduledAt
+	}
+	campaign, err = s.transition(p, campaign, map[string]interface{}{
+		"status":       models.CampaignScheduled,
+		"scheduled_at": scheduledAt,
+		"last_error":   "",
+	})
+	if err != nil {
+		return nil, err
+	}
+	utils.Infof("营销活动已计划发送: ID=%d, 时间=%s", id, scheduledAt.Format(time.RFC3339))
+	wakeCampaignWorker()
+	return campaign, nil
+}
+
+// Pause 暂停已计划或发送中的活动，正在发送的一封邮件会发送完成
+func (s *CampaignService) Pause(p *Principal, id uint) (*models.Campaign, error) {
+	campaign, err := s.GetCampaign(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != models.CampaignScheduled && campaign.Status != models.CampaignSending {
+		return nil, ErrCampaignState
+	}
+	return s.transition(p, campaign, map[string]interface{}{"status": models.CampaignPaused})
+}
+
+// Resume 恢复暂停的活动：已开始发送的继续发送剩余收件人，否则回到计划状态
+func (s *CampaignService) Resume(p *Principal, id uint) (*models.Campaign, error) {
+	campaign, err := s.GetCampaign(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != models.CampaignPaused {
+		return nil, ErrCampaignState
+	}
+	if _, err := s.campaignPrincipal(campaign); err != nil {
+		return nil, err
+	}
+
+	status := models.CampaignSending
+	if campaign.StartedAt == nil {
+		status = models.CampaignScheduled
+	}
+	campaign, err = s.transition(p, campaign, map[string]interface{}{
+		"status":       status,
+		"last_error":   "",
+		"next_send_at": nil,
+	})
+	if err != nil {
+		return nil, err
+	}
+	wakeCampaignWorker()
+	return campaign, nil
+}
+
+// transition 改变活动状态并记录审计日志；只在状态未被后台协程同时修改时生效
+func (s *CampaignService) transition(p *Principal, campaign *models.Campaign, updates map[string]interface{}) (*models.Campaign, error) {
+	before := *campaign
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Campaign{}).Where("id = ? AND status = ?", campaign.ID, campaign.Status).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrCampaignState
+		}
+		if err := tx.First(campaign, campaign.ID).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityCampaign, campaign.ID, &before, campaign)
+	})
+	if err != nil {
+		utils.Errorf("更新营销活动状态失败 (ID: %d): %v", campaign.ID, err)
+		return nil, fmt.Errorf("更新营销活动状态失败: %w", err)
+	}
+	return campaign, nil
+}
+
+// preflight 发送前检查：以创建者身份检查模板和SMTP配置，受众不能为空，批量和追踪需要外部访问地址
+func (s *CampaignService) preflight(campaign *models.Campaign) error {
+	p, err := s.campaignPrincipal(campaign)
+	if err != nil {
+		return err
+	}
+	req := &CampaignRequest{
+		TemplateID:   campaign.TemplateID,
+		SmtpConfigID: campaign.SmtpConfigID,
+		GroupID:      campaign.GroupID,
+		Variants:     variantRequests(campaign.Variants),
+
+		FallbackSmtpConfigIDs: campaign.FallbackSmtpConfigIDs,
+	}
+	if err := s.validate(p, req); err != nil {
+		return err
+	}
+	if len(campaign.Variants) > 0 && !TrackingEnabled() {
+		return errABTestTrackingDisabled
+	}
+	if campaign.Bulk || campaign.Track {
+		if _, err := publicURL("批量发送和追踪"); err != nil {
+			return err
+		}
+	}
+
+	var uploaded int64
+	if err := database.GetDB().Model(&models.CampaignRecipient{}).Where("campaign_id = ?", campaign.ID).Count(&uploaded).Error; err != nil {
+		return fmt.Errorf("查询收件人失败: %w", err)
+	}
+	if uploaded > 0 {
+		return nil
+	}
+	if campaign.GroupID != nil {
+		var members int64
+		if err := database.GetDB().Model(&models.ContactGroupMember{}).Where("group_id = ?", *campaign.GroupID).Count(&members).Error; err != nil {
+			return fmt.Errorf("查询分组成员失败: %w", err)
+		}
+		if members > 0 {
+			return nil
+		}
+	}
+	return errors.New("营销活动没有收件人，请设置分组或上传收件人列表")
+}
+
+// campaignPrincipal 以活动创建者在活动所属工作区内的身份发送，创建者被禁用或失去发送权限时无法发送
+func (s *CampaignService) campaignPrincipal(campaign *models.Campaign) (*Principal, error) {
+	var user models.User
+	if err := database.GetDB().First(&user, campaign.CreatedBy).Error; err != nil {
+		return nil, fmt.Errorf("活动创建者不存在: %w", err)
+	}
+	if user.Disabled {
+		return nil, fmt.Errorf("活动创建者 %s 已被禁用: %w", user.Username, ErrForbidden)
+	}
+
+	p := &Principal{
+		UserID:     user.ID,
+		Username:   user.Username,
+		Role:       user.Role,
+		SystemRole: user.Role,
+	}
+	if err := s.workspaceService.Resolve(p, strconv.FormatUint(uint64(campaign.WorkspaceID), 10)); err != nil {
+		return nil, fmt.Errorf("活动创建者 %s 不再是工作区成员: %w", user.Username, err)
+	}
+	if err := p.Authorize(PermEmailSend); err != nil {
+		return nil, fmt.Errorf("活动创建者 %s 没有发送邮件权限: %w", user.Username, err)
+	}
+	return p, nil
+}
+
+// Stats 营销活动统计
+func (s *CampaignService) Stats(p *Principal, id uint) (*CampaignStats, error) {
+	campaign, err := s.GetCampaign(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	type statusCount struct {
+		Status string
+		Count  int64
+	}
+	stats := &CampaignStats{}
+
+	var recipients []statusCount
+	if err := db.Model(&models.CampaignRecipient{}).Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", id).Group("status").Scan(&recipients).Error; err != nil {
+		return nil, fmt.Errorf("统计收件人失败: %w", err)
+	}
+	for _, row := range recipients {
+		stats.Total += row.Count
+		switch row.Status {
+		case models.CampaignRecipientPending, models.CampaignRecipientSending:
+			stats.Pending += row.Count
+		case models.CampaignRecipientSent:
+			stats.Sent = row.Count
+		case models.CampaignRecipientFailed:
+			stats.Failed = row.Count
+		case models.CampaignRecipientSuppressed:
+			stats.Suppressed = row.Count
+		}
+	}
+
+	var histories []statusCount
+	if err := db.Model(&models.EmailHistory{}).Select("status, COUNT(*) AS count").
+		Where("campaign_id = ?", id).Group("status").Scan(&histories).Error; err != nil {
+		return nil, fmt.Errorf("统计发送历史失败: %w", err)
+	}
+	for _, row := range histories {
+		switch models.EmailStatus(row.Status) {
+		case models.EmailStatusSuccess:
+			stats.Delivered = row.Count
+		case models.EmailStatusCaptured:
+			stats.Captured = row.Count
+		case models.EmailStatusBounced:
+			stats.Bounced = row.Count
+		}
+	}
+
+	campaignHistories := db.Model(&models.EmailHistory{}).Select("id").Where("campaign_id = ?", id)
+	if err := db.Model(&models.EmailRecipient{}).Where("history_id IN (?) AND open_count > 0", campaignHistories).Count(&stats.Opened).Error; err != nil {
+		return nil, fmt.Errorf("统计打开失败: %w", err)
+	}
+	if err := db.Model(&models.EmailRecipient{}).Where("history_id IN (?) AND click_count > 0", campaignHistories).Count(&stats.Clicked).Error; err != nil {
+		return nil, fmt.Errorf("统计点击失败: %w", err)
+	}
+
+	if len(campaign.Variants) > 0 {
+		if stats.Variants, err = variantStats(campaign); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// campaignPollInterval 检查到期和发送中活动的间隔，也是每个活动每轮最长的发送时间
+const campaignPollInterval = 5 * time.Second
+
+// campaignClaimTimeout 收件人被认领后超过该时间仍未完成发送（进程在发送中退出）时重新发送
+const campaignClaimTimeout = 10 * time.Minute
+
+// campaignWake 计划或恢复活动时唤醒发送协程
+var campaignWake = make(chan struct{}, 1)
+
+// wakeCampaignWorker 唤醒发送协程，不阻塞
+func wakeCampaignWorker() {
+	select {
+	case campaignWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartWorker 启动营销活动发送协程
+// 活动状态和收件人进度保存在数据库中，服务重启后从未发送的收件人继续
+func (s *CampaignService) StartWorker(ctx context.Context) {
+	go func() {
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			case <-campaignWake:
+				timer.Stop()
+			}
+			s.processDue(ctx)
+			timer.Reset(campaignPollInterval)
+		}
+	}()
+}
+
+// processDue 开始到达计划时间的活动，并为每个发送中的活动发送一批邮件
+func (s *CampaignService) processDue(ctx context.Context) {
+	db := database.GetDB()
+
+	var due []models.Campaign
+	if err := db.Where("status = ? AND scheduled_at <= ?", models.CampaignScheduled, time.Now()).Preload("Variants", orderByID).
+		Order("scheduled_at").Find(&due).Error; err != nil {
+		utils.Errorf("查询到期的营销活动失败: %v", err)
+		return
+	}
+	for i := range due {
+		s.start(&due[i])
+	}
+
+	// 进程在发送中退出时，已认领的收件人停留在sending，超时后重新发送
+	if err := db.Model(&models.CampaignRecipient{}).
+		Where("status = ? AND sent_at < ?", models.CampaignRecipientSending, time.Now().Add(-campaignClaimTimeout)).
+		Updates(map[string]interface{}{"status": models.CampaignRecipientPending, "sent_at": nil}).Error; err != nil {
+		utils.Errorf("恢复超时的营销活动收件人失败: %v", err)
+	}
+
+	var sending []models.Campaign
+	if err := db.Where("status = ?", models.CampaignSending).Preload("Variants", orderByID).Order("id").Find(&sending).Error; err != nil {
+		utils.Errorf("查询发送中的营销活动失败: %v", err)
+		return
+	}
+	for i := range sending {
+		if ctx.Err() != nil {
+			return
+		}
+		s.sendBatch(ctx, &sending[i])
+	}
+}
+
+// start 开始发送：复制模板的主题和正文，把分组成员加入收件人（与上传的收件人去重）
+// 有A/B测试版本时，确定各版本的内容并随机抽取测试收件人
+func (s *CampaignService) start(campaign *models.Campaign) {
+	p, err := s.campaignPrincipal(campaign)
+	if err != nil {
+		s.autoPause(campaign, err)
+		return
+	}
+	template, err := s.templateService.GetTemplateByID(p, campaign.TemplateID)
+	if err != nil {
+		s.autoPause(campaign, err)
+		return
+	}
+	// 计划发送后追踪被全局关闭时不开始测试
+	if len(campaign.Variants) > 0 && !TrackingEnabled() {
+		s.autoPause(campaign, errABTestTrackingDisabled)
+		return
+	}
+	variants, err := s.resolveVariants(p, campaign, template)
+	if err != nil {
+		s.autoPause(campaign, err)
+		return
+	}
+	var recipients []models.CampaignRecipient
+	if campaign.GroupID != nil {
+		contacts, err := s.contactService.ExpandGroups(p, []uint{*campaign.GroupID})
+		if err != nil {
+			s.autoPause(campaign, err)
+			return
+		}
+		for i := range contacts {
+			recipients = append(recipients, models.CampaignRecipient{
+				CampaignID: campaign.ID,
+				Email:      contacts[i].Email,
+				Variables:  ContactVariables(&contacts[i]),
+				Source:     models.CampaignSourceGroup,
+				Status:     models.CampaignRecipientPending,
+			})
+		}
+	}
+
+	now := time.Now()
+	started := false
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Campaign{}).Where("id = ? AND status = ?", campaign.ID, models.CampaignScheduled).Updates(map[string]interface{}{
+			"status":           models.CampaignSending,
+			"subject":          template.Subject,
+			"body":             template.Body,
+			"template_version": template.Version,
+			"started_at":       now,
+			"next_send_at":     nil,
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			// 同时被暂停时不开始
+			return result.Error
+		}
+		started = true
+		if _, err := addCampaignRecipients(tx, recipients); err != nil {
+			return err
+		}
+		if len(variants) == 0 {
+			return nil
+		}
+		for _, variant := range variants {
+			if err := tx.Model(&variant).Updates(map[string]interface{}{
+				"subject":          variant.Subject,
+				"body":             variant.Body,
+				"template_version": variant.TemplateVersion,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return assignTestGroup(tx, campaign, variants)
+	})
+	if err != nil {
+		utils.Errorf("开始营销活动失败 (ID: %d): %v", campaign.ID, err)
+		return
+	}
+	if !started {
+		return
+	}
+	utils.Infof("营销活动开始发送: ID=%d, 分组成员=%d", campaign.ID, len(recipients))
+}
+
+// sendBatch 按限速逐个发送未发送的收件人，最长发送一个轮询间隔；活动被暂停时停止
+// A/B测试在选出获胜版本前只发送测试收件人
+func (s *CampaignService) sendBatch(ctx context.Context, campaign *models.Campaign) {
+	p, err := s.campaignPrincipal(campaign)
+	if err != nil {
+		s.autoPause(campaign, err)
+		return
+	}
+
+	db := database.GetDB()
+	deadline := time.Now().Add(campaignPollInterval)
+	for ctx.Err() == nil {
+		if campaign.NextSendAt != nil {
+			wait := time.Until(*campaign.NextSendAt)
+			if time.Now().Add(wait).After(deadline) {
+				return
+			}
+			if wait > 0 {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(wait):
+				}
+			}
+		} else if time.Now().After(deadline) {
+			return
+		}
+
+		var current models.Campaign
+		if err := db.Select("status").First(&current, campaign.ID).Error; err != nil || current.Status != models.CampaignSending {
+			return
+		}
+
+		testing := len(campaign.Variants) > 0 && campaign.WinnerVariantID == nil
+		query := db.Where("campaign_id = ? AND status = ?", campaign.ID, models.CampaignRecipientPending)
+		if testing {
+			query = query.Where("variant_id IS NOT NULL")
+		}
+		var recipient models.CampaignRecipient
+		err := query.Order("id").First(&recipient).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 其他进程仍在发送的收件人完成前不结束测试或活动
+			var sending int64
+			if err := db.Model(&models.CampaignRecipient{}).
+				Where("campaign_id = ? AND status = ?", campaign.ID, models.CampaignRecipientSending).
+				Count(&sending).Error; err != nil || sending > 0 {
+				return
+			}
+			if testing {
+				s.awaitWinner(campaign)
+				if campaign.WinnerVariantID != nil {
+					continue
+				}
+				return
+			}
+			s.complete(campaign)
+			return
+		}
+		if err != nil {
+			utils.Errorf("查询营销活动收件人失败 (ID: %d): %v", campaign.ID, err)
+			return
+		}
+
+		claimed, err := claimRecipient(&recipient)
+		if err != nil {
+			utils.Errorf("认领营销活动收件人失败 (ID: %d): %v", recipient.ID, err)
+			return
+		}
+		if !claimed {
+			// 已被其他进程认领
+			continue
+		}
+		if err := s.sendOne(p, campaign, &recipient); err != nil {
+			s.autoPause(campaign, err)
+			return
+		}
+
+		if campaign.RatePerMinute > 0 {
+			next := time.Now().Add(time.Minute / time.Duration(campaign.RatePerMinute))
+			campaign.NextSendAt = &next
+			db.Model(&models.Campaign{}).Where("id = ?", campaign.ID).Update("next_send_at", next)
+		}
+	}
+}
+
+// claimRecipient 认领未发送的收件人：状态改为sending并把认领时间记录在sent_at，
+// 只有更新成功的进程发送，多个服务进程共用数据库时同一收件人不会被重复发送
+func claimRecipient(recipient *models.CampaignRecipient) (bool, error) {
+	result := database.GetDB().Model(&models.CampaignRecipient{}).
+		Where("id = ? AND status = ?", recipient.ID, models.CampaignRecipientPending).
+		Updates(map[string]interface{}{"status": models.CampaignRecipientSending, "sent_at": time.Now()})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// sendOne 向已认领的收件人发送，并记录结果
+// 配额用完或创建者失去权限时返回错误，收件人恢复为未发送，活动暂停
+func (s *CampaignService) sendOne(p *Principal, campaign *models.Campaign, recipient *models.CampaignRecipient) error {
+	req := &SendEmailRequest{
+		SmtpConfigID: campaign.SmtpConfigID,
+		To:           []string{recipient.Email},
+		Subject:      campaign.Subject,
+		Body:         campaign.Body,
+		Bulk:         campaign.Bulk,
+		Track:        campaign.Track,
+		variables:    recipient.Variables,
+		campaignID:   &campaign.ID,
+
+		fallbackSmtpConfigIDs: campaign.FallbackSmtpConfigIDs,
+
+		templateID:      &campaign.TemplateID,
+		templateVersion: campaign.TemplateVersion,
+	}
+	if variant := recipientVariant(campaign, recipient); variant != nil {
+		req.Subject, req.Body, req.templateVersion = variant.Subject, variant.Body, variant.TemplateVersion
+		if variant.TemplateID != nil {
+			req.templateID = variant.TemplateID
+		}
+	}
+	if req.templateVersion == 0 {
+		// 模板版本功能之前开始的活动没有记录版本
+		req.templateID = nil
+	}
+	history, err := s.emailService.SendEmail(p, req)
+	if errors.Is(err, ErrQuotaExceeded) || errors.Is(err, ErrForbidden) {
+		database.GetDB().Model(recipient).Updates(map[string]interface{}{"status": models.CampaignRecipientPending, "sent_at": nil})
+		return err
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": models.CampaignRecipientSent, "error": "", "sent_at": now}
+	switch {
+	case errors.Is(err, ErrAllRecipientsSuppressed):
+		updates["status"] = models.CampaignRecipientSuppressed
+	case err != nil:
+		updates["status"] = models.CampaignRecipientFailed
+		updates["error"] = err.Error()
+	}
+	if history != nil {
+		updates["history_id"] = history.ID
+	}
+	if err := database.GetDB().Model(recipient).Updates(updates).Error; err != nil {
+		utils.Errorf("更新营销活动收件人失败 (ID: %d): %v", recipient.ID, err)
+	}
+	return nil
+}
+
+// complete 所有收件人都已处理，活动完成
+func (s *CampaignService) complete(campaign *models.Campaign) {
+	err := database.GetDB().Model(&models.Campaign{}).Where("id = ? AND status = ?", campaign.ID, models.CampaignSending).
+		Updates(map[string]interface{}{"status": models.CampaignCompleted, "completed_at": time.Now(), "next_send_at": nil}).Error
+	if err != nil {
+		utils.Errorf("更新营销活动状态失败 (ID: %d): %v", campaign.ID, err)
+		return
+	}
+	utils.Infof("营销活动发送完成: ID=%d", campaign.ID)
+}
+
+// autoPause 无法继续发送时暂停活动并记录原因，排除问题后可以恢复
+func (s *CampaignService) autoPause(campaign *models.Campaign, cause error) {
+	utils.Warnf("营销活动已暂停 (ID: %d): %v", campaign.ID, cause)
+	err := database.GetDB().Model(&models.Campaign{}).
+		Where("id = ? AND status IN ?", campaign.ID, []models.CampaignStatus{models.CampaignScheduled, models.CampaignSending}).
+		Updates(map[string]interface{}{"status": models.CampaignPaused, "last_error": cause.Error()}).Error
+	if err != nil {
+		utils.Errorf("暂停营销活动失败 (ID: %d): %v", campaign.ID, err)
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+)
+
+func TestClaimRecipient(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		db := database.GetDB()
+		recipient := models.CampaignRecipient{CampaignID: 1, Email: "bob@example.com", Source: models.CampaignSourceUpload,
+			Status: models.CampaignRecipientPending}
+		if err := db.Create(&recipient).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		// 两个进程读到同一个未发送的收件人，只有一个能认领
+		first, second := recipient, recipient
+		if claimed, err := claimRecipient(&first); err != nil || !claimed {
+			t.Fatalf("第一次认领 %v (%v)", claimed, err)
+		}
+		if claimed, err := claimRecipient(&second); err != nil || claimed {
+			t.Errorf("第二次认领 %v (%v)，want false", claimed, err)
+		}
+
+		// 认领超时的收件人恢复为未发送
+		db.Model(&recipient).Update("sent_at", time.Now().Add(-2*campaignClaimTimeout))
+		NewCampaignService().processDue(context.Background())
+		var reset models.CampaignRecipient
+		db.First(&reset, recipient.ID)
+		if reset.Status != models.CampaignRecipientPending || reset.SentAt != nil {
+			t.Errorf("超时后状态为 %s，sent_at=%v", reset.Status, reset.SentAt)
+		}
+	})
+}
+
+func TestSendOneFailover(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		useTestKeyring(t, testKey1)
+		db := database.GetDB()
+		if err := db.Create(&models.Workspace{ID: 1, Name: "one", Slug: "one"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		fallback := newFakeSMTP(t, "250 ok")
+		configs := []models.SMTPConfig{
+			{WorkspaceID: 1, Name: "primary", Host: "127.0.0.1", Port: closedPort(t), FromEmail: "from@example.com"},
+			{WorkspaceID: 1, Name: "fallback", Host: "127.0.0.1", Port: fallback.listener.Addr().(*net.TCPAddr).Port, FromEmail: "from@example.com"},
+		}
+		if err := db.Create(&configs).Error; err != nil {
+			t.Fatal(err)
+		}
+		campaign := models.Campaign{WorkspaceID: 1, Name: "news", TemplateID: 1, SmtpConfigID: configs[0].ID,
+			FallbackSmtpConfigIDs: models.UintSlice{configs[1].ID}, Status: models.CampaignSending, Subject: "hello", Body: "<p>hi</p>"}
+		if err := db.Create(&campaign).Error; err != nil {
+			t.Fatal(err)
+		}
+		recipient := models.CampaignRecipient{CampaignID: campaign.ID, Email: "bob@example.com", Source: models.CampaignSourceUpload,
+			Status: models.CampaignRecipientSending}
+		if err := db.Create(&recipient).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		// 从数据库读出的活动保留备用配置，主配置无法连接时改用备用配置发送
+		var stored models.Campaign
+		if err := db.First(&stored, campaign.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(stored.FallbackSmtpConfigIDs) != 1 || stored.FallbackSmtpConfigIDs[0] != configs[1].ID {
+			t.Fatalf("备用配置为 %v", stored.FallbackSmtpConfigIDs)
+		}
+		p := &Principal{UserID: 1, Role: models.RoleAdmin, WorkspaceID: 1}
+		if err := NewCampaignService().sendOne(p, &stored, &recipient); err != nil {
+			t.Fatalf("发送失败: %v", err)
+		}
+
+		var sent models.CampaignRecipient
+		db.First(&sent, recipient.ID)
+		if sent.Status != models.CampaignRecipientSent || fallback.count() != 1 {
+			t.Errorf("收件人状态 %s（%s），备用配置收到 %d 封", sent.Status, sent.Error, fallback.count())
+		}
+	})
+}
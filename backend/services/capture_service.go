@@ -0,0 +1,217 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// ErrCaptureAttachmentNotFound 捕获邮件中不存在指定附件
+var ErrCaptureAttachmentNotFound = errors.New("附件不存在")
+
+// CaptureService 捕获模式服务：保存未投递的邮件并提供类似收件箱的查看接口
+type CaptureService struct {
+	auditService *AuditService
+}
+
+// NewCaptureService 创建捕获服务实例
+func NewCaptureService() *CaptureService {
+	return &CaptureService{
+		auditService: NewAuditService(),
+	}
+}
+
+// CaptureEnabled 判断使用指定SMTP配置发送的邮件是否应被捕获（全局捕获模式或配置开启捕获）
+func CaptureEnabled(smtpConfig *models.SMTPConfig) bool {
+	return config.GetConfig().SMTP.Capture || smtpConfig.Capture
+}
+
+// CaptureListResponse 捕获邮件列表响应
+type CaptureListResponse struct {
+	List     []models.CapturedMessage `json:"list"`
+	Total    int64                    `json:"total"`
+	Page     int                      `json:"page"`
+	PageSize int                      `json:"pageSize"`
+}
+
+// CapturedMessageDetail 捕获邮件详情，包括解析后的邮件头、正文、附件和MIME结构
+type CapturedMessageDetail struct {
+	models.CapturedMessage
+	Message *ParsedMessage `json:"message"`
+}
+
+// save 保存捕获的邮件
+func (s *CaptureService) save(workspaceID, userID uint, smtpConfig *models.SMTPConfig, historyID uint, recipients []string, subject string, message []byte) (*models.CapturedMessage, error) {
+	captured := &models.CapturedMessage{
+		WorkspaceID:  workspaceID,
+		SmtpConfigID: smtpConfig.ID,
+		HistoryID:    historyID,
+		UserID:       userID,
+		FromEmail:    smtpConfig.FromEmail,
+		Recipients:   recipients,
+		Subject:      subject,
+		Size:         len(message),
+		Raw:          string(message),
+	}
+	if err := database.GetDB().Create(captured).Error; err != nil {
+		utils.Errorf("保存捕获邮件失败: %v", err)
+		return nil, fmt.Errorf("保存捕获邮件失败: %w", err)
+	}
+
+	utils.Infof("捕获模式：邮件未投递，已保存 (ID: %d, SmtpConfigID: %d, To: %v)", captured.ID, smtpConfig.ID, recipients)
+	return captured, nil
+}
+
+// ListMessages 获取捕获的邮件列表（不含原始内容），query按主题或收件人模糊匹配
+func (s *CaptureService) ListMessages(p *Principal, page, pageSize int, query string) (*CaptureListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	db := p.ScopeHistory(database.GetDB().Model(&models.CapturedMessage{}))
+	if query != "" {
+		like := "%" + query + "%"
+		db = db.Where("subject LIKE ? OR recipients LIKE ?", like, like)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		utils.Errorf("获取捕获邮件总数失败: %v", err)
+		return nil, fmt.Errorf("获取捕获邮件总数失败: %w", err)
+	}
+
+	var messages []models.CapturedMessage
+	if err := db.Omit("raw").Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&messages).Error; err != nil {
+		utils.Errorf("获取捕获邮件列表失败: %v", err)
+		return nil, fmt.Errorf("获取捕获邮件列表失败: %w", err)
+	}
+
+	return &CaptureListResponse{
+		List:     messages,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// GetRaw 获取捕获的邮件（包含原始内容）
+func (s *CaptureService) GetRaw(p *Principal, id uint) (*models.CapturedMessage, error) {
+	var captured models.CapturedMessage
+	if err := p.ScopeHistory(database.GetDB()).First(&captured, id).Error; err != nil {
+		return nil, fmt.Errorf("获取捕获邮件失败: %w", err)
+	}
+	return &captured, nil
+}
+
+// GetMessage 获取捕获邮件详情并解析邮件内容
+func (s *CaptureService) GetMessage(p *Principal, id uint) (*CapturedMessageDetail, error) {
+	captured, err := s.GetRaw(p, id)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := ParseMessage(strings.NewReader(captured.Raw))
+	if err != nil {
+		return nil, err
+	}
+	return &CapturedMessageDetail{CapturedMessage: *captured, Message: parsed}, nil
+}
+
+// GetAttachment 获取捕获邮件中的第index个附件（从0开始，顺序与详情中的attachments相同）
+func (s *CaptureService) GetAttachment(p *Principal, id uint, index int) (*MIMEPart, error) {
+	detail, err := s.GetMessage(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(detail.Message.Attachments) {
+		return nil, ErrCaptureAttachmentNotFound
+	}
+	return detail.Message.Attachments[index], nil
+}
+
+// cidPattern 匹配HTML中的 cid: 引用
+var cidPattern = regexp.MustCompile(`(?i)cid:([^"'\s)>]+)`)
+
+// Preview 渲染邮件预览HTML：使用HTML正文（没有时转换纯文本正文），cid:引用的内嵌资源替换为data URI
+func (s *CaptureService) Preview(p *Principal, id uint) (string, error) {
+	detail, err := s.GetMessage(p, id)
+	if err != nil {
+		return "", err
+	}
+	return renderPreview(detail.Message), nil
+}
+
+// renderPreview 渲染解析后邮件的预览HTML
+func renderPreview(parsed *ParsedMessage) string {
+	body := parsed.HTML
+	if body == "" {
+		return TextToHTML(parsed.Text)
+	}
+
+	inline := map[string]*MIMEPart{}
+	for _, part := range parsed.Attachments {
+		if part.ContentID != "" {
+			inline[strings.ToLower(part.ContentID)] = part
+		}
+	}
+	return cidPattern.ReplaceAllStringFunc(body, func(ref string) string {
+		part, ok := inline[strings.ToLower(ref[len("cid:"):])]
+		if !ok {
+			return ref
+		}
+		return "data:" + part.ContentType + ";base64," + base64.StdEncoding.EncodeToString(part.body)
+	})
+}
+
+// DeleteMessage 删除捕获的邮件
+func (s *CaptureService) DeleteMessage(p *Principal, id uint) error {
+	db := database.GetDB()
+
+	var captured models.CapturedMessage
+	if err := p.ScopeHistory(db).Omit("raw").First(&captured, id).Error; err != nil {
+		return fmt.Errorf("捕获邮件不存在: %w", err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&captured).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityCapture, id, &captured, nil)
+	})
+	if err != nil {
+		utils.Errorf("删除捕获邮件失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("删除捕获邮件失败: %w", err)
+	}
+	return nil
+}
+
+// ClearMessages 清空当前用户可见的全部捕获邮件，返回删除数量
+func (s *CaptureService) ClearMessages(p *Principal) (int64, error) {
+	var deleted int64
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := p.ScopeHistory(tx).Delete(&models.CapturedMessage{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityCapture, 0, nil, map[string]int64{"deleted": deleted})
+	})
+	if err != nil {
+		utils.Errorf("清空捕获邮件失败: %v", err)
+		return 0, fmt.Errorf("清空捕获邮件失败: %w", err)
+	}
+
+	utils.Infof("已清空捕获邮件: %d 封", deleted)
+	return deleted, nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/config"
+)
+
+// clamdChunkSize INSTREAM每个数据块的大小，需小于clamd的StreamMaxLength
+const clamdChunkSize = 64 * 1024
+
+func init() {
+	RegisterScanner("clamav", func(cfg config.ScannerConfig) (Scanner, error) {
+		network, address := parseClamdAddress(cfg.Address)
+		if address == "" {
+			return nil, fmt.Errorf("clamd地址不能为空")
+		}
+		return &ClamAVScanner{network: network, address: address, timeout: cfg.Timeout}, nil
+	})
+}
+
+// ClamAVScanner 通过clamd的INSTREAM协议扫描附件
+type ClamAVScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// parseClamdAddress 解析clamd地址：unix:/path、tcp:host:port、/path（unix）或host:port（tcp）
+func parseClamdAddress(addr string) (network, address string) {
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		return "unix", strings.TrimPrefix(addr, "unix:")
+	case strings.HasPrefix(addr, "tcp:"):
+		return "tcp", strings.TrimPrefix(addr, "tcp:")
+	case strings.HasPrefix(addr, "/"):
+		return "unix", addr
+	}
+	return "tcp", addr
+}
+
+// Name 返回引擎名称
+func (s *ClamAVScanner) Name() string {
+	return "clamav"
+}
+
+// Scan 将内容分块发送给clamd并解析结果
+func (s *ClamAVScanner) Scan(ctx context.Context, r io.Reader) (*ScanVerdict, error) {
+	if s.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("连接clamd失败: %w", err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return nil, fmt.Errorf("发送INSTREAM命令失败: %w", err)
+	}
+
+	// 每个数据块前为4字节大端长度，长度为0的块表示结束
+	buf := make([]byte, 4+clamdChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf[:4], uint32(n))
+			if _, err := conn.Write(buf[:4+n]); err != nil {
+				return nil, fmt.Errorf("发送扫描数据失败: %w", err)
+			}
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			return nil, fmt.Errorf("读取附件失败: %w", readErr)
+		}
+	}
+	if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("发送扫描数据失败: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && reply == "" {
+		return nil, fmt.Errorf("读取clamd响应失败: %w", err)
+	}
+	return parseClamdReply(strings.TrimRight(reply, "\x00\n"))
+}
+
+// parseClamdReply 解析clamd响应，如 "stream: OK"、"stream: Eicar-Test-Signature FOUND"
+func parseClamdReply(reply string) (*ScanVerdict, error) {
+	result := reply
+	if i := strings.Index(reply, ": "); i >= 0 {
+		result = reply[i+2:]
+	}
+	switch {
+	case result == "OK":
+		return &ScanVerdict{}, nil
+	case strings.HasSuffix(result, " FOUND"):
+		return &ScanVerdict{Infected: true, Signature: strings.TrimSuffix(result, " FOUND")}, nil
+	}
+	return nil, fmt.Errorf("clamd返回错误: %s", reply)
+}
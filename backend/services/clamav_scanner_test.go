@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"smtp-mail/backend/config"
+)
+
+// fakeClamd 模拟clamd的INSTREAM协议，记录收到的数据块，内容包含signature时报告病毒
+type fakeClamd struct {
+	listener  net.Listener
+	signature string
+	command   chan string
+	chunks    chan []int
+	data      chan []byte
+}
+
+func newFakeClamd(t *testing.T, signature string) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	clamd := &fakeClamd{
+		listener:  listener,
+		signature: signature,
+		command:   make(chan string, 4),
+		chunks:    make(chan []int, 4),
+		data:      make(chan []byte, 4),
+	}
+	go clamd.serve()
+	return clamd
+}
+
+func (f *fakeClamd) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.handle(conn)
+	}
+}
+
+func (f *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	f.command <- command
+
+	var sizes []int
+	var data bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		sizes = append(sizes, int(size))
+		if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+			return
+		}
+	}
+	f.chunks <- sizes
+	f.data <- data.Bytes()
+
+	reply := "stream: OK"
+	if f.signature != "" && bytes.Contains(data.Bytes(), []byte("EICAR")) {
+		reply = "stream: " + f.signature + " FOUND"
+	}
+	conn.Write([]byte(reply + "\x00"))
+}
+
+func newTestClamAVScanner(t *testing.T, address string) Scanner {
+	t.Helper()
+	scanner, err := NewScanner(config.ScannerConfig{Enabled: true, Engine: "clamav", Address: "tcp:" + address, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("创建扫描引擎失败: %v", err)
+	}
+	return scanner
+}
+
+func TestClamAVScannerChunks(t *testing.T) {
+	clamd := newFakeClamd(t, "")
+	scanner := newTestClamAVScanner(t, clamd.listener.Addr().String())
+
+	// 超过两个数据块的内容，最后一块不满
+	content := bytes.Repeat([]byte("0123456789abcdef"), (2*clamdChunkSize+100)/16)
+	verdict, err := scanner.Scan(context.Background(), bytes.NewReader(content))
+	if err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	if verdict.Infected {
+		t.Errorf("干净的内容被判定为病毒: %+v", verdict)
+	}
+
+	if command := <-clamd.command; command != "zINSTREAM\x00" {
+		t.Errorf("命令为 %q，want zINSTREAM", command)
+	}
+	sizes := <-clamd.chunks
+	if len(sizes) != 3 || sizes[0] != clamdChunkSize || sizes[1] != clamdChunkSize || sizes[2] != len(content)-2*clamdChunkSize {
+		t.Errorf("数据块大小为 %v", sizes)
+	}
+	if data := <-clamd.data; !bytes.Equal(data, content) {
+		t.Errorf("clamd收到 %d 字节，与发送的 %d 字节不一致", len(data), len(content))
+	}
+}
+
+func TestClamAVScannerInfected(t *testing.T) {
+	clamd := newFakeClamd(t, "Eicar-Test-Signature")
+	scanner := newTestClamAVScanner(t, clamd.listener.Addr().String())
+
+	verdict, err := scanner.Scan(context.Background(), strings.NewReader("X5O!P%@AP[4\\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*"))
+	if err != nil {
+		t.Fatalf("扫描失败: %v", err)
+	}
+	if !verdict.Infected || verdict.Signature != "Eicar-Test-Signature" {
+		t.Errorf("got %+v, want infected Eicar-Test-Signature", verdict)
+	}
+
+	// 附件检出病毒时拒绝发送并记录扫描结果
+	s := &EmailService{scanner: scanner}
+	req := &SendEmailRequest{Attachments: []Attachment{
+		{Filename: "eicar.txt", Content: base64.StdEncoding.EncodeToString([]byte("EICAR"))},
+	}}
+	if err := s.scanAttachments(req); !errors.Is(err, ErrAttachmentInfected) {
+		t.Errorf("scanAttachments = %v, want ErrAttachmentInfected", err)
+	}
+	if req.scanResult != "infected: Eicar-Test-Signature (eicar.txt)" {
+		t.Errorf("scanResult = %q", req.scanResult)
+	}
+}
+
+func TestClamAVScannerUnavailable(t *testing.T) {
+	// 监听后立即关闭，得到一个没有服务的地址
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	scanner := newTestClamAVScanner(t, address)
+
+	newRequest := func() *SendEmailRequest {
+		return &SendEmailRequest{Attachments: []Attachment{
+			{Filename: "a.txt", Content: base64.StdEncoding.EncodeToString([]byte("hello"))},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		failOpen   bool
+		wantErr    error
+		wantResult string
+	}{
+		{"fail closed", false, ErrScannerUnavailable, "error: a.txt"},
+		{"fail open", true, nil, "unscanned"},
+	}
+	for _, tt := range tests {
+		s := &EmailService{scanner: scanner, scannerFailOpen: tt.failOpen}
+		req := newRequest()
+		if err := s.scanAttachments(req); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: scanAttachments = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if req.scanResult != tt.wantResult {
+			t.Errorf("%s: scanResult = %q, want %q", tt.name, req.scanResult, tt.wantResult)
+		}
+	}
+}
+
+func TestParseClamdAddress(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+	}{
+		{"unix:/var/run/clamd.ctl", "unix", "/var/run/clamd.ctl"},
+		{"/tmp/clamd.sock", "unix", "/tmp/clamd.sock"},
+		{"tcp:127.0.0.1:3310", "tcp", "127.0.0.1:3310"},
+		{"clamd:3310", "tcp", "clamd:3310"},
+	}
+	for _, tt := range tests {
+		network, address := parseClamdAddress(tt.addr)
+		if network != tt.network || address != tt.address {
+			t.Errorf("parseClamdAddress(%q) = %s %s, want %s %s", tt.addr, network, address, tt.network, tt.address)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// 联系人导入导出格式
+const (
+	ContactFormatCSV   = "csv"
+	ContactFormatVCard = "vcard"
+)
+
+// ContactImportResult 导入结果
+type ContactImportResult struct {
+	Created int      `json:"created"`
+	Updated int      `json:"updated"`
+	Skipped int      `json:"skipped"`
+	Errors  []string `json:"errors"` // 跳过的行及原因
+}
+
+// ImportContacts 从CSV或vCard导入联系人，按邮箱合并到已有联系人：姓名非空时覆盖，属性合并，标签取并集
+// groupID不为0时，导入的联系人同时加入该分组
+func (s *ContactService) ImportContacts(p *Principal, r io.Reader, format string, groupID uint) (*ContactImportResult, error) {
+	if groupID != 0 {
+		if _, err := s.getGroup(p, groupID); err != nil {
+			return nil, err
+		}
+	}
+
+	var requests []ContactRequest
+	var errs []string
+	var err error
+	switch format {
+	case ContactFormatCSV:
+		requests, errs, err = parseContactsCSV(r)
+	case ContactFormatVCard:
+		requests, errs, err = parseVCards(r)
+	default:
+		return nil, fmt.Errorf("不支持的导入格式: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ContactImportResult{Errors: errs, Skipped: len(errs)}
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		ids := make([]uint, 0, len(requests))
+		for i := range requests {
+			req := &requests[i]
+			if err := req.normalize(); err != nil {
+				result.Skipped++
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", req.Email, err))
+				continue
+			}
+
+			var contact models.Contact
+			err := tx.Where("workspace_id = ? AND email = ?", p.WorkspaceID, req.Email).First(&contact).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				contact = models.Contact{
+					WorkspaceID: p.WorkspaceID,
+					Email:       req.Email,
+					Name:        req.Name,
+					Attributes:  req.Attributes,
+					Tags:        req.Tags,
+					CreatedBy:   p.UserID,
+				}
+				if err := tx.Create(&contact).Error; err != nil {
+					return err
+				}
+				result.Created++
+				ids = append(ids, contact.ID)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			updates := map[string]interface{}{"tags": models.StringSlice(mergeTags(contact.Tags, req.Tags))}
+			if req.Name != "" {
+				updates["name"] = req.Name
+			}
+			if len(req.Attributes) > 0 {
+				attributes := models.StringMap{}
+				for key, value := range contact.Attributes {
+					attributes[key] = value
+				}
+				for key, value := range req.Attributes {
+					attributes[key] = value
+				}
+				updates["attributes"] = attributes
+			}
+			if err := tx.Model(&contact).Updates(updates).Error; err != nil {
+				return err
+			}
+			result.Updated++
+			ids = append(ids, contact.ID)
+		}
+
+		if groupID != 0 {
+			if _, err := addGroupMembers(tx, groupID, uniqueIDs(ids)); err != nil {
+				return err
+			}
+		}
+		return s.auditService.Record(tx, p, models.AuditActionImport, models.AuditEntityContact, 0, nil, result)
+	})
+	if err != nil {
+		utils.Errorf("导入联系人失败: %v", err)
+		return nil, fmt.Errorf("导入联系人失败: %w", err)
+	}
+
+	utils.Infof("导入联系人完成: 新增=%d, 更新=%d, 跳过=%d", result.Created, result.Updated, result.Skipped)
+	return result, nil
+}
+
+// parseContactsCSV 解析CSV：第一行为表头，必须有email列，name、tags（以;分隔）为内置列，其余列作为自定义属性
+func parseContactsCSV(r io.Reader) ([]ContactRequest, []string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取CSV表头失败: %w", err)
+	}
+	emailColumn := -1
+	columns := make([]string, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		switch strings.ToLower(name) {
+		case "email", "e-mail", "邮箱":
+			columns[i], emailColumn = "email", i
+		case "name", "姓名":
+			columns[i] = "name"
+		case "tags", "标签":
+			columns[i] = "tags"
+		default:
+			columns[i] = name
+		}
+	}
+	if emailColumn < 0 {
+		return nil, nil, errors.New("CSV缺少email列")
+	}
+
+	var requests []ContactRequest
+	var errs []string
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取CSV第%d行失败: %w", line, err)
+		}
+		if emailColumn >= len(record) || strings.TrimSpace(record[emailColumn]) == "" {
+			errs = append(errs, fmt.Sprintf("第%d行: 缺少邮箱", line))
+			continue
+		}
+
+		req := ContactRequest{}
+		for i, value := range record {
+			if i >= len(columns) || columns[i] == "" {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			switch columns[i] {
+			case "email":
+				req.Email = value
+			case "name":
+				req.Name = value
+			case "tags":
+				req.Tags = strings.Split(value, ";")
+			default:
+				if value == "" {
+					continue
+				}
+				if req.Attributes == nil {
+					req.Attributes = map[string]string{}
+				}
+				req.Attributes[columns[i]] = value
+			}
+		}
+		requests = append(requests, req)
+	}
+	return requests, errs, nil
+}
+
+// parseVCards 解析vCard（3.0/4.0）：FN（或N）为姓名，第一个EMAIL为邮箱，CATEGORIES为标签，
+// ORG、TITLE、TEL保存为org、title、phone属性，X-ATTRIBUTE;KEY=属性名 为自定义属性
+func parseVCards(r io.Reader) ([]ContactRequest, []string, error) {
+	lines, err := unfoldVCardLines(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var requests []ContactRequest
+	var errs []string
+	var card *ContactRequest
+	var structuredName string
+	index := 0
+	for _, line := range lines {
+		name, params, value := splitVCardLine(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VCARD"):
+			index++
+			card, structuredName = &ContactRequest{}, ""
+		case card == nil:
+			continue
+		case name == "END":
+			if card.Name == "" {
+				card.Name = structuredName
+			}
+			if card.Email == "" {
+				errs = append(errs, fmt.Sprintf("第%d个联系人: 缺少邮箱", index))
+			} else {
+				requests = append(requests, *card)
+			}
+			card = nil
+		case name == "FN":
+			card.Name = unescapeVCard(value)
+		case name == "N":
+			// N:姓;名;其他名;前缀;后缀
+			parts := splitVCardValue(value, ';')
+			if len(parts) > 1 {
+				structuredName = strings.TrimSpace(unescapeVCard(parts[1]) + " " + unescapeVCard(parts[0]))
+			}
+		case name == "EMAIL":
+			if card.Email == "" {
+				card.Email = strings.TrimPrefix(strings.TrimSpace(value), "mailto:")
+			}
+		case name == "CATEGORIES":
+			for _, tag := range splitVCardValue(value, ',') {
+				card.Tags = append(card.Tags, unescapeVCard(tag))
+			}
+		case name == "ORG", name == "TITLE", name == "TEL", name == "X-ATTRIBUTE":
+			key := map[string]string{"ORG": "org", "TITLE": "title", "TEL": "phone"}[name]
+			if name == "X-ATTRIBUTE" {
+				key = params["KEY"]
+			}
+			if name == "ORG" {
+				value = splitVCardValue(value, ';')[0]
+			}
+			if key == "" || value == "" {
+				continue
+			}
+			if card.Attributes == nil {
+				card.Attributes = map[string]string{}
+			}
+			if _, exists := card.Attributes[key]; !exists {
+				card.Attributes[key] = unescapeVCard(value)
+			}
+		}
+	}
+	if len(requests) == 0 && len(errs) == 0 {
+		return nil, nil, errors.New("没有找到vCard联系人")
+	}
+	return requests, errs, nil
+}
+
+// unfoldVCardLines 读取vCard并展开折叠行（以空格或制表符开头的行接续上一行）
+func unfoldVCardLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取vCard失败: %w", err)
+	}
+	return lines, nil
+}
+
+// splitVCardLine 拆分属性行为大写的属性名（去掉分组前缀）、参数和值
+func splitVCardLine(line string) (string, map[string]string, string) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return "", nil, ""
+	}
+	parts := strings.Split(line[:colon], ";")
+	name := strings.ToUpper(parts[0])
+	if dot := strings.LastIndex(name, "."); dot >= 0 {
+		name = name[dot+1:]
+	}
+	params := map[string]string{}
+	for _, param := range parts[1:] {
+		if key, value, ok := strings.Cut(param, "="); ok {
+			params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return name, params, line[colon+1:]
+}
+
+// splitVCardValue 按未转义的分隔符拆分值
+func splitVCardValue(value string, sep byte) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, value[start:])
+}
+
+// unescapeVCard 还原vCard文本值中的转义字符
+func unescapeVCard(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return strings.TrimSpace(replacer.Replace(value))
+}
+
+// escapeVCard 转义vCard文本值
+func escapeVCard(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "\n", `\n`, ",", `\,`, ";", `\;`, "\r", "")
+	return replacer.Replace(value)
+}
+
+// ExportContacts 导出联系人为CSV或vCard，groupID不为0时只导出该分组的成员
+func (s *ContactService) ExportContacts(p *Principal, w io.Writer, format string, groupID uint) error {
+	var contacts []models.Contact
+	var err error
+	if groupID != 0 {
+		contacts, err = s.ExpandGroups(p, []uint{groupID})
+	} else {
+		err = p.ScopeWorkspace(database.GetDB()).Order("id").Find(&contacts).Error
+	}
+	if err != nil {
+		return fmt.Errorf("获取联系人失败: %w", err)
+	}
+
+	switch format {
+	case ContactFormatCSV:
+		return writeContactsCSV(w, contacts)
+	case ContactFormatVCard:
+		return writeVCards(w, contacts)
+	}
+	return fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+// writeContactsCSV 写出CSV，列为email、name、tags以及所有出现过的属性
+func writeContactsCSV(w io.Writer, contacts []models.Contact) error {
+	keys := attributeKeys(contacts)
+	writer := csv.NewWriter(w)
+	if err := writer.Write(append([]string{"email", "name", "tags"}, keys...)); err != nil {
+		return err
+	}
+	for _, contact := range contacts {
+		record := []string{contact.Email, contact.Name, strings.Join(contact.Tags, ";")}
+		for _, key := range keys {
+			record = append(record, contact.Attributes[key])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// writeVCards 写出vCard 3.0，自定义属性写为 X-ATTRIBUTE;KEY=属性名
+func writeVCards(w io.Writer, contacts []models.Contact) error {
+	buf := bufio.NewWriter(w)
+	for _, contact := range contacts {
+		name := contact.Name
+		if name == "" {
+			name = contact.Email
+		}
+		fmt.Fprintf(buf, "BEGIN:VCARD\r\nVERSION:3.0\r\n")
+		fmt.Fprintf(buf, "FN:%s\r\nN:;%s;;;\r\n", escapeVCard(name), escapeVCard(contact.Name))
+		fmt.Fprintf(buf, "EMAIL;TYPE=INTERNET:%s\r\n", contact.Email)
+		if len(contact.Tags) > 0 {
+			tags := make([]string, len(contact.Tags))
+			for i, tag := range contact.Tags {
+				tags[i] = escapeVCard(tag)
+			}
+			fmt.Fprintf(buf, "CATEGORIES:%s\r\n", strings.Join(tags, ","))
+		}
+		for _, key := range attributeKeys([]models.Contact{contact}) {
+			fmt.Fprintf(buf, "X-ATTRIBUTE;KEY=%s:%s\r\n", key, escapeVCard(contact.Attributes[key]))
+		}
+		fmt.Fprintf(buf, "END:VCARD\r\n")
+	}
+	return buf.Flush()
+}
@@ -0,0 +1,524 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strings"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// attributeKeyPattern 联系人属性名，需要能在模板中以 {{属性名}} 引用
+var attributeKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedVariables 内置的模板变量，不能作为联系人属性名
+var reservedVariables = map[string]bool{
+	"email":           true,
+	"name":            true,
+	"unsubscribe_url": true,
+}
+
+// ContactService 联系人和分组服务，联系人和分组属于工作区，工作区成员共用
+type ContactService struct {
+	auditService *AuditService
+}
+
+// NewContactService 创建联系人服务实例
+func NewContactService() *ContactService {
+	return &ContactService{
+		auditService: NewAuditService(),
+	}
+}
+
+// ContactRequest 创建或更新联系人请求
+type ContactRequest struct {
+	Email      string            `json:"email" binding:"required"`
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes"`
+	Tags       []string          `json:"tags"`
+}
+
+// ContactGroupRequest 创建或更新分组请求
+type ContactGroupRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// ContactMembersRequest 添加或移除分组成员请求
+type ContactMembersRequest struct {
+	ContactIDs []uint `json:"contact_ids" binding:"required,min=1"`
+}
+
+// ContactListResponse 联系人列表响应
+type ContactListResponse struct {
+	List     []models.Contact `json:"list"`
+	Total    int64            `json:"total"`
+	Page     int              `json:"page"`
+	PageSize int              `json:"pageSize"`
+}
+
+// normalize 校验并规范化联系人：邮箱转为小写，属性名需可作为模板变量，标签去重
+func (req *ContactRequest) normalize() error {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	parsed, err := mail.ParseAddress(email)
+	if err != nil || parsed.Address != email {
+		return fmt.Errorf("无效的邮箱地址: %s", req.Email)
+	}
+	req.Email = email
+	req.Name = strings.TrimSpace(req.Name)
+
+	for key := range req.Attributes {
+		if !attributeKeyPattern.MatchString(key) {
+			return fmt.Errorf("属性名 %q 只能包含字母、数字和下划线，且不能以数字开头", key)
+		}
+		if reservedVariables[key] {
+			return fmt.Errorf("属性名 %q 是内置变量", key)
+		}
+	}
+	req.Tags = mergeTags(nil, req.Tags)
+	return nil
+}
+
+// mergeTags 合并标签，去掉空白和重复项，保持顺序
+func mergeTags(tags []string, more []string) []string {
+	seen := make(map[string]bool, len(tags)+len(more))
+	merged := make([]string, 0, len(tags)+len(more))
+	for _, tag := range append(append([]string{}, tags...), more...) {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		merged = append(merged, tag)
+	}
+	return merged
+}
+
+// ListContacts 获取当前工作区的联系人，q按邮箱和姓名匹配，tag按标签筛选，groupID按分组筛选
+func (s *ContactService) ListContacts(p *Principal, page, pageSize int, q, tag string, groupID uint) (*ContactListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	db := p.ScopeWorkspace(database.GetDB().Model(&models.Contact{}))
+	if q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		db = db.Where("(email LIKE ? OR LOWER(name) LIKE ?)", like, like)
+	}
+	if tag != "" {
+		// 标签以JSON数组保存
+		db = db.Where("tags LIKE ?", "%"+fmt.Sprintf("%q", tag)+"%")
+	}
+	if groupID != 0 {
+		db = db.Where("id IN (?)", database.GetDB().Model(&models.ContactGroupMember{}).Select("contact_id").Where("group_id = ?", groupID))
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("获取联系人总数失败: %w", err)
+	}
+	var contacts []models.Contact
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&contacts).Error; err != nil {
+		utils.Errorf("获取联系人列表失败: %v", err)
+		return nil, fmt.Errorf("获取联系人列表失败: %w", err)
+	}
+
+	return &ContactListResponse{
+		List:     contacts,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// GetContact 获取单个联系人
+func (s *ContactService) GetContact(p *Principal, id uint) (*models.Contact, error) {
+	var contact models.Contact
+	if err := p.ScopeWorkspace(database.GetDB()).First(&contact, id).Error; err != nil {
+		return nil, fmt.Errorf("联系人不存在: %w", err)
+	}
+	return &contact, nil
+}
+
+// CreateContact 创建联系人，邮箱在工作区内不能重复
+func (s *ContactService) CreateContact(p *Principal, req *ContactRequest) (*models.Contact, error) {
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+
+	contact := models.Contact{
+		WorkspaceID: p.WorkspaceID,
+		Email:       req.Email,
+		Name:        req.Name,
+		Attributes:  req.Attributes,
+		Tags:        req.Tags,
+		CreatedBy:   p.UserID,
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Contact{}).Where("workspace_id = ? AND email = ?", p.WorkspaceID, req.Email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("联系人 %s 已存在", req.Email)
+		}
+		if err := tx.Create(&contact).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityContact, contact.ID, nil, &contact)
+	})
+	if err != nil {
+		utils.Errorf("创建联系人失败 (%s): %v", req.Email, err)
+		return nil, fmt.Errorf("创建联系人失败: %w", err)
+	}
+
+	utils.Infof("创建联系人成功: ID=%d, Email=%s", contact.ID, contact.Email)
+	return &contact, nil
+}
+
+// UpdateContact 更新联系人，属性和标签整体替换
+func (s *ContactService) UpdateContact(p *Principal, id uint, req *ContactRequest) (*models.Contact, error) {
+	if err := req.normalize(); err != nil {
+		return nil, err
+	}
+	contact, err := s.GetContact(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *contact
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if req.Email != contact.Email {
+			var count int64
+			if err := tx.Model(&models.Contact{}).Where("workspace_id = ? AND email = ? AND id <> ?", p.WorkspaceID, req.Email, id).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("联系人 %s 已存在", req.Email)
+			}
+		}
+		if err := tx.Model(contact).Updates(map[string]interface{}{
+			"email":      req.Email,
+			"name":       req.Name,
+			"attributes": models.StringMap(req.Attributes),
+			"tags":       models.StringSlice(req.Tags),
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.First(contact, id).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityContact, id, &before, contact)
+	})
+	if err != nil {
+		utils.Errorf("更新联系人失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("更新联系人失败: %w", err)
+	}
+	return contact, nil
+}
+
+// DeleteContact 删除联系人，同时将其移出所有分组
+func (s *ContactService) DeleteContact(p *Principal, id uint) error {
+	contact, err := s.GetContact(p, id)
+	if err != nil {
+		return err
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("contact_id = ?", id).Delete(&models.ContactGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(contact).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityContact, id, contact, nil)
+	})
+	if err != nil {
+		utils.Errorf("删除联系人失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("删除联系人失败: %w", err)
+	}
+	return nil
+}
+
+// ListGroups 获取当前工作区的分组及成员数
+func (s *ContactService) ListGroups(p *Principal) ([]models.ContactGroup, error) {
+	db := database.GetDB()
+	var groups []models.ContactGroup
+	if err := p.ScopeWorkspace(db).Order("name").Find(&groups).Error; err != nil {
+		utils.Errorf("获取分组列表失败: %v", err)
+		return nil, fmt.Errorf("获取分组列表失败: %w", err)
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	ids := make([]uint, len(groups))
+	for i, group := range groups {
+		ids[i] = group.ID
+	}
+	var counts []struct {
+		GroupID uint
+		Count   int64
+	}
+	if err := db.Model(&models.ContactGroupMember{}).Select("group_id, COUNT(*) AS count").
+		Where("group_id IN ?", ids).Group("group_id").Scan(&counts).Error; err != nil {
+		return nil, fmt.Errorf("获取分组成员数失败: %w", err)
+	}
+	byGroup := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		byGroup[count.GroupID] = count.Count
+	}
+	for i := range groups {
+		groups[i].MemberCount = byGroup[groups[i].ID]
+	}
+	return groups, nil
+}
+
+// getGroup 获取当前工作区的分组
+func (s *ContactService) getGroup(p *Principal, id uint) (*models.ContactGroup, error) {
+	var group models.ContactGroup
+	if err := p.ScopeWorkspace(database.GetDB()).First(&group, id).Error; err != nil {
+		return nil, fmt.Errorf("分组不存在: %w", err)
+	}
+	return &group, nil
+}
+
+// CreateGroup 创建分组，名称在工作区内不能重复
+func (s *ContactService) CreateGroup(p *Principal, req *ContactGroupRequest) (*models.ContactGroup, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("分组名称不能为空")
+	}
+
+	group := models.ContactGroup{
+		WorkspaceID: p.WorkspaceID,
+		Name:        name,
+		Description: req.Description,
+		CreatedBy:   p.UserID,
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.ContactGroup{}).Where("workspace_id = ? AND name = ?", p.WorkspaceID, name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("分组 %s 已存在", name)
+		}
+		if err := tx.Create(&group).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityContactGroup, group.ID, nil, &group)
+	})
+	if err != nil {
+		utils.Errorf("创建分组失败 (%s): %v", name, err)
+		return nil, fmt.Errorf("创建分组失败: %w", err)
+	}
+	return &group, nil
+}
+
+// UpdateGroup 更新分组名称和描述
+func (s *ContactService) UpdateGroup(p *Principal, id uint, req *ContactGroupRequest) (*models.ContactGroup, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("分组名称不能为空")
+	}
+	group, err := s.getGroup(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *group
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.ContactGroup{}).Where("workspace_id = ? AND name = ? AND id <> ?", p.WorkspaceID, name, id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("分组 %s 已存在", name)
+		}
+		if err := tx.Model(group).Updates(map[string]interface{}{"name": name, "description": req.Description}).Error; err != nil {
+			return err
+		}
+		if err := tx.First(group, id).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityContactGroup, id, &before, group)
+	})
+	if err != nil {
+		utils.Errorf("更新分组失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("更新分组失败: %w", err)
+	}
+	return group, nil
+}
+
+// DeleteGroup 删除分组，分组中的联系人保留
+func (s *ContactService) DeleteGroup(p *Principal, id uint) error {
+	group, err := s.getGroup(p, id)
+	if err != nil {
+		return err
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("group_id = ?", id).Delete(&models.ContactGroupMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(group).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityContactGroup, id, group, nil)
+	})
+	if err != nil {
+		utils.Errorf("删除分组失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("删除分组失败: %w", err)
+	}
+	return nil
+}
+
+// AddMembers 将联系人加入分组，已在分组中的联系人忽略，返回新加入的数量
+func (s *ContactService) AddMembers(p *Principal, groupID uint, contactIDs []uint) (int64, error) {
+	if _, err := s.getGroup(p, groupID); err != nil {
+		return 0, err
+	}
+
+	var added int64
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		// 只接受当前工作区的联系人
+		var ids []uint
+		if err := p.ScopeWorkspace(tx.Model(&models.Contact{})).Where("id IN ?", contactIDs).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) != len(uniqueIDs(contactIDs)) {
+			return fmt.Errorf("联系人不存在: %w", gorm.ErrRecordNotFound)
+		}
+		var err error
+		if added, err = addGroupMembers(tx, groupID, ids); err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityContactGroup, groupID,
+			nil, map[string]interface{}{"added_contact_ids": ids})
+	})
+	if err != nil {
+		utils.Errorf("添加分组成员失败 (GroupID: %d): %v", groupID, err)
+		return 0, fmt.Errorf("添加分组成员失败: %w", err)
+	}
+	return added, nil
+}
+
+// addGroupMembers 批量写入分组成员，已存在的忽略
+func addGroupMembers(tx *gorm.DB, groupID uint, contactIDs []uint) (int64, error) {
+	if len(contactIDs) == 0 {
+		return 0, nil
+	}
+	members := make([]models.ContactGroupMember, len(contactIDs))
+	for i, id := range contactIDs {
+		members[i] = models.ContactGroupMember{GroupID: groupID, ContactID: id}
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(members, 500)
+	return result.RowsAffected, result.Error
+}
+
+// RemoveMembers 将联系人移出分组，返回移出的数量
+func (s *ContactService) RemoveMembers(p *Principal, groupID uint, contactIDs []uint) (int64, error) {
+	if _, err := s.getGroup(p, groupID); err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("group_id = ? AND contact_id IN ?", groupID, contactIDs).Delete(&models.ContactGroupMember{})
+		if result.Error != nil {
+			return result.Error
+		}
+		removed = result.RowsAffected
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityContactGroup, groupID,
+			map[string]interface{}{"removed_contact_ids": contactIDs}, nil)
+	})
+	if err != nil {
+		utils.Errorf("移出分组成员失败 (GroupID: %d): %v", groupID, err)
+		return 0, fmt.Errorf("移出分组成员失败: %w", err)
+	}
+	return removed, nil
+}
+
+// ExpandGroups 展开分组为联系人（去重，按ID排序），分组不存在时返回错误
+func (s *ContactService) ExpandGroups(p *Principal, groupIDs []uint) ([]models.Contact, error) {
+	groupIDs = uniqueIDs(groupIDs)
+	var count int64
+	if err := p.ScopeWorkspace(database.GetDB().Model(&models.ContactGroup{})).Where("id IN ?", groupIDs).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询分组失败: %w", err)
+	}
+	if int(count) != len(groupIDs) {
+		return nil, fmt.Errorf("分组不存在: %w", gorm.ErrRecordNotFound)
+	}
+
+	var contacts []models.Contact
+	members := database.GetDB().Model(&models.ContactGroupMember{}).Select("contact_id").Where("group_id IN ?", groupIDs)
+	if err := p.ScopeWorkspace(database.GetDB()).Where("id IN (?)", members).Order("id").Find(&contacts).Error; err != nil {
+		return nil, fmt.Errorf("获取分组成员失败: %w", err)
+	}
+	return contacts, nil
+}
+
+// FindByEmail 按邮箱查找工作区内的联系人，不存在时返回nil
+func (s *ContactService) FindByEmail(workspaceID uint, email string) (*models.Contact, error) {
+	var contact models.Contact
+	err := database.GetDB().Where("workspace_id = ? AND email = ?", workspaceID, strings.ToLower(strings.TrimSpace(email))).First(&contact).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
+
+// ContactVariables 联系人的模板变量：内置的email、name以及自定义属性
+func ContactVariables(contact *models.Contact) map[string]string {
+	variables := make(map[string]string, len(contact.Attributes)+2)
+	for key, value := range contact.Attributes {
+		variables[key] = value
+	}
+	variables["email"] = contact.Email
+	variables["name"] = contact.Name
+	return variables
+}
+
+// attributeKeys 返回联系人属性名的并集，按字母排序
+func attributeKeys(contacts []models.Contact) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, contact := range contacts {
+		for key := range contact.Attributes {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// uniqueIDs 去掉重复的ID
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
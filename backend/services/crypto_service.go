@@ -3,21 +3,27 @@ package services
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"golang.org/x/crypto/bcrypt"
 	"smtp-mail/backend/config"
-	"smtp-mail/backend/utils"
 )
 
+// ErrInvalidToken 签名令牌格式错误或签名无效
+var ErrInvalidToken = errors.New("无效的令牌")
+
 // CryptoService 加密服务
 type CryptoService struct {
-	cost          int
-	encryptionKey string
+	cost       int
+	keyring    *Keyring
+	keyringErr error
 }
 
 // NewCryptoService 创建加密服务实例
@@ -28,38 +34,48 @@ func NewCryptoService() *CryptoService {
 		cost = 10 // 默认值
 	}
 
-	// 使用JWT密钥的SHA256哈希作为AES密钥
-	encryptionKey := deriveEncryptionKey(cfg.Security.JWTSecret)
+	// 密钥环加载失败时，加解密操作返回该错误
+	keyring, err := LoadKeyring()
 
 	return &CryptoService{
-		cost:          cost,
-		encryptionKey: encryptionKey,
+		cost:       cost,
+		keyring:    keyring,
+		keyringErr: err,
 	}
 }
 
-// deriveEncryptionKey 从密钥派生AES密钥
-func deriveEncryptionKey(secret string) string {
-	if secret == "" {
-		secret = "default-secret-key-change-in-production"
+// newGCM 使用指定密钥创建AES-GCM
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES密码块失败: %w", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("创建GCM模式失败: %w", err)
 	}
-	hash := sha256.Sum256([]byte(secret))
-	return string(hash[:])
+	return gcm, nil
 }
 
-// EncryptPassword 使用AES-GCM加密密码（用于SMTP密码）
+// EncryptPassword 使用当前主密钥以AES-GCM加密密码（用于SMTP密码）
+// 密文格式为 v1:<密钥ID>:<base64(nonce+密文)>
 func (s *CryptoService) EncryptPassword(plaintext string) (string, error) {
 	if plaintext == "" {
 		return "", nil
 	}
+	if s.keyringErr != nil {
+		return "", s.keyringErr
+	}
 
-	block, err := aes.NewCipher([]byte(s.encryptionKey))
+	keyID := s.keyring.ActiveKeyID()
+	key, err := s.keyring.key(keyID)
 	if err != nil {
-		return "", fmt.Errorf("创建AES密码块失败: %w", err)
+		return "", err
 	}
-
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := newGCM(key)
 	if err != nil {
-		return "", fmt.Errorf("创建GCM模式失败: %w", err)
+		return "", err
 	}
 
 	nonce := make([]byte, gcm.NonceSize())
@@ -68,58 +84,128 @@ func (s *CryptoService) EncryptPassword(plaintext string) (string, error) {
 	}
 
 	ciphertext := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
-	return base64.StdEncoding.EncodeToString(ciphertext), nil
+	return ciphertextVersion + ":" + keyID + ":" + base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-// DecryptPassword 解密SMTP密码
+// DecryptPassword 解密SMTP密码，密钥不存在或密文无法解密时返回错误
 func (s *CryptoService) DecryptPassword(ciphertext string) (string, error) {
 	if ciphertext == "" {
 		return "", nil
 	}
-
-	// 尝试base64解码
-	data, err := base64.StdEncoding.DecodeString(ciphertext)
-	if err != nil {
-		// 如果不是有效的base64，可能是明文密码，直接返回
-		utils.Infof("密码不是有效的base64格式，使用明文密码")
-		return ciphertext, nil
+	if s.keyringErr != nil {
+		return "", s.keyringErr
 	}
 
-	// 检查解码后的数据是否太短（不足以包含nonce）
-	if len(data) < 12 {
-		// 数据太短，可能是明文密码
-		utils.Infof("解码后的数据太短，使用明文密码")
-		return ciphertext, nil
+	keyID, payload := parseCiphertext(ciphertext)
+	key, err := s.keyring.key(keyID)
+	if err != nil {
+		return "", err
 	}
 
-	block, err := aes.NewCipher([]byte(s.encryptionKey))
+	data, err := base64.StdEncoding.DecodeString(payload)
 	if err != nil {
-		return "", fmt.Errorf("创建AES密码块失败: %w", err)
+		return "", fmt.Errorf("%w: 密文不是有效的base64", ErrDecryptFailed)
 	}
 
-	gcm, err := cipher.NewGCM(block)
+	gcm, err := newGCM(key)
 	if err != nil {
-		return "", fmt.Errorf("创建GCM模式失败: %w", err)
+		return "", err
 	}
 
 	nonceSize := gcm.NonceSize()
 	if len(data) < nonceSize {
-		// 数据太短，可能是明文密码
-		utils.Infof("数据长度小于nonce大小，使用明文密码")
-		return ciphertext, nil
+		return "", fmt.Errorf("%w: 密文长度不足", ErrDecryptFailed)
 	}
 
 	nonce, encryptedData := data[:nonceSize], data[nonceSize:]
 	plaintext, err := gcm.Open(nil, nonce, encryptedData, nil)
 	if err != nil {
-		// 解密失败，可能是明文密码
-		utils.Infof("解密失败，使用明文密码: %v", err)
-		return ciphertext, nil
+		return "", fmt.Errorf("%w (密钥ID: %s)", ErrDecryptFailed, keyID)
 	}
 
 	return string(plaintext), nil
 }
 
+// NeedsRotation 检查密文是否未使用当前主密钥加密
+func (s *CryptoService) NeedsRotation(ciphertext string) bool {
+	if ciphertext == "" || s.keyringErr != nil {
+		return false
+	}
+	keyID, _ := parseCiphertext(ciphertext)
+	return keyID != s.keyring.ActiveKeyID()
+}
+
+// Sign 使用当前主密钥派生的用途密钥计算HMAC-SHA256签名，返回签名使用的密钥ID
+// 用于退订链接等长期有效的令牌，主密钥轮换后旧令牌仍可验证（只要旧密钥未移除）
+// 当前密钥为旧密钥（allow_legacy_key）时返回ErrLegacyKeySign
+func (s *CryptoService) Sign(purpose string, payload []byte) (string, []byte, error) {
+	if s.keyringErr != nil {
+		return "", nil, s.keyringErr
+	}
+	keyID := s.keyring.ActiveKeyID()
+	signature, err := s.mac(purpose, keyID, payload)
+	if err != nil {
+		return "", nil, err
+	}
+	return keyID, signature, nil
+}
+
+// Verify 使用指定密钥验证Sign生成的签名
+func (s *CryptoService) Verify(purpose string, payload []byte, keyID string, signature []byte) bool {
+	if s.keyringErr != nil {
+		return false
+	}
+	expected, err := s.mac(purpose, keyID, payload)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(expected, signature)
+}
+
+// SignToken 生成URL安全的签名令牌：<密钥ID>.<base64(内容)>.<base64(签名)>
+func (s *CryptoService) SignToken(purpose string, payload []byte) (string, error) {
+	keyID, signature, err := s.Sign(purpose, payload)
+	if err != nil {
+		return "", err
+	}
+	encoding := base64.RawURLEncoding
+	return keyID + "." + encoding.EncodeToString(payload) + "." + encoding.EncodeToString(signature), nil
+}
+
+// VerifyToken 验证SignToken生成的令牌，返回其中的内容
+func (s *CryptoService) VerifyToken(purpose, token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	encoding := base64.RawURLEncoding
+	payload, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if !s.Verify(purpose, payload, parts[0], signature) {
+		return nil, ErrInvalidToken
+	}
+	return payload, nil
+}
+
+// mac 计算签名：用途密钥 = HMAC(主密钥, 用途)，签名 = HMAC(用途密钥, 内容)
+func (s *CryptoService) mac(purpose, keyID string, payload []byte) ([]byte, error) {
+	key, err := s.keyring.signingKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	derive := hmac.New(sha256.New, key)
+	derive.Write([]byte(purpose))
+	h := hmac.New(sha256.New, derive.Sum(nil))
+	h.Write(payload)
+	return h.Sum(nil), nil
+}
+
 // HashPassword 加密密码（bcrypt，用于用户密码）
 func (s *CryptoService) HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), s.cost)
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// ErrDraftConflict 草稿已被其他请求修改或发送，客户端需要重新获取后再保存
+var ErrDraftConflict = errors.New("草稿已被修改，请重新获取最新版本")
+
+// DraftService 邮件草稿服务
+// 草稿是个人未发送的内容，保存频繁（自动保存），不记录审计日志；发送后记录在发送历史中
+type DraftService struct {
+	emailService      *EmailService
+	attachmentService *AttachmentService
+}
+
+// NewDraftService 创建草稿服务实例
+func NewDraftService() *DraftService {
+	return &DraftService{
+		emailService:      NewEmailService(),
+		attachmentService: NewAttachmentService(),
+	}
+}
+
+// DraftRequest 创建或保存草稿请求，字段与发送邮件请求相同但都可以为空
+// 附件只能引用通过 POST /api/attachments 上传的文件；保存时需要提供获取到的version
+type DraftRequest struct {
+	SmtpConfigID       uint         `json:"smtp_config_id"`
+	To                 []string     `json:"to"`
+	Cc                 []string     `json:"cc"`
+	Bcc                []string     `json:"bcc"`
+	Subject            string       `json:"subject"`
+	Body               string       `json:"body"`
+	Text               string       `json:"text"`
+	Attachments        []Attachment `json:"attachments"`
+	IgnoreSuppressions bool         `json:"ignore_suppressions"`
+	Bulk               bool         `json:"bulk"`
+	Track              bool         `json:"track"`
+	GroupIDs           []uint       `json:"group_ids"`
+	TemplateID         *uint        `json:"template_id"`
+	TemplateVersion    int          `json:"template_version"`
+	Personalize        bool         `json:"personalize"`
+	Version            int          `json:"version"`
+}
+
+// DraftSendRequest 发送草稿请求，提供version时只有草稿未被修改才发送
+type DraftSendRequest struct {
+	Version int `json:"version"`
+}
+
+// DraftListResponse 草稿列表响应
+type DraftListResponse struct {
+	List     []models.Draft `json:"list"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"pageSize"`
+}
+
+// scopeDrafts 将查询限制为当前工作区内自己的草稿，管理员也只能看到自己的草稿
+func scopeDrafts(p *Principal, db *gorm.DB) *gorm.DB {
+	var userID uint
+	if p != nil {
+		userID = p.UserID
+	}
+	return p.ScopeWorkspace(db).Where("user_id = ?", userID)
+}
+
+// attachments 校验引用的上传文件对当前用户可见
+func (s *DraftService) attachments(p *Principal, req *DraftRequest) (models.DraftAttachments, error) {
+	if len(req.Attachments) == 0 {
+		return nil, nil
+	}
+	attachments := make(models.DraftAttachments, len(req.Attachments))
+	for i, attachment := range req.Attachments {
+		if attachment.ID == 0 {
+			return nil, fmt.Errorf("草稿中的附件 %s 需要先通过 POST /api/attachments 上传，再按ID引用", attachment.Filename)
+		}
+		if _, err := s.attachmentService.GetFile(p, attachment.ID); err != nil {
+			return nil, err
+		}
+		attachments[i] = models.DraftAttachment{ID: attachment.ID, Filename: attachment.Filename}
+	}
+	return attachments, nil
+}
+
+// draftFields 草稿保存的字段
+func draftFields(req *DraftRequest, attachments models.DraftAttachments) map[string]interface{} {
+	return map[string]interface{}{
+		"smtp_config_id":      req.SmtpConfigID,
+		"to":                  models.StringSlice(req.To),
+		"cc":                  models.StringSlice(req.Cc),
+		"bcc":                 models.StringSlice(req.Bcc),
+		"subject":             req.Subject,
+		"body":                req.Body,
+		"text":                req.Text,
+		"attachments":         attachments,
+		"ignore_suppressions": req.IgnoreSuppressions,
+		"bulk":                req.Bulk,
+		"track":               req.Track,
+		"group_ids":           models.UintSlice(req.GroupIDs),
+		"template_id":         req.TemplateID,
+		"template_version":    req.TemplateVersion,
+		"personalize":         req.Personalize,
+	}
+}
+
+// ListDrafts 获取自己的草稿，最近保存的在前
+func (s *DraftService) ListDrafts(p *Principal, page, pageSize int) (*DraftListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	db := scopeDrafts(p, database.GetDB().Model(&models.Draft{}))
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("获取草稿总数失败: %w", err)
+	}
+	var drafts []models.Draft
+	if err := db.Order("updated_at DESC, id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&drafts).Error; err != nil {
+		utils.Errorf("获取草稿列表失败: %v", err)
+		return nil, fmt.Errorf("获取草稿列表失败: %w", err)
+	}
+
+	return &DraftListResponse{
+		List:     drafts,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// GetDraft 获取单个草稿
+func (s *DraftService) GetDraft(p *Principal, id uint) (*models.Draft, error) {
+	var draft models.Draft
+	if err := scopeDrafts(p, database.GetDB()).First(&draft, id).Error; err != nil {
+		return nil, fmt.Errorf("草稿不存在: %w", err)
+	}
+	return &draft, nil
+}
+
+// CreateDraft 创建草稿
+func (s *DraftService) CreateDraft(p *Principal, req *DraftRequest) (*models.Draft, error) {
+	attachments, err := s.attachments(p, req)
+	if err != nil {
+		return nil, err
+	}
+
+	draft := models.Draft{
+		SmtpConfigID:       req.SmtpConfigID,
+		To:                 req.To,
+		Cc:                 req.Cc,
+		Bcc:                req.Bcc,
+		Subject:            req.Subject,
+		Body:               req.Body,
+		Text:               req.Text,
+		Attachments:        attachments,
+		IgnoreSuppressions: req.IgnoreSuppressions,
+		Bulk:               req.Bulk,
+		Track:              req.Track,
+		GroupIDs:           req.GroupIDs,
+		TemplateID:         req.TemplateID,
+		TemplateVersion:    req.TemplateVersion,
+		Personalize:        req.Personalize,
+		Version:            1,
+	}
+	if p != nil {
+		draft.WorkspaceID = p.WorkspaceID
+		draft.UserID = p.UserID
+	}
+	if err := database.GetDB().Create(&draft).Error; err != nil {
+		utils.Errorf("创建草稿失败: %v", err)
+		return nil, fmt.Errorf("创建草稿失败: %w", err)
+	}
+	return &draft, nil
+}
+
+// UpdateDraft 保存草稿，整体替换内容
+// req.Version必须等于当前版本，否则说明草稿已在其他地方被保存或发送，返回ErrDraftConflict
+func (s *DraftService) UpdateDraft(p *Principal, id uint, req *DraftRequest) (*models.Draft, error) {
+	draft, err := s.GetDraft(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Version != draft.Version {
+		return nil, ErrDraftConflict
+	}
+	attachments, err := s.attachments(p, req)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := draftFields(req, attachments)
+	updates["version"] = gorm.Expr("version + 1")
+	result := database.GetDB().Model(&models.Draft{}).Where("id = ? AND version = ?", id, req.Version).Updates(updates)
+	if result.Error != nil {
+		utils.Errorf("保存草稿失败 (ID: %d): %v", id, result.Error)
+		return nil, fmt.Errorf("保存草稿失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrDraftConflict
+	}
+	return s.GetDraft(p, id)
+}
+
+// DeleteDraft 删除草稿
+func (s *DraftService) DeleteDraft(p *Principal, id uint) error {
+	draft, err := s.GetDraft(p, id)
+	if err != nil {
+		return err
+	}
+	if err := database.GetDB().Delete(draft).Error; err != nil {
+		utils.Errorf("删除草稿失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("删除草稿失败: %w", err)
+	}
+	return nil
+}
+
+// draftSendRequest 由草稿生成发送邮件请求，检查发送邮件接口要求的必填字段
+func draftSendRequest(draft *models.Draft) (*SendEmailRequest, error) {
+	switch {
+	case draft.SmtpConfigID == 0:
+		return nil, errors.New("SMTP配置ID不能为空")
+	case len(draft.To) == 0 && len(draft.GroupIDs) == 0:
+		return nil, errors.New("收件人列表不能为空")
+	case draft.Subject == "" && draft.TemplateID == nil:
+		return nil, errors.New("邮件主题不能为空")
+	case draft.Body == "" && draft.Text == "" && draft.TemplateID == nil:
+		return nil, errors.New("邮件正文不能为空")
+	}
+
+	attachments := make([]Attachment, len(draft.Attachments))
+	for i, attachment := range draft.Attachments {
+		attachments[i] = Attachment{ID: attachment.ID, Filename: attachment.Filename}
+	}
+	return &SendEmailRequest{
+		SmtpConfigID:       draft.SmtpConfigID,
+		To:                 draft.To,
+		Cc:                 draft.Cc,
+		Bcc:                draft.Bcc,
+		Subject:            draft.Subject,
+		Body:               draft.Body,
+		Text:               draft.Text,
+		Attachments:        attachments,
+		IgnoreSuppressions: draft.IgnoreSuppressions,
+		Bulk:               draft.Bulk,
+		Track:              draft.Track,
+		GroupIDs:           draft.GroupIDs,
+		TemplateID:         draft.TemplateID,
+		TemplateVersion:    draft.TemplateVersion,
+		Personalize:        draft.Personalize,
+	}, nil
+}
+
+// SendDraft 发送草稿，按分组发送时返回分组发送结果，否则返回发送历史
+// 发送前先按版本删除草稿认领，同一草稿同时发送多次时只有一次会发送；发送失败时恢复草稿，修改后可以重新发送
+func (s *DraftService) SendDraft(p *Principal, id uint, req *DraftSendRequest) (*models.EmailHistory, *GroupSendResult, error) {
+	draft, err := s.GetDraft(p, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if req.Version != 0 && req.Version != draft.Version {
+		return nil, nil, ErrDraftConflict
+	}
+	send, err := draftSendRequest(draft)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 读取后草稿又被保存或已被其他请求发送时删除不到
+	db := database.GetDB()
+	claim := db.Where("id = ? AND version = ?", id, draft.Version).Delete(&models.Draft{})
+	if claim.Error != nil {
+		utils.Errorf("认领草稿失败 (ID: %d): %v", id, claim.Error)
+		return nil, nil, fmt.Errorf("发送草稿失败: %w", claim.Error)
+	}
+	if claim.RowsAffected == 0 {
+		return nil, nil, ErrDraftConflict
+	}
+
+	var history *models.EmailHistory
+	var result *GroupSendResult
+	if len(send.GroupIDs) > 0 {
+		result, err = s.emailService.SendToGroups(p, send)
+	} else {
+		history, err = s.emailService.SendEmail(p, send)
+	}
+	if err != nil {
+		if restoreErr := db.Create(draft).Error; restoreErr != nil {
+			utils.Errorf("发送失败后恢复草稿失败 (ID: %d): %v", id, restoreErr)
+		}
+		return history, result, err
+	}
+
+	utils.Infof("草稿已发送: ID=%d", id)
+	return history, result, nil
+}
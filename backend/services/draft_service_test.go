@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+)
+
+// seedDraft 创建工作区、捕获模式的SMTP配置和一个草稿
+func seedDraft(t *testing.T, smtpConfigID uint) *models.Draft {
+	t.Helper()
+	db := database.GetDB()
+	if err := db.Create(&models.Workspace{ID: 1, Name: "one", Slug: "one"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	smtpConfig := models.SMTPConfig{WorkspaceID: 1, Name: "capture", Host: "localhost", Port: 25, FromEmail: "from@example.com", Capture: true}
+	if err := db.Create(&smtpConfig).Error; err != nil {
+		t.Fatal(err)
+	}
+	if smtpConfigID == 0 {
+		smtpConfigID = smtpConfig.ID
+	}
+	draft := models.Draft{WorkspaceID: 1, UserID: 1, SmtpConfigID: smtpConfigID, To: models.StringSlice{"bob@example.com"},
+		Subject: "hello", Body: "<p>hi</p>", Version: 2}
+	if err := db.Create(&draft).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &draft
+}
+
+func TestSendDraftOnce(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		useTestKeyring(t, testKey1)
+		draft := seedDraft(t, 0)
+		s := NewDraftService()
+		p := &Principal{UserID: 1, Role: models.RoleAdmin, WorkspaceID: 1}
+
+		// 同时发送同一个草稿，只有一次发送成功
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		sent := 0
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, _, err := s.SendDraft(p, draft.ID, &DraftSendRequest{}); err == nil {
+					mu.Lock()
+					sent++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		var histories, drafts int64
+		database.GetDB().Model(&models.EmailHistory{}).Count(&histories)
+		database.GetDB().Model(&models.Draft{}).Count(&drafts)
+		if sent != 1 || histories != 1 || drafts != 0 {
+			t.Errorf("成功 %d 次，发送历史 %d 条，剩余草稿 %d 个，want 1、1、0", sent, histories, drafts)
+		}
+	})
+}
+
+func TestSendDraftRestoresOnFailure(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		useTestKeyring(t, testKey1)
+		draft := seedDraft(t, 999)
+		s := NewDraftService()
+		p := &Principal{UserID: 1, Role: models.RoleAdmin, WorkspaceID: 1}
+
+		if _, _, err := s.SendDraft(p, draft.ID, &DraftSendRequest{Version: 1}); !errors.Is(err, ErrDraftConflict) {
+			t.Errorf("版本不一致时返回 %v", err)
+		}
+		if _, _, err := s.SendDraft(p, draft.ID, &DraftSendRequest{Version: 2}); err == nil {
+			t.Fatal("SMTP配置不存在时发送成功")
+		}
+
+		// 发送失败后草稿恢复，内容和版本不变
+		restored, err := s.GetDraft(p, draft.ID)
+		if err != nil {
+			t.Fatalf("发送失败后草稿不存在: %v", err)
+		}
+		if restored.Version != 2 || restored.Subject != "hello" || len(restored.To) != 1 {
+			t.Errorf("恢复的草稿 %+v", restored)
+		}
+	})
+}
+
+func TestDraftTextRoundTrip(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		useTestKeyring(t, testKey1)
+		seeded := seedDraft(t, 0)
+		s := NewDraftService()
+		p := &Principal{UserID: 1, Role: models.RoleAdmin, WorkspaceID: 1}
+
+		req := &DraftRequest{SmtpConfigID: seeded.SmtpConfigID, To: []string{"bob@example.com"}, Subject: "hello",
+			Body: "<p>hi</p>", Text: "hi (draft)"}
+		draft, err := s.CreateDraft(p, req)
+		if err != nil {
+			t.Fatalf("创建草稿失败: %v", err)
+		}
+		req.Text, req.Version = "hi (saved)", draft.Version
+		if draft, err = s.UpdateDraft(p, draft.ID, req); err != nil {
+			t.Fatalf("保存草稿失败: %v", err)
+		}
+		if draft.Text != "hi (saved)" {
+			t.Errorf("保存后 text=%q", draft.Text)
+		}
+
+		// 发送的邮件包含草稿的纯文本部分
+		history, _, err := s.SendDraft(p, draft.ID, &DraftSendRequest{})
+		if err != nil {
+			t.Fatalf("发送草稿失败: %v", err)
+		}
+		var captured models.CapturedMessage
+		if err := database.GetDB().Where("history_id = ?", history.ID).First(&captured).Error; err != nil {
+			t.Fatalf("没有捕获到邮件: %v", err)
+		}
+		if !strings.Contains(captured.Raw, "text/plain") || !strings.Contains(captured.Raw, "hi (saved)") {
+			t.Errorf("邮件没有纯文本部分:\n%s", captured.Raw)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+)
+
+// variablePattern 模板变量 {{name}}，允许花括号内有空白
+var variablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// GroupSendItem 按分组发送时单个收件人的结果
+type GroupSendItem struct {
+	Email     string             `json:"email"`
+	HistoryID uint               `json:"history_id,omitempty"`
+	Status    models.EmailStatus `json:"status,omitempty"`
+	Error     string             `json:"error,omitempty"`
+}
+
+// GroupSendResult 按分组发送的结果
+type GroupSendResult struct {
+	Total      int             `json:"total"` // 展开并去掉被抑制的地址后的收件人数
+	Sent       int             `json:"sent"`
+	Failed     int             `json:"failed"`
+	Suppressed []string        `json:"suppressed,omitempty"`
+	Results    []GroupSendItem `json:"results"`
+	Error      string          `json:"error,omitempty"` // 中途停止的原因，如配额用完
+}
+
+// groupRecipient 分组展开后的一个收件人，不是联系人时Contact为nil
+type groupRecipient struct {
+	Email   string
+	Contact *models.Contact
+}
+
+// renderVariables 替换文本中的 {{变量}}，值经过encode处理后写入
+// {{unsubscribe_url}} 由发送流程单独处理，这里保留；没有值的变量原样保留并返回变量名
+func renderVariables(text string, variables map[string]string, encode func(string) string) (string, []string) {
+	var missing []string
+	rendered := variablePattern.ReplaceAllStringFunc(text, func(ref string) string {
+		name := variablePattern.FindStringSubmatch(ref)[1]
+		if "{{"+name+"}}" == UnsubscribePlaceholder {
+			return UnsubscribePlaceholder
+		}
+		value, ok := variables[name]
+		if !ok {
+			missing = append(missing, name)
+			return ref
+		}
+		return encode(value)
+	})
+	return rendered, missing
+}
+
+// headerValue 去掉变量值中的换行，避免在主题中插入额外的邮件头（导入的联系人属性可能包含换行）
+func headerValue(value string) string {
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(value)
+}
+
+// plainValue 纯文本正文中的变量值原样写入
+func plainValue(value string) string {
+	return value
+}
+
+// applyVariables 替换主题、HTML正文和纯文本正文中的模板变量
+// 变量来自按分组发送时的联系人或营销活动的收件人；普通发送只有设置了personalize时才替换，
+// 变量来自唯一收件人对应的联系人，不是联系人时只有email变量；有多个收件人时无法个性化，不做替换
+func (s *EmailService) applyVariables(p *Principal, req *SendEmailRequest) error {
+	if req.variables == nil {
+		recipients := req.recipients()
+		if !req.Personalize || len(recipients) != 1 {
+			return nil
+		}
+		contact, err := s.contactService.FindByEmail(p.WorkspaceID, recipients[0])
+		if err != nil {
+			return fmt.Errorf("查询联系人失败: %w", err)
+		}
+		if contact != nil {
+			req.variables = ContactVariables(contact)
+		} else {
+			req.variables = map[string]string{"email": recipients[0]}
+		}
+	}
+
+	var missingSubject, missingBody, missingText []string
+	req.Subject, missingSubject = renderVariables(req.Subject, req.variables, headerValue)
+	req.Body, missingBody = renderVariables(req.Body, req.variables, html.EscapeString)
+	req.Text, missingText = renderVariables(req.Text, req.variables, plainValue)
+	req.missingVariables = mergeTags(mergeTags(missingSubject, missingBody), missingText)
+	return nil
+}
+
+// groupRecipients 展开分组，与To中的地址合并去重，并去掉抑制列表中的地址
+func (s *EmailService) groupRecipients(p *Principal, req *SendEmailRequest) ([]groupRecipient, []string, error) {
+	if len(req.Cc) > 0 || len(req.Bcc) > 0 {
+		return nil, nil, errors.New("按分组发送时每个收件人单独发送，不支持抄送和密送")
+	}
+	contacts, err := s.contactService.ExpandGroups(p, req.GroupIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := map[string]bool{}
+	var recipients []groupRecipient
+	for _, address := range req.To {
+		if key := suppressionKey(address); !seen[key] {
+			seen[key] = true
+			recipients = append(recipients, groupRecipient{Email: address})
+		}
+	}
+	for i := range contacts {
+		if !seen[contacts[i].Email] {
+			seen[contacts[i].Email] = true
+			recipients = append(recipients, groupRecipient{Email: contacts[i].Email, Contact: &contacts[i]})
+		}
+	}
+
+	if req.IgnoreSuppressions {
+		if !p.IsAdmin() {
+			return nil, nil, ErrForbidden
+		}
+		return recipients, nil, nil
+	}
+	addresses := make([]string, len(recipients))
+	for i, recipient := range recipients {
+		addresses[i] = recipient.Email
+	}
+	suppressed, err := s.suppressionService.Suppressed(p.WorkspaceID, addresses)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(suppressed) > 0 {
+		blocked := make(map[string]bool, len(suppressed))
+		for _, address := range suppressed {
+			blocked[suppressionKey(address)] = true
+		}
+		kept := recipients[:0]
+		for _, recipient := range recipients {
+			if !blocked[suppressionKey(recipient.Email)] {
+				kept = append(kept, recipient)
+			}
+		}
+		recipients = kept
+	}
+	if len(recipients) == 0 {
+		if len(suppressed) > 0 {
+			return nil, suppressed, fmt.Errorf("%w: %s", ErrAllRecipientsSuppressed, strings.Join(suppressed, ", "))
+		}
+		return nil, nil, errors.New("分组中没有联系人")
+	}
+	return recipients, suppressed, nil
+}
+
+// recipientRequest 复制请求，改为只发给一个收件人，并带上联系人的模板变量
+func recipientRequest(req *SendEmailRequest, recipient groupRecipient) *SendEmailRequest {
+	single := *req
+	single.To = []string{recipient.Email}
+	single.GroupIDs = nil
+	if recipient.Contact != nil {
+		single.variables = ContactVariables(recipient.Contact)
+	}
+	return &single
+}
+
+// SendToGroups 按分组发送：分组展开为联系人，与To中的地址合并去重、去掉抑制列表中的地址后，
+// 逐个单独发送，每个收件人一条发送历史，主题和正文按联系人属性个性化
+// 配额用完时停止发送，已发送的结果仍然返回
+func (s *EmailService) SendToGroups(p *Principal, req *SendEmailRequest) (*GroupSendResult, error) {
+	recipients, suppressed, err := s.groupRecipients(p, req)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Infof("按分组发送: GroupIDs=%v, 收件人=%d, 跳过=%d", req.GroupIDs, len(recipients), len(suppressed))
+	result := &GroupSendResult{Total: len(recipients), Suppressed: suppressed, Results: []GroupSendItem{}}
+	for _, recipient := range recipients {
+		item := GroupSendItem{Email: recipient.Email}
+		history, err := s.SendEmail(p, recipientRequest(req, recipient))
+		if history != nil {
+			item.HistoryID, item.Status = history.ID, history.Status
+		}
+		if err != nil {
+			item.Error = err.Error()
+			result.Failed++
+		} else {
+			result.Sent++
+		}
+		result.Results = append(result.Results, item)
+
+		if errors.Is(err, ErrQuotaExceeded) {
+			result.Error = err.Error()
+			break
+		}
+	}
+	return result, nil
+}
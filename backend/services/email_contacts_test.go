@@ -0,0 +1,72 @@
+package services
+
+import (
+	"html"
+	"reflect"
+	"testing"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+)
+
+func TestRenderVariables(t *testing.T) {
+	variables := map[string]string{"name": "<Bob>", "company": "Acme\r\nBcc: victim@example.com"}
+
+	body, missing := renderVariables("<p>Hi {{ name }} from {{company}}, {{unknown}} {{unsubscribe_url}}</p>", variables, html.EscapeString)
+	want := "<p>Hi &lt;Bob&gt; from Acme\r\nBcc: victim@example.com, {{unknown}} {{unsubscribe_url}}</p>"
+	if body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+	if !reflect.DeepEqual(missing, []string{"unknown"}) {
+		t.Errorf("missing = %v", missing)
+	}
+
+	// 主题中的值去掉换行，不能插入邮件头
+	subject, _ := renderVariables("Offer for {{company}}", variables, headerValue)
+	if subject != "Offer for Acme Bcc: victim@example.com" {
+		t.Errorf("subject = %q", subject)
+	}
+}
+
+func TestApplyVariables(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		contact := models.Contact{WorkspaceID: 1, Email: "bob@example.com", Name: "Bob", Attributes: models.StringMap{"plan": "pro"}}
+		if err := database.GetDB().Create(&contact).Error; err != nil {
+			t.Fatal(err)
+		}
+		s := &EmailService{contactService: NewContactService()}
+		p := &Principal{UserID: 1, Role: models.RoleSender, WorkspaceID: 1}
+
+		tests := []struct {
+			name                            string
+			req                             SendEmailRequest
+			wantSubject, wantBody, wantText string
+			wantMissing                     []string
+		}{
+			{"not requested", SendEmailRequest{To: []string{"bob@example.com"}, Subject: "{{name}}", Body: "{{plan}}", Text: "{{plan}}"},
+				"{{name}}", "{{plan}}", "{{plan}}", nil},
+			{"contact", SendEmailRequest{To: []string{"bob@example.com"}, Subject: "Hi {{name}}", Body: "{{plan}} {{team}}",
+				Text: "{{plan}} {{region}}", Personalize: true}, "Hi Bob", "pro {{team}}", "pro {{region}}", []string{"team", "region"}},
+			{"not a contact", SendEmailRequest{To: []string{"carol@example.com"}, Subject: "{{email}}", Body: "{{name}}", Personalize: true},
+				"carol@example.com", "{{name}}", "", []string{"name"}},
+			{"several recipients", SendEmailRequest{To: []string{"bob@example.com"}, Cc: []string{"carol@example.com"},
+				Subject: "{{name}}", Body: "{{name}}", Text: "{{name}}", Personalize: true}, "{{name}}", "{{name}}", "{{name}}", nil},
+			{"group member", SendEmailRequest{To: []string{"dave@example.com"}, Subject: "{{name}}", Body: "{{name}}", Text: "Hi {{name}}",
+				variables: map[string]string{"name": "<Dave>"}}, "<Dave>", "&lt;Dave&gt;", "Hi <Dave>", nil},
+		}
+		for _, tt := range tests {
+			req := tt.req
+			if err := s.applyVariables(p, &req); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			missing := req.missingVariables
+			if len(missing) == 0 {
+				missing = nil
+			}
+			if req.Subject != tt.wantSubject || req.Body != tt.wantBody || req.Text != tt.wantText || !reflect.DeepEqual(missing, tt.wantMissing) {
+				t.Errorf("%s: got %q %q %q %v, want %q %q %q %v", tt.name, req.Subject, req.Body, req.Text, req.missingVariables,
+					tt.wantSubject, tt.wantBody, tt.wantText, tt.wantMissing)
+			}
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"smtp-mail/backend/config"
+)
+
+// 预览警告代码
+const (
+	PreviewWarningOversize      = "oversize"          // 邮件超过大小上限
+	PreviewWarningMissingText   = "missing_text_part" // 没有纯文本部分
+	PreviewWarningUnresolvedCID = "unresolved_cid"    // HTML引用的cid:资源不存在
+	PreviewWarningQuotaExceeded = "quota_exceeded"    // 工作区配额已用完，实际发送会被拒绝
+	PreviewWarningCapture       = "capture"           // 捕获模式，实际发送不会投递
+	PreviewWarningSuppressed    = "suppressed"        // 部分收件人在抑制列表中，发送时会被跳过
+	PreviewWarningUnsubscribe   = "unsubscribe_url"   // 非批量邮件使用了退订链接占位符
+	PreviewWarningTracking      = "tracking_disabled" // 请求了追踪，但追踪已全局关闭
+	PreviewWarningGroup         = "group_preview"     // 按分组发送，只预览第一个收件人
+	PreviewWarningMissingVar    = "missing_variable"  // 模板变量没有值，会原样保留
+)
+
+// PreviewWarning 预览警告
+type PreviewWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// EmailPreview 邮件预览结果
+type EmailPreview struct {
+	Raw      string           `json:"raw"`  // 将要发送的原始邮件
+	Size     int              `json:"size"` // 原始邮件字节数
+	Message  *ParsedMessage   `json:"message"`
+	Warnings []PreviewWarning `json:"warnings"`
+}
+
+// PreviewEmail 预览邮件：执行与发送相同的权限检查、验证和MIME构建，
+// 返回原始邮件、解析后的MIME结构和警告，不连接SMTP服务器也不记录发送历史
+func (s *EmailService) PreviewEmail(p *Principal, req *SendEmailRequest) (*EmailPreview, error) {
+	// 按分组发送时每个收件人单独发送，预览第一个收件人的邮件
+	groupTotal := 0
+	if len(req.GroupIDs) > 0 {
+		recipients, _, err := s.groupRecipients(p, req)
+		if err != nil {
+			return nil, err
+		}
+		groupTotal = len(recipients)
+		req = recipientRequest(req, recipients[0])
+	}
+
+	if err := s.applySuppressions(p, req); err != nil {
+		return nil, err
+	}
+	smtpConfig, message, err := s.prepareMessage(p, req)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := ParseMessage(bytes.NewReader(message))
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &EmailPreview{
+		Raw:      string(message),
+		Size:     len(message),
+		Message:  parsed,
+		Warnings: []PreviewWarning{},
+	}
+	warn := func(code, format string, args ...interface{}) {
+		preview.Warnings = append(preview.Warnings, PreviewWarning{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if limit := config.GetConfig().SMTP.MaxMessageSize; limit > 0 && int64(preview.Size) > limit {
+		warn(PreviewWarningOversize, "邮件大小 %d 字节超过上限 %d 字节，可能被SMTP服务器拒绝", preview.Size, limit)
+	}
+	if parsed.Text == "" {
+		warn(PreviewWarningMissingText, "邮件没有纯文本部分，不支持HTML的客户端无法显示，且可能提高垃圾邮件评分")
+	}
+	for _, cid := range unresolvedCIDs(parsed) {
+		warn(PreviewWarningUnresolvedCID, "HTML引用的内嵌资源 cid:%s 不存在", cid)
+	}
+	if err := s.workspaceService.CheckQuota(p.WorkspaceID); errors.Is(err, ErrQuotaExceeded) {
+		warn(PreviewWarningQuotaExceeded, "%v", err)
+	}
+	if len(req.suppressed) > 0 {
+		warn(PreviewWarningSuppressed, "以下收件人在抑制列表中，发送时会被跳过: %s", strings.Join(req.suppressed, ", "))
+	}
+	if !req.Bulk && strings.Contains(req.Body, UnsubscribePlaceholder) {
+		warn(PreviewWarningUnsubscribe, "正文包含 %s，但邮件未标记为批量邮件，占位符会被替换为空", UnsubscribePlaceholder)
+	}
+	if req.Track && !req.tracked {
+		warn(PreviewWarningTracking, "打开和点击追踪已全局关闭，邮件不会被追踪")
+	}
+	if groupTotal > 0 {
+		warn(PreviewWarningGroup, "按分组发送给 %d 个收件人，每人单独发送，这里预览发给 %s 的邮件", groupTotal, req.To[0])
+	}
+	if len(req.missingVariables) > 0 {
+		warn(PreviewWarningMissingVar, "以下模板变量没有值，会原样保留: %s", strings.Join(req.missingVariables, ", "))
+	}
+	if CaptureEnabled(smtpConfig) {
+		warn(PreviewWarningCapture, "SMTP配置处于捕获模式，邮件只会保存到捕获收件箱，不会投递")
+	}
+
+	return preview, nil
+}
+
+// unresolvedCIDs 返回HTML正文中引用但邮件中不存在的Content-ID（去重，按出现顺序）
+func unresolvedCIDs(parsed *ParsedMessage) []string {
+	known := map[string]bool{}
+	for _, part := range parsed.Attachments {
+		if part.ContentID != "" {
+			known[strings.ToLower(part.ContentID)] = true
+		}
+	}
+
+	var missing []string
+	for _, match := range cidPattern.FindAllStringSubmatch(parsed.HTML, -1) {
+		cid := strings.ToLower(match[1])
+		if !known[cid] {
+			known[cid] = true
+			missing = append(missing, match[1])
+		}
+	}
+	return missing
+}
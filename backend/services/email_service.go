@@ -2,17 +2,21 @@ package services
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"mime/multipart"
+	"net"
 	"net/mail"
 	"net/smtp"
 	"net/textproto"
 	"strings"
 	"time"
 
+	"smtp-mail/backend/config"
 	"smtp-mail/backend/database"
 	"smtp-mail/backend/models"
 	"smtp-mail/backend/utils"
@@ -20,67 +24,252 @@ import (
 
 // EmailService 邮件服务
 type EmailService struct {
-	smtpService *SMTPService
+	smtpService        *SMTPService
+	workspaceService   *WorkspaceService
+	captureService     *CaptureService
+	attachmentService  *AttachmentService
+	webhookService     *WebhookService
+	suppressionService *SuppressionService
+	unsubscribeService *UnsubscribeService
+	contactService     *ContactService
+	trackingService    *TrackingService
+	templateService    *TemplateService
+	scanner            Scanner
+	scannerFailOpen    bool
 }
 
 // NewEmailService 创建邮件服务实例
 func NewEmailService() *EmailService {
+	scanCfg := config.GetConfig().Scanner
+	scanner, err := NewScanner(scanCfg)
+	if err != nil {
+		utils.Errorf("创建附件扫描引擎失败: %v", err)
+		scanner = unavailableScanner{err: err}
+	}
+
 	return &EmailService{
-		smtpService: NewSMTPService(),
+		smtpService:        NewSMTPService(),
+		workspaceService:   NewWorkspaceService(),
+		captureService:     NewCaptureService(),
+		attachmentService:  NewAttachmentService(),
+		webhookService:     NewWebhookService(),
+		suppressionService: NewSuppressionService(),
+		unsubscribeService: NewUnsubscribeService(),
+		contactService:     NewContactService(),
+		templateService:    NewTemplateService(),
+		trackingService:    NewTrackingService(),
+		scanner:            scanner,
+		scannerFailOpen:    scanCfg.FailOpen,
 	}
 }
 
 // SendEmailRequest 发送邮件请求
 type SendEmailRequest struct {
 	SmtpConfigID uint         `json:"smtp_config_id" binding:"required"`
-	To           []string     `json:"to" binding:"required,min=1"`
+	To           []string     `json:"to"`
 	Cc           []string     `json:"cc"`
 	Bcc          []string     `json:"bcc"`
-	Subject      string       `json:"subject" binding:"required"`
-	Body         string       `json:"body" binding:"required"`
+	Subject      string       `json:"subject"`
+	Body         string       `json:"body"`
+	Text         string       `json:"text"` // 纯文本正文：与body同时提供时作为纯文本替代部分，只有text时发送纯文本邮件
 	Attachments  []Attachment `json:"attachments"`
+
+	// IgnoreSuppressions 忽略抑制列表，用于必须送达的事务邮件，仅工作区管理员可用
+	IgnoreSuppressions bool `json:"ignore_suppressions"`
+	// Bulk 批量（营销）邮件：添加List-Unsubscribe和一键退订头，只允许一个收件人
+	Bulk bool `json:"bulk"`
+	// Track 开启打开和点击追踪：改写正文中的链接并添加追踪像素，全局开关关闭时忽略
+	Track bool `json:"track"`
+	// GroupIDs 联系人分组，展开为成员后逐个单独发送，见SendToGroups
+	GroupIDs []uint `json:"group_ids"`
+	// TemplateID 使用邮件模板：主题或正文为空时取自模板，发送历史记录实际使用的模板版本
+	TemplateID *uint `json:"template_id"`
+	// TemplateVersion 使用的模板版本，0表示当前发布的版本
+	TemplateVersion int `json:"template_version"`
+	// Personalize 按唯一收件人的联系人属性替换主题和正文中的变量；按分组发送和营销活动总是替换
+	Personalize bool `json:"personalize"`
+
+	suppressed     []string // 因在抑制列表中而跳过的收件人
+	scanResult     string   // 附件扫描结论，记录到发送历史
+	messageID      string   // 生成的Message-ID，用于匹配退信
+	envelopeFrom   string   // 信封发件人，启用VERP时为 local+bounce-<token>@domain
+	unsubscribeURL string   // 批量邮件收件人的退订链接
+	tracked        bool     // 实际开启了追踪（请求了追踪且全局开关打开）
+
+	variables        map[string]string // 模板变量，收件人是联系人时为联系人的属性
+	missingVariables []string          // 正文或主题中引用了但没有值的变量
+	campaignID       *uint             // 营销活动发送时记录到发送历史
+	templateID       *uint             // 实际使用的模板和版本，记录到发送历史
+	templateVersion  int
+
+	fallbackSmtpConfigIDs []uint // 备用SMTP配置，SmtpConfigID连接失败或返回临时错误时按顺序改用
+	retried               bool   // 已改用备用配置重新发送，生成email.retried事件
+
+	from    *mail.Address        // 转发原始邮件时邮件头中的发件人，保留其显示名称
+	headers textproto.MIMEHeader // 转发原始邮件时保留的自定义邮件头
+}
+
+// recipients 返回收件人、抄送和密送的全部地址
+func (req *SendEmailRequest) recipients() []string {
+	return append(append(append([]string{}, req.To...), req.Cc...), req.Bcc...)
 }
 
 // Attachment 附件（用于请求）
+// 通过 POST /api/attachments 上传的文件使用ID引用，否则需要提供文件名和base64内容
 type Attachment struct {
-	Filename    string `json:"filename" binding:"required"`
-	Content     string `json:"content" binding:"required"` // base64编码的文件内容
-	ContentType string `json:"content_type"`               // 内容类型（可选）
+	ID          uint   `json:"id"`           // 已上传文件的ID
+	Filename    string `json:"filename"`     // 引用已上传文件时可选，默认为上传时的文件名
+	Content     string `json:"content"`      // base64编码的文件内容
+	ContentType string `json:"content_type"` // 内容类型（可选）
+
+	file *models.UploadedFile // 按ID解析出的上传文件
 }
 
-// SendEmail 发送邮件
-func (s *EmailService) SendEmail(req *SendEmailRequest) (*models.EmailHistory, error) {
+// SendEmail 以指定用户身份发送邮件
+func (s *EmailService) SendEmail(p *Principal, req *SendEmailRequest) (*models.EmailHistory, error) {
 	utils.Infof("开始发送邮件: SmtpConfigID=%d, To=%v, Subject=%s, Attachments=%d",
 		req.SmtpConfigID, req.To, req.Subject, len(req.Attachments))
-	
-	// 1. 获取SMTP配置（包含密码）
-	config, err := s.smtpService.GetConfigByIDWithPassword(req.SmtpConfigID)
+
+	if err := s.workspaceService.CheckQuota(p.WorkspaceID); err != nil {
+		return nil, err
+	}
+
+	// 跳过抑制列表中的收件人
+	if err := s.applySuppressions(p, req); err != nil {
+		return nil, err
+	}
+
+	// 1~3. 获取SMTP配置、验证收件人并构建邮件消息
+	config, message, err := s.prepareMessage(p, req)
 	if err != nil {
-		utils.Errorf("获取SMTP配置失败 (ID: %d): %v", req.SmtpConfigID, err)
-		return nil, fmt.Errorf("获取SMTP配置失败: %w", err)
+		return nil, err
 	}
 
-	utils.Infof("获取SMTP配置成功: Host=%s, Port=%d, FromEmail=%s", config.Host, config.Port, config.FromEmail)
+	// 扫描附件，发现病毒时拒绝发送并记录失败历史
+	if err := s.scanAttachments(req); err != nil {
+		history := s.createEmailHistory(p, req, models.EmailStatusFailed, err.Error())
+		return history, err
+	}
 
-	// 2. 解密SMTP密码
+	// 4. 解密SMTP密码
 	password, err := s.smtpService.cryptoService.DecryptPassword(config.Password)
 	if err != nil {
 		utils.Errorf("解密密码失败: %v", err)
 		return nil, fmt.Errorf("解密密码失败: %w", err)
 	}
 
-	// 3. 验证收件人邮箱格式
+	// 捕获模式：只保存邮件，不连接SMTP服务器
+	if CaptureEnabled(config) {
+		history := s.createEmailHistory(p, req, models.EmailStatusCaptured, "")
+		recipients := req.recipients()
+		if _, err := s.captureService.save(p.WorkspaceID, p.UserID, config, history.ID, recipients, req.Subject, message); err != nil {
+			return history, err
+		}
+		return history, nil
+	}
+
+	// 5. 发送邮件，连接失败或临时错误时改用备用SMTP配置
+	err = s.sendEmailViaSMTP(config, password, req.envelopeFrom, req.To, req.Cc, req.Bcc, message)
+	err = s.sendWithFallback(p, req, err)
+	if err != nil {
+		utils.Errorf("发送邮件失败: %v", err)
+		// 上游永久拒绝的收件人加入抑制列表
+		var rcptErr *RecipientError
+		if errors.As(err, &rcptErr) && rcptErr.Permanent() {
+			s.suppressionService.Add(p.WorkspaceID, rcptErr.Address, models.SuppressionSourceSMTPRejection, rcptErr.Err.Error())
+		}
+		// 记录失败历史
+		history := s.createEmailHistory(p, req, models.EmailStatusFailed, err.Error())
+		return history, fmt.Errorf("发送邮件失败: %w", err)
+	}
+
+	// 6. 记录成功历史
+	history := s.createEmailHistory(p, req, models.EmailStatusSuccess, "")
+	utils.Infof("邮件发送成功: To=%v, Subject=%s", req.To, req.Subject)
+
+	return history, nil
+}
+
+// prepareMessage 检查SMTP配置的使用权限、验证收件人并构建邮件消息，发送和预览共用
+func (s *EmailService) prepareMessage(p *Principal, req *SendEmailRequest) (*models.SMTPConfig, []byte, error) {
+	// 1. 获取SMTP配置（包含密码），API密钥可能限制可用的配置
+	if !p.CanUseSMTPConfig(req.SmtpConfigID) {
+		utils.Warnf("API密钥 %d 无权使用SMTP配置 (ID: %d)", p.APIKeyID, req.SmtpConfigID)
+		return nil, nil, ErrForbidden
+	}
+	config, err := s.smtpService.GetConfigByIDWithPassword(p, req.SmtpConfigID)
+	if err != nil {
+		utils.Errorf("获取SMTP配置失败 (ID: %d): %v", req.SmtpConfigID, err)
+		return nil, nil, fmt.Errorf("获取SMTP配置失败: %w", err)
+	}
+
+	utils.Infof("获取SMTP配置成功: Host=%s, Port=%d, FromEmail=%s", config.Host, config.Port, config.FromEmail)
+
+	// 使用模板时从模板的版本补全主题和正文
+	if err := s.applyTemplate(p, req); err != nil {
+		return nil, nil, err
+	}
+
+	// 转发的原始邮件只能以SMTP配置的发件邮箱发出
+	if req.from != nil && !strings.EqualFold(req.from.Address, config.FromEmail) {
+		return nil, nil, fmt.Errorf("%w: %s（配置为 %s）", ErrSenderMismatch, req.from.Address, config.FromEmail)
+	}
+
+	// 2. 验证收件人邮箱格式
+	if len(req.recipients()) == 0 {
+		return nil, nil, errors.New("收件人列表不能为空")
+	}
 	if err := validateEmails(req.To); err != nil {
-		return nil, fmt.Errorf("收件人邮箱格式错误: %w", err)
+		return nil, nil, fmt.Errorf("收件人邮箱格式错误: %w", err)
 	}
 	if err := validateEmails(req.Cc); err != nil {
-		return nil, fmt.Errorf("抄送邮箱格式错误: %w", err)
+		return nil, nil, fmt.Errorf("抄送邮箱格式错误: %w", err)
 	}
 	if err := validateEmails(req.Bcc); err != nil {
-		return nil, fmt.Errorf("密送邮箱格式错误: %w", err)
+		return nil, nil, fmt.Errorf("密送邮箱格式错误: %w", err)
+	}
+
+	// 只有一个收件人时，主题和正文中的 {{变量}} 替换为收件人（联系人）的属性
+	if err := s.applyVariables(p, req); err != nil {
+		return nil, nil, err
+	}
+
+	// 批量邮件的退订链接按收件人生成，因此每封邮件只能有一个收件人
+	if req.Bulk {
+		recipients := req.recipients()
+		if len(recipients) != 1 {
+			return nil, nil, fmt.Errorf("批量邮件只能有一个收件人，当前为 %d 个", len(recipients))
+		}
+		unsubscribeURL, err := s.unsubscribeService.URL(p.WorkspaceID, recipients[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		req.unsubscribeURL = unsubscribeURL
+	}
+
+	req.tracked = req.Track && TrackingEnabled()
+	if req.Track && !req.tracked {
+		utils.Infof("打开和点击追踪已全局关闭，忽略track")
 	}
 
-	// 4. 构建邮件消息
+	// 3. 解析引用的上传文件并构建邮件消息
+	if err := s.resolveAttachments(p, req); err != nil {
+		return nil, nil, err
+	}
+	message, err := s.buildMessageFor(config, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return config, message, nil
+}
+
+// buildMessageFor 按SMTP配置的发件人生成Message-ID和信封发件人并构建邮件消息
+func (s *EmailService) buildMessageFor(config *models.SMTPConfig, req *SendEmailRequest) ([]byte, error) {
+	req.messageID = newMessageID(config.FromEmail)
+	req.envelopeFrom = config.FromEmail
+	if verpEnabled(config.ID) {
+		req.envelopeFrom = verpAddress(config.FromEmail, req.messageID)
+	}
 	message, err := s.buildEmailMessage(config, req)
 	if err != nil {
 		utils.Errorf("构建邮件消息失败: %v", err)
@@ -88,21 +277,158 @@ func (s *EmailService) SendEmail(req *SendEmailRequest) (*models.EmailHistory, e
 	}
 
 	utils.Infof("邮件消息构建成功: 消息大小=%d 字节", len(message))
+	return message, nil
+}
+
+// sendWithFallback 依次使用备用SMTP配置重新发送，直到成功或遇到不应切换配置的错误
+// 发送历史记录最后使用的配置；不可用（无权使用、已删除或处于捕获模式）的备用配置被跳过
+func (s *EmailService) sendWithFallback(p *Principal, req *SendEmailRequest, err error) error {
+	for _, id := range req.fallbackSmtpConfigIDs {
+		if err == nil || !failoverable(err) {
+			break
+		}
+		if !p.CanUseSMTPConfig(id) {
+			utils.Warnf("跳过备用SMTP配置 (ID: %d): 无权使用", id)
+			continue
+		}
+		config, getErr := s.smtpService.GetConfigByIDWithPassword(p, id)
+		if getErr != nil {
+			utils.Warnf("跳过备用SMTP配置 (ID: %d): %v", id, getErr)
+			continue
+		}
+		if CaptureEnabled(config) {
+			utils.Warnf("跳过备用SMTP配置 (ID: %d): 处于捕获模式", id)
+			continue
+		}
+		password, decryptErr := s.smtpService.cryptoService.DecryptPassword(config.Password)
+		if decryptErr != nil {
+			utils.Warnf("跳过备用SMTP配置 (ID: %d): 解密密码失败: %v", id, decryptErr)
+			continue
+		}
+		message, buildErr := s.buildMessageFor(config, req)
+		if buildErr != nil {
+			return buildErr
+		}
+
+		utils.Warnf("SMTP配置 (ID: %d) 发送失败，改用备用配置 (ID: %d): %v", req.SmtpConfigID, id, err)
+		req.SmtpConfigID = id
+		req.retried = true
+		err = s.sendEmailViaSMTP(config, password, req.envelopeFrom, req.To, req.Cc, req.Bcc, message)
+	}
+	return err
+}
+
+// failoverable 是否应改用备用SMTP配置：网络连接失败，或服务器返回4xx临时错误
+// 认证失败、5xx永久拒绝等换一台服务器也不会成功的错误不切换
+func failoverable(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 400 && protoErr.Code < 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
 
-	// 5. 发送邮件
-	err = s.sendEmailViaSMTP(config, password, req.To, req.Cc, req.Bcc, message)
+// applySuppressions 从收件人、抄送和密送中移除抑制列表中的地址，移除的地址记录到req.suppressed
+// 设置了IgnoreSuppressions时不检查（需要工作区管理员）；没有剩余收件人时返回ErrAllRecipientsSuppressed
+func (s *EmailService) applySuppressions(p *Principal, req *SendEmailRequest) error {
+	if req.IgnoreSuppressions {
+		if !p.IsAdmin() {
+			utils.Warnf("用户 %d 无权忽略抑制列表", p.UserID)
+			return ErrForbidden
+		}
+		return nil
+	}
+
+	suppressed, err := s.suppressionService.Suppressed(p.WorkspaceID, req.recipients())
 	if err != nil {
-		utils.Errorf("发送邮件失败: %v", err)
-		// 记录失败历史
-		history := s.createEmailHistory(req, models.EmailStatusFailed, err.Error())
-		return history, fmt.Errorf("发送邮件失败: %w", err)
+		return err
+	}
+	if len(suppressed) == 0 {
+		return nil
 	}
 
-	// 6. 记录成功历史
-	history := s.createEmailHistory(req, models.EmailStatusSuccess, "")
-	utils.Infof("邮件发送成功: To=%v, Subject=%s", req.To, req.Subject)
+	// 按去掉显示名称后的地址过滤，同一地址在不同字段中写法不同时也会被跳过
+	blocked := make(map[string]bool, len(suppressed))
+	for _, address := range suppressed {
+		blocked[suppressionKey(address)] = true
+	}
+	filter := func(addresses []string) []string {
+		var kept []string
+		for _, address := range addresses {
+			if !blocked[suppressionKey(address)] {
+				kept = append(kept, address)
+			}
+		}
+		return kept
+	}
+	req.To, req.Cc, req.Bcc = filter(req.To), filter(req.Cc), filter(req.Bcc)
+	req.suppressed = suppressed
 
-	return history, nil
+	utils.Infof("跳过抑制列表中的收件人: %v", suppressed)
+	if len(req.To)+len(req.Cc)+len(req.Bcc) == 0 {
+		return fmt.Errorf("%w: %s", ErrAllRecipientsSuppressed, strings.Join(suppressed, ", "))
+	}
+	return nil
+}
+
+// resolveAttachments 按ID查找引用的上传文件，补全文件名和内容类型
+func (s *EmailService) resolveAttachments(p *Principal, req *SendEmailRequest) error {
+	for i := range req.Attachments {
+		attachment := &req.Attachments[i]
+		if attachment.ID == 0 {
+			if attachment.Filename == "" || attachment.Content == "" {
+				return fmt.Errorf("附件需要提供ID，或文件名和内容")
+			}
+			continue
+		}
+		file, err := s.attachmentService.GetFile(p, attachment.ID)
+		if err != nil {
+			return err
+		}
+		attachment.file = file
+		if attachment.Filename == "" {
+			attachment.Filename = file.Filename
+		}
+		if attachment.ContentType == "" {
+			attachment.ContentType = file.ContentType
+		}
+	}
+	return nil
+}
+
+// scanAttachments 使用扫描引擎逐个扫描附件，结论写入req.scanResult
+// 扫描服务出错时，除非配置了 scanner.fail_open，否则拒绝发送
+func (s *EmailService) scanAttachments(req *SendEmailRequest) error {
+	if s.scanner == nil || len(req.Attachments) == 0 {
+		return nil
+	}
+
+	req.scanResult = "clean"
+	for _, attachment := range req.Attachments {
+		content, err := s.openAttachment(attachment)
+		if err != nil {
+			return err
+		}
+		verdict, err := s.scanner.Scan(context.Background(), content)
+		content.Close()
+
+		if err != nil {
+			utils.Errorf("扫描附件失败 (%s, %s): %v", s.scanner.Name(), attachment.Filename, err)
+			if !s.scannerFailOpen {
+				req.scanResult = fmt.Sprintf("error: %s", attachment.Filename)
+				return fmt.Errorf("%w: %v", ErrScannerUnavailable, err)
+			}
+			req.scanResult = "unscanned"
+			continue
+		}
+		if verdict.Infected {
+			utils.Warnf("附件 %s 检出病毒 %s，拒绝发送", attachment.Filename, verdict.Signature)
+			req.scanResult = fmt.Sprintf("infected: %s (%s)", verdict.Signature, attachment.Filename)
+			return fmt.Errorf("%w: %s (%s)", ErrAttachmentInfected, verdict.Signature, attachment.Filename)
+		}
+	}
+	return nil
 }
 
 // buildEmailMessage 构建邮件消息
@@ -110,20 +436,58 @@ func (s *EmailService) buildEmailMessage(config *models.SMTPConfig, req *SendEma
 	var buf bytes.Buffer
 	writer := multipart.NewWriter(&buf)
 
-	// 构建邮件头
+	// 构建邮件头，转发的原始邮件保留发件人的显示名称
+	fromName := config.FromName
+	if req.from != nil && req.from.Name != "" {
+		fromName = req.from.Name
+	}
 	headers := map[string]string{
-		"From":         s.formatEmailAddress(config.FromName, config.FromEmail),
-		"To":           strings.Join(req.To, ", "),
+		"From":         s.formatEmailAddress(fromName, config.FromEmail),
 		"Subject":      req.Subject,
 		"Date":         time.Now().Format(time.RFC1123Z),
 		"MIME-Version": "1.0",
 	}
+	if req.messageID != "" {
+		headers["Message-ID"] = req.messageID
+	}
+
+	// 批量邮件添加退订头，支持一键退订的客户端会向该链接POST List-Unsubscribe=One-Click
+	if req.unsubscribeURL != "" {
+		headers["List-Unsubscribe"] = "<" + req.unsubscribeURL + ">"
+		headers["List-Unsubscribe-Post"] = "List-Unsubscribe=One-Click"
+	}
+	// 正文中的退订链接占位符，非批量邮件没有退订链接，替换为空
+	body := strings.ReplaceAll(req.Body, UnsubscribePlaceholder, req.unsubscribeURL)
+	text := strings.ReplaceAll(req.Text, UnsubscribePlaceholder, req.unsubscribeURL)
+
+	// 开启追踪时改写链接并添加追踪像素；只有一个收件人时追踪到具体收件人
+	if req.tracked && body != "" {
+		recipients := req.recipients()
+		recipient := ""
+		if len(recipients) == 1 {
+			recipient = recipients[0]
+		}
+		var err error
+		if body, err = s.trackingService.Instrument(body, req.messageID, recipient); err != nil {
+			return nil, err
+		}
+	}
+
+	// 收件人全部被抑制、只剩抄送时省略To
+	if len(req.To) > 0 {
+		headers["To"] = strings.Join(req.To, ", ")
+	}
 
 	// 添加抄送
 	if len(req.Cc) > 0 {
 		headers["Cc"] = strings.Join(req.Cc, ", ")
 	}
 
+	contentType, content, err := messageContent(body, text)
+	if err != nil {
+		return nil, err
+	}
+
 	// 如果有附件，使用multipart/mixed
 	if len(req.Attachments) > 0 {
 		headers["Content-Type"] = fmt.Sprintf("multipart/mixed; boundary=%s", writer.Boundary())
@@ -132,16 +496,17 @@ func (s *EmailService) buildEmailMessage(config *models.SMTPConfig, req *SendEma
 		for k, v := range headers {
 			buf.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
 		}
+		writeRelayHeaders(&buf, req.headers)
 		buf.WriteString("\r\n")
 
-		// 创建HTML正文部分
-		htmlHeader := textproto.MIMEHeader{}
-		htmlHeader.Set("Content-Type", "text/html; charset=UTF-8")
-		htmlPart, err := writer.CreatePart(htmlHeader)
+		// 创建正文部分
+		contentHeader := textproto.MIMEHeader{}
+		contentHeader.Set("Content-Type", contentType)
+		contentPart, err := writer.CreatePart(contentHeader)
 		if err != nil {
-			return nil, fmt.Errorf("创建HTML部分失败: %w", err)
+			return nil, fmt.Errorf("创建正文部分失败: %w", err)
 		}
-		htmlPart.Write([]byte(req.Body))
+		contentPart.Write(content)
 
 		// 添加附件
 		for _, attachment := range req.Attachments {
@@ -152,32 +517,84 @@ func (s *EmailService) buildEmailMessage(config *models.SMTPConfig, req *SendEma
 
 		writer.Close()
 	} else {
-		// 没有附件，直接发送HTML
-		headers["Content-Type"] = "text/html; charset=UTF-8"
+		// 没有附件，直接发送正文
+		headers["Content-Type"] = contentType
 
 		// 写入邮件头
 		for k, v := range headers {
 			buf.WriteString(fmt.Sprintf("%s: %s\r\n", k, v))
 		}
+		writeRelayHeaders(&buf, req.headers)
 		buf.WriteString("\r\n")
-		buf.WriteString(req.Body)
+		buf.Write(content)
 	}
 
 	return buf.Bytes(), nil
 }
 
-// addAttachment 添加附件
-func (s *EmailService) addAttachment(writer *multipart.Writer, attachment Attachment) error {
-	utils.Infof("处理附件: Filename=%s, ContentLength=%d", attachment.Filename, len(attachment.Content))
-	
+// messageContent 生成正文：同时有HTML和纯文本时为multipart/alternative，只有纯文本时为text/plain，否则为text/html
+func messageContent(body, text string) (string, []byte, error) {
+	switch {
+	case text == "":
+		return "text/html; charset=UTF-8", []byte(body), nil
+	case body == "":
+		return "text/plain; charset=UTF-8", []byte(text), nil
+	}
+
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", body},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return "", nil, fmt.Errorf("创建正文部分失败: %w", err)
+		}
+		w.Write([]byte(part.content))
+	}
+	writer.Close()
+	return "multipart/alternative; boundary=" + writer.Boundary(), buf.Bytes(), nil
+}
+
+// writeRelayHeaders 写入转发原始邮件时保留的自定义邮件头（已由邮件解析展开为单行）
+func writeRelayHeaders(buf *bytes.Buffer, headers textproto.MIMEHeader) {
+	for key, values := range headers {
+		for _, value := range values {
+			buf.WriteString(key + ": " + value + "\r\n")
+		}
+	}
+}
+
+// openAttachment 打开附件内容：已上传的文件从磁盘读取，否则解码base64内容
+func (s *EmailService) openAttachment(attachment Attachment) (io.ReadCloser, error) {
+	if attachment.file != nil {
+		return s.attachmentService.Open(attachment.file)
+	}
+
 	// 解码base64内容
-	content, err := base64.StdEncoding.DecodeString(attachment.Content)
+	decoded, err := base64.StdEncoding.DecodeString(attachment.Content)
 	if err != nil {
 		utils.Errorf("解码附件内容失败: %v", err)
-		return fmt.Errorf("解码附件内容失败: %w", err)
+		return nil, fmt.Errorf("解码附件内容失败: %w", err)
 	}
+	return io.NopCloser(bytes.NewReader(decoded)), nil
+}
 
-	utils.Infof("附件解码成功: Filename=%s, DecodedSize=%d", attachment.Filename, len(content))
+// addAttachment 添加附件，已上传的文件从磁盘流式写入
+func (s *EmailService) addAttachment(writer *multipart.Writer, attachment Attachment) error {
+	if attachment.file != nil {
+		utils.Infof("处理附件: Filename=%s, UploadedFileID=%d, Size=%d", attachment.Filename, attachment.file.ID, attachment.file.Size)
+	} else {
+		utils.Infof("处理附件: Filename=%s, ContentLength=%d", attachment.Filename, len(attachment.Content))
+	}
+	content, err := s.openAttachment(attachment)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
 
 	// 确定内容类型
 	contentType := attachment.ContentType
@@ -198,15 +615,38 @@ func (s *EmailService) addAttachment(writer *multipart.Writer, attachment Attach
 
 	// 写入base64编码的内容
 	encoder := base64.NewEncoder(base64.StdEncoding, part)
-	encoder.Write(content)
-	encoder.Close()
+	if _, err := io.Copy(encoder, content); err != nil {
+		return fmt.Errorf("写入附件内容失败: %w", err)
+	}
+	return encoder.Close()
+}
 
-	return nil
+// RecipientError 上游SMTP服务器拒绝了收件人
+type RecipientError struct {
+	Address string
+	Code    int // SMTP响应码，无法识别时为0
+	Err     error
 }
 
-// sendEmailViaSMTP 通过SMTP发送邮件
-func (s *EmailService) sendEmailViaSMTP(config *models.SMTPConfig, password string, to, cc, bcc []string, message []byte) error {
+func (e *RecipientError) Error() string {
+	return fmt.Sprintf("设置收件人失败 (%s): %v", e.Address, e.Err)
+}
+
+func (e *RecipientError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent 是否为5xx永久错误（如地址不存在），这类地址会被加入抑制列表
+func (e *RecipientError) Permanent() bool {
+	return e.Code >= 500 && e.Code < 600
+}
+
+// sendEmailViaSMTP 通过SMTP发送邮件，from为信封发件人，为空时使用配置的发件人
+func (s *EmailService) sendEmailViaSMTP(config *models.SMTPConfig, password, from string, to, cc, bcc []string, message []byte) error {
 	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
+	if from == "" {
+		from = config.FromEmail
+	}
 
 	// 合并所有收件人
 	allRecipients := append(append(to, cc...), bcc...)
@@ -214,18 +654,36 @@ func (s *EmailService) sendEmailViaSMTP(config *models.SMTPConfig, password stri
 	// 根据加密类型选择发送方式
 	switch config.Encryption {
 	case models.EncryptionTLS:
-		return s.sendWithTLS(addr, config.Username, password, config.Host, config.FromEmail, allRecipients, message)
+		return s.sendWithTLS(addr, config.Username, password, config.Host, from, allRecipients, message)
 	case models.EncryptionStartTLS:
-		return s.sendWithStartTLS(addr, config.Username, password, config.Host, config.FromEmail, allRecipients, message)
+		return s.sendWithStartTLS(addr, config.Username, password, config.Host, from, allRecipients, message)
 	default:
-		return s.sendPlain(addr, config.Username, password, config.Host, config.FromEmail, allRecipients, message)
+		return s.sendPlain(addr, config.Username, password, config.Host, from, allRecipients, message)
 	}
 }
 
-// sendPlain 普通SMTP发送
+// sendPlain 普通SMTP发送，服务器支持STARTTLS时自动升级（与 smtp.SendMail 相同）
 func (s *EmailService) sendPlain(addr, username, password, host, from string, to []string, message []byte) error {
-	auth := smtp.PlainAuth("", username, password, host)
-	return smtp.SendMail(addr, auth, from, to, message)
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("启动STARTTLS失败: %w", err)
+		}
+	}
+
+	// 未配置用户名密码时不认证（如本机或内网中继）
+	if username != "" && password != "" {
+		if err := client.Auth(smtp.PlainAuth("", username, password, host)); err != nil {
+			return fmt.Errorf("认证失败: %w", err)
+		}
+	}
+
+	return s.deliver(client, from, to, message)
 }
 
 // sendWithTLS 使用TLS发送邮件（端口465 - SMTPS）
@@ -257,31 +715,7 @@ func (s *EmailService) sendWithTLS(addr, username, password, host, from string,
 		}
 	}
 
-	// 设置发件人
-	if err := client.Mail(from); err != nil {
-		return fmt.Errorf("设置发件人失败: %w", err)
-	}
-
-	// 设置收件人
-	for _, recipient := range to {
-		if err := client.Rcpt(recipient); err != nil {
-			return fmt.Errorf("设置收件人失败 (%s): %w", recipient, err)
-		}
-	}
-
-	// 发送邮件内容
-	wc, err := client.Data()
-	if err != nil {
-		return fmt.Errorf("获取数据写入器失败: %w", err)
-	}
-	defer wc.Close()
-
-	_, err = wc.Write(message)
-	if err != nil {
-		return fmt.Errorf("写入邮件内容失败: %w", err)
-	}
-
-	return nil
+	return s.deliver(client, from, to, message)
 }
 
 // sendWithStartTLS 使用StartTLS发送（端口587）
@@ -316,6 +750,11 @@ func (s *EmailService) sendWithStartTLS(addr, username, password, host, from str
 		}
 	}
 
+	return s.deliver(client, from, to, message)
+}
+
+// deliver 在已建立的连接上发送邮件；收件人被拒绝时返回 *RecipientError
+func (s *EmailService) deliver(client *smtp.Client, from string, to []string, message []byte) error {
 	// 设置发件人
 	if err := client.Mail(from); err != nil {
 		return fmt.Errorf("设置发件人失败: %w", err)
@@ -324,7 +763,12 @@ func (s *EmailService) sendWithStartTLS(addr, username, password, host, from str
 	// 设置收件人
 	for _, recipient := range to {
 		if err := client.Rcpt(recipient); err != nil {
-			return fmt.Errorf("设置收件人失败 (%s): %w", recipient, err)
+			rcptErr := &RecipientError{Address: recipient, Err: err}
+			var protoErr *textproto.Error
+			if errors.As(err, &protoErr) {
+				rcptErr.Code = protoErr.Code
+			}
+			return rcptErr
 		}
 	}
 
@@ -333,18 +777,29 @@ func (s *EmailService) sendWithStartTLS(addr, username, password, host, from str
 	if err != nil {
 		return fmt.Errorf("获取数据写入器失败: %w", err)
 	}
-	defer wc.Close()
-
-	_, err = wc.Write(message)
-	if err != nil {
+	if _, err := wc.Write(message); err != nil {
+		wc.Close()
 		return fmt.Errorf("写入邮件内容失败: %w", err)
 	}
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("发送邮件内容失败: %w", err)
+	}
 
+	// 邮件已被接受，QUIT失败不影响结果
+	client.Quit()
 	return nil
 }
 
+// historyBody 发送历史中保存的正文，只有纯文本正文时转换为HTML以便显示
+func historyBody(req *SendEmailRequest) string {
+	if req.Body == "" {
+		return TextToHTML(req.Text)
+	}
+	return req.Body
+}
+
 // createEmailHistory 创建邮件发送历史记录
-func (s *EmailService) createEmailHistory(req *SendEmailRequest, status models.EmailStatus, errorMessage string) *models.EmailHistory {
+func (s *EmailService) createEmailHistory(p *Principal, req *SendEmailRequest, status models.EmailStatus, errorMessage string) *models.EmailHistory {
 	// 转换附件格式
 	attachments := make([]models.Attachment, len(req.Attachments))
 	for i, att := range req.Attachments {
@@ -353,33 +808,68 @@ func (s *EmailService) createEmailHistory(req *SendEmailRequest, status models.E
 			Path:     "", // base64内容不保存路径
 			Size:     int64(len(att.Content)),
 		}
+		if att.file != nil {
+			attachments[i].Size = att.file.Size
+		}
 	}
 
-	// 使用第一个收件人作为主要收件人
-	toEmail := req.To[0]
-	if len(req.To) > 1 {
-		toEmail = strings.Join(req.To, ", ")
+	// 多个收件人以逗号分隔
+	toEmail := strings.Join(req.To, ", ")
+
+	// 通过API密钥发送时记录密钥ID
+	var apiKeyID *uint
+	if p.IsAPIKey() {
+		id := p.APIKeyID
+		apiKeyID = &id
 	}
 
 	history := &models.EmailHistory{
+		WorkspaceID:  p.WorkspaceID,
 		SmtpConfigID: req.SmtpConfigID,
+		UserID:       p.UserID,
+		APIKeyID:     apiKeyID,
 		ToEmail:      toEmail,
 		CcEmail:      req.Cc,
 		BccEmail:     req.Bcc,
 		Subject:      req.Subject,
-		Body:         req.Body,
+		Body:         historyBody(req),
 		Attachments:  attachments,
 		Status:       status,
 		ErrorMessage: errorMessage,
+		ScanResult:   req.scanResult,
+		MessageID:    req.messageID,
+		Suppressed:   req.suppressed,
+		Tracked:      req.tracked,
+		CampaignID:   req.campaignID,
 		SentAt:       time.Now(),
+
+		TemplateID:      req.templateID,
+		TemplateVersion: req.templateVersion,
+	}
+	// 开启追踪时为每个收件人创建一行，记录打开和点击
+	if req.tracked {
+		add := func(kind string, addresses []string) {
+			for _, address := range addresses {
+				history.Recipients = append(history.Recipients, models.EmailRecipient{Address: address, Kind: kind})
+			}
+		}
+		add(models.RecipientTo, req.To)
+		add(models.RecipientCc, req.Cc)
+		add(models.RecipientBcc, req.Bcc)
 	}
 
 	// 保存到数据库
 	db := database.GetDB()
 	if err := db.Create(history).Error; err != nil {
 		utils.Errorf("保存邮件历史失败: %v", err)
+		return history
 	}
 
+	// 生成发送结果事件，推送给订阅的Webhook；改用过备用配置时先生成重试事件
+	if req.retried {
+		s.webhookService.EnqueueEvent(models.WebhookEventEmailRetried, history)
+	}
+	s.webhookService.Enqueue(history)
 	return history
 }
 
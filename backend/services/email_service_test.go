@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+)
+
+// fakeSMTP 本地的SMTP服务器替身，不支持STARTTLS和认证；RCPT按rcptReply回复，记录收到的邮件数
+type fakeSMTP struct {
+	listener  net.Listener
+	rcptReply string
+
+	mu       sync.Mutex
+	received int
+}
+
+func newFakeSMTP(t *testing.T, rcptReply string) *fakeSMTP {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeSMTP{listener: listener, rcptReply: rcptReply}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "220 fake ESMTP\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, _, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			fmt.Fprintf(conn, "250 fake\r\n")
+		case "RCPT":
+			fmt.Fprintf(conn, "%s\r\n", f.rcptReply)
+		case "DATA":
+			fmt.Fprintf(conn, "354 go ahead\r\n")
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+			}
+			f.mu.Lock()
+			f.received++
+			f.mu.Unlock()
+			fmt.Fprintf(conn, "250 queued\r\n")
+		case "QUIT":
+			fmt.Fprintf(conn, "221 bye\r\n")
+			return
+		default:
+			fmt.Fprintf(conn, "250 ok\r\n")
+		}
+	}
+}
+
+func (f *fakeSMTP) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.received
+}
+
+// closedPort 返回一个没有服务监听的本地端口
+func closedPort(t *testing.T) int {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+	return port
+}
+
+func TestSendWithFallback(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		useTestKeyring(t, testKey1)
+		db := database.GetDB()
+		if err := db.Create(&models.Workspace{ID: 1, Name: "one", Slug: "one"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		createConfig := func(name string, port int) uint {
+			config := models.SMTPConfig{WorkspaceID: 1, Name: name, Host: "127.0.0.1", Port: port, FromEmail: "from@example.com"}
+			if err := db.Create(&config).Error; err != nil {
+				t.Fatal(err)
+			}
+			return config.ID
+		}
+		port := func(f *fakeSMTP) int { return f.listener.Addr().(*net.TCPAddr).Port }
+
+		fallback := newFakeSMTP(t, "250 ok")
+		fallbackID := createConfig("fallback", port(fallback))
+		tests := []struct {
+			name         string
+			primaryPort  int
+			wantFallback bool
+		}{
+			{"connection refused", closedPort(t), true},
+			{"temporary rejection", port(newFakeSMTP(t, "451 4.7.1 try again later")), true},
+			{"permanent rejection", port(newFakeSMTP(t, "550 5.1.1 user unknown")), false},
+		}
+
+		s := NewEmailService()
+		p := &Principal{UserID: 1, Role: models.RoleAdmin, WorkspaceID: 1}
+		for _, tt := range tests {
+			before := fallback.count()
+			primaryID := createConfig(tt.name, tt.primaryPort)
+			history, err := s.SendEmail(p, &SendEmailRequest{
+				SmtpConfigID: primaryID,
+				To:           []string{"bob@example.com"},
+				Subject:      "hello",
+				Body:         "<p>hi</p>",
+
+				fallbackSmtpConfigIDs: []uint{fallbackID},
+			})
+			if history == nil {
+				t.Fatalf("%s: 没有记录发送历史: %v", tt.name, err)
+			}
+			if (err == nil) != tt.wantFallback || (fallback.count() > before) != tt.wantFallback {
+				t.Errorf("%s: err=%v 备用配置收到 %d 封，want fallback=%v", tt.name, err, fallback.count()-before, tt.wantFallback)
+			}
+			wantConfig := primaryID
+			if tt.wantFallback {
+				wantConfig = fallbackID
+			}
+			if history.SmtpConfigID != wantConfig {
+				t.Errorf("%s: 发送历史记录配置 %d，want %d", tt.name, history.SmtpConfigID, wantConfig)
+			}
+		}
+
+		// 每次发送只记录一条发送历史
+		var histories int64
+		db.Model(&models.EmailHistory{}).Count(&histories)
+		if histories != int64(len(tests)) {
+			t.Errorf("发送历史 %d 条，want %d", histories, len(tests))
+		}
+	})
+}
@@ -6,14 +6,20 @@ import (
 	"smtp-mail/backend/database"
 	"smtp-mail/backend/models"
 	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
 )
 
 // HistoryService 历史服务
-type HistoryService struct{}
+type HistoryService struct {
+	auditService *AuditService
+}
 
 // NewHistoryService 创建历史服务实例
 func NewHistoryService() *HistoryService {
-	return &HistoryService{}
+	return &HistoryService{
+		auditService: NewAuditService(),
+	}
 }
 
 // HistoryListResponse 历史列表响应
@@ -29,10 +35,13 @@ type StatisticsResponse struct {
 	Total  int64 `json:"total"`
 	Success int64 `json:"success"`
 	Failed int64 `json:"failed"`
+	Tracked int64 `json:"tracked"` // 开启追踪的邮件数
+	Opened  int64 `json:"opened"`  // 开启追踪且至少被打开一次的邮件数
+	Clicked int64 `json:"clicked"` // 开启追踪且至少被点击一次的邮件数
 }
 
-// GetAllHistory 获取发送历史（支持分页和状态筛选）
-func (s *HistoryService) GetAllHistory(page, pageSize int, status string) (*HistoryListResponse, error) {
+// GetAllHistory 获取当前用户的发送历史（支持分页和状态筛选）
+func (s *HistoryService) GetAllHistory(p *Principal, page, pageSize int, status string) (*HistoryListResponse, error) {
 	db := database.GetDB()
 	
 	// 设置默认值
@@ -44,7 +53,7 @@ func (s *HistoryService) GetAllHistory(page, pageSize int, status string) (*Hist
 	}
 
 	// 构建查询
-	query := db.Model(&models.EmailHistory{})
+	query := p.ScopeHistory(db.Model(&models.EmailHistory{}))
 	
 	// 按状态筛选
 	if status != "" && status != "all" {
@@ -76,11 +85,11 @@ func (s *HistoryService) GetAllHistory(page, pageSize int, status string) (*Hist
 }
 
 // GetHistoryByID 获取单条历史记录
-func (s *HistoryService) GetHistoryByID(id uint) (*models.EmailHistory, error) {
+func (s *HistoryService) GetHistoryByID(p *Principal, id uint) (*models.EmailHistory, error) {
 	db := database.GetDB()
 	var history models.EmailHistory
 
-	if err := db.First(&history, id).Error; err != nil {
+	if err := p.ScopeHistory(db).Preload("Recipients").First(&history, id).Error; err != nil {
 		utils.Errorf("获取历史记录失败 (ID: %d): %v", id, err)
 		return nil, fmt.Errorf("获取历史记录失败: %w", err)
 	}
@@ -90,18 +99,31 @@ func (s *HistoryService) GetHistoryByID(id uint) (*models.EmailHistory, error) {
 }
 
 // DeleteHistory 删除历史记录
-func (s *HistoryService) DeleteHistory(id uint) error {
+func (s *HistoryService) DeleteHistory(p *Principal, id uint) error {
 	db := database.GetDB()
 
 	// 检查历史记录是否存在
 	var history models.EmailHistory
-	if err := db.First(&history, id).Error; err != nil {
+	if err := p.ScopeHistory(db).First(&history, id).Error; err != nil {
 		utils.Errorf("历史记录不存在 (ID: %d): %v", id, err)
 		return fmt.Errorf("历史记录不存在: %w", err)
 	}
 
 	// 删除历史记录
-	if err := db.Delete(&history).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&history).Error; err != nil {
+			return err
+		}
+		// 同时删除追踪的收件人和事件
+		if err := tx.Where("history_id = ?", id).Delete(&models.EmailRecipient{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("history_id = ?", id).Delete(&models.TrackingEvent{}).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityEmailHistory, id, &history, nil)
+	})
+	if err != nil {
 		utils.Errorf("删除历史记录失败 (ID: %d): %v", id, err)
 		return fmt.Errorf("删除历史记录失败: %w", err)
 	}
@@ -110,35 +132,55 @@ func (s *HistoryService) DeleteHistory(id uint) error {
 	return nil
 }
 
-// GetStatistics 获取统计信息（总数、成功数、失败数）
-func (s *HistoryService) GetStatistics() (*StatisticsResponse, error) {
+// GetStatistics 获取当前用户的统计信息（总数、成功数、失败数）
+func (s *HistoryService) GetStatistics(p *Principal) (*StatisticsResponse, error) {
 	db := database.GetDB()
 
 	// 获取总数
 	var total int64
-	if err := db.Model(&models.EmailHistory{}).Count(&total).Error; err != nil {
+	if err := p.ScopeHistory(db.Model(&models.EmailHistory{})).Count(&total).Error; err != nil {
 		utils.Errorf("获取历史记录总数失败: %v", err)
 		return nil, fmt.Errorf("获取历史记录总数失败: %w", err)
 	}
 
 	// 获取成功数
 	var success int64
-	if err := db.Model(&models.EmailHistory{}).Where("status = ?", models.EmailStatusSuccess).Count(&success).Error; err != nil {
+	if err := p.ScopeHistory(db.Model(&models.EmailHistory{})).Where("status = ?", models.EmailStatusSuccess).Count(&success).Error; err != nil {
 		utils.Errorf("获取成功记录数失败: %v", err)
 		return nil, fmt.Errorf("获取成功记录数失败: %w", err)
 	}
 
 	// 获取失败数
 	var failed int64
-	if err := db.Model(&models.EmailHistory{}).Where("status = ?", models.EmailStatusFailed).Count(&failed).Error; err != nil {
+	if err := p.ScopeHistory(db.Model(&models.EmailHistory{})).Where("status = ?", models.EmailStatusFailed).Count(&failed).Error; err != nil {
 		utils.Errorf("获取失败记录数失败: %v", err)
 		return nil, fmt.Errorf("获取失败记录数失败: %w", err)
 	}
 
+	// 追踪统计：开启追踪的邮件数，以及其中被打开、被点击的邮件数
+	var tracked, opened, clicked int64
+	trackedScope := func() *gorm.DB {
+		return p.ScopeHistory(db.Model(&models.EmailHistory{})).Where("tracked = ?", true)
+	}
+	if err := trackedScope().Count(&tracked).Error; err != nil {
+		utils.Errorf("获取追踪记录数失败: %v", err)
+		return nil, fmt.Errorf("获取追踪记录数失败: %w", err)
+	}
+	for eventType, count := range map[string]*int64{models.TrackingOpen: &opened, models.TrackingClick: &clicked} {
+		events := db.Model(&models.TrackingEvent{}).Select("history_id").Where("type = ?", eventType)
+		if err := trackedScope().Where("id IN (?)", events).Count(count).Error; err != nil {
+			utils.Errorf("获取追踪统计失败: %v", err)
+			return nil, fmt.Errorf("获取追踪统计失败: %w", err)
+		}
+	}
+
 	utils.Infof("获取统计信息成功: Total=%d, Success=%d, Failed=%d", total, success, failed)
 	return &StatisticsResponse{
 		Total:  total,
 		Success: success,
 		Failed: failed,
+		Tracked: tracked,
+		Opened:  opened,
+		Clicked: clicked,
 	}, nil
 }
\ No newline at end of file
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+)
+
+// seedHistory 在工作区1和2中写入发送历史和追踪事件，返回按写入顺序的历史ID
+func seedHistory(t *testing.T) []uint {
+	t.Helper()
+	db := database.GetDB()
+
+	for _, workspace := range []models.Workspace{{ID: 1, Name: "one", Slug: "one", DailyQuota: 3}, {ID: 2, Name: "two", Slug: "two"}} {
+		if err := db.Create(&workspace).Error; err != nil {
+			t.Fatalf("创建工作区失败: %v", err)
+		}
+	}
+	config := models.SMTPConfig{WorkspaceID: 1, Name: "smtp", Host: "localhost", Port: 25, FromEmail: "from@example.com"}
+	if err := db.Create(&config).Error; err != nil {
+		t.Fatalf("创建SMTP配置失败: %v", err)
+	}
+
+	now := time.Now()
+	lastMonth := time.Date(now.Year(), now.Month(), 1, 12, 0, 0, 0, now.Location()).AddDate(0, -1, 0)
+	rows := []struct {
+		workspaceID, userID uint
+		status              models.EmailStatus
+		tracked             bool
+		createdAt           time.Time
+	}{
+		{1, 1, models.EmailStatusSuccess, true, now.Add(-time.Minute)},
+		{1, 1, models.EmailStatusSuccess, true, now.Add(-2 * time.Minute)},
+		{1, 1, models.EmailStatusFailed, false, now.Add(-3 * time.Minute)},
+		{1, 2, models.EmailStatusSuccess, false, now.Add(-4 * time.Minute)},
+		{1, 2, models.EmailStatusCaptured, false, lastMonth},
+		{2, 1, models.EmailStatusSuccess, true, now},
+	}
+
+	var ids []uint
+	for _, row := range rows {
+		history := models.EmailHistory{
+			WorkspaceID:  row.workspaceID,
+			SmtpConfigID: config.ID,
+			UserID:       row.userID,
+			ToEmail:      "to@example.com",
+			Subject:      "subject",
+			Body:         "body",
+			Status:       row.status,
+			Tracked:      row.tracked,
+			CreatedAt:    row.createdAt,
+		}
+		if err := db.Create(&history).Error; err != nil {
+			t.Fatalf("创建发送历史失败: %v", err)
+		}
+		ids = append(ids, history.ID)
+	}
+
+	// 第一封被打开两次并被点击，第二封只被打开；工作区2的邮件也被打开
+	events := []models.TrackingEvent{
+		{WorkspaceID: 1, HistoryID: ids[0], Type: models.TrackingOpen},
+		{WorkspaceID: 1, HistoryID: ids[0], Type: models.TrackingOpen},
+		{WorkspaceID: 1, HistoryID: ids[0], Type: models.TrackingClick, URL: "https://example.com"},
+		{WorkspaceID: 1, HistoryID: ids[1], Type: models.TrackingOpen},
+		{WorkspaceID: 2, HistoryID: ids[5], Type: models.TrackingOpen},
+	}
+	if err := db.Create(&events).Error; err != nil {
+		t.Fatalf("创建追踪事件失败: %v", err)
+	}
+	return ids
+}
+
+func TestGetStatistics(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		seedHistory(t)
+		s := NewHistoryService()
+
+		tests := []struct {
+			name string
+			p    *Principal
+			want StatisticsResponse
+		}{
+			{"admin", &Principal{UserID: 1, Role: models.RoleAdmin, WorkspaceID: 1},
+				StatisticsResponse{Total: 5, Success: 3, Failed: 1, Tracked: 2, Opened: 2, Clicked: 1}},
+			{"sender", &Principal{UserID: 2, Role: models.RoleSender, WorkspaceID: 1},
+				StatisticsResponse{Total: 2, Success: 1}},
+			{"other workspace", &Principal{UserID: 1, Role: models.RoleSender, WorkspaceID: 2},
+				StatisticsResponse{Total: 1, Success: 1, Tracked: 1, Opened: 1}},
+		}
+		for _, tt := range tests {
+			got, err := s.GetStatistics(tt.p)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if *got != tt.want {
+				t.Errorf("%s: got %+v, want %+v", tt.name, *got, tt.want)
+			}
+		}
+	})
+}
+
+func TestGetAllHistory(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		ids := seedHistory(t)
+		s := NewHistoryService()
+		p := &Principal{UserID: 1, Role: models.RoleSender, WorkspaceID: 1}
+
+		result, err := s.GetAllHistory(p, 1, 2, "all")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Total != 3 || len(result.List) != 2 {
+			t.Fatalf("total=%d len=%d, want 3 and 2", result.Total, len(result.List))
+		}
+		// 按创建时间倒序
+		if result.List[0].ID != ids[0] || result.List[1].ID != ids[1] {
+			t.Errorf("第一页为 %d、%d，want %d、%d", result.List[0].ID, result.List[1].ID, ids[0], ids[1])
+		}
+
+		result, err = s.GetAllHistory(p, 2, 2, "all")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.List) != 1 || result.List[0].ID != ids[2] {
+			t.Errorf("第二页为 %+v，want [%d]", result.List, ids[2])
+		}
+
+		result, err = s.GetAllHistory(p, 1, 10, string(models.EmailStatusFailed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Total != 1 || result.List[0].Status != models.EmailStatusFailed {
+			t.Errorf("按状态筛选: %+v", result)
+		}
+	})
+}
+
+func TestWorkspaceUsage(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		seedHistory(t)
+		s := NewWorkspaceService()
+
+		// 上个月的记录不计入用量（测试恰好跨越零点时当日用量可能少一条）
+		usage, err := s.usage(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if usage.DailySent < 3 || usage.DailySent > 4 || usage.MonthlySent != 4 {
+			t.Errorf("got daily=%d monthly=%d, want daily 3-4 and monthly 4", usage.DailySent, usage.MonthlySent)
+		}
+		if err := s.CheckQuota(1); err != ErrQuotaExceeded {
+			t.Errorf("CheckQuota(1) = %v, want ErrQuotaExceeded", err)
+		}
+		if err := s.CheckQuota(2); err != nil {
+			t.Errorf("CheckQuota(2) = %v, want nil", err)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/utils"
+)
+
+// 加密密钥错误定义
+var (
+	ErrUnknownKeyID     = errors.New("未找到密文对应的加密密钥")
+	ErrDecryptFailed    = errors.New("解密失败，密钥不匹配或密文已损坏")
+	ErrInvalidMasterKey = errors.New("主密钥格式错误")
+	ErrNoMasterKey      = errors.New("未配置主密钥，请设置 master_key 或 master_key_file")
+	ErrLegacyKeySign    = errors.New("旧密钥不能用于签名，请配置主密钥")
+)
+
+// ciphertextVersion 带密钥ID的密文格式版本，格式为 v1:<密钥ID>:<base64(nonce+密文)>
+const ciphertextVersion = "v1"
+
+// legacyKeyID 由JWT密钥派生的旧密钥，用于解密升级前的无前缀密文，不用于签名
+const legacyKeyID = "legacy"
+
+// legacyDefaultSecret 升级前未配置JWT密钥时使用的默认值，仅用于解密当时保存的密文
+const legacyDefaultSecret = "default-secret-key-change-in-production"
+
+// Keyring 加密密钥环，包含当前密钥和用于解密旧密文的历史密钥
+type Keyring struct {
+	keys     map[string][]byte
+	activeID string
+}
+
+var (
+	keyringOnce sync.Once
+	keyring     *Keyring
+	keyringErr  error
+)
+
+// LoadKeyring 加载全局密钥环（只加载一次），启动时调用以尽早发现配置错误
+func LoadKeyring() (*Keyring, error) {
+	keyringOnce.Do(func() {
+		keyring, keyringErr = newKeyring(&config.GetConfig().Security)
+	})
+	return keyring, keyringErr
+}
+
+// newKeyring 根据安全配置构建密钥环
+// 主密钥来自 master_key（或环境变量 MASTER_KEY）和 master_key_file，每行一个 <密钥ID>:<base64密钥>
+// 当前密钥为 active_key_id 指定的密钥，未指定时为最后一个密钥
+// 没有主密钥时拒绝加载，除非显式设置 allow_legacy_key 使用旧密钥加密（此时仍不能签名）
+func newKeyring(cfg *config.SecurityConfig) (*Keyring, error) {
+	k := &Keyring{keys: map[string][]byte{}}
+
+	// 旧密钥始终可用于解密，保证升级前的密文可读
+	legacySecret := cfg.JWTSecret
+	if legacySecret == "" {
+		legacySecret = legacyDefaultSecret
+	}
+	legacy := sha256.Sum256([]byte(legacySecret))
+	k.keys[legacyKeyID] = legacy[:]
+
+	var entries []string
+	if cfg.MasterKeyFile != "" {
+		data, err := os.ReadFile(cfg.MasterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取主密钥文件失败: %w", err)
+		}
+		entries = append(entries, strings.Split(string(data), "\n")...)
+	}
+	entries = append(entries, strings.FieldsFunc(cfg.MasterKey, func(r rune) bool { return r == ',' || r == '\n' })...)
+
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" || id == legacyKeyID || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("%w: 每行格式应为 <密钥ID>:<base64密钥>", ErrInvalidMasterKey)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("%w: 密钥 %s 必须是base64编码的32字节", ErrInvalidMasterKey, id)
+		}
+		k.keys[id] = key
+		k.activeID = id
+	}
+
+	if cfg.ActiveKeyID != "" {
+		if _, ok := k.keys[cfg.ActiveKeyID]; !ok {
+			return nil, fmt.Errorf("%w: 未找到 active_key_id=%s", ErrInvalidMasterKey, cfg.ActiveKeyID)
+		}
+		k.activeID = cfg.ActiveKeyID
+	}
+	if k.activeID == "" {
+		k.activeID = legacyKeyID
+	}
+	if k.activeID == legacyKeyID {
+		if !cfg.AllowLegacyKey {
+			return nil, fmt.Errorf("%w（生成方法: echo \"k1:$(openssl rand -base64 32)\"），仅为兼容旧版本可设置 allow_legacy_key=true", ErrNoMasterKey)
+		}
+		if cfg.JWTSecret == "" {
+			return nil, fmt.Errorf("%w: allow_legacy_key 需要同时配置 jwt_secret", ErrNoMasterKey)
+		}
+		utils.Warnf("未配置主密钥，SMTP密码使用JWT密钥派生的旧密钥加密，修改JWT密钥将导致已保存的密码无法解密；退订链接和打开点击追踪需要主密钥，暂不可用")
+	}
+	return k, nil
+}
+
+// ActiveKeyID 当前用于加密的密钥ID
+func (k *Keyring) ActiveKeyID() string {
+	return k.activeID
+}
+
+// signingKey 获取用于签名的密钥，旧密钥可能是公开的默认值，不能用于签名和验证
+func (k *Keyring) signingKey(id string) ([]byte, error) {
+	if id == legacyKeyID {
+		return nil, ErrLegacyKeySign
+	}
+	return k.key(id)
+}
+
+// key 获取指定ID的密钥
+func (k *Keyring) key(id string) ([]byte, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKeyID, id)
+	}
+	return key, nil
+}
+
+// parseCiphertext 解析密文，返回密钥ID和base64部分；无前缀的旧密文使用旧密钥
+func parseCiphertext(ciphertext string) (keyID, payload string) {
+	parts := strings.SplitN(ciphertext, ":", 3)
+	if len(parts) == 3 && parts[0] == ciphertextVersion {
+		return parts[1], parts[2]
+	}
+	return legacyKeyID, ciphertext
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/models"
+)
+
+// mailboxMessage 从退信邮箱取回的一封邮件
+type mailboxMessage struct {
+	ID  string // IMAP为UID，POP3为UIDL
+	Raw []byte
+}
+
+// mailboxClient 退信邮箱客户端
+type mailboxClient interface {
+	// Fetch 取回未处理的邮件，最多limit封
+	Fetch(limit int) ([]mailboxMessage, error)
+	// Done 标记邮件已处理：IMAP设置\Seen标记，POP3删除邮件
+	Done(id string) error
+	Close() error
+}
+
+// dialMailbox 连接并登录退信邮箱
+func dialMailbox(mailbox *models.BounceMailbox, password string, timeout time.Duration) (mailboxClient, error) {
+	addr := net.JoinHostPort(mailbox.Host, strconv.Itoa(mailbox.Port))
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if mailbox.TLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: mailbox.Host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("连接退信邮箱失败: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	var client mailboxClient
+	switch mailbox.Protocol {
+	case models.MailboxPOP3:
+		client, err = newPOP3Client(conn, mailbox.Username, password)
+	default:
+		folder := mailbox.Folder
+		if folder == "" {
+			folder = "INBOX"
+		}
+		client, err = newIMAPClient(conn, mailbox.Username, password, folder)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// imapClient 最小的IMAP4rev1客户端，只实现读取退信需要的命令
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	seq    int
+}
+
+// imapResponse 一条未标记的响应，带有其中的字面量内容
+type imapResponse struct {
+	Text     string
+	Literals [][]byte
+}
+
+func newIMAPClient(conn net.Conn, username, password, folder string) (*imapClient, error) {
+	c := &imapClient{conn: conn, reader: bufio.NewReader(conn)}
+	greeting, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("读取IMAP问候失败: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return nil, fmt.Errorf("IMAP服务器拒绝连接: %s", strings.TrimSpace(greeting))
+	}
+	if !strings.HasPrefix(greeting, "* PREAUTH") {
+		if _, err := c.command("LOGIN %s %s", imapQuote(username), imapQuote(password)); err != nil {
+			return nil, fmt.Errorf("IMAP登录失败: %w", err)
+		}
+	}
+	if _, err := c.command("SELECT %s", imapQuote(folder)); err != nil {
+		return nil, fmt.Errorf("打开IMAP文件夹 %s 失败: %w", folder, err)
+	}
+	return c, nil
+}
+
+// command 发送命令并读取到对应的标记响应为止，返回期间的未标记响应
+func (c *imapClient) command(format string, args ...interface{}) ([]imapResponse, error) {
+	c.seq++
+	tag := fmt.Sprintf("A%03d", c.seq)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, fmt.Sprintf(format, args...)); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, tag+" ") {
+			status := strings.TrimPrefix(line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("%s", status)
+			}
+			return responses, nil
+		}
+		if !strings.HasPrefix(line, "* ") {
+			continue
+		}
+
+		// 行尾的 {n} 表示后面跟着n字节的字面量，之后继续读取该响应的剩余部分
+		response := imapResponse{Text: line[2:]}
+		for strings.HasSuffix(line, "}") {
+			open := strings.LastIndex(line, "{")
+			if open < 0 {
+				break
+			}
+			size, err := strconv.Atoi(strings.TrimSuffix(line[open+1:len(line)-1], "+"))
+			if err != nil {
+				break
+			}
+			literal := make([]byte, size)
+			if _, err := io.ReadFull(c.reader, literal); err != nil {
+				return nil, err
+			}
+			response.Literals = append(response.Literals, literal)
+			if line, err = c.reader.ReadString('\n'); err != nil {
+				return nil, err
+			}
+			line = strings.TrimRight(line, "\r\n")
+			response.Text += line
+		}
+		responses = append(responses, response)
+	}
+}
+
+// Fetch 取回未读邮件；使用BODY.PEEK[]读取，不会自动设置\Seen标记
+func (c *imapClient) Fetch(limit int) ([]mailboxMessage, error) {
+	responses, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, fmt.Errorf("搜索未读邮件失败: %w", err)
+	}
+	var uids []string
+	for _, response := range responses {
+		if strings.HasPrefix(response.Text, "SEARCH") {
+			uids = append(uids, strings.Fields(strings.TrimPrefix(response.Text, "SEARCH"))...)
+		}
+	}
+	if limit > 0 && len(uids) > limit {
+		uids = uids[:limit]
+	}
+
+	messages := make([]mailboxMessage, 0, len(uids))
+	for _, uid := range uids {
+		responses, err := c.command("UID FETCH %s BODY.PEEK[]", uid)
+		if err != nil {
+			return messages, fmt.Errorf("读取邮件 %s 失败: %w", uid, err)
+		}
+		for _, response := range responses {
+			if strings.Contains(response.Text, "FETCH") && len(response.Literals) > 0 {
+				messages = append(messages, mailboxMessage{ID: uid, Raw: response.Literals[0]})
+				break
+			}
+		}
+	}
+	return messages, nil
+}
+
+// Done 设置\Seen标记，下次轮询不再取回
+func (c *imapClient) Done(id string) error {
+	_, err := c.command("UID STORE %s +FLAGS.SILENT (\\Seen)", id)
+	return err
+}
+
+// Close 注销并关闭连接
+func (c *imapClient) Close() error {
+	c.command("LOGOUT")
+	return c.conn.Close()
+}
+
+// imapQuote 将字符串编码为IMAP带引号字符串
+func imapQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// pop3Client 最小的POP3客户端
+// POP3没有已读标记，处理过的邮件会被删除，因此应使用专用的退信邮箱
+type pop3Client struct {
+	conn    net.Conn
+	text    *textproto.Conn
+	numbers map[string]string // UIDL -> 邮件编号
+}
+
+func newPOP3Client(conn net.Conn, username, password string) (*pop3Client, error) {
+	c := &pop3Client{conn: conn, text: textproto.NewConn(conn), numbers: make(map[string]string)}
+	if _, err := c.response(); err != nil {
+		return nil, fmt.Errorf("POP3服务器拒绝连接: %w", err)
+	}
+	if _, err := c.command("USER %s", username); err != nil {
+		return nil, fmt.Errorf("POP3登录失败: %w", err)
+	}
+	if _, err := c.command("PASS %s", password); err != nil {
+		return nil, fmt.Errorf("POP3登录失败: %w", err)
+	}
+	return c, nil
+}
+
+// command 发送命令并读取单行响应
+func (c *pop3Client) command(format string, args ...interface{}) (string, error) {
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return "", err
+	}
+	return c.response()
+}
+
+// response 读取单行响应，-ERR时返回错误
+func (c *pop3Client) response() (string, error) {
+	line, err := c.text.ReadLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return "", fmt.Errorf("%s", line)
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+}
+
+// Fetch 取回邮箱中的邮件
+func (c *pop3Client) Fetch(limit int) ([]mailboxMessage, error) {
+	if _, err := c.command("UIDL"); err != nil {
+		return nil, fmt.Errorf("获取邮件列表失败: %w", err)
+	}
+	lines, err := c.text.ReadDotLines()
+	if err != nil {
+		return nil, fmt.Errorf("获取邮件列表失败: %w", err)
+	}
+
+	var messages []mailboxMessage
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if limit > 0 && len(messages) >= limit {
+			break
+		}
+		number, uid := fields[0], fields[1]
+		if _, err := c.command("RETR %s", number); err != nil {
+			return messages, fmt.Errorf("读取邮件 %s 失败: %w", uid, err)
+		}
+		raw, err := io.ReadAll(c.text.DotReader())
+		if err != nil {
+			return messages, fmt.Errorf("读取邮件 %s 失败: %w", uid, err)
+		}
+		c.numbers[uid] = number
+		messages = append(messages, mailboxMessage{ID: uid, Raw: raw})
+	}
+	return messages, nil
+}
+
+// Done 删除邮件，QUIT后生效
+func (c *pop3Client) Done(id string) error {
+	number, ok := c.numbers[id]
+	if !ok {
+		return fmt.Errorf("邮件 %s 不存在", id)
+	}
+	_, err := c.command("DELE %s", number)
+	return err
+}
+
+// Close 发送QUIT提交删除并关闭连接
+func (c *pop3Client) Close() error {
+	c.command("QUIT")
+	return c.text.Close()
+}
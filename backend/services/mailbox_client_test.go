@@ -0,0 +1,235 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"smtp-mail/backend/models"
+)
+
+// fakeMailbox 本地的IMAP/POP3邮箱替身，只实现退信处理使用的命令
+// IMAP按UID保存邮件和\Seen标记；POP3的DELE在QUIT后删除邮件
+type fakeMailbox struct {
+	listener net.Listener
+	username string
+	password string
+
+	mu       sync.Mutex
+	messages map[string][]byte // UID -> 邮件内容
+	seen     map[string]bool
+}
+
+func newFakeMailbox(t *testing.T, protocol string, messages map[string][]byte) *fakeMailbox {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	f := &fakeMailbox{listener: listener, username: "bounces", password: "secret", messages: messages, seen: map[string]bool{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if protocol == models.MailboxPOP3 {
+				go f.servePOP3(conn)
+			} else {
+				go f.serveIMAP(conn)
+			}
+		}
+	}()
+	return f
+}
+
+// mailbox 指向替身的退信邮箱配置
+func (f *fakeMailbox) mailbox(protocol string) *models.BounceMailbox {
+	addr := f.listener.Addr().(*net.TCPAddr)
+	return &models.BounceMailbox{Protocol: protocol, Host: "127.0.0.1", Port: addr.Port, Username: f.username, Enabled: true}
+}
+
+// uids 按顺序返回邮件的UID，unseen为true时只返回未读邮件
+func (f *fakeMailbox) uids(unseen bool) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var uids []string
+	for uid := range f.messages {
+		if !unseen || !f.seen[uid] {
+			uids = append(uids, uid)
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool {
+		a, _ := strconv.Atoi(uids[i])
+		b, _ := strconv.Atoi(uids[j])
+		return a < b
+	})
+	return uids
+}
+
+func (f *fakeMailbox) serveIMAP(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprintf(conn, "* OK fake IMAP ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, command, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		fields := strings.Fields(command)
+		if len(fields) == 0 {
+			fmt.Fprintf(conn, "%s BAD empty command\r\n", tag)
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "LOGIN":
+			if command != fmt.Sprintf("LOGIN %s %s", imapQuote(f.username), imapQuote(f.password)) {
+				fmt.Fprintf(conn, "%s NO authentication failed\r\n", tag)
+				continue
+			}
+		case "SELECT":
+			fmt.Fprintf(conn, "* %d EXISTS\r\n", len(f.uids(false)))
+		case "UID":
+			switch strings.ToUpper(fields[1]) {
+			case "SEARCH":
+				fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(f.uids(true), " "))
+			case "FETCH":
+				f.mu.Lock()
+				raw, ok := f.messages[fields[2]]
+				f.mu.Unlock()
+				if ok {
+					fmt.Fprintf(conn, "* 1 FETCH (UID %s BODY[] {%d}\r\n%s)\r\n", fields[2], len(raw), raw)
+				}
+			case "STORE":
+				f.mu.Lock()
+				f.seen[fields[2]] = true
+				f.mu.Unlock()
+			}
+		case "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT completed\r\n", tag)
+			return
+		}
+		fmt.Fprintf(conn, "%s OK completed\r\n", tag)
+	}
+}
+
+func (f *fakeMailbox) servePOP3(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	text.PrintfLine("+OK fake POP3 ready")
+	uids := f.uids(false)
+	deleted := map[string]bool{}
+	user := ""
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "USER":
+			user = arg
+			text.PrintfLine("+OK")
+		case "PASS":
+			if user != f.username || arg != f.password {
+				text.PrintfLine("-ERR authentication failed")
+				continue
+			}
+			text.PrintfLine("+OK logged in")
+		case "UIDL":
+			text.PrintfLine("+OK")
+			w := text.DotWriter()
+			for i, uid := range uids {
+				fmt.Fprintf(w, "%d %s\n", i+1, uid)
+			}
+			w.Close()
+		case "RETR", "DELE":
+			number, err := strconv.Atoi(arg)
+			if err != nil || number < 1 || number > len(uids) {
+				text.PrintfLine("-ERR no such message")
+				continue
+			}
+			uid := uids[number-1]
+			if command == "DELE" {
+				deleted[uid] = true
+				text.PrintfLine("+OK")
+				continue
+			}
+			f.mu.Lock()
+			raw := f.messages[uid]
+			f.mu.Unlock()
+			text.PrintfLine("+OK")
+			w := text.DotWriter()
+			w.Write(raw)
+			w.Close()
+		case "QUIT":
+			f.mu.Lock()
+			for uid := range deleted {
+				delete(f.messages, uid)
+			}
+			f.mu.Unlock()
+			text.PrintfLine("+OK bye")
+			return
+		default:
+			text.PrintfLine("-ERR unknown command")
+		}
+	}
+}
+
+func TestMailboxClients(t *testing.T) {
+	for _, protocol := range []string{models.MailboxIMAP, models.MailboxPOP3} {
+		t.Run(protocol, func(t *testing.T) {
+			fake := newFakeMailbox(t, protocol, map[string][]byte{
+				"1": []byte("Subject: first\r\n\r\nhello\r\n"),
+				"2": []byte("Subject: second\r\n\r\n.leading dot\r\n"),
+			})
+
+			if _, err := dialMailbox(fake.mailbox(protocol), "wrong", 5*time.Second); err == nil {
+				t.Error("密码错误时登录成功")
+			}
+
+			client, err := dialMailbox(fake.mailbox(protocol), fake.password, 5*time.Second)
+			if err != nil {
+				t.Fatalf("连接失败: %v", err)
+			}
+			messages, err := client.Fetch(10)
+			if err != nil {
+				t.Fatalf("取回邮件失败: %v", err)
+			}
+			if len(messages) != 2 || messages[0].ID != "1" || messages[1].ID != "2" {
+				t.Fatalf("取回 %+v", messages)
+			}
+			if !strings.Contains(string(messages[1].Raw), "\n.leading dot") {
+				t.Errorf("邮件内容不完整: %q", messages[1].Raw)
+			}
+			if err := client.Done("1"); err != nil {
+				t.Fatalf("标记已处理失败: %v", err)
+			}
+			client.Close()
+
+			// 已处理的邮件下次不再取回
+			client, err = dialMailbox(fake.mailbox(protocol), fake.password, 5*time.Second)
+			if err != nil {
+				t.Fatalf("连接失败: %v", err)
+			}
+			defer client.Close()
+			messages, err = client.Fetch(10)
+			if err != nil {
+				t.Fatalf("取回邮件失败: %v", err)
+			}
+			if len(messages) != 1 || messages[0].ID != "2" {
+				t.Errorf("第二次取回 %+v，want 只有邮件2", messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,298 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// MIMEPart 解析后的MIME部分（树形结构）
+type MIMEPart struct {
+	ContentType string      `json:"content_type"`
+	Charset     string      `json:"charset,omitempty"`
+	Encoding    string      `json:"encoding,omitempty"`    // Content-Transfer-Encoding
+	Disposition string      `json:"disposition,omitempty"` // inline、attachment
+	Filename    string      `json:"filename,omitempty"`
+	ContentID   string      `json:"content_id,omitempty"` // 不含尖括号
+	Size        int         `json:"size"`                 // 解码后的字节数
+	Parts       []*MIMEPart `json:"parts,omitempty"`
+
+	body []byte // 解码后的内容（multipart为空）
+}
+
+// Body 返回解码后的内容
+func (p *MIMEPart) Body() []byte {
+	return p.body
+}
+
+// IsAttachment 是否为附件（带文件名或声明为attachment的非multipart部分）
+func (p *MIMEPart) IsAttachment() bool {
+	return len(p.Parts) == 0 && !strings.HasPrefix(p.ContentType, "multipart/") &&
+		(p.Disposition == "attachment" || p.Filename != "")
+}
+
+// ParsedMessage 解析后的邮件
+type ParsedMessage struct {
+	Header      textproto.MIMEHeader `json:"headers"`
+	From        string               `json:"from"`
+	To          []string             `json:"to"`
+	Cc          []string             `json:"cc"`
+	Bcc         []string             `json:"bcc"`
+	Subject     string               `json:"subject"`
+	Root        *MIMEPart            `json:"mime"`
+	HTML        string               `json:"html"`
+	Text        string               `json:"text"`
+	Attachments []*MIMEPart          `json:"attachments"` // 按出现顺序，包括带Content-ID的内嵌资源
+}
+
+// ParseMessage 解析RFC 5322格式的原始邮件，生成MIME树并提取正文和附件
+// 正文取第一个非附件的text/html和text/plain部分
+func ParseMessage(r io.Reader) (*ParsedMessage, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("解析邮件失败: %w", err)
+	}
+
+	parsed := &ParsedMessage{Header: textproto.MIMEHeader(msg.Header)}
+	decoder := new(mime.WordDecoder)
+	if parsed.Subject, err = decoder.DecodeHeader(msg.Header.Get("Subject")); err != nil {
+		parsed.Subject = msg.Header.Get("Subject")
+	}
+	if from, err := headerAddresses(msg.Header, "From"); err == nil && len(from) > 0 {
+		parsed.From = from[0]
+	}
+	if parsed.To, err = headerAddresses(msg.Header, "To"); err != nil {
+		return nil, err
+	}
+	if parsed.Cc, err = headerAddresses(msg.Header, "Cc"); err != nil {
+		return nil, err
+	}
+	if parsed.Bcc, err = headerAddresses(msg.Header, "Bcc"); err != nil {
+		return nil, err
+	}
+
+	if parsed.Root, err = parsePart(textproto.MIMEHeader(msg.Header), msg.Body); err != nil {
+		return nil, fmt.Errorf("解析邮件正文失败: %w", err)
+	}
+	parsed.collect(parsed.Root)
+	return parsed, nil
+}
+
+// collect 遍历MIME树提取正文和附件
+func (m *ParsedMessage) collect(part *MIMEPart) {
+	if len(part.Parts) > 0 {
+		for _, child := range part.Parts {
+			m.collect(child)
+		}
+		return
+	}
+	if part.IsAttachment() || (part.ContentID != "" && !strings.HasPrefix(part.ContentType, "text/")) {
+		m.Attachments = append(m.Attachments, part)
+		return
+	}
+	switch part.ContentType {
+	case "text/html":
+		if m.HTML == "" {
+			m.HTML = string(part.body)
+		}
+	case "text/plain":
+		if m.Text == "" {
+			m.Text = string(part.body)
+		}
+	}
+}
+
+// parsePart 递归解析MIME部分
+func parsePart(header textproto.MIMEHeader, body io.Reader) (*MIMEPart, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	part := &MIMEPart{
+		ContentType: mediaType,
+		Charset:     params["charset"],
+		Encoding:    strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))),
+		ContentID:   strings.Trim(header.Get("Content-ID"), "<> "),
+	}
+	if disposition, dispParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		part.Disposition = disposition
+		part.Filename = dispParams["filename"]
+	}
+	if part.Filename == "" {
+		part.Filename = params["name"]
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			raw, err := reader.NextRawPart()
+			if err == io.EOF {
+				return part, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			child, err := parsePart(raw.Header, raw)
+			if err != nil {
+				return nil, err
+			}
+			part.Parts = append(part.Parts, child)
+			part.Size += child.Size
+		}
+	}
+
+	if part.body, err = io.ReadAll(decodeTransfer(part.Encoding, body)); err != nil {
+		return nil, err
+	}
+	part.Size = len(part.body)
+	return part, nil
+}
+
+// ErrSenderMismatch 邮件头的发件人与SMTP配置的发件邮箱不一致，无法原样转发
+var ErrSenderMismatch = errors.New("邮件头的发件人与SMTP配置的发件邮箱不一致")
+
+// relayDroppedHeaders 转发原始邮件时不保留的邮件头：由发送时重新生成，或转发后不再有效
+var relayDroppedHeaders = map[string]bool{
+	"From": true, "Sender": true, "To": true, "Cc": true, "Bcc": true, "Subject": true, "Date": true,
+	"Message-Id": true, "Mime-Version": true, "Content-Type": true, "Content-Transfer-Encoding": true,
+	"Content-Disposition": true, "Return-Path": true, "Received": true, "Dkim-Signature": true,
+	"List-Unsubscribe": true, "List-Unsubscribe-Post": true,
+}
+
+// ParseRawMessage 解析RFC 5322格式的原始邮件（如 .eml 文件）为发送请求
+// 收件人、抄送、密送和主题取自邮件头；text/html和text/plain部分分别作为HTML和纯文本正文；
+// 附件和内嵌资源作为附件。发件人（须与SMTP配置的发件邮箱一致）和其余自定义邮件头原样保留。
+// SmtpConfigID需由调用方设置
+func ParseRawMessage(r io.Reader) (*SendEmailRequest, error) {
+	parsed, err := ParseMessage(r)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &SendEmailRequest{
+		To:      parsed.To,
+		Cc:      parsed.Cc,
+		Bcc:     parsed.Bcc,
+		Subject: parsed.Subject,
+		Body:    parsed.HTML,
+		Text:    parsed.Text,
+	}
+	if from, err := mail.ParseAddress(parsed.Header.Get("From")); err == nil {
+		req.from = from
+	}
+	for key, values := range parsed.Header {
+		if !relayDroppedHeaders[key] {
+			if req.headers == nil {
+				req.headers = textproto.MIMEHeader{}
+			}
+			req.headers[key] = values
+		}
+	}
+	for _, part := range parsed.Attachments {
+		filename := part.Filename
+		if filename == "" {
+			filename = "attachment"
+		}
+		req.Attachments = append(req.Attachments, Attachment{
+			Filename:    filename,
+			Content:     base64.StdEncoding.EncodeToString(part.body),
+			ContentType: part.ContentType,
+		})
+	}
+	return req, nil
+}
+
+// headerAddresses 读取邮件头中的地址列表，只返回邮箱地址
+func headerAddresses(header mail.Header, key string) ([]string, error) {
+	list, err := header.AddressList(key)
+	if errors.Is(err, mail.ErrHeaderNotPresent) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 失败: %w", key, err)
+	}
+	addresses := make([]string, len(list))
+	for i, addr := range list {
+		addresses[i] = addr.Address
+	}
+	return addresses, nil
+}
+
+// TextToHTML 将纯文本正文转换为HTML（转义并保留换行）
+func TextToHTML(text string) string {
+	if text == "" {
+		return ""
+	}
+	escaped := html.EscapeString(strings.ReplaceAll(text, "\r\n", "\n"))
+	return strings.ReplaceAll(escaped, "\n", "<br>\n")
+}
+
+// decodeTransfer 按Content-Transfer-Encoding解码
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &base64LineReader{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// base64LineReader 去除base64内容中的换行和空白
+type base64LineReader struct {
+	r io.Reader
+}
+
+func (l *base64LineReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	clean := bytes.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+			return -1
+		}
+		return r
+	}, p[:n])
+	copy(p, clean)
+	return len(clean), err
+}
+
+// ApplyEnvelope 按信封收件人（SMTP RCPT TO）确定实际收件人
+// 邮件头中的收件人和抄送只保留信封中存在的地址，信封中其余的地址作为密送
+func ApplyEnvelope(req *SendEmailRequest, recipients []string) {
+	remaining := make(map[string]bool, len(recipients))
+	for _, rcpt := range recipients {
+		remaining[strings.ToLower(rcpt)] = true
+	}
+	keep := func(list []string) []string {
+		var kept []string
+		for _, addr := range list {
+			if remaining[strings.ToLower(addr)] {
+				kept = append(kept, addr)
+				delete(remaining, strings.ToLower(addr))
+			}
+		}
+		return kept
+	}
+	req.To = keep(req.To)
+	req.Cc = keep(req.Cc)
+
+	req.Bcc = nil
+	for _, rcpt := range recipients {
+		if remaining[strings.ToLower(rcpt)] {
+			req.Bcc = append(req.Bcc, rcpt)
+			delete(remaining, strings.ToLower(rcpt))
+		}
+	}
+	// 邮件头中没有任何信封收件人时，将密送作为收件人
+	if len(req.To) == 0 && len(req.Cc) == 0 {
+		req.To, req.Bcc = req.Bcc, nil
+	}
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"smtp-mail/backend/config"
+)
+
+var (
+	// ErrAttachmentInfected 附件被扫描引擎判定为恶意文件
+	ErrAttachmentInfected = errors.New("附件包含病毒")
+	// ErrScannerUnavailable 扫描引擎不可用或扫描出错
+	ErrScannerUnavailable = errors.New("附件扫描服务不可用")
+)
+
+// ScanVerdict 扫描结果
+type ScanVerdict struct {
+	Infected  bool
+	Signature string // 检出的病毒名称
+}
+
+// Scanner 附件扫描引擎，Scan读取完整的附件内容并返回结果
+// 实现需要可以并发使用
+type Scanner interface {
+	Name() string
+	Scan(ctx context.Context, r io.Reader) (*ScanVerdict, error)
+}
+
+// ScannerFactory 根据配置创建扫描引擎
+type ScannerFactory func(cfg config.ScannerConfig) (Scanner, error)
+
+var (
+	scannerMu        sync.RWMutex
+	scannerFactories = map[string]ScannerFactory{}
+)
+
+// RegisterScanner 注册扫描引擎，engine对应配置中的 scanner.engine
+func RegisterScanner(engine string, factory ScannerFactory) {
+	scannerMu.Lock()
+	defer scannerMu.Unlock()
+	scannerFactories[strings.ToLower(engine)] = factory
+}
+
+// NewScanner 根据配置创建扫描引擎，未启用扫描时返回nil
+func NewScanner(cfg config.ScannerConfig) (Scanner, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	scannerMu.RLock()
+	factory, ok := scannerFactories[strings.ToLower(cfg.Engine)]
+	scannerMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("未知的扫描引擎 %q，可用: %s", cfg.Engine, strings.Join(scannerEngines(), ", "))
+	}
+	return factory(cfg)
+}
+
+// scannerEngines 返回已注册的扫描引擎名称
+func scannerEngines() []string {
+	scannerMu.RLock()
+	defer scannerMu.RUnlock()
+	names := make([]string, 0, len(scannerFactories))
+	for name := range scannerFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// unavailableScanner 扫描引擎创建失败时使用，所有扫描都返回创建时的错误
+type unavailableScanner struct {
+	err error
+}
+
+func (s unavailableScanner) Name() string { return "unavailable" }
+
+func (s unavailableScanner) Scan(ctx context.Context, r io.Reader) (*ScanVerdict, error) {
+	return nil, s.err
+}
@@ -9,26 +9,30 @@ import (
 	"smtp-mail/backend/database"
 	"smtp-mail/backend/models"
 	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
 )
 
 // SMTPService SMTP服务
 type SMTPService struct {
 	cryptoService *CryptoService
+	auditService  *AuditService
 }
 
 // NewSMTPService 创建SMTP服务实例
 func NewSMTPService() *SMTPService {
 	return &SMTPService{
 		cryptoService: NewCryptoService(),
+		auditService:  NewAuditService(),
 	}
 }
 
-// GetAllConfigs 获取所有SMTP配置（不返回密码）
-func (s *SMTPService) GetAllConfigs() ([]models.SMTPConfig, error) {
+// GetAllConfigs 获取当前用户可见的SMTP配置（不返回密码）
+func (s *SMTPService) GetAllConfigs(p *Principal) ([]models.SMTPConfig, error) {
 	var configs []models.SMTPConfig
 	db := database.GetDB()
 
-	err := db.Find(&configs).Error
+	err := p.ScopeSMTPConfigs(db).Find(&configs).Error
 	if err != nil {
 		utils.Errorf("获取所有SMTP配置失败: %v", err)
 		return nil, err
@@ -44,11 +48,11 @@ func (s *SMTPService) GetAllConfigs() ([]models.SMTPConfig, error) {
 }
 
 // GetConfigByID 获取单个配置（不返回密码）
-func (s *SMTPService) GetConfigByID(id uint) (*models.SMTPConfig, error) {
+func (s *SMTPService) GetConfigByID(p *Principal, id uint) (*models.SMTPConfig, error) {
 	var config models.SMTPConfig
 	db := database.GetDB()
 
-	err := db.First(&config, id).Error
+	err := p.ScopeSMTPConfigs(db).First(&config, id).Error
 	if err != nil {
 		utils.Errorf("获取SMTP配置失败 (ID: %d): %v", id, err)
 		return nil, err
@@ -62,11 +66,11 @@ func (s *SMTPService) GetConfigByID(id uint) (*models.SMTPConfig, error) {
 }
 
 // GetConfigByIDWithPassword 获取单个配置（包含密码，用于内部使用）
-func (s *SMTPService) GetConfigByIDWithPassword(id uint) (*models.SMTPConfig, error) {
+func (s *SMTPService) GetConfigByIDWithPassword(p *Principal, id uint) (*models.SMTPConfig, error) {
 	var config models.SMTPConfig
 	db := database.GetDB()
 
-	err := db.First(&config, id).Error
+	err := p.ScopeSMTPConfigs(db).First(&config, id).Error
 	if err != nil {
 		utils.Errorf("获取SMTP配置失败 (ID: %d): %v", id, err)
 		return nil, err
@@ -76,8 +80,16 @@ func (s *SMTPService) GetConfigByIDWithPassword(id uint) (*models.SMTPConfig, er
 	return &config, nil
 }
 
-// CreateConfig 创建配置（密码加密）
-func (s *SMTPService) CreateConfig(config *models.SMTPConfig) error {
+// CreateConfig 创建配置（密码加密），创建者成为配置所有者
+func (s *SMTPService) CreateConfig(p *Principal, config *models.SMTPConfig) error {
+	config.ID = 0
+	config.OwnerID = p.UserID
+	config.WorkspaceID = p.WorkspaceID
+	// 默认配置对整个工作区生效，只有具备权限的用户才能设置
+	if !p.Can(PermSMTPSetDefault) {
+		config.IsDefault = false
+	}
+
 	// 加密密码（使用AES加密，SMTP认证需要可解密的密码）
 	if config.Password != "" {
 		encryptedPassword, err := s.cryptoService.EncryptPassword(config.Password)
@@ -90,7 +102,12 @@ func (s *SMTPService) CreateConfig(config *models.SMTPConfig) error {
 
 	db := database.GetDB()
 
-	err := db.Create(config).Error
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(config).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntitySMTPConfig, config.ID, nil, config)
+	})
 	if err != nil {
 		utils.Errorf("创建SMTP配置失败: %v", err)
 		return err
@@ -103,17 +120,29 @@ func (s *SMTPService) CreateConfig(config *models.SMTPConfig) error {
 	return nil
 }
 
-// UpdateConfig 更新配置
-func (s *SMTPService) UpdateConfig(id uint, config *models.SMTPConfig) error {
+// UpdateConfig 更新配置（仅所有者或管理员）
+func (s *SMTPService) UpdateConfig(p *Principal, id uint, config *models.SMTPConfig) error {
 	db := database.GetDB()
 
 	// 检查配置是否存在
 	var existingConfig models.SMTPConfig
-	if err := db.First(&existingConfig, id).Error; err != nil {
+	if err := p.ScopeSMTPConfigs(db).First(&existingConfig, id).Error; err != nil {
 		utils.Errorf("SMTP配置不存在 (ID: %d): %v", id, err)
 		return err
 	}
 
+	// 检查修改权限
+	if err := p.AuthorizeModify(existingConfig.OwnerID); err != nil {
+		utils.Warnf("用户 %d 无权修改SMTP配置 (ID: %d)", p.UserID, id)
+		return err
+	}
+	config.ID = existingConfig.ID
+	config.OwnerID = existingConfig.OwnerID
+	config.WorkspaceID = existingConfig.WorkspaceID
+	if !p.Can(PermSMTPSetDefault) {
+		config.IsDefault = existingConfig.IsDefault
+	}
+
 	// 如果提供了新密码，则加密
 	if config.Password != "" {
 		encryptedPassword, err := s.cryptoService.EncryptPassword(config.Password)
@@ -127,8 +156,22 @@ func (s *SMTPService) UpdateConfig(id uint, config *models.SMTPConfig) error {
 		config.Password = existingConfig.Password
 	}
 
-	// 更新配置
-	err := db.Model(&existingConfig).Updates(config).Error
+	// 更新配置（共享和捕获标志可能被取消，需要单独更新零值）
+	before := existingConfig
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&existingConfig).Updates(config).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&existingConfig).Updates(map[string]interface{}{"shared": config.Shared, "capture": config.Capture}).Error; err != nil {
+			return err
+		}
+
+		var after models.SMTPConfig
+		if err := tx.First(&after, id).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntitySMTPConfig, id, &before, &after)
+	})
 	if err != nil {
 		utils.Errorf("更新SMTP配置失败 (ID: %d): %v", id, err)
 		return err
@@ -141,19 +184,33 @@ func (s *SMTPService) UpdateConfig(id uint, config *models.SMTPConfig) error {
 	return nil
 }
 
-// DeleteConfig 删除配置
-func (s *SMTPService) DeleteConfig(id uint) error {
+// DeleteConfig 删除配置（仅所有者或管理员）
+func (s *SMTPService) DeleteConfig(p *Principal, id uint) error {
 	db := database.GetDB()
 
 	// 检查配置是否存在
 	var config models.SMTPConfig
-	if err := db.First(&config, id).Error; err != nil {
+	if err := p.ScopeSMTPConfigs(db).First(&config, id).Error; err != nil {
 		utils.Errorf("SMTP配置不存在 (ID: %d): %v", id, err)
 		return err
 	}
 
-	// 删除配置
-	err := db.Delete(&config).Error
+	// 检查删除权限
+	if err := p.AuthorizeModify(config.OwnerID); err != nil {
+		utils.Warnf("用户 %d 无权删除SMTP配置 (ID: %d)", p.UserID, id)
+		return err
+	}
+
+	// 删除配置及其退信邮箱
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("smtp_config_id = ?", id).Delete(&models.BounceMailbox{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&config).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntitySMTPConfig, id, &config, nil)
+	})
 	if err != nil {
 		utils.Errorf("删除SMTP配置失败 (ID: %d): %v", id, err)
 		return err
@@ -164,12 +221,16 @@ func (s *SMTPService) DeleteConfig(id uint) error {
 }
 
 // SetDefaultConfig 设置默认配置
-func (s *SMTPService) SetDefaultConfig(id uint) error {
+func (s *SMTPService) SetDefaultConfig(p *Principal, id uint) error {
+	if err := p.Authorize(PermSMTPSetDefault); err != nil {
+		return err
+	}
+
 	db := database.GetDB()
 
 	// 检查配置是否存在
 	var config models.SMTPConfig
-	if err := db.First(&config, id).Error; err != nil {
+	if err := p.ScopeWorkspace(db).First(&config, id).Error; err != nil {
 		utils.Errorf("SMTP配置不存在 (ID: %d): %v", id, err)
 		return err
 	}
@@ -177,20 +238,29 @@ func (s *SMTPService) SetDefaultConfig(id uint) error {
 	// 开始事务
 	tx := db.Begin()
 
-	// 将所有配置的IsDefault设置为false
-	if err := tx.Model(&models.SMTPConfig{}).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
+	// 将当前工作区所有配置的IsDefault设置为false
+	if err := p.ScopeWorkspace(tx.Model(&models.SMTPConfig{})).Where("is_default = ?", true).Update("is_default", false).Error; err != nil {
 		tx.Rollback()
 		utils.Errorf("重置默认配置失败: %v", err)
 		return err
 	}
 
 	// 将指定配置设置为默认
+	before := config
 	if err := tx.Model(&config).Update("is_default", true).Error; err != nil {
 		tx.Rollback()
 		utils.Errorf("设置默认配置失败 (ID: %d): %v", id, err)
 		return err
 	}
 
+	// 记录审计事件
+	after := before
+	after.IsDefault = true
+	if err := s.auditService.Record(tx, p, models.AuditActionSetDefault, models.AuditEntitySMTPConfig, id, &before, &after); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		utils.Errorf("提交事务失败: %v", err)
@@ -201,12 +271,12 @@ func (s *SMTPService) SetDefaultConfig(id uint) error {
 	return nil
 }
 
-// GetDefaultConfig 获取默认配置
-func (s *SMTPService) GetDefaultConfig() (*models.SMTPConfig, error) {
+// GetDefaultConfig 获取默认配置（仅当对当前用户可见时返回）
+func (s *SMTPService) GetDefaultConfig(p *Principal) (*models.SMTPConfig, error) {
 	var config models.SMTPConfig
 	db := database.GetDB()
 
-	err := db.Where("is_default = ?", true).First(&config).Error
+	err := p.ScopeSMTPConfigs(db).Where("is_default = ?", true).First(&config).Error
 	if err != nil {
 		utils.Errorf("获取默认SMTP配置失败: %v", err)
 		return nil, err
@@ -219,13 +289,24 @@ func (s *SMTPService) GetDefaultConfig() (*models.SMTPConfig, error) {
 	return &config, nil
 }
 
-// TestConnection 测试SMTP连接
-func (s *SMTPService) TestConnection(config *models.SMTPConfig) error {
-	// 解密密码
+// resolvePassword 获取测试使用的明文密码：优先使用请求中提供的密码，否则解密已保存的密码
+func (s *SMTPService) resolvePassword(config *models.SMTPConfig, override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
 	password, err := s.cryptoService.DecryptPassword(config.Password)
 	if err != nil {
-		utils.Errorf("解密密码失败: %v", err)
-		return fmt.Errorf("解密密码失败: %w", err)
+		utils.Errorf("解密密码失败 (ID: %d): %v", config.ID, err)
+		return "", fmt.Errorf("解密密码失败: %w", err)
+	}
+	return password, nil
+}
+
+// TestConnection 测试SMTP连接，config为包含加密密码的配置，password不为空时代替已保存的密码
+func (s *SMTPService) TestConnection(config *models.SMTPConfig, password string) error {
+	password, err := s.resolvePassword(config, password)
+	if err != nil {
+		return err
 	}
 
 	// 构建SMTP服务器地址
@@ -328,15 +409,8 @@ func testStartTLSConnection(addr string, auth smtp.Auth, host string) error {
 	return nil
 }
 
-// SendTestEmail 发送测试邮件
-func (s *SMTPService) SendTestEmail(config *models.SMTPConfig, toEmail string) error {
-	// 解密密码
-	password, err := s.cryptoService.DecryptPassword(config.Password)
-	if err != nil {
-		utils.Errorf("解密密码失败: %v", err)
-		return fmt.Errorf("解密密码失败: %w", err)
-	}
-
+// SendTestEmail 以指定用户身份发送测试邮件，password不为空时代替已保存的密码
+func (s *SMTPService) SendTestEmail(p *Principal, config *models.SMTPConfig, toEmail, password string) error {
 	// 构建SMTP服务器地址
 	addr := fmt.Sprintf("%s:%d", config.Host, config.Port)
 
@@ -352,6 +426,17 @@ func (s *SMTPService) SendTestEmail(config *models.SMTPConfig, toEmail string) e
 
 	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s", from, toEmail, subject, body)
 
+	// 捕获模式下测试邮件同样只保存不投递
+	if CaptureEnabled(config) {
+		_, err := NewCaptureService().save(config.WorkspaceID, p.UserID, config, 0, []string{toEmail}, subject, []byte(message))
+		return err
+	}
+
+	password, err := s.resolvePassword(config, password)
+	if err != nil {
+		return err
+	}
+
 	// 根据加密类型选择发送方式
 	var sendErr error
 	if config.Encryption == models.EncryptionTLS {
@@ -382,3 +467,131 @@ func sendStartTLS(addr, username, password, host, from string, to []string, msg
 	auth := smtp.PlainAuth("", username, password, host)
 	return smtp.SendMail(addr, auth, from, to, msg)
 }
+
+// KeyRotationResult 密钥轮换结果
+type KeyRotationResult struct {
+	ActiveKeyID string `json:"active_key_id"`
+	Total       int    `json:"total"`   // 加密保存的密码和密钥数量
+	Rotated     int    `json:"rotated"` // 重新加密的数量
+}
+
+// RotateKeys 使用当前主密钥重新加密所有SMTP配置的密码、Webhook的签名密钥和退信邮箱的密码
+// 任一密文无法解密时整体回滚，已使用当前密钥的密文保持不变
+func (s *SMTPService) RotateKeys() (*KeyRotationResult, error) {
+	keyring, err := LoadKeyring()
+	if err != nil {
+		return nil, err
+	}
+	result := &KeyRotationResult{ActiveKeyID: keyring.ActiveKeyID()}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var configs []models.SMTPConfig
+		if err := tx.Where("password <> ?", "").Find(&configs).Error; err != nil {
+			return err
+		}
+		result.Total += len(configs)
+
+		for _, config := range configs {
+			encrypted, err := s.reencrypt(config.Password)
+			if err != nil {
+				return fmt.Errorf("SMTP配置 %d: %w", config.ID, err)
+			}
+			if encrypted == "" {
+				continue
+			}
+
+			before := config
+			if err := tx.Model(&config).UpdateColumn("password", encrypted).Error; err != nil {
+				return err
+			}
+			after := before
+			after.Password = encrypted
+			// 审计事件归属配置所在的工作区
+			actor := &Principal{WorkspaceID: config.WorkspaceID}
+			if err := s.auditService.Record(tx, actor, models.AuditActionRotateKey, models.AuditEntitySMTPConfig, config.ID, &before, &after); err != nil {
+				return err
+			}
+			result.Rotated++
+		}
+
+		var webhooks []models.Webhook
+		if err := tx.Where("secret <> ?", "").Find(&webhooks).Error; err != nil {
+			return err
+		}
+		result.Total += len(webhooks)
+
+		for _, webhook := range webhooks {
+			encrypted, err := s.reencrypt(webhook.Secret)
+			if err != nil {
+				return fmt.Errorf("Webhook %d: %w", webhook.ID, err)
+			}
+			if encrypted == "" {
+				continue
+			}
+
+			before := webhook
+			if err := tx.Model(&webhook).UpdateColumn("secret", encrypted).Error; err != nil {
+				return err
+			}
+			after := before
+			after.Secret = encrypted
+			actor := &Principal{WorkspaceID: webhook.WorkspaceID}
+			if err := s.auditService.Record(tx, actor, models.AuditActionRotateKey, models.AuditEntityWebhook, webhook.ID, &before, &after); err != nil {
+				return err
+			}
+			result.Rotated++
+		}
+
+		var mailboxes []models.BounceMailbox
+		if err := tx.Where("password <> ?", "").Find(&mailboxes).Error; err != nil {
+			return err
+		}
+		result.Total += len(mailboxes)
+
+		for _, mailbox := range mailboxes {
+			encrypted, err := s.reencrypt(mailbox.Password)
+			if err != nil {
+				return fmt.Errorf("退信邮箱 %d: %w", mailbox.ID, err)
+			}
+			if encrypted == "" {
+				continue
+			}
+
+			before := mailbox
+			if err := tx.Model(&mailbox).UpdateColumn("password", encrypted).Error; err != nil {
+				return err
+			}
+			after := before
+			after.Password = encrypted
+			actor := &Principal{WorkspaceID: mailbox.WorkspaceID}
+			if err := s.auditService.Record(tx, actor, models.AuditActionRotateKey, models.AuditEntityBounceMailbox, mailbox.ID, &before, &after); err != nil {
+				return err
+			}
+			result.Rotated++
+		}
+		return nil
+	})
+	if err != nil {
+		utils.Errorf("轮换加密密钥失败: %v", err)
+		return nil, err
+	}
+
+	utils.Infof("轮换加密密钥完成: 当前密钥=%s, 共 %d 个, 重新加密 %d 个", result.ActiveKeyID, result.Total, result.Rotated)
+	return result, nil
+}
+
+// reencrypt 使用当前密钥重新加密密文，已使用当前密钥时返回空字符串
+func (s *SMTPService) reencrypt(ciphertext string) (string, error) {
+	if !s.cryptoService.NeedsRotation(ciphertext) {
+		return "", nil
+	}
+	plaintext, err := s.cryptoService.DecryptPassword(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("解密失败: %w", err)
+	}
+	encrypted, err := s.cryptoService.EncryptPassword(plaintext)
+	if err != nil {
+		return "", fmt.Errorf("加密失败: %w", err)
+	}
+	return encrypted, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+)
+
+const (
+	testKey1 = "k1:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	testKey2 = "k2:ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA="
+)
+
+// useTestKeyring 使用指定的主密钥替换全局密钥环，测试结束后恢复
+func useTestKeyring(t *testing.T, masterKey string) {
+	t.Helper()
+	keyringOnce.Do(func() {})
+	previous, previousErr := keyring, keyringErr
+	t.Cleanup(func() { keyring, keyringErr = previous, previousErr })
+
+	keyring, keyringErr = newKeyring(&config.SecurityConfig{MasterKey: masterKey})
+	if keyringErr != nil {
+		t.Fatalf("加载密钥环失败: %v", keyringErr)
+	}
+}
+
+func TestRotateKeys(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		useTestKeyring(t, testKey1)
+		crypto := NewCryptoService()
+		encrypt := func(plaintext string) string {
+			encrypted, err := crypto.EncryptPassword(plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return encrypted
+		}
+
+		db := database.GetDB()
+		smtpConfig := models.SMTPConfig{WorkspaceID: 1, Name: "smtp", Host: "localhost", Port: 25, FromEmail: "from@example.com",
+			Password: encrypt("smtp-password")}
+		if err := db.Create(&smtpConfig).Error; err != nil {
+			t.Fatal(err)
+		}
+		webhook := models.Webhook{WorkspaceID: 1, URL: "https://example.com/hook", Secret: encrypt("webhook-secret"), Enabled: true}
+		if err := db.Create(&webhook).Error; err != nil {
+			t.Fatal(err)
+		}
+		mailbox := models.BounceMailbox{WorkspaceID: 1, SmtpConfigID: smtpConfig.ID, Protocol: models.MailboxIMAP, Host: "localhost",
+			Port: 143, Password: encrypt("mailbox-password"), Enabled: true}
+		if err := db.Create(&mailbox).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		// 追加新密钥后轮换，所有密文改用k2加密且内容不变
+		useTestKeyring(t, testKey1+","+testKey2)
+		result, err := NewSMTPService().RotateKeys()
+		if err != nil {
+			t.Fatalf("轮换失败: %v", err)
+		}
+		if result.ActiveKeyID != "k2" || result.Total != 3 || result.Rotated != 3 {
+			t.Errorf("got %+v, want k2 3/3", result)
+		}
+
+		db.First(&smtpConfig, smtpConfig.ID)
+		db.First(&webhook, webhook.ID)
+		db.First(&mailbox, mailbox.ID)
+		crypto = NewCryptoService()
+		for ciphertext, want := range map[string]string{
+			smtpConfig.Password: "smtp-password",
+			webhook.Secret:      "webhook-secret",
+			mailbox.Password:    "mailbox-password",
+		} {
+			if !strings.HasPrefix(ciphertext, "v1:k2:") {
+				t.Errorf("密文 %s 没有使用k2", ciphertext)
+			}
+			if plaintext, err := crypto.DecryptPassword(ciphertext); err != nil || plaintext != want {
+				t.Errorf("解密得到 %q (%v)，want %q", plaintext, err, want)
+			}
+		}
+
+		var events int64
+		db.Model(&models.AuditEvent{}).Where("action = ?", models.AuditActionRotateKey).Count(&events)
+		if events != 3 {
+			t.Errorf("rotate_key审计事件 %d 条，want 3", events)
+		}
+
+		// 再次轮换时没有需要重新加密的密文
+		if result, err = NewSMTPService().RotateKeys(); err != nil || result.Rotated != 0 {
+			t.Errorf("第二次轮换 %+v (%v)，want 0", result, err)
+		}
+	})
+}
+
+func TestUpdateConfigKeepsWorkspace(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		useTestKeyring(t, testKey1)
+		s := NewSMTPService()
+		p := &Principal{UserID: 2, Role: models.RoleSender, WorkspaceID: 1}
+		smtpConfig := &models.SMTPConfig{Name: "smtp", Host: "localhost", Port: 25, FromEmail: "from@example.com"}
+		if err := s.CreateConfig(p, smtpConfig); err != nil {
+			t.Fatalf("创建配置失败: %v", err)
+		}
+
+		// 请求中的workspace_id不能把配置移到其他工作区
+		update := &models.SMTPConfig{WorkspaceID: 2, Name: "smtp", Host: "mail.example.com", Port: 587, FromEmail: "from@example.com"}
+		if err := s.UpdateConfig(p, smtpConfig.ID, update); err != nil {
+			t.Fatalf("更新配置失败: %v", err)
+		}
+		var stored models.SMTPConfig
+		database.GetDB().First(&stored, smtpConfig.ID)
+		if stored.WorkspaceID != 1 || stored.Host != "mail.example.com" {
+			t.Errorf("更新后 workspace_id=%d host=%s, want 1 mail.example.com", stored.WorkspaceID, stored.Host)
+		}
+	})
+}
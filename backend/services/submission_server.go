@@ -0,0 +1,604 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+)
+
+// maxAuthFailures 单个连接允许的认证失败次数，超过后断开连接
+const maxAuthFailures = 3
+
+// errMessageTooLarge 邮件超过大小限制
+var errMessageTooLarge = errors.New("邮件超过大小限制")
+
+// SubmissionServer 内置SMTP提交服务
+// 应用通过SMTP提交邮件（支持STARTTLS，使用API密钥或用户名密码认证），
+// 邮件解析后与HTTP发送接口走相同的流程：检查权限和配额、经上游SMTP配置发送、记录发送历史
+type SubmissionServer struct {
+	cfg              *config.SubmissionConfig
+	tlsConfig        *tls.Config
+	authService      *AuthService
+	apiKeyService    *APIKeyService
+	workspaceService *WorkspaceService
+	smtpService      *SMTPService
+	emailService     *EmailService
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewSubmissionServer 创建SMTP提交服务，配置了证书时加载证书以支持STARTTLS
+func NewSubmissionServer() (*SubmissionServer, error) {
+	cfg := &config.GetConfig().Submission
+	s := &SubmissionServer{
+		cfg:              cfg,
+		authService:      NewAuthService(),
+		apiKeyService:    NewAPIKeyService(),
+		workspaceService: NewWorkspaceService(),
+		smtpService:      NewSMTPService(),
+		emailService:     NewEmailService(),
+		conns:            map[net.Conn]struct{}{},
+	}
+
+	if cfg.TLSCert != "" || cfg.TLSKey != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCert, cfg.TLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("加载SMTP提交服务证书失败: %w", err)
+		}
+		s.tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	} else if !cfg.AllowInsecure {
+		utils.Warnf("SMTP提交服务未配置证书且不允许未加密认证，客户端将无法认证")
+	}
+	return s, nil
+}
+
+// ListenAndServe 监听配置的地址并处理连接，Close后返回nil
+func (s *SubmissionServer) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil {
+		return fmt.Errorf("SMTP提交服务监听失败: %w", err)
+	}
+	return s.Serve(listener)
+}
+
+// Serve 在指定的监听器上处理连接
+func (s *SubmissionServer) Serve(listener net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		listener.Close()
+		return nil
+	}
+	s.listener = listener
+	s.mu.Unlock()
+
+	utils.Infof("SMTP提交服务已启动: %s (STARTTLS=%v)", listener.Addr(), s.tlsConfig != nil)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			session := s.newSession(conn)
+			session.serve()
+			s.mu.Lock()
+			delete(s.conns, session.conn) // STARTTLS后为TLS连接
+			s.mu.Unlock()
+		}()
+	}
+}
+
+// Close 停止监听并断开所有连接，等待正在处理的会话结束
+func (s *SubmissionServer) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// submissionSession 单个SMTP连接的会话状态
+type submissionSession struct {
+	server       *SubmissionServer
+	conn         net.Conn
+	text         *textproto.Conn
+	remoteIP     string
+	helo         string
+	tls          bool
+	principal    *Principal
+	authFailures int
+	inMail       bool // 已收到 MAIL FROM
+	from         string
+	recipients   []string
+}
+
+// newSession 创建会话
+func (s *SubmissionServer) newSession(conn net.Conn) *submissionSession {
+	remoteIP, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+	return &submissionSession{
+		server:   s,
+		conn:     conn,
+		text:     textproto.NewConn(conn),
+		remoteIP: remoteIP,
+	}
+}
+
+// reply 发送单行响应
+func (c *submissionSession) reply(code int, format string, args ...interface{}) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.server.cfg.Timeout))
+	return c.text.PrintfLine("%d %s", code, fmt.Sprintf(format, args...))
+}
+
+// replyLines 发送多行响应
+func (c *submissionSession) replyLines(code int, lines []string) error {
+	c.conn.SetWriteDeadline(time.Now().Add(c.server.cfg.Timeout))
+	for i, line := range lines {
+		sep := "-"
+		if i == len(lines)-1 {
+			sep = " "
+		}
+		if err := c.text.PrintfLine("%d%s%s", code, sep, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readLine 读取一行命令
+func (c *submissionSession) readLine() (string, error) {
+	c.conn.SetReadDeadline(time.Now().Add(c.server.cfg.Timeout))
+	return c.text.ReadLine()
+}
+
+// serve 处理会话直到客户端退出或连接断开
+func (c *submissionSession) serve() {
+	defer c.text.Close()
+
+	if err := c.reply(220, "%s ESMTP smtp-mail submission ready", c.server.cfg.Hostname); err != nil {
+		return
+	}
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return
+		}
+		verb, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		var quit bool
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			err = c.handleHello(arg, true)
+		case "HELO":
+			err = c.handleHello(arg, false)
+		case "STARTTLS":
+			err = c.handleStartTLS()
+		case "AUTH":
+			err = c.handleAuth(arg)
+			quit = c.authFailures >= maxAuthFailures
+		case "MAIL":
+			err = c.handleMail(arg)
+		case "RCPT":
+			err = c.handleRcpt(arg)
+		case "DATA":
+			err = c.handleData()
+		case "RSET":
+			c.reset()
+			err = c.reply(250, "2.0.0 OK")
+		case "NOOP":
+			err = c.reply(250, "2.0.0 OK")
+		case "VRFY":
+			err = c.reply(252, "2.5.2 Cannot VRFY user")
+		case "QUIT":
+			c.reply(221, "2.0.0 Bye")
+			return
+		default:
+			err = c.reply(500, "5.5.2 Command not recognized")
+		}
+		if err != nil || quit {
+			if quit {
+				utils.Warnf("SMTP提交服务认证失败次数过多，断开连接: %s", c.remoteIP)
+				c.reply(421, "4.7.0 Too many authentication failures")
+			}
+			return
+		}
+	}
+}
+
+// reset 清除当前邮件事务
+func (c *submissionSession) reset() {
+	c.inMail = false
+	c.from = ""
+	c.recipients = nil
+}
+
+// authAllowed 是否允许在当前连接上认证（未加密连接需配置允许）
+func (c *submissionSession) authAllowed() bool {
+	return c.tls || c.server.cfg.AllowInsecure
+}
+
+// handleHello 处理 EHLO/HELO
+func (c *submissionSession) handleHello(domain string, extended bool) error {
+	if domain == "" {
+		return c.reply(501, "5.5.4 Domain required")
+	}
+	c.helo = domain
+	c.reset()
+	if !extended {
+		return c.reply(250, "%s", c.server.cfg.Hostname)
+	}
+
+	lines := []string{
+		c.server.cfg.Hostname,
+		"PIPELINING",
+		"8BITMIME",
+		"ENHANCEDSTATUSCODES",
+		fmt.Sprintf("SIZE %d", c.server.cfg.MaxMessageBytes),
+	}
+	if c.server.tlsConfig != nil && !c.tls {
+		lines = append(lines, "STARTTLS")
+	}
+	if c.authAllowed() && c.principal == nil {
+		lines = append(lines, "AUTH PLAIN LOGIN")
+	}
+	return c.replyLines(250, lines)
+}
+
+// handleStartTLS 升级为TLS连接，升级后客户端需重新发送EHLO
+func (c *submissionSession) handleStartTLS() error {
+	if c.server.tlsConfig == nil {
+		return c.reply(502, "5.5.1 STARTTLS not supported")
+	}
+	if c.tls {
+		return c.reply(503, "5.5.1 Already running in TLS")
+	}
+	if err := c.reply(220, "2.0.0 Ready to start TLS"); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Server(c.conn, c.server.tlsConfig)
+	tlsConn.SetDeadline(time.Now().Add(c.server.cfg.Timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		utils.Warnf("SMTP提交服务TLS握手失败 (%s): %v", c.remoteIP, err)
+		return err
+	}
+
+	// 替换连接并清除握手前的会话状态
+	c.server.mu.Lock()
+	delete(c.server.conns, c.conn)
+	c.server.conns[tlsConn] = struct{}{}
+	c.server.mu.Unlock()
+	c.conn = tlsConn
+	c.text = textproto.NewConn(tlsConn)
+	c.tls = true
+	c.helo = ""
+	c.reset()
+	return nil
+}
+
+// handleAuth 处理 AUTH PLAIN / AUTH LOGIN
+// 密码为API密钥时按API密钥认证（用户名任意），否则按用户名和密码认证
+func (c *submissionSession) handleAuth(arg string) error {
+	if c.helo == "" {
+		return c.reply(503, "5.5.1 Send EHLO first")
+	}
+	if c.principal != nil {
+		return c.reply(503, "5.5.1 Already authenticated")
+	}
+	if !c.authAllowed() {
+		return c.reply(538, "5.7.11 Encryption required for requested authentication mechanism")
+	}
+
+	mechanism, initial := arg, ""
+	if i := strings.IndexByte(arg, ' '); i >= 0 {
+		mechanism, initial = arg[:i], strings.TrimSpace(arg[i+1:])
+	}
+
+	var username, password string
+	switch strings.ToUpper(mechanism) {
+	case "PLAIN":
+		response, err := c.authResponse(initial, "")
+		if err != nil {
+			return c.authError(err)
+		}
+		// authzid \0 authcid \0 passwd
+		parts := strings.Split(string(response), "\x00")
+		if len(parts) != 3 {
+			return c.reply(501, "5.5.2 Invalid PLAIN response")
+		}
+		username, password = parts[1], parts[2]
+	case "LOGIN":
+		user, err := c.authResponse(initial, "VXNlcm5hbWU6") // "Username:"
+		if err != nil {
+			return c.authError(err)
+		}
+		pass, err := c.authResponse("", "UGFzc3dvcmQ6") // "Password:"
+		if err != nil {
+			return c.authError(err)
+		}
+		username, password = string(user), string(pass)
+	default:
+		return c.reply(504, "5.5.4 Unrecognized authentication mechanism")
+	}
+
+	p, err := c.server.authenticate(username, password, c.remoteIP)
+	if err != nil {
+		c.authFailures++
+		utils.Warnf("SMTP提交服务认证失败 (%s, 用户=%s): %v", c.remoteIP, username, err)
+		return c.reply(535, "5.7.8 Authentication credentials invalid")
+	}
+	c.principal = p
+	utils.Infof("SMTP提交服务认证成功: %s (用户=%s, API密钥=%d, 工作区=%d)", c.remoteIP, p.Username, p.APIKeyID, p.WorkspaceID)
+	return c.reply(235, "2.7.0 Authentication successful")
+}
+
+// errAuthCancelled 客户端取消认证
+var errAuthCancelled = errors.New("认证已取消")
+
+// authResponse 读取认证响应：有初始响应时直接使用，否则发送334质询后读取
+func (c *submissionSession) authResponse(initial, challenge string) ([]byte, error) {
+	if initial == "" {
+		if err := c.reply(334, "%s", challenge); err != nil {
+			return nil, err
+		}
+		line, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		initial = line
+	}
+	if initial == "*" {
+		return nil, errAuthCancelled
+	}
+	if initial == "=" {
+		return []byte{}, nil
+	}
+	return base64.StdEncoding.DecodeString(initial)
+}
+
+// authError 认证过程中的错误响应
+func (c *submissionSession) authError(err error) error {
+	var base64Err base64.CorruptInputError
+	switch {
+	case errors.Is(err, errAuthCancelled):
+		return c.reply(501, "5.0.0 Authentication cancelled")
+	case errors.As(err, &base64Err):
+		return c.reply(501, "5.5.2 Invalid base64 data")
+	}
+	return err
+}
+
+// authenticate 校验凭据并确定工作区，要求拥有发送邮件权限
+func (s *SubmissionServer) authenticate(username, password, remoteIP string) (*Principal, error) {
+	var p *Principal
+	var err error
+	if IsAPIKey(password) {
+		p, err = s.apiKeyService.Authenticate(password, remoteIP)
+	} else {
+		p, err = s.authService.AuthenticatePassword(username, password)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.workspaceService.Resolve(p, ""); err != nil {
+		return nil, err
+	}
+	if err := p.Authorize(PermEmailSend); err != nil {
+		return nil, err
+	}
+	p.ClientIP = remoteIP
+	return p, nil
+}
+
+// parsePath 解析 MAIL FROM:<addr> / RCPT TO:<addr>，返回地址和之后的参数
+func parsePath(arg, prefix string) (string, []string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", nil, false
+	}
+	rest := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(rest, "<") {
+		return "", nil, false
+	}
+	end := strings.IndexByte(rest, '>')
+	if end < 0 {
+		return "", nil, false
+	}
+	return rest[1:end], strings.Fields(rest[end+1:]), true
+}
+
+// handleMail 处理 MAIL FROM
+func (c *submissionSession) handleMail(arg string) error {
+	if c.principal == nil {
+		return c.reply(530, "5.7.0 Authentication required")
+	}
+	if c.inMail {
+		return c.reply(503, "5.5.1 Sender already specified")
+	}
+	from, params, ok := parsePath(arg, "FROM:")
+	if !ok {
+		return c.reply(501, "5.5.4 Syntax: MAIL FROM:<address>")
+	}
+	for _, param := range params {
+		if key, value, found := strings.Cut(param, "="); found && strings.EqualFold(key, "SIZE") {
+			size, err := strconv.ParseInt(value, 10, 64)
+			if err == nil && size > c.server.cfg.MaxMessageBytes {
+				return c.reply(552, "5.3.4 Message size exceeds fixed limit")
+			}
+		}
+	}
+	c.inMail = true
+	c.from = from
+	return c.reply(250, "2.1.0 OK")
+}
+
+// handleRcpt 处理 RCPT TO
+func (c *submissionSession) handleRcpt(arg string) error {
+	if !c.inMail {
+		return c.reply(503, "5.5.1 Need MAIL command")
+	}
+	if len(c.recipients) >= c.server.cfg.MaxRecipients {
+		return c.reply(452, "4.5.3 Too many recipients")
+	}
+	rcpt, _, ok := parsePath(arg, "TO:")
+	if !ok {
+		return c.reply(501, "5.5.4 Syntax: RCPT TO:<address>")
+	}
+	if _, err := mail.ParseAddress(rcpt); err != nil {
+		return c.reply(553, "5.1.3 Invalid recipient address")
+	}
+	c.recipients = append(c.recipients, rcpt)
+	return c.reply(250, "2.1.5 OK")
+}
+
+// handleData 接收邮件内容并发送
+func (c *submissionSession) handleData() error {
+	if !c.inMail || len(c.recipients) == 0 {
+		return c.reply(503, "5.5.1 Need RCPT command")
+	}
+	if err := c.reply(354, "Start mail input; end with <CRLF>.<CRLF>"); err != nil {
+		return err
+	}
+
+	c.conn.SetReadDeadline(time.Now().Add(c.server.cfg.Timeout))
+	data, err := readLimited(c.text.DotReader(), c.server.cfg.MaxMessageBytes)
+	from, recipients := c.from, c.recipients
+	c.reset()
+	if errors.Is(err, errMessageTooLarge) {
+		return c.reply(552, "5.3.4 Message size exceeds fixed limit")
+	}
+	if err != nil {
+		return err
+	}
+
+	history, err := c.server.deliver(c.principal, from, recipients, data)
+	if err != nil {
+		utils.Errorf("SMTP提交服务发送失败 (%s): %v", c.remoteIP, err)
+		switch {
+		case errors.Is(err, ErrQuotaExceeded):
+			return c.reply(452, "4.3.1 Workspace sending quota exceeded")
+		case errors.Is(err, ErrForbidden):
+			return c.reply(550, "5.7.1 Not allowed to use this SMTP configuration")
+		case errors.Is(err, ErrAttachmentInfected):
+			return c.reply(554, "5.7.0 Message rejected: attachment contains a virus (history %d)", history.ID)
+		case errors.Is(err, ErrScannerUnavailable):
+			return c.reply(451, "4.7.0 Attachment scanning unavailable, try again later")
+		case errors.Is(err, ErrSenderMismatch):
+			return c.reply(550, "5.7.1 From header does not match the sender address of the SMTP configuration")
+		case errors.Is(err, ErrAllRecipientsSuppressed):
+			return c.reply(550, "5.1.1 All recipients are on the suppression list")
+		case history != nil:
+			// 上游SMTP发送失败，已记录失败历史
+			return c.reply(451, "4.4.0 Upstream delivery failed (history %d)", history.ID)
+		}
+		return c.reply(554, "5.6.0 Message rejected: %s", asciiOnly(err.Error()))
+	}
+	return c.reply(250, "2.0.0 OK: sent as history %d", history.ID)
+}
+
+// readLimited 读取最多limit字节，超出时读完剩余内容后返回errMessageTooLarge
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		io.Copy(io.Discard, r)
+		return nil, errMessageTooLarge
+	}
+	return data, nil
+}
+
+// asciiOnly 将响应中的非ASCII字符替换为问号（SMTP响应只允许ASCII）
+func asciiOnly(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r > 126 || r < 32 {
+			return '?'
+		}
+		return r
+	}, s)
+}
+
+// deliver 解析邮件并通过上游SMTP配置发送，上游发送失败时同时返回失败的历史记录
+func (s *SubmissionServer) deliver(p *Principal, from string, recipients []string, data []byte) (*models.EmailHistory, error) {
+	req, err := ParseRawMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	ApplyEnvelope(req, recipients)
+	if req.Subject == "" {
+		req.Subject = "(无主题)"
+	}
+
+	if req.SmtpConfigID, err = s.routeConfig(p, from); err != nil {
+		return nil, err
+	}
+	return s.emailService.SendEmail(p, req)
+}
+
+// routeConfig 选择上游SMTP配置：配置文件指定的配置、发件人邮箱与 MAIL FROM 相同的配置或工作区默认配置
+func (s *SubmissionServer) routeConfig(p *Principal, from string) (uint, error) {
+	if s.cfg.SMTPConfigID != 0 {
+		return s.cfg.SMTPConfigID, nil
+	}
+	configs, err := s.smtpService.GetAllConfigs(p)
+	if err != nil {
+		return 0, err
+	}
+	for _, config := range configs {
+		if strings.EqualFold(config.FromEmail, from) && p.CanUseSMTPConfig(config.ID) {
+			return config.ID, nil
+		}
+	}
+	config, err := s.smtpService.GetDefaultConfig(p)
+	if err != nil {
+		return 0, fmt.Errorf("没有可用的SMTP配置: %w", err)
+	}
+	return config.ID, nil
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrAllRecipientsSuppressed 所有收件人都在抑制列表中，邮件不会发送
+var ErrAllRecipientsSuppressed = errors.New("所有收件人都在抑制列表中")
+
+// SuppressionService 抑制列表服务：发送时跳过硬退信、被上游拒绝或手动屏蔽的地址和域名
+type SuppressionService struct {
+	auditService *AuditService
+}
+
+// NewSuppressionService 创建抑制列表服务实例
+func NewSuppressionService() *SuppressionService {
+	return &SuppressionService{
+		auditService: NewAuditService(),
+	}
+}
+
+// SuppressionRequest 添加抑制条目请求
+type SuppressionRequest struct {
+	Address   string     `json:"address" binding:"required"` // 邮箱地址或域名
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"` // 为空表示永久
+}
+
+// SuppressionListResponse 抑制列表响应
+type SuppressionListResponse struct {
+	List     []models.Suppression `json:"list"`
+	Total    int64                `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"pageSize"`
+}
+
+// normalizeSuppressionAddress 规范化抑制条目：邮箱地址或域名，统一为小写，域名去掉开头的@
+func normalizeSuppressionAddress(address string) (string, error) {
+	address = strings.ToLower(strings.TrimSpace(address))
+	address = strings.TrimPrefix(address, "@")
+	if address == "" {
+		return "", errors.New("地址不能为空")
+	}
+	if strings.Contains(address, "@") {
+		parsed, err := mail.ParseAddress(address)
+		if err != nil || parsed.Address != address {
+			return "", fmt.Errorf("无效的邮箱地址: %s", address)
+		}
+		return address, nil
+	}
+	if !strings.Contains(address, ".") || strings.ContainsAny(address, " \t,;<>") {
+		return "", fmt.Errorf("无效的域名: %s", address)
+	}
+	return address, nil
+}
+
+// suppressionKey 收件人地址的匹配键：去掉显示名称并转为小写，如 "Bob <Bob@X.com>" 为 bob@x.com
+// 无法解析的地址按原样转为小写
+func suppressionKey(address string) string {
+	if parsed, err := mail.ParseAddress(address); err == nil {
+		return strings.ToLower(parsed.Address)
+	}
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// scopeActive 只保留未过期的条目
+func scopeActive(db *gorm.DB) *gorm.DB {
+	return db.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+// ListSuppressions 获取当前工作区的抑制列表，q按地址匹配，source按来源筛选
+func (s *SuppressionService) ListSuppressions(p *Principal, page, pageSize int, q, source string) (*SuppressionListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	db := p.ScopeWorkspace(database.GetDB().Model(&models.Suppression{}))
+	if q != "" {
+		db = db.Where("address LIKE ?", "%"+strings.ToLower(q)+"%")
+	}
+	if source != "" {
+		db = db.Where("source = ?", source)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("获取抑制列表总数失败: %w", err)
+	}
+	var suppressions []models.Suppression
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&suppressions).Error; err != nil {
+		utils.Errorf("获取抑制列表失败: %v", err)
+		return nil, fmt.Errorf("获取抑制列表失败: %w", err)
+	}
+
+	return &SuppressionListResponse{
+		List:     suppressions,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// CreateSuppression 手动添加抑制条目，地址已存在时更新原因和过期时间
+func (s *SuppressionService) CreateSuppression(p *Principal, req *SuppressionRequest) (*models.Suppression, error) {
+	address, err := normalizeSuppressionAddress(req.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	var suppression models.Suppression
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("workspace_id = ? AND address = ?", p.WorkspaceID, address).First(&suppression).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			suppression = models.Suppression{
+				WorkspaceID: p.WorkspaceID,
+				Address:     address,
+				Reason:      req.Reason,
+				Source:      models.SuppressionSourceManual,
+				ExpiresAt:   req.ExpiresAt,
+				CreatedBy:   p.UserID,
+			}
+			if err := tx.Create(&suppression).Error; err != nil {
+				return err
+			}
+			return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntitySuppression, suppression.ID, nil, &suppression)
+		}
+		if err != nil {
+			return err
+		}
+
+		before := suppression
+		if err := tx.Model(&suppression).Updates(map[string]interface{}{
+			"reason":     req.Reason,
+			"source":     models.SuppressionSourceManual,
+			"expires_at": req.ExpiresAt,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&suppression, suppression.ID).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntitySuppression, suppression.ID, &before, &suppression)
+	})
+	if err != nil {
+		utils.Errorf("添加抑制条目失败 (%s): %v", address, err)
+		return nil, fmt.Errorf("添加抑制条目失败: %w", err)
+	}
+
+	utils.Infof("添加抑制条目: WorkspaceID=%d, Address=%s", p.WorkspaceID, address)
+	return &suppression, nil
+}
+
+// DeleteSuppression 删除抑制条目，之后可以再次向该地址发送
+func (s *SuppressionService) DeleteSuppression(p *Principal, id uint) error {
+	var suppression models.Suppression
+	if err := p.ScopeWorkspace(database.GetDB()).First(&suppression, id).Error; err != nil {
+		return fmt.Errorf("抑制条目不存在: %w", err)
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&suppression).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntitySuppression, id, &suppression, nil)
+	})
+	if err != nil {
+		utils.Errorf("删除抑制条目失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("删除抑制条目失败: %w", err)
+	}
+	return nil
+}
+
+// Suppressed 返回addresses中被抑制的地址（保持原样），按完整地址或域名匹配未过期的条目
+// 地址可以带显示名称，如 "Bob <bob@example.com>"
+func (s *SuppressionService) Suppressed(workspaceID uint, addresses []string) ([]string, error) {
+	if len(addresses) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, 0, len(addresses)*2)
+	for _, address := range addresses {
+		address = suppressionKey(address)
+		keys = append(keys, address)
+		if i := strings.LastIndex(address, "@"); i >= 0 {
+			keys = append(keys, address[i+1:])
+		}
+	}
+
+	var entries []models.Suppression
+	err := scopeActive(database.GetDB().Where("workspace_id = ? AND address IN ?", workspaceID, keys)).
+		Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询抑制列表失败: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	blocked := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		blocked[entry.Address] = true
+	}
+	var suppressed []string
+	for _, address := range addresses {
+		lower := suppressionKey(address)
+		domain := lower
+		if i := strings.LastIndex(lower, "@"); i >= 0 {
+			domain = lower[i+1:]
+		}
+		if blocked[lower] || blocked[domain] {
+			suppressed = append(suppressed, address)
+		}
+	}
+	return suppressed, nil
+}
+
+// Add 自动添加抑制条目（硬退信、上游永久拒绝），地址已存在时保留原条目
+func (s *SuppressionService) Add(workspaceID uint, address, source, reason string) {
+	address, err := normalizeSuppressionAddress(address)
+	if err != nil {
+		utils.Warnf("忽略无效的抑制地址: %v", err)
+		return
+	}
+	db := database.GetDB()
+	// 已过期的条目由新条目替换
+	db.Where("workspace_id = ? AND address = ? AND expires_at <= ?", workspaceID, address, time.Now()).
+		Delete(&models.Suppression{})
+
+	suppression := models.Suppression{
+		WorkspaceID: workspaceID,
+		Address:     address,
+		Reason:      reason,
+		Source:      source,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&suppression)
+	if result.Error != nil {
+		utils.Errorf("添加抑制条目失败 (%s): %v", address, result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		utils.Infof("自动添加抑制条目: WorkspaceID=%d, Address=%s, Source=%s", workspaceID, address, source)
+	}
+}
@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"smtp-mail/backend/models"
+)
+
+func TestSuppressedDisplayNames(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		s := NewSuppressionService()
+		s.Add(1, "bob@example.com", models.SuppressionSourceBounce, "550 5.1.1")
+		s.Add(1, "Blocked.Example", models.SuppressionSourceBounce, "")
+
+		addresses := []string{"Bob <Bob@Example.com>", "\"Carol\" <carol@blocked.example>", "alice@example.com", "bob@example.com"}
+		suppressed, err := s.Suppressed(1, addresses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"Bob <Bob@Example.com>", "\"Carol\" <carol@blocked.example>", "bob@example.com"}
+		if !reflect.DeepEqual(suppressed, want) {
+			t.Errorf("Suppressed = %q, want %q", suppressed, want)
+		}
+		if suppressed, _ := s.Suppressed(2, addresses); len(suppressed) != 0 {
+			t.Errorf("其他工作区的抑制条目生效: %q", suppressed)
+		}
+
+		// 发送时跳过带显示名称的被抑制收件人
+		email := &EmailService{suppressionService: s}
+		p := &Principal{UserID: 1, Role: models.RoleSender, WorkspaceID: 1}
+		req := &SendEmailRequest{To: []string{"Bob <bob@example.com>", "Alice <alice@example.com>"}, Cc: []string{"BOB@example.com"}}
+		if err := email.applySuppressions(p, req); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(req.To, []string{"Alice <alice@example.com>"}) || len(req.Cc) != 0 {
+			t.Errorf("过滤后 To=%q Cc=%q", req.To, req.Cc)
+		}
+
+		req = &SendEmailRequest{To: []string{"Bob <bob@example.com>"}}
+		if err := email.applySuppressions(p, req); !errors.Is(err, ErrAllRecipientsSuppressed) {
+			t.Errorf("全部被抑制时返回 %v", err)
+		}
+	})
+}
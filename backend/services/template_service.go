@@ -3,26 +3,33 @@ package services
 import (
 	"errors"
 	"fmt"
+	"unicode/utf8"
 
 	"smtp-mail/backend/database"
 	"smtp-mail/backend/models"
 	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
 )
 
 // TemplateService 模板服务
-type TemplateService struct{}
+type TemplateService struct {
+	auditService *AuditService
+}
 
 // NewTemplateService 创建模板服务实例
 func NewTemplateService() *TemplateService {
-	return &TemplateService{}
+	return &TemplateService{
+		auditService: NewAuditService(),
+	}
 }
 
-// GetAllTemplates 获取所有邮件模板
-func (s *TemplateService) GetAllTemplates() ([]models.EmailTemplate, error) {
+// GetAllTemplates 获取当前用户可见的邮件模板
+func (s *TemplateService) GetAllTemplates(p *Principal) ([]models.EmailTemplate, error) {
 	db := database.GetDB()
 	var templates []models.EmailTemplate
 
-	if err := db.Find(&templates).Error; err != nil {
+	if err := p.ScopeOwned(db).Find(&templates).Error; err != nil {
 		utils.Errorf("获取所有模板失败: %v", err)
 		return nil, fmt.Errorf("获取所有模板失败: %w", err)
 	}
@@ -32,11 +39,11 @@ func (s *TemplateService) GetAllTemplates() ([]models.EmailTemplate, error) {
 }
 
 // GetTemplateByID 获取单个模板
-func (s *TemplateService) GetTemplateByID(id uint) (*models.EmailTemplate, error) {
+func (s *TemplateService) GetTemplateByID(p *Principal, id uint) (*models.EmailTemplate, error) {
 	db := database.GetDB()
 	var template models.EmailTemplate
 
-	if err := db.First(&template, id).Error; err != nil {
+	if err := p.ScopeOwned(db).First(&template, id).Error; err != nil {
 		utils.Errorf("获取模板失败 (ID: %d): %v", id, err)
 		return nil, fmt.Errorf("获取模板失败: %w", err)
 	}
@@ -45,15 +52,28 @@ func (s *TemplateService) GetTemplateByID(id uint) (*models.EmailTemplate, error
 	return &template, nil
 }
 
-// CreateTemplate 创建模板
-func (s *TemplateService) CreateTemplate(template *models.EmailTemplate) error {
+// CreateTemplate 创建模板，创建者成为模板所有者，内容保存为版本1
+func (s *TemplateService) CreateTemplate(p *Principal, template *models.EmailTemplate) error {
 	// 验证数据
 	if err := s.validateTemplate(template); err != nil {
 		return err
 	}
+	template.ID = 0
+	template.OwnerID = p.UserID
+	template.WorkspaceID = p.WorkspaceID
+	template.Version = 1
 
 	db := database.GetDB()
-	if err := db.Create(template).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(template).Error; err != nil {
+			return err
+		}
+		if _, err := createTemplateVersion(tx, p, template.ID, template.Subject, template.Body); err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityEmailTemplate, template.ID, nil, template)
+	})
+	if err != nil {
 		utils.Errorf("创建模板失败: %v", err)
 		return fmt.Errorf("创建模板失败: %w", err)
 	}
@@ -62,8 +82,9 @@ func (s *TemplateService) CreateTemplate(template *models.EmailTemplate) error {
 	return nil
 }
 
-// UpdateTemplate 更新模板
-func (s *TemplateService) UpdateTemplate(id uint, template *models.EmailTemplate) error {
+// UpdateTemplate 更新模板（仅所有者或管理员）
+// 每次保存都新增一个版本；publish为false时只保存版本，当前发布的内容不变，之后可以通过PublishVersion发布
+func (s *TemplateService) UpdateTemplate(p *Principal, id uint, template *models.EmailTemplate, publish bool) error {
 	// 验证数据
 	if err := s.validateTemplate(template); err != nil {
 		return err
@@ -73,13 +94,47 @@ func (s *TemplateService) UpdateTemplate(id uint, template *models.EmailTemplate
 
 	// 检查模板是否存在
 	var existingTemplate models.EmailTemplate
-	if err := db.First(&existingTemplate, id).Error; err != nil {
+	if err := p.ScopeOwned(db).First(&existingTemplate, id).Error; err != nil {
 		utils.Errorf("模板不存在 (ID: %d): %v", id, err)
 		return fmt.Errorf("模板不存在: %w", err)
 	}
 
-	// 更新模板
-	if err := db.Model(&existingTemplate).Updates(template).Error; err != nil {
+	// 检查修改权限
+	if err := p.AuthorizeModify(existingTemplate.OwnerID); err != nil {
+		utils.Warnf("用户 %d 无权修改模板 (ID: %d)", p.UserID, id)
+		return err
+	}
+	template.ID = existingTemplate.ID
+	template.OwnerID = existingTemplate.OwnerID
+	template.WorkspaceID = existingTemplate.WorkspaceID
+
+	// 更新模板（共享标志可能被取消，需要单独更新零值）
+	before := existingTemplate
+	err := db.Transaction(func(tx *gorm.DB) error {
+		version, err := createTemplateVersion(tx, p, id, template.Subject, template.Body)
+		if err != nil {
+			return err
+		}
+		if publish {
+			template.Version = version.Version
+		} else {
+			template.Subject, template.Body, template.Version = existingTemplate.Subject, existingTemplate.Body, existingTemplate.Version
+		}
+
+		if err := tx.Model(&existingTemplate).Updates(template).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&existingTemplate).Update("shared", template.Shared).Error; err != nil {
+			return err
+		}
+
+		var after models.EmailTemplate
+		if err := tx.First(&after, id).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityEmailTemplate, id, &before, &after)
+	})
+	if err != nil {
 		utils.Errorf("更新模板失败 (ID: %d): %v", id, err)
 		return fmt.Errorf("更新模板失败: %w", err)
 	}
@@ -88,19 +143,35 @@ func (s *TemplateService) UpdateTemplate(id uint, template *models.EmailTemplate
 	return nil
 }
 
-// DeleteTemplate 删除模板
-func (s *TemplateService) DeleteTemplate(id uint) error {
+// DeleteTemplate 删除模板（仅所有者或管理员），模板的版本保留
+func (s *TemplateService) DeleteTemplate(p *Principal, id uint) error {
 	db := database.GetDB()
 
 	// 检查模板是否存在
 	var template models.EmailTemplate
-	if err := db.First(&template, id).Error; err != nil {
+	if err := p.ScopeOwned(db).First(&template, id).Error; err != nil {
 		utils.Errorf("模板不存在 (ID: %d): %v", id, err)
 		return fmt.Errorf("模板不存在: %w", err)
 	}
 
-	// 删除模板
-	if err := db.Delete(&template).Error; err != nil {
+	// 检查删除权限
+	if err := p.AuthorizeModify(template.OwnerID); err != nil {
+		utils.Warnf("用户 %d 无权删除模板 (ID: %d)", p.UserID, id)
+		return err
+	}
+
+	// 软删除模板并保留全部版本，改名释放工作区内唯一的名称，之后可以创建同名模板
+	before := template
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&template).Update("name", deletedTemplateName(&template)).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&template).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityEmailTemplate, id, &before, nil)
+	})
+	if err != nil {
 		utils.Errorf("删除模板失败 (ID: %d): %v", id, err)
 		return fmt.Errorf("删除模板失败: %w", err)
 	}
@@ -109,6 +180,16 @@ func (s *TemplateService) DeleteTemplate(id uint) error {
 	return nil
 }
 
+// deletedTemplateName 已删除模板的名称：原名称后加上模板ID，不超过名称的长度限制
+func deletedTemplateName(template *models.EmailTemplate) string {
+	suffix := fmt.Sprintf(" (已删除 #%d)", template.ID)
+	name := []rune(template.Name)
+	if limit := 100 - utf8.RuneCountInString(suffix); len(name) > limit {
+		name = name[:limit]
+	}
+	return string(name) + suffix
+}
+
 // validateTemplate 验证模板数据
 func (s *TemplateService) validateTemplate(template *models.EmailTemplate) error {
 	if template.Name == "" {
@@ -124,11 +205,11 @@ func (s *TemplateService) validateTemplate(template *models.EmailTemplate) error
 }
 
 // GetTemplateByName 根据名称获取模板
-func (s *TemplateService) GetTemplateByName(name string) (*models.EmailTemplate, error) {
+func (s *TemplateService) GetTemplateByName(p *Principal, name string) (*models.EmailTemplate, error) {
 	db := database.GetDB()
 	var template models.EmailTemplate
 
-	if err := db.Where("name = ?", name).First(&template).Error; err != nil {
+	if err := p.ScopeOwned(db).Where("name = ?", name).First(&template).Error; err != nil {
 		utils.Errorf("根据名称获取模板失败 (Name: %s): %v", name, err)
 		return nil, fmt.Errorf("根据名称获取模板失败: %w", err)
 	}
@@ -150,12 +231,12 @@ func (s *TemplateService) TemplateExists(id uint) (bool, error) {
 	return count > 0, nil
 }
 
-// NameExists 检查模板名称是否已存在
-func (s *TemplateService) NameExists(name string, excludeID uint) (bool, error) {
+// NameExists 检查当前工作区内模板名称是否已存在
+func (s *TemplateService) NameExists(p *Principal, name string, excludeID uint) (bool, error) {
 	db := database.GetDB()
 	var count int64
 
-	query := db.Model(&models.EmailTemplate{}).Where("name = ?", name)
+	query := p.ScopeWorkspace(db.Model(&models.EmailTemplate{})).Where("name = ?", name)
 	if excludeID > 0 {
 		query = query.Where("id != ?", excludeID)
 	}
@@ -169,14 +250,14 @@ func (s *TemplateService) NameExists(name string, excludeID uint) (bool, error)
 }
 
 // ValidateTemplateForUpdate 验证更新时的模板数据（包括名称唯一性检查）
-func (s *TemplateService) ValidateTemplateForUpdate(id uint, template *models.EmailTemplate) error {
+func (s *TemplateService) ValidateTemplateForUpdate(p *Principal, id uint, template *models.EmailTemplate) error {
 	// 基本验证
 	if err := s.validateTemplate(template); err != nil {
 		return err
 	}
 
 	// 检查名称是否已被其他模板使用
-	exists, err := s.NameExists(template.Name, id)
+	exists, err := s.NameExists(p, template.Name, id)
 	if err != nil {
 		return err
 	}
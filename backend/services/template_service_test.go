@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+
+	"gorm.io/gorm"
+)
+
+func TestDeleteTemplateKeepsVersions(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		s := NewTemplateService()
+		p := &Principal{UserID: 1, Role: models.RoleAdmin, WorkspaceID: 1}
+
+		template := &models.EmailTemplate{Name: "welcome", Subject: "hello", Body: "<p>v1</p>"}
+		if err := s.CreateTemplate(p, template); err != nil {
+			t.Fatalf("创建模板失败: %v", err)
+		}
+		if err := s.UpdateTemplate(p, template.ID, &models.EmailTemplate{Name: "welcome", Subject: "hello", Body: "<p>v2</p>"}, true); err != nil {
+			t.Fatalf("更新模板失败: %v", err)
+		}
+		if err := s.DeleteTemplate(p, template.ID); err != nil {
+			t.Fatalf("删除模板失败: %v", err)
+		}
+
+		if _, err := s.GetTemplateByID(p, template.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("删除后获取模板 = %v, want ErrRecordNotFound", err)
+		}
+		db := database.GetDB()
+		var versions int64
+		db.Model(&models.TemplateVersion{}).Where("template_id = ?", template.ID).Count(&versions)
+		if versions != 2 {
+			t.Errorf("删除后保留 %d 个版本，want 2", versions)
+		}
+		var deleted models.EmailTemplate
+		if err := db.Unscoped().First(&deleted, template.ID).Error; err != nil {
+			t.Fatalf("已删除的模板不存在: %v", err)
+		}
+		if !deleted.DeletedAt.Valid || !strings.HasPrefix(deleted.Name, "welcome (") {
+			t.Errorf("已删除的模板 DeletedAt=%v Name=%q", deleted.DeletedAt, deleted.Name)
+		}
+
+		// 删除后可以创建同名模板
+		if err := s.CreateTemplate(p, &models.EmailTemplate{Name: "welcome", Subject: "hello", Body: "<p>new</p>"}); err != nil {
+			t.Errorf("创建同名模板失败: %v", err)
+		}
+	})
+}
+
+func TestDeletedTemplateName(t *testing.T) {
+	name := deletedTemplateName(&models.EmailTemplate{ID: 42, Name: strings.Repeat("模", 100)})
+	if !strings.HasSuffix(name, " (已删除 #42)") || len([]rune(name)) != 100 {
+		t.Errorf("deletedTemplateName = %q (%d)", name, len([]rune(name)))
+	}
+}
+
+func TestUpdateTemplateKeepsWorkspace(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		s := NewTemplateService()
+		p := &Principal{UserID: 2, Role: models.RoleSender, WorkspaceID: 1}
+		template := &models.EmailTemplate{Name: "welcome", Subject: "hello", Body: "<p>v1</p>"}
+		if err := s.CreateTemplate(p, template); err != nil {
+			t.Fatalf("创建模板失败: %v", err)
+		}
+
+		// 请求中的workspace_id不能把模板移到其他工作区
+		update := &models.EmailTemplate{WorkspaceID: 2, Name: "welcome", Subject: "hello", Body: "<p>v2</p>"}
+		if err := s.UpdateTemplate(p, template.ID, update, true); err != nil {
+			t.Fatalf("更新模板失败: %v", err)
+		}
+		var stored models.EmailTemplate
+		database.GetDB().First(&stored, template.ID)
+		if stored.WorkspaceID != 1 || stored.Body != "<p>v2</p>" {
+			t.Errorf("更新后 workspace_id=%d body=%s, want 1 <p>v2</p>", stored.WorkspaceID, stored.Body)
+		}
+	})
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// maxDiffCells 逐行比较的最大规模（两个版本行数的乘积），超过时整体显示为删除和新增
+const maxDiffCells = 4000000
+
+// TemplateVersionListResponse 模板版本列表响应，列表不包含正文
+type TemplateVersionListResponse struct {
+	List     []models.TemplateVersion `json:"list"`
+	Total    int64                    `json:"total"`
+	Page     int                      `json:"page"`
+	PageSize int                      `json:"pageSize"`
+}
+
+// TemplateRollbackRequest 回滚请求，version为空时回滚到当前发布版本的上一个版本
+type TemplateRollbackRequest struct {
+	Version int `json:"version" binding:"min=0"`
+}
+
+// DiffLine 差异中的一行
+type DiffLine struct {
+	Op   string `json:"op"` // equal、delete（只在from中）、insert（只在to中）
+	Text string `json:"text"`
+}
+
+// TemplateDiff 两个版本的差异，正文按行比较
+type TemplateDiff struct {
+	From           int        `json:"from"`
+	To             int        `json:"to"`
+	SubjectFrom    string     `json:"subject_from"`
+	SubjectTo      string     `json:"subject_to"`
+	SubjectChanged bool       `json:"subject_changed"`
+	BodyChanged    bool       `json:"body_changed"`
+	Lines          []DiffLine `json:"lines"`
+}
+
+// createTemplateVersion 保存模板内容为新版本，版本号为模板的最大版本号加1
+func createTemplateVersion(tx *gorm.DB, p *Principal, templateID uint, subject, body string) (*models.TemplateVersion, error) {
+	var latest int
+	if err := tx.Model(&models.TemplateVersion{}).Where("template_id = ?", templateID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+	version := models.TemplateVersion{
+		TemplateID: templateID,
+		Version:    latest + 1,
+		Subject:    subject,
+		Body:       body,
+	}
+	if p != nil {
+		version.AuthorID = p.UserID
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return nil, err
+	}
+	return &version, nil
+}
+
+// findTemplateVersion 查找模板的指定版本，number为0时为当前发布的版本
+func findTemplateVersion(template *models.EmailTemplate, number int) (*models.TemplateVersion, error) {
+	if number == 0 {
+		number = template.Version
+	}
+	var version models.TemplateVersion
+	if err := database.GetDB().Where("template_id = ? AND version = ?", template.ID, number).First(&version).Error; err != nil {
+		return nil, fmt.Errorf("模板版本 %d 不存在: %w", number, err)
+	}
+	version.Current = version.Version == template.Version
+	return &version, nil
+}
+
+// ListVersions 获取模板的版本，最新的在前
+func (s *TemplateService) ListVersions(p *Principal, id uint, page, pageSize int) (*TemplateVersionListResponse, error) {
+	template, err := s.GetTemplateByID(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	db := database.GetDB().Model(&models.TemplateVersion{}).Where("template_id = ?", id)
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("获取模板版本总数失败: %w", err)
+	}
+	var versions []models.TemplateVersion
+	if err := db.Select("id", "template_id", "version", "subject", "author_id", "created_at").
+		Order("version DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&versions).Error; err != nil {
+		utils.Errorf("获取模板版本失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("获取模板版本失败: %w", err)
+	}
+	for i := range versions {
+		versions[i].Current = versions[i].Version == template.Version
+	}
+
+	return &TemplateVersionListResponse{
+		List:     versions,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// GetVersion 获取模板的一个版本，number为0时为当前发布的版本
+func (s *TemplateService) GetVersion(p *Principal, id uint, number int) (*models.TemplateVersion, error) {
+	template, err := s.GetTemplateByID(p, id)
+	if err != nil {
+		return nil, err
+	}
+	return findTemplateVersion(template, number)
+}
+
+// PublishVersion 发布指定版本，模板的主题和正文替换为该版本的内容（仅所有者或管理员）
+func (s *TemplateService) PublishVersion(p *Principal, id uint, number int) (*models.EmailTemplate, error) {
+	template, err := s.GetTemplateByID(p, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.AuthorizeModify(template.OwnerID); err != nil {
+		utils.Warnf("用户 %d 无权发布模板版本 (ID: %d)", p.UserID, id)
+		return nil, err
+	}
+	version, err := findTemplateVersion(template, number)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *template
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(template).Updates(map[string]interface{}{
+			"subject": version.Subject,
+			"body":    version.Body,
+			"version": version.Version,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.First(template, id).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionPublish, models.AuditEntityEmailTemplate, id, &before, template)
+	})
+	if err != nil {
+		utils.Errorf("发布模板版本失败 (ID: %d, Version: %d): %v", id, version.Version, err)
+		return nil, fmt.Errorf("发布模板版本失败: %w", err)
+	}
+
+	utils.Infof("发布模板版本成功: ID=%d, Version=%d", id, version.Version)
+	return template, nil
+}
+
+// Rollback 回滚到指定版本，number为0时回滚到当前发布版本的上一个版本
+func (s *TemplateService) Rollback(p *Principal, id uint, number int) (*models.EmailTemplate, error) {
+	if number == 0 {
+		template, err := s.GetTemplateByID(p, id)
+		if err != nil {
+			return nil, err
+		}
+		if template.Version <= 1 {
+			return nil, errors.New("当前发布的已经是第一个版本")
+		}
+		number = template.Version - 1
+	}
+	return s.PublishVersion(p, id, number)
+}
+
+// DiffVersions 比较模板的两个版本，from或to为0时为当前发布的版本
+func (s *TemplateService) DiffVersions(p *Principal, id uint, from, to int) (*TemplateDiff, error) {
+	template, err := s.GetTemplateByID(p, id)
+	if err != nil {
+		return nil, err
+	}
+	fromVersion, err := findTemplateVersion(template, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := findTemplateVersion(template, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TemplateDiff{
+		From:           fromVersion.Version,
+		To:             toVersion.Version,
+		SubjectFrom:    fromVersion.Subject,
+		SubjectTo:      toVersion.Subject,
+		SubjectChanged: fromVersion.Subject != toVersion.Subject,
+		BodyChanged:    fromVersion.Body != toVersion.Body,
+		Lines:          diffLines(strings.Split(fromVersion.Body, "\n"), strings.Split(toVersion.Body, "\n")),
+	}, nil
+}
+
+// diffLines 按最长公共子序列逐行比较，相同的开头和结尾不参与计算
+func diffLines(a, b []string) []DiffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]DiffLine, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		lines = append(lines, DiffLine{Op: "equal", Text: text})
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, text := range midA {
+			lines = append(lines, DiffLine{Op: "delete", Text: text})
+		}
+		for _, text := range midB {
+			lines = append(lines, DiffLine{Op: "insert", Text: text})
+		}
+	} else {
+		// lcs[i][j] 为 midA[i:] 与 midB[j:] 的最长公共子序列长度
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(midA) || j < len(midB) {
+			switch {
+			case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+				lines = append(lines, DiffLine{Op: "equal", Text: midA[i]})
+				i++
+				j++
+			case i < len(midA) && (j == len(midB) || lcs[i+1][j] >= lcs[i][j+1]):
+				lines = append(lines, DiffLine{Op: "delete", Text: midA[i]})
+				i++
+			default:
+				lines = append(lines, DiffLine{Op: "insert", Text: midB[j]})
+				j++
+			}
+		}
+	}
+
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, DiffLine{Op: "equal", Text: text})
+	}
+	return lines
+}
+
+// applyTemplate 使用邮件模板发送：主题或正文为空时取自模板的指定版本（默认为当前发布的版本），
+// 并记录实际使用的版本到发送历史
+func (s *EmailService) applyTemplate(p *Principal, req *SendEmailRequest) error {
+	if req.TemplateID == nil {
+		return nil
+	}
+	version, err := s.templateService.GetVersion(p, *req.TemplateID, req.TemplateVersion)
+	if err != nil {
+		return err
+	}
+	if req.Subject == "" {
+		req.Subject = version.Subject
+	}
+	if req.Body == "" {
+		req.Body = version.Body
+	}
+	req.templateID = req.TemplateID
+	req.templateVersion = version.Version
+	return nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+
+	"gorm.io/gorm/logger"
+)
+
+// testDrivers 测试使用的数据库：SQLite使用临时文件，PostgreSQL和MySQL通过环境变量提供连接字符串，未设置时跳过
+var testDrivers = []struct {
+	driver string
+	dsnEnv string
+}{
+	{database.DriverSQLite, ""},
+	{database.DriverPostgres, "SMTP_MAIL_TEST_POSTGRES_DSN"},
+	{database.DriverMySQL, "SMTP_MAIL_TEST_MYSQL_DSN"},
+}
+
+// testTables 每个测试开始前清空的表（按外键依赖的逆序）
+var testTables = []string{
+	"audit_events", "drafts", "captured_messages", "campaign_recipients", "campaigns", "contacts", "suppressions", "bounces", "bounce_mailboxes", "webhook_deliveries", "webhooks", "tracking_events",
+	"template_versions", "email_templates", "email_recipients", "email_histories", "smtp_configs", "workspace_members", "workspaces",
+}
+
+// forEachDriver 在每个可用的数据库上执行测试，数据库已执行全部迁移且业务表为空
+func forEachDriver(t *testing.T, test func(t *testing.T)) {
+	for _, backend := range testDrivers {
+		t.Run(backend.driver, func(t *testing.T) {
+			cfg := &config.GetConfig().Database
+			cfg.Driver = backend.driver
+			cfg.DSN = ""
+			cfg.Path = filepath.Join(t.TempDir(), "test.db")
+			if backend.dsnEnv != "" {
+				if cfg.DSN = os.Getenv(backend.dsnEnv); cfg.DSN == "" {
+					t.Skipf("未设置 %s", backend.dsnEnv)
+				}
+			}
+
+			if err := database.Open(); err != nil {
+				t.Fatalf("连接数据库失败: %v", err)
+			}
+			t.Cleanup(func() { database.Close() })
+			database.DB.Logger = logger.Default.LogMode(logger.Silent)
+			if err := database.Migrate(database.DB, 0); err != nil {
+				t.Fatalf("数据库迁移失败: %v", err)
+			}
+			for _, table := range testTables {
+				if err := database.DB.Exec("DELETE FROM " + table).Error; err != nil {
+					t.Fatalf("清空 %s 失败: %v", table, err)
+				}
+			}
+			test(t)
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// trackingSignPurpose 追踪令牌的签名用途，与其他签名隔离
+const trackingSignPurpose = "tracking"
+
+var (
+	// anchorHrefPattern 匹配<a>标签的href属性，分别捕获双引号和单引号中的值
+	anchorHrefPattern  = regexp.MustCompile(`(?i)(<a\b[^>]*?\bhref\s*=\s*)(?:"([^"]*)"|'([^']*)')`)
+	closingBodyPattern = regexp.MustCompile(`(?i)</body\s*>`)
+)
+
+// TrackingService 打开和点击追踪：改写HTML正文中的链接、添加追踪像素，并记录收件人的打开和点击
+type TrackingService struct {
+	cryptoService *CryptoService
+}
+
+// NewTrackingService 创建追踪服务实例
+func NewTrackingService() *TrackingService {
+	return &TrackingService{
+		cryptoService: NewCryptoService(),
+	}
+}
+
+// trackingPayload 追踪令牌的内容
+type trackingPayload struct {
+	Type      string `json:"t"`           // open、click
+	MessageID string `json:"m"`           // 邮件的Message-ID，对应发送历史
+	Recipient string `json:"r,omitempty"` // 只有一个收件人时记录，否则无法区分是谁打开的
+	URL       string `json:"u,omitempty"` // 点击跳转的目标链接
+}
+
+// TrackingEnabled 全局追踪开关
+func TrackingEnabled() bool {
+	return config.GetConfig().Tracking.Enabled
+}
+
+// token 生成签名的追踪令牌
+func (s *TrackingService) token(payload trackingPayload) (string, error) {
+	// 不转义<>&，Message-ID和链接中的这些字符会明显加长令牌
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(payload); err != nil {
+		return "", err
+	}
+	return s.cryptoService.SignToken(trackingSignPurpose, bytes.TrimSpace(buf.Bytes()))
+}
+
+// parseToken 验证追踪令牌并检查类型
+func (s *TrackingService) parseToken(token, eventType string) (*trackingPayload, error) {
+	data, err := s.cryptoService.VerifyToken(trackingSignPurpose, token)
+	if err != nil {
+		return nil, err
+	}
+	var payload trackingPayload
+	if err := json.Unmarshal(data, &payload); err != nil || payload.Type != eventType || payload.MessageID == "" {
+		return nil, ErrInvalidToken
+	}
+	if eventType == models.TrackingClick && payload.URL == "" {
+		return nil, ErrInvalidToken
+	}
+	return &payload, nil
+}
+
+// Instrument 改写HTML正文：http(s)链接改为经过签名跳转地址，并在</body>前（没有时在末尾）添加1x1追踪像素
+// 指向本服务的链接（如退订链接）不改写。recipient为空表示邮件有多个收件人
+func (s *TrackingService) Instrument(body, messageID, recipient string) (string, error) {
+	baseURL, err := publicURL("打开和点击追踪")
+	if err != nil {
+		return "", err
+	}
+
+	var rewriteErr error
+	body = anchorHrefPattern.ReplaceAllStringFunc(body, func(tag string) string {
+		m := anchorHrefPattern.FindStringSubmatch(tag)
+		target := html.UnescapeString(m[2] + m[3])
+		lower := strings.ToLower(target)
+		if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") || strings.HasPrefix(target, baseURL+"/") {
+			return tag
+		}
+		token, err := s.token(trackingPayload{Type: models.TrackingClick, MessageID: messageID, Recipient: recipient, URL: target})
+		if err != nil {
+			rewriteErr = err
+			return tag
+		}
+		return m[1] + `"` + baseURL + "/t/c/" + token + `"`
+	})
+	if rewriteErr != nil {
+		return "", fmt.Errorf("生成追踪链接失败: %w", rewriteErr)
+	}
+
+	token, err := s.token(trackingPayload{Type: models.TrackingOpen, MessageID: messageID, Recipient: recipient})
+	if err != nil {
+		return "", fmt.Errorf("生成追踪像素失败: %w", err)
+	}
+	pixel := `<img src="` + baseURL + "/t/o/" + token + `" width="1" height="1" alt="" style="border:0;width:1px;height:1px">`
+	if locs := closingBodyPattern.FindAllStringIndex(body, -1); len(locs) > 0 {
+		at := locs[len(locs)-1][0]
+		return body[:at] + pixel + body[at:], nil
+	}
+	return body + pixel, nil
+}
+
+// Open 记录一次打开。令牌无效时返回错误，调用方仍应返回像素图片
+func (s *TrackingService) Open(token, userAgent, ip string) error {
+	payload, err := s.parseToken(token, models.TrackingOpen)
+	if err != nil {
+		return err
+	}
+	s.record(payload, userAgent, ip)
+	return nil
+}
+
+// Click 记录一次点击并返回跳转的目标链接；记录失败不影响跳转
+func (s *TrackingService) Click(token, userAgent, ip string) (string, error) {
+	payload, err := s.parseToken(token, models.TrackingClick)
+	if err != nil {
+		return "", err
+	}
+	s.record(payload, userAgent, ip)
+	return payload.URL, nil
+}
+
+// record 按Message-ID找到发送历史，写入追踪事件并更新收件人的计数；全局开关关闭时不记录
+func (s *TrackingService) record(payload *trackingPayload, userAgent, ip string) {
+	if !TrackingEnabled() {
+		return
+	}
+
+	db := database.GetDB()
+	var history models.EmailHistory
+	if err := db.Where("message_id = ? AND tracked = ?", payload.MessageID, true).First(&history).Error; err != nil {
+		utils.Warnf("追踪事件没有匹配的发送历史 (%s): %v", payload.MessageID, err)
+		return
+	}
+
+	if len(userAgent) > 512 {
+		userAgent = userAgent[:512]
+	}
+	event := models.TrackingEvent{
+		WorkspaceID: history.WorkspaceID,
+		HistoryID:   history.ID,
+		Type:        payload.Type,
+		URL:         payload.URL,
+		UserAgent:   userAgent,
+		IP:          ip,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if payload.Recipient != "" {
+			var recipient models.EmailRecipient
+			err := tx.Where("history_id = ? AND LOWER(address) = ?", history.ID, strings.ToLower(payload.Recipient)).First(&recipient).Error
+			if err == nil {
+				event.RecipientID = &recipient.ID
+				if err := s.countRecipient(tx, &recipient, payload.Type); err != nil {
+					return err
+				}
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+		}
+		return tx.Create(&event).Error
+	})
+	if err != nil {
+		utils.Errorf("记录追踪事件失败 (HistoryID: %d): %v", history.ID, err)
+	}
+}
+
+// countRecipient 增加收件人的打开或点击次数，第一次时记录时间
+func (s *TrackingService) countRecipient(tx *gorm.DB, recipient *models.EmailRecipient, eventType string) error {
+	counter, firstAt, first := "open_count", "opened_at", recipient.OpenedAt == nil
+	if eventType == models.TrackingClick {
+		counter, firstAt, first = "click_count", "clicked_at", recipient.ClickedAt == nil
+	}
+	updates := map[string]interface{}{counter: gorm.Expr(counter + " + 1")}
+	if first {
+		updates[firstAt] = time.Now()
+	}
+	return tx.Model(recipient).Updates(updates).Error
+}
+
+// ListEvents 获取发送历史的追踪事件，按时间倒序
+func (s *TrackingService) ListEvents(p *Principal, historyID uint) ([]models.TrackingEvent, error) {
+	db := database.GetDB()
+	var history models.EmailHistory
+	if err := p.ScopeHistory(db).First(&history, historyID).Error; err != nil {
+		return nil, fmt.Errorf("历史记录不存在: %w", err)
+	}
+
+	var events []models.TrackingEvent
+	if err := db.Where("history_id = ?", history.ID).Order("id DESC").Find(&events).Error; err != nil {
+		utils.Errorf("获取追踪事件失败 (HistoryID: %d): %v", historyID, err)
+		return nil, fmt.Errorf("获取追踪事件失败: %w", err)
+	}
+	return events, nil
+}
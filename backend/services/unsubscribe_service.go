@@ -0,0 +1,103 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+)
+
+const (
+	// UnsubscribePlaceholder 邮件正文（包括模板）中的退订链接占位符，批量发送时替换为收件人的退订链接
+	UnsubscribePlaceholder = "{{unsubscribe_url}}"
+	// unsubscribeSignPurpose 退订令牌的签名用途，与其他签名隔离
+	unsubscribeSignPurpose = "unsubscribe"
+)
+
+// ErrInvalidUnsubscribeToken 退订令牌格式错误或签名无效
+var ErrInvalidUnsubscribeToken = errors.New("无效的退订链接")
+
+// UnsubscribeService 一键退订服务（RFC 8058）：生成签名的退订令牌，收件人退订后加入抑制列表
+type UnsubscribeService struct {
+	cryptoService      *CryptoService
+	suppressionService *SuppressionService
+}
+
+// NewUnsubscribeService 创建退订服务实例
+func NewUnsubscribeService() *UnsubscribeService {
+	return &UnsubscribeService{
+		cryptoService:      NewCryptoService(),
+		suppressionService: NewSuppressionService(),
+	}
+}
+
+// UnsubscribeResult 退订结果
+type UnsubscribeResult struct {
+	Email string `json:"email"`
+}
+
+// Token 生成收件人的退订令牌，内容为 工作区ID:邮箱
+// 令牌不过期，主密钥轮换后只要旧密钥仍在密钥环中即可验证
+func (s *UnsubscribeService) Token(workspaceID uint, email string) (string, error) {
+	payload := fmt.Sprintf("%d:%s", workspaceID, strings.ToLower(strings.TrimSpace(email)))
+	token, err := s.cryptoService.SignToken(unsubscribeSignPurpose, []byte(payload))
+	if err != nil {
+		return "", fmt.Errorf("生成退订令牌失败: %w", err)
+	}
+	return token, nil
+}
+
+// ParseToken 验证退订令牌，返回工作区ID和收件人邮箱
+func (s *UnsubscribeService) ParseToken(token string) (uint, string, error) {
+	payload, err := s.cryptoService.VerifyToken(unsubscribeSignPurpose, token)
+	if err != nil {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+	id, email, ok := strings.Cut(string(payload), ":")
+	if !ok {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+	workspaceID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil || email == "" {
+		return 0, "", ErrInvalidUnsubscribeToken
+	}
+	return uint(workspaceID), email, nil
+}
+
+// publicURL 返回配置的外部访问地址（不带结尾的/），退订和追踪链接基于该地址生成
+func publicURL(feature string) (string, error) {
+	url := strings.TrimRight(config.GetConfig().Server.PublicURL, "/")
+	if url == "" {
+		return "", fmt.Errorf("%s需要配置 server.public_url", feature)
+	}
+	return url, nil
+}
+
+// URL 生成收件人的退订链接，需要配置 server.public_url
+func (s *UnsubscribeService) URL(workspaceID uint, email string) (string, error) {
+	baseURL, err := publicURL("批量发送")
+	if err != nil {
+		return "", err
+	}
+	token, err := s.Token(workspaceID, email)
+	if err != nil {
+		return "", err
+	}
+	return baseURL + "/u/" + token, nil
+}
+
+// Unsubscribe 验证令牌并将收件人加入所在工作区的抑制列表，重复退订不会报错
+func (s *UnsubscribeService) Unsubscribe(token string) (*UnsubscribeResult, error) {
+	workspaceID, email, err := s.ParseToken(token)
+	if err != nil {
+		utils.Warnf("退订令牌无效: %v", err)
+		return nil, err
+	}
+	s.suppressionService.Add(workspaceID, email, models.SuppressionSourceUnsubscribe, "收件人通过退订链接退订")
+	utils.Infof("收件人退订: WorkspaceID=%d, Email=%s", workspaceID, email)
+	return &UnsubscribeResult{Email: email}, nil
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrWebhookAddressBlocked Webhook地址指向回环、内网或链路本地等非公网地址
+var ErrWebhookAddressBlocked = errors.New("Webhook地址不能指向回环、内网或链路本地地址")
+
+// webhookBlockedPrefixes 除回环、私有和链路本地地址外不允许投递的地址段
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级NAT，部分云平台的元数据服务也在此段
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF协议分配
+	netip.MustParsePrefix("198.18.0.0/15"), // 基准测试
+	netip.MustParsePrefix("240.0.0.0/4"),   // 保留地址和广播
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// webhookAddressBlocked 地址是否不允许作为Webhook的投递目标
+func webhookAddressBlocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return true
+	}
+	for _, prefix := range webhookBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWebhookHost 保存Webhook时检查URL的主机：IP地址直接检查，域名解析后检查全部地址
+// 投递时连接的地址还会由webhookTransport再次检查，防止保存后域名被解析到内网
+func checkWebhookHost(ctx context.Context, u *url.URL) error {
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if webhookAddressBlocked(addr) {
+			return fmt.Errorf("%w: %s", ErrWebhookAddressBlocked, host)
+		}
+		return nil
+	}
+	if strings.EqualFold(strings.TrimSuffix(host, "."), "localhost") {
+		return fmt.Errorf("%w: %s", ErrWebhookAddressBlocked, host)
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("无法解析Webhook地址 %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if webhookAddressBlocked(addr) {
+			return fmt.Errorf("%w: %s 解析为 %s", ErrWebhookAddressBlocked, host, addr)
+		}
+	}
+	return nil
+}
+
+// webhookDialControl 在建立连接前检查解析后的实际地址，重定向和DNS重新绑定也无法连接到内网
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("无法识别连接地址 %s: %w", address, err)
+	}
+	if webhookAddressBlocked(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddressBlocked, addrPort.Addr())
+	}
+	return nil
+}
+
+// webhookTransport 投递使用的HTTP传输，allowPrivate为false时拒绝连接非公网地址
+// 检查的是直接连接的地址，因此不使用环境变量中的HTTP代理
+func webhookTransport(allowPrivate bool) http.RoundTripper {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if allowPrivate {
+		return transport
+	}
+	transport.Proxy = nil
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: webhookDialControl}
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,593 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"smtp-mail/backend/config"
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// webhookMaxBackoff 重试等待时间上限
+	webhookMaxBackoff = time.Hour
+	// webhookResponseLimit 投递记录中保存的响应内容长度
+	webhookResponseLimit = 1024
+	// webhookPollInterval 投递队列的轮询间隔
+	webhookPollInterval = 5 * time.Second
+	// webhookClaimLease 认领投递后的租约时间（另加请求超时），进程在投递中退出时租约到期后重新投递
+	webhookClaimLease = time.Minute
+)
+
+// webhookWake 有新的投递时唤醒投递协程
+var webhookWake = make(chan struct{}, 1)
+
+// WebhookService 出站Webhook服务：管理订阅，并通过持久化的投递队列推送事件
+type WebhookService struct {
+	cfg           config.WebhookConfig
+	cryptoService *CryptoService
+	auditService  *AuditService
+	client        *http.Client
+}
+
+// NewWebhookService 创建Webhook服务实例
+func NewWebhookService() *WebhookService {
+	cfg := config.GetConfig().Webhook
+	return &WebhookService{
+		cfg:           cfg,
+		cryptoService: NewCryptoService(),
+		auditService:  NewAuditService(),
+		client:        &http.Client{Timeout: cfg.Timeout, Transport: webhookTransport(cfg.AllowPrivateNetworks)},
+	}
+}
+
+// WebhookRequest 创建或更新Webhook请求
+type WebhookRequest struct {
+	URL     string   `json:"url" binding:"required"`
+	Events  []string `json:"events"`  // 为空表示订阅全部事件
+	Secret  string   `json:"secret"`  // 创建时为空则自动生成；更新时为空表示不修改
+	Enabled *bool    `json:"enabled"` // 默认启用
+}
+
+// CreateWebhookResponse 创建Webhook响应，签名密钥只返回这一次
+type CreateWebhookResponse struct {
+	Secret  string         `json:"secret"`
+	Webhook models.Webhook `json:"webhook"`
+}
+
+// WebhookDeliveryListResponse 投递记录列表响应
+type WebhookDeliveryListResponse struct {
+	List     []models.WebhookDelivery `json:"list"`
+	Total    int64                    `json:"total"`
+	Page     int                      `json:"page"`
+	PageSize int                      `json:"pageSize"`
+}
+
+// WebhookPayload 推送的事件内容
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// webhookEmailData 邮件事件的数据（不包含正文）
+type webhookEmailData struct {
+	HistoryID    uint               `json:"history_id"`
+	WorkspaceID  uint               `json:"workspace_id"`
+	SmtpConfigID uint               `json:"smtp_config_id"`
+	UserID       uint               `json:"user_id"`
+	APIKeyID     *uint              `json:"api_key_id"`
+	ToEmail      string             `json:"to_email"`
+	CcEmail      []string           `json:"cc_email"`
+	Subject      string             `json:"subject"`
+	Status       models.EmailStatus `json:"status"`
+	ErrorMessage string             `json:"error_message,omitempty"`
+	ScanResult   string             `json:"scan_result,omitempty"`
+	MessageID    string             `json:"message_id,omitempty"`
+	BounceType   string             `json:"bounce_type,omitempty"`
+	SentAt       time.Time          `json:"sent_at"`
+}
+
+// validateWebhook 校验URL和事件；未允许内网投递时URL不能指向回环、内网或链路本地地址
+func (s *WebhookService) validateWebhook(ctx context.Context, req *WebhookRequest) error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("URL必须是有效的http或https地址")
+	}
+	if !s.cfg.AllowPrivateNetworks {
+		if err := checkWebhookHost(ctx, u); err != nil {
+			return err
+		}
+	}
+	for _, event := range req.Events {
+		if event == "*" {
+			continue
+		}
+		valid := false
+		for _, known := range models.WebhookEvents {
+			if event == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("不支持的事件: %s", event)
+		}
+	}
+	return nil
+}
+
+// ListWebhooks 获取当前工作区的Webhook
+func (s *WebhookService) ListWebhooks(p *Principal) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	if err := p.ScopeWorkspace(database.GetDB()).Order("id ASC").Find(&webhooks).Error; err != nil {
+		utils.Errorf("获取Webhook列表失败: %v", err)
+		return nil, fmt.Errorf("获取Webhook列表失败: %w", err)
+	}
+	return webhooks, nil
+}
+
+// GetWebhook 获取Webhook
+func (s *WebhookService) GetWebhook(p *Principal, id uint) (*models.Webhook, error) {
+	var webhook models.Webhook
+	if err := p.ScopeWorkspace(database.GetDB()).First(&webhook, id).Error; err != nil {
+		return nil, fmt.Errorf("Webhook不存在: %w", err)
+	}
+	return &webhook, nil
+}
+
+// CreateWebhook 创建Webhook
+func (s *WebhookService) CreateWebhook(p *Principal, req *WebhookRequest) (*CreateWebhookResponse, error) {
+	if err := s.validateWebhook(context.Background(), req); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		generated, err := randomHex(24)
+		if err != nil {
+			return nil, fmt.Errorf("生成签名密钥失败: %w", err)
+		}
+		secret = "whsec_" + generated
+	}
+	encrypted, err := s.cryptoService.EncryptPassword(secret)
+	if err != nil {
+		return nil, fmt.Errorf("加密签名密钥失败: %w", err)
+	}
+
+	webhook := models.Webhook{
+		WorkspaceID: p.WorkspaceID,
+		URL:         req.URL,
+		Events:      req.Events,
+		Secret:      encrypted,
+		CreatedBy:   p.UserID,
+	}
+	enabled := req.Enabled == nil || *req.Enabled
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&webhook).Error; err != nil {
+			return err
+		}
+		// 列默认值为true，关闭时需要单独更新零值
+		if !enabled {
+			if err := tx.Model(&webhook).Update("enabled", false).Error; err != nil {
+				return err
+			}
+			webhook.Enabled = false
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityWebhook, webhook.ID, nil, &webhook)
+	})
+	if err != nil {
+		utils.Errorf("创建Webhook失败: %v", err)
+		return nil, fmt.Errorf("创建Webhook失败: %w", err)
+	}
+
+	utils.Infof("创建Webhook成功: ID=%d, URL=%s, Events=%v", webhook.ID, webhook.URL, webhook.Events)
+	return &CreateWebhookResponse{Secret: secret, Webhook: webhook}, nil
+}
+
+// UpdateWebhook 更新Webhook
+func (s *WebhookService) UpdateWebhook(p *Principal, id uint, req *WebhookRequest) (*models.Webhook, error) {
+	if err := s.validateWebhook(context.Background(), req); err != nil {
+		return nil, err
+	}
+	webhook, err := s.GetWebhook(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	before := *webhook
+	updates := map[string]interface{}{
+		"url":    req.URL,
+		"events": models.StringSlice(req.Events),
+	}
+	if req.Enabled != nil {
+		updates["enabled"] = *req.Enabled
+	}
+	if req.Secret != "" {
+		encrypted, err := s.cryptoService.EncryptPassword(req.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("加密签名密钥失败: %w", err)
+		}
+		updates["secret"] = encrypted
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(webhook).Updates(updates).Error; err != nil {
+			return err
+		}
+		if err := tx.First(webhook, id).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionUpdate, models.AuditEntityWebhook, id, &before, webhook)
+	})
+	if err != nil {
+		utils.Errorf("更新Webhook失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("更新Webhook失败: %w", err)
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook 删除Webhook及其投递记录
+func (s *WebhookService) DeleteWebhook(p *Principal, id uint) error {
+	webhook, err := s.GetWebhook(p, id)
+	if err != nil {
+		return err
+	}
+
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(webhook).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionDelete, models.AuditEntityWebhook, id, webhook, nil)
+	})
+	if err != nil {
+		utils.Errorf("删除Webhook失败 (ID: %d): %v", id, err)
+		return fmt.Errorf("删除Webhook失败: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries 获取Webhook的投递记录
+func (s *WebhookService) ListDeliveries(p *Principal, id uint, page, pageSize int) (*WebhookDeliveryListResponse, error) {
+	if _, err := s.GetWebhook(p, id); err != nil {
+		return nil, err
+	}
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	db := database.GetDB().Model(&models.WebhookDelivery{}).Where("webhook_id = ?", id)
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("获取投递记录总数失败: %w", err)
+	}
+	var deliveries []models.WebhookDelivery
+	if err := db.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("获取投递记录失败: %w", err)
+	}
+
+	return &WebhookDeliveryListResponse{
+		List:     deliveries,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}, nil
+}
+
+// TestWebhook 立即向Webhook发送一个测试事件（只尝试一次），返回投递记录
+func (s *WebhookService) TestWebhook(p *Principal, id uint) (*models.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(WebhookPayload{
+		Event:     models.WebhookEventTest,
+		CreatedAt: time.Now(),
+		Data:      map[string]interface{}{"webhook_id": webhook.ID, "triggered_by": p.Username},
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery := &models.WebhookDelivery{
+		WebhookID:   webhook.ID,
+		WorkspaceID: webhook.WorkspaceID,
+		Event:       models.WebhookEventTest,
+		Payload:     string(payload),
+		Status:      models.WebhookDeliveryPending,
+	}
+	if err := database.GetDB().Create(delivery).Error; err != nil {
+		return nil, fmt.Errorf("创建投递记录失败: %w", err)
+	}
+
+	s.attempt(context.Background(), webhook, delivery, 1)
+	return delivery, nil
+}
+
+// Enqueue 根据发送历史的状态生成邮件事件，加入订阅了该事件的Webhook的投递队列
+func (s *WebhookService) Enqueue(history *models.EmailHistory) {
+	var event string
+	switch history.Status {
+	case models.EmailStatusSuccess:
+		event = models.WebhookEventEmailSent
+	case models.EmailStatusFailed:
+		event = models.WebhookEventEmailFailed
+	case models.EmailStatusCaptured:
+		event = models.WebhookEventEmailCaptured
+	case models.EmailStatusBounced:
+		event = models.WebhookEventEmailBounced
+	default:
+		return
+	}
+	s.EnqueueEvent(event, history)
+}
+
+// EnqueueEvent 生成指定的邮件事件，加入订阅了该事件的Webhook的投递队列
+func (s *WebhookService) EnqueueEvent(event string, history *models.EmailHistory) {
+	db := database.GetDB()
+	var webhooks []models.Webhook
+	if err := db.Where("workspace_id = ? AND enabled = ?", history.WorkspaceID, true).Find(&webhooks).Error; err != nil {
+		utils.Errorf("查询Webhook失败: %v", err)
+		return
+	}
+
+	var payload []byte
+	now := time.Now()
+	queued := 0
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event) {
+			continue
+		}
+		if payload == nil {
+			var err error
+			payload, err = json.Marshal(WebhookPayload{
+				Event:     event,
+				CreatedAt: now,
+				Data: webhookEmailData{
+					HistoryID:    history.ID,
+					WorkspaceID:  history.WorkspaceID,
+					SmtpConfigID: history.SmtpConfigID,
+					UserID:       history.UserID,
+					APIKeyID:     history.APIKeyID,
+					ToEmail:      history.ToEmail,
+					CcEmail:      history.CcEmail,
+					Subject:      history.Subject,
+					Status:       history.Status,
+					ErrorMessage: history.ErrorMessage,
+					ScanResult:   history.ScanResult,
+					MessageID:    history.MessageID,
+					BounceType:   history.BounceType,
+					SentAt:       history.SentAt,
+				},
+			})
+			if err != nil {
+				utils.Errorf("序列化Webhook事件失败: %v", err)
+				return
+			}
+		}
+
+		delivery := models.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			WorkspaceID:   webhook.WorkspaceID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := db.Create(&delivery).Error; err != nil {
+			utils.Errorf("创建Webhook投递记录失败 (WebhookID: %d): %v", webhook.ID, err)
+			continue
+		}
+		queued++
+	}
+
+	if queued > 0 {
+		select {
+		case webhookWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// StartWorker 启动投递协程，处理到期的投递和重试
+// 投递队列保存在数据库中，命令行等其他进程产生的事件也由服务进程投递
+func (s *WebhookService) StartWorker(ctx context.Context) {
+	go func() {
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			case <-webhookWake:
+				timer.Stop()
+			}
+			s.processDue(ctx)
+			timer.Reset(s.nextWait())
+		}
+	}()
+}
+
+// nextWait 距离下一次到期投递的等待时间，最长为轮询间隔
+func (s *WebhookService) nextWait() time.Duration {
+	var next models.WebhookDelivery
+	err := database.GetDB().Select("next_attempt_at").
+		Where("status = ? AND next_attempt_at IS NOT NULL", models.WebhookDeliveryPending).
+		Order("next_attempt_at ASC").Limit(1).Find(&next).Error
+	if err != nil || next.NextAttemptAt == nil {
+		return webhookPollInterval
+	}
+	if wait := time.Until(*next.NextAttemptAt); wait < webhookPollInterval {
+		if wait < 0 {
+			return 0
+		}
+		return wait
+	}
+	return webhookPollInterval
+}
+
+// processDue 投递所有到期的记录
+func (s *WebhookService) processDue(ctx context.Context) {
+	db := database.GetDB()
+	for ctx.Err() == nil {
+		var due []models.WebhookDelivery
+		err := db.Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).
+			Order("next_attempt_at ASC").Limit(50).Find(&due).Error
+		if err != nil {
+			utils.Errorf("查询待投递的Webhook失败: %v", err)
+			return
+		}
+		if len(due) == 0 {
+			return
+		}
+
+		for i := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			delivery := &due[i]
+			if !s.claim(delivery) {
+				continue
+			}
+			var webhook models.Webhook
+			if err := db.First(&webhook, delivery.WebhookID).Error; err != nil || !webhook.Enabled {
+				delivery.Status = models.WebhookDeliveryFailed
+				delivery.LastError = "Webhook已删除或已停用"
+				delivery.NextAttemptAt = nil
+				db.Save(delivery)
+				continue
+			}
+			s.attempt(ctx, &webhook, delivery, s.cfg.MaxAttempts)
+		}
+	}
+}
+
+// claim 认领到期的投递：把下一次投递时间推迟到租约结束，只有更新成功的进程进行投递
+func (s *WebhookService) claim(delivery *models.WebhookDelivery) bool {
+	now := time.Now()
+	lease := now.Add(webhookClaimLease + s.cfg.Timeout)
+	result := database.GetDB().Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND attempts = ? AND next_attempt_at <= ?", delivery.ID, models.WebhookDeliveryPending, delivery.Attempts, now).
+		Update("next_attempt_at", lease)
+	if result.Error != nil {
+		utils.Errorf("认领Webhook投递失败 (ID: %d): %v", delivery.ID, result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+	delivery.NextAttemptAt = &lease
+	return true
+}
+
+// attempt 投递一次并更新投递记录；失败且未达到maxAttempts时按指数退避安排下一次重试
+func (s *WebhookService) attempt(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery, maxAttempts int) {
+	delivery.Attempts++
+	delivery.LastStatusCode = 0
+	delivery.LastError = ""
+	delivery.LastResponse = ""
+
+	err := s.post(ctx, webhook, delivery)
+	now := time.Now()
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliverySuccess
+		delivery.DeliveredAt = &now
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= maxAttempts:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(webhookBackoff(s.cfg.Backoff, delivery.Attempts))
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+
+	if err != nil {
+		utils.Warnf("Webhook投递失败 (WebhookID: %d, DeliveryID: %d, 第%d次): %v", webhook.ID, delivery.ID, delivery.Attempts, err)
+	}
+	if err := database.GetDB().Save(delivery).Error; err != nil {
+		utils.Errorf("更新Webhook投递记录失败 (ID: %d): %v", delivery.ID, err)
+	}
+}
+
+// webhookBackoff 第attempts次失败后的等待时间：base * 2^(attempts-1)，最长1小时
+func webhookBackoff(base time.Duration, attempts int) time.Duration {
+	if base <= 0 {
+		base = time.Second
+	}
+	wait := base
+	for i := 1; i < attempts && wait < webhookMaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > webhookMaxBackoff {
+		wait = webhookMaxBackoff
+	}
+	return wait
+}
+
+// post 发送签名的POST请求，2xx响应视为成功
+func (s *WebhookService) post(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) error {
+	secret, err := s.cryptoService.DecryptPassword(webhook.Secret)
+	if err != nil {
+		return fmt.Errorf("解密签名密钥失败: %w", err)
+	}
+
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "smtp-mail-webhook/1.0")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Signature", "t="+timestamp+",v1="+SignWebhookPayload(secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	response, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	delivery.LastStatusCode = resp.StatusCode
+	delivery.LastResponse = string(response)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("接收方返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhookPayload 计算签名：HMAC-SHA256(secret, timestamp + "." + body) 的十六进制
+func SignWebhookPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+)
+
+func TestWebhookClaim(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		db := database.GetDB()
+		past := time.Now().Add(-time.Second)
+		delivery := models.WebhookDelivery{WebhookID: 1, WorkspaceID: 1, Event: models.WebhookEventEmailSent, Payload: "{}",
+			Status: models.WebhookDeliveryPending, NextAttemptAt: &past}
+		if err := db.Create(&delivery).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		// 两个进程读到同一条到期记录，只有一个能认领
+		first, second := delivery, delivery
+		s := NewWebhookService()
+		if !s.claim(&first) {
+			t.Fatal("第一次认领失败")
+		}
+		if s.claim(&second) {
+			t.Error("已认领的投递被再次认领")
+		}
+		if !first.NextAttemptAt.After(time.Now()) {
+			t.Errorf("认领后的下一次投递时间 %v 应在租约结束时", first.NextAttemptAt)
+		}
+
+		var due int64
+		db.Model(&models.WebhookDelivery{}).Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, time.Now()).Count(&due)
+		if due != 0 {
+			t.Errorf("认领后仍有 %d 条到期投递", due)
+		}
+	})
+}
+
+func TestRetriedEvent(t *testing.T) {
+	forEachDriver(t, func(t *testing.T) {
+		useTestKeyring(t, testKey1)
+		db := database.GetDB()
+		if err := db.Create(&models.Workspace{ID: 1, Name: "one", Slug: "one"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&models.Webhook{WorkspaceID: 1, URL: "https://example.com/hook", Secret: "secret", Enabled: true}).Error; err != nil {
+			t.Fatal(err)
+		}
+		fallback := newFakeSMTP(t, "250 ok")
+		configs := []models.SMTPConfig{
+			{WorkspaceID: 1, Name: "primary", Host: "127.0.0.1", Port: closedPort(t), FromEmail: "from@example.com"},
+			{WorkspaceID: 1, Name: "fallback", Host: "127.0.0.1", Port: fallback.listener.Addr().(*net.TCPAddr).Port, FromEmail: "from@example.com"},
+		}
+		if err := db.Create(&configs).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		// 直接发送成功时只有发送结果事件，改用备用配置时先生成重试事件
+		s := NewEmailService()
+		p := &Principal{UserID: 1, Role: models.RoleAdmin, WorkspaceID: 1}
+		tests := []struct {
+			name       string
+			configID   uint
+			wantEvents []string
+		}{
+			{"direct", configs[1].ID, []string{models.WebhookEventEmailSent}},
+			{"fallback", configs[0].ID, []string{models.WebhookEventEmailRetried, models.WebhookEventEmailSent}},
+		}
+		for _, tt := range tests {
+			history, err := s.SendEmail(p, &SendEmailRequest{SmtpConfigID: tt.configID, To: []string{"bob@example.com"},
+				Subject: "hello", Body: "<p>hi</p>", fallbackSmtpConfigIDs: []uint{configs[1].ID}})
+			if err != nil {
+				t.Fatalf("%s: 发送失败: %v", tt.name, err)
+			}
+			var events []string
+			db.Model(&models.WebhookDelivery{}).Where("payload LIKE ?", fmt.Sprintf(`%%"history_id":%d,%%`, history.ID)).
+				Order("id").Pluck("event", &events)
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("%s: 事件 %v，want %v", tt.name, events, tt.wantEvents)
+			}
+		}
+	})
+}
+
+func TestWebhookAddressGuard(t *testing.T) {
+	blocked := []string{"http://127.0.0.1/hook", "http://localhost:8080/hook", "http://[::1]/hook", "http://10.1.2.3/hook",
+		"http://192.168.1.10/hook", "http://169.254.169.254/latest/meta-data", "http://100.100.100.200/", "http://0.0.0.0/",
+		"http://[fe80::1]/hook", "http://[::ffff:127.0.0.1]/hook"}
+	s := &WebhookService{}
+	for _, address := range blocked {
+		if err := s.validateWebhook(context.Background(), &WebhookRequest{URL: address}); !errors.Is(err, ErrWebhookAddressBlocked) {
+			t.Errorf("%s: validateWebhook = %v, want ErrWebhookAddressBlocked", address, err)
+		}
+	}
+	if err := s.validateWebhook(context.Background(), &WebhookRequest{URL: "https://93.184.216.34/hook"}); err != nil {
+		t.Errorf("公网地址被拒绝: %v", err)
+	}
+	s.cfg.AllowPrivateNetworks = true
+	if err := s.validateWebhook(context.Background(), &WebhookRequest{URL: "http://127.0.0.1/hook"}); err != nil {
+		t.Errorf("允许内网时被拒绝: %v", err)
+	}
+
+	// 投递时检查实际连接的地址
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	for _, allowPrivate := range []bool{false, true} {
+		client := &http.Client{Transport: webhookTransport(allowPrivate)}
+		resp, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+		if err == nil {
+			resp.Body.Close()
+		}
+		if allowPrivate && err != nil {
+			t.Errorf("允许内网时投递失败: %v", err)
+		}
+		if !allowPrivate && !errors.Is(err, ErrWebhookAddressBlocked) {
+			t.Errorf("投递到 %s = %v，want ErrWebhookAddressBlocked", server.URL, err)
+		}
+	}
+}
@@ -0,0 +1,640 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"smtp-mail/backend/database"
+	"smtp-mail/backend/models"
+	"smtp-mail/backend/utils"
+
+	"gorm.io/gorm"
+)
+
+// 工作区错误定义
+var (
+	ErrNoWorkspace    = errors.New("当前用户不属于任何工作区")
+	ErrQuotaExceeded  = errors.New("工作区发送配额已用尽")
+	ErrInvalidInvite  = errors.New("邀请无效或已过期")
+	ErrAlreadyMember  = errors.New("用户已是该工作区成员")
+	ErrLastAdminLeave = errors.New("不能移除工作区的最后一个管理员")
+)
+
+// defaultWorkspaceSlug 默认工作区标识，升级时已有数据归入该工作区
+const defaultWorkspaceSlug = "default"
+
+// invitationTokenPrefix 邀请令牌前缀
+const invitationTokenPrefix = "inv_"
+
+// slugPattern 工作区标识格式
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,99}$`)
+
+// workspaceScopedTables 带有workspace_id字段的业务表
+var workspaceScopedTables = []string{"smtp_configs", "email_templates", "email_histories", "api_keys"}
+
+// WorkspaceService 工作区服务
+type WorkspaceService struct {
+	auditService *AuditService
+}
+
+// NewWorkspaceService 创建工作区服务实例
+func NewWorkspaceService() *WorkspaceService {
+	return &WorkspaceService{
+		auditService: NewAuditService(),
+	}
+}
+
+// WorkspaceRequest 创建/更新工作区请求
+type WorkspaceRequest struct {
+	Name         string `json:"name"`
+	Slug         string `json:"slug"`
+	DailyQuota   int    `json:"daily_quota"`
+	MonthlyQuota int    `json:"monthly_quota"`
+}
+
+// MemberRequest 添加/更新成员请求
+type MemberRequest struct {
+	UserID uint        `json:"user_id"`
+	Role   models.Role `json:"role" binding:"required"`
+}
+
+// InvitationRequest 创建邀请请求
+type InvitationRequest struct {
+	Email          string      `json:"email"`
+	Role           models.Role `json:"role" binding:"required"`
+	ExpiresInHours int         `json:"expires_in_hours"`
+}
+
+// InvitationResponse 创建邀请响应，完整令牌只返回这一次
+type InvitationResponse struct {
+	Token      string                     `json:"token"`
+	Invitation models.WorkspaceInvitation `json:"invitation"`
+}
+
+// WorkspaceUsage 工作区配额使用情况
+type WorkspaceUsage struct {
+	DailyQuota   int   `json:"daily_quota"`
+	DailySent    int64 `json:"daily_sent"`
+	MonthlyQuota int   `json:"monthly_quota"`
+	MonthlySent  int64 `json:"monthly_sent"`
+}
+
+// EnsureDefaultWorkspace 没有任何工作区时创建默认工作区
+// 并将升级前的数据和已有用户归入默认工作区
+func (s *WorkspaceService) EnsureDefaultWorkspace() error {
+	db := database.GetDB()
+
+	var count int64
+	if err := db.Model(&models.Workspace{}).Count(&count).Error; err != nil {
+		return fmt.Errorf("统计工作区数量失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		workspace := models.Workspace{Name: "默认工作区", Slug: defaultWorkspaceSlug}
+		if err := tx.Create(&workspace).Error; err != nil {
+			return fmt.Errorf("创建默认工作区失败: %w", err)
+		}
+
+		// 已有数据归入默认工作区
+		for _, table := range workspaceScopedTables {
+			if err := tx.Table(table).Where("workspace_id = ?", 0).Update("workspace_id", workspace.ID).Error; err != nil {
+				return fmt.Errorf("迁移 %s 到默认工作区失败: %w", table, err)
+			}
+		}
+
+		// 已有用户以全局角色加入默认工作区
+		var users []models.User
+		if err := tx.Find(&users).Error; err != nil {
+			return err
+		}
+		for _, user := range users {
+			member := models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID, Role: user.Role}
+			if err := tx.Create(&member).Error; err != nil {
+				return fmt.Errorf("添加默认工作区成员失败: %w", err)
+			}
+		}
+
+		utils.Infof("已创建默认工作区: ID=%d, 成员数=%d", workspace.ID, len(users))
+		return nil
+	})
+}
+
+// Resolve 确定操作者的当前工作区和工作区内角色
+// requested为请求头 X-Workspace-ID 的值，为空时使用用户加入的第一个工作区
+func (s *WorkspaceService) Resolve(p *Principal, requested string) error {
+	db := database.GetDB()
+
+	// API密钥固定属于创建时的工作区
+	if p.IsAPIKey() {
+		if requested != "" && requested != strconv.FormatUint(uint64(p.WorkspaceID), 10) {
+			return ErrForbidden
+		}
+		role, err := s.memberRole(p, p.WorkspaceID)
+		if err != nil {
+			return ErrUnauthorized
+		}
+		*p = *p.InWorkspace(p.WorkspaceID, role)
+		return nil
+	}
+
+	if requested != "" {
+		id, err := strconv.ParseUint(requested, 10, 32)
+		if err != nil {
+			return ErrForbidden
+		}
+		role, err := s.memberRole(p, uint(id))
+		if err != nil {
+			return err
+		}
+		*p = *p.InWorkspace(uint(id), role)
+		return nil
+	}
+
+	// 默认使用最早加入的工作区，系统管理员没有成员关系时使用第一个工作区
+	var member models.WorkspaceMember
+	err := db.Where("user_id = ?", p.UserID).Order("id").First(&member).Error
+	if err == nil {
+		*p = *p.InWorkspace(member.WorkspaceID, member.Role)
+		return nil
+	}
+	if p.IsSystemAdmin() {
+		var workspace models.Workspace
+		if err := db.Order("id").First(&workspace).Error; err == nil {
+			*p = *p.InWorkspace(workspace.ID, models.RoleAdmin)
+			return nil
+		}
+	}
+
+	// 不属于任何工作区时不能访问工作区数据，角色清空以免沿用全局角色
+	*p = *p.InWorkspace(0, "")
+	return ErrNoWorkspace
+}
+
+// memberRole 获取用户在工作区内的角色，系统管理员对所有存在的工作区都是管理员
+func (s *WorkspaceService) memberRole(p *Principal, workspaceID uint) (models.Role, error) {
+	db := database.GetDB()
+
+	var member models.WorkspaceMember
+	err := db.Where("workspace_id = ? AND user_id = ?", workspaceID, p.UserID).First(&member).Error
+	if err == nil {
+		return member.Role, nil
+	}
+	if p.IsSystemAdmin() {
+		if err := db.First(&models.Workspace{}, workspaceID).Error; err != nil {
+			return "", err
+		}
+		return models.RoleAdmin, nil
+	}
+	return "", ErrForbidden
+}
+
+// authorizeWorkspaceAdmin 检查操作者是否为指定工作区的管理员，返回切换到该工作区的操作者
+func (s *WorkspaceService) authorizeWorkspaceAdmin(p *Principal, workspaceID uint) (*Principal, error) {
+	role, err := s.memberRole(p, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	scoped := p.InWorkspace(workspaceID, role)
+	if err := scoped.Authorize(PermWorkspaceManage); err != nil {
+		return nil, err
+	}
+	return scoped, nil
+}
+
+// ListWorkspaces 获取当前用户所属的工作区，系统管理员可查看全部
+func (s *WorkspaceService) ListWorkspaces(p *Principal) ([]models.Workspace, error) {
+	db := database.GetDB()
+	query := db.Order("id")
+	if !p.IsSystemAdmin() {
+		query = query.Where("id IN (?)", db.Model(&models.WorkspaceMember{}).Select("workspace_id").Where("user_id = ?", p.UserID))
+	}
+
+	var workspaces []models.Workspace
+	if err := query.Find(&workspaces).Error; err != nil {
+		utils.Errorf("获取工作区列表失败: %v", err)
+		return nil, fmt.Errorf("获取工作区列表失败: %w", err)
+	}
+	return workspaces, nil
+}
+
+// GetWorkspace 获取单个工作区（需为成员）
+func (s *WorkspaceService) GetWorkspace(p *Principal, id uint) (*models.Workspace, error) {
+	if _, err := s.memberRole(p, id); err != nil {
+		return nil, err
+	}
+
+	var workspace models.Workspace
+	if err := database.GetDB().First(&workspace, id).Error; err != nil {
+		utils.Errorf("获取工作区失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("获取工作区失败: %w", err)
+	}
+	return &workspace, nil
+}
+
+// CreateWorkspace 创建工作区，创建者成为工作区管理员
+func (s *WorkspaceService) CreateWorkspace(p *Principal, req *WorkspaceRequest) (*models.Workspace, error) {
+	if err := p.Authorize(PermWorkspaceCreate); err != nil {
+		return nil, err
+	}
+	if err := validateWorkspaceRequest(req); err != nil {
+		return nil, err
+	}
+
+	workspace := models.Workspace{
+		Name:         req.Name,
+		Slug:         req.Slug,
+		DailyQuota:   req.DailyQuota,
+		MonthlyQuota: req.MonthlyQuota,
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workspace).Error; err != nil {
+			return err
+		}
+		member := models.WorkspaceMember{WorkspaceID: workspace.ID, UserID: p.UserID, Role: models.RoleAdmin}
+		if err := tx.Create(&member).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p.InWorkspace(workspace.ID, models.RoleAdmin), models.AuditActionCreate, models.AuditEntityWorkspace, workspace.ID, nil, &workspace)
+	})
+	if err != nil {
+		utils.Errorf("创建工作区失败: %v", err)
+		return nil, fmt.Errorf("创建工作区失败: %w", err)
+	}
+
+	utils.Infof("创建工作区成功: ID=%d, Slug=%s", workspace.ID, workspace.Slug)
+	return &workspace, nil
+}
+
+// UpdateWorkspace 更新工作区名称和配额（需为工作区管理员）
+func (s *WorkspaceService) UpdateWorkspace(p *Principal, id uint, req *WorkspaceRequest) (*models.Workspace, error) {
+	scoped, err := s.authorizeWorkspaceAdmin(p, id)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	var workspace models.Workspace
+	if err := db.First(&workspace, id).Error; err != nil {
+		return nil, err
+	}
+	if req.Slug == "" {
+		req.Slug = workspace.Slug
+	}
+	if err := validateWorkspaceRequest(req); err != nil {
+		return nil, err
+	}
+
+	before := workspace
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&workspace).Updates(map[string]interface{}{
+			"name":          req.Name,
+			"slug":          req.Slug,
+			"daily_quota":   req.DailyQuota,
+			"monthly_quota": req.MonthlyQuota,
+		}).Error; err != nil {
+			return err
+		}
+
+		var after models.Workspace
+		if err := tx.First(&after, id).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, scoped, models.AuditActionUpdate, models.AuditEntityWorkspace, id, &before, &after)
+	})
+	if err != nil {
+		utils.Errorf("更新工作区失败 (ID: %d): %v", id, err)
+		return nil, fmt.Errorf("更新工作区失败: %w", err)
+	}
+
+	utils.Infof("更新工作区成功: ID=%d", id)
+	return s.GetWorkspace(p, id)
+}
+
+// validateWorkspaceRequest 验证工作区数据
+func validateWorkspaceRequest(req *WorkspaceRequest) error {
+	if req.Name == "" {
+		return errors.New("工作区名称不能为空")
+	}
+	req.Slug = strings.ToLower(req.Slug)
+	if !slugPattern.MatchString(req.Slug) {
+		return errors.New("工作区标识只能包含小写字母、数字和短横线")
+	}
+	if req.DailyQuota < 0 || req.MonthlyQuota < 0 {
+		return errors.New("配额不能为负数")
+	}
+	return nil
+}
+
+// ListMembers 获取工作区成员（需为成员）
+func (s *WorkspaceService) ListMembers(p *Principal, workspaceID uint) ([]models.WorkspaceMember, error) {
+	if _, err := s.memberRole(p, workspaceID); err != nil {
+		return nil, err
+	}
+
+	var members []models.WorkspaceMember
+	if err := database.GetDB().Preload("User").Where("workspace_id = ?", workspaceID).Order("id").Find(&members).Error; err != nil {
+		utils.Errorf("获取工作区成员失败 (WorkspaceID: %d): %v", workspaceID, err)
+		return nil, fmt.Errorf("获取工作区成员失败: %w", err)
+	}
+	return members, nil
+}
+
+// AddMember 直接添加已有用户为工作区成员（需为工作区管理员）
+func (s *WorkspaceService) AddMember(p *Principal, workspaceID uint, req *MemberRequest) (*models.WorkspaceMember, error) {
+	scoped, err := s.authorizeWorkspaceAdmin(p, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Role.IsValid() {
+		return nil, errors.New("无效的成员角色")
+	}
+
+	db := database.GetDB()
+	if err := db.First(&models.User{}, req.UserID).Error; err != nil {
+		return nil, fmt.Errorf("用户不存在: %w", err)
+	}
+
+	member := models.WorkspaceMember{WorkspaceID: workspaceID, UserID: req.UserID, Role: req.Role}
+	if err := s.createMember(db, scoped, &member); err != nil {
+		return nil, err
+	}
+
+	utils.Infof("添加工作区成员成功: WorkspaceID=%d, UserID=%d, Role=%s", workspaceID, req.UserID, req.Role)
+	return &member, nil
+}
+
+// createMember 创建成员记录并写入审计事件
+func (s *WorkspaceService) createMember(db *gorm.DB, p *Principal, member *models.WorkspaceMember) error {
+	var count int64
+	if err := db.Model(&models.WorkspaceMember{}).Where("workspace_id = ? AND user_id = ?", member.WorkspaceID, member.UserID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrAlreadyMember
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, p, models.AuditActionCreate, models.AuditEntityMember, member.ID, nil, member)
+	})
+}
+
+// UpdateMember 修改成员角色（需为工作区管理员）
+func (s *WorkspaceService) UpdateMember(p *Principal, workspaceID, userID uint, req *MemberRequest) (*models.WorkspaceMember, error) {
+	scoped, err := s.authorizeWorkspaceAdmin(p, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Role.IsValid() {
+		return nil, errors.New("无效的成员角色")
+	}
+
+	db := database.GetDB()
+	var member models.WorkspaceMember
+	if err := db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	if member.Role == models.RoleAdmin && req.Role != models.RoleAdmin {
+		if err := s.ensureOtherAdmin(db, workspaceID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	before := member
+	member.Role = req.Role
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&member).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, scoped, models.AuditActionUpdate, models.AuditEntityMember, member.ID, &before, &member)
+	})
+	if err != nil {
+		utils.Errorf("更新工作区成员失败: %v", err)
+		return nil, fmt.Errorf("更新工作区成员失败: %w", err)
+	}
+
+	utils.Infof("更新工作区成员成功: WorkspaceID=%d, UserID=%d, Role=%s", workspaceID, userID, req.Role)
+	return &member, nil
+}
+
+// RemoveMember 移除工作区成员（需为工作区管理员）
+func (s *WorkspaceService) RemoveMember(p *Principal, workspaceID, userID uint) error {
+	scoped, err := s.authorizeWorkspaceAdmin(p, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	db := database.GetDB()
+	var member models.WorkspaceMember
+	if err := db.Where("workspace_id = ? AND user_id = ?", workspaceID, userID).First(&member).Error; err != nil {
+		return err
+	}
+	if member.Role == models.RoleAdmin {
+		if err := s.ensureOtherAdmin(db, workspaceID, userID); err != nil {
+			return err
+		}
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, scoped, models.AuditActionDelete, models.AuditEntityMember, member.ID, &member, nil)
+	})
+	if err != nil {
+		utils.Errorf("移除工作区成员失败: %v", err)
+		return fmt.Errorf("移除工作区成员失败: %w", err)
+	}
+
+	utils.Infof("移除工作区成员成功: WorkspaceID=%d, UserID=%d", workspaceID, userID)
+	return nil
+}
+
+// ensureOtherAdmin 确保工作区除指定用户外还有其他管理员
+func (s *WorkspaceService) ensureOtherAdmin(db *gorm.DB, workspaceID, userID uint) error {
+	var count int64
+	if err := db.Model(&models.WorkspaceMember{}).
+		Where("workspace_id = ? AND role = ? AND user_id != ?", workspaceID, models.RoleAdmin, userID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrLastAdminLeave
+	}
+	return nil
+}
+
+// ListInvitations 获取工作区的邀请（需为工作区管理员）
+func (s *WorkspaceService) ListInvitations(p *Principal, workspaceID uint) ([]models.WorkspaceInvitation, error) {
+	if _, err := s.authorizeWorkspaceAdmin(p, workspaceID); err != nil {
+		return nil, err
+	}
+
+	var invitations []models.WorkspaceInvitation
+	if err := database.GetDB().Where("workspace_id = ?", workspaceID).Order("created_at DESC").Find(&invitations).Error; err != nil {
+		utils.Errorf("获取工作区邀请失败 (WorkspaceID: %d): %v", workspaceID, err)
+		return nil, fmt.Errorf("获取工作区邀请失败: %w", err)
+	}
+	return invitations, nil
+}
+
+// CreateInvitation 创建工作区邀请（需为工作区管理员）
+func (s *WorkspaceService) CreateInvitation(p *Principal, workspaceID uint, req *InvitationRequest) (*InvitationResponse, error) {
+	scoped, err := s.authorizeWorkspaceAdmin(p, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	if !req.Role.IsValid() {
+		return nil, errors.New("无效的成员角色")
+	}
+	expiresInHours := req.ExpiresInHours
+	if expiresInHours <= 0 {
+		expiresInHours = 72
+	}
+
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, fmt.Errorf("生成邀请令牌失败: %w", err)
+	}
+	token := invitationTokenPrefix + secret
+
+	invitation := models.WorkspaceInvitation{
+		WorkspaceID: workspaceID,
+		Email:       req.Email,
+		Role:        req.Role,
+		TokenHash:   hashAPIKey(token),
+		InvitedBy:   p.UserID,
+		ExpiresAt:   time.Now().Add(time.Duration(expiresInHours) * time.Hour),
+	}
+	err = database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invitation).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, scoped, models.AuditActionCreate, models.AuditEntityInvitation, invitation.ID, nil, &invitation)
+	})
+	if err != nil {
+		utils.Errorf("创建工作区邀请失败: %v", err)
+		return nil, fmt.Errorf("创建工作区邀请失败: %w", err)
+	}
+
+	utils.Infof("创建工作区邀请成功: ID=%d, WorkspaceID=%d, Role=%s", invitation.ID, workspaceID, req.Role)
+	return &InvitationResponse{Token: token, Invitation: invitation}, nil
+}
+
+// RevokeInvitation 撤销未接受的邀请（需为工作区管理员）
+func (s *WorkspaceService) RevokeInvitation(p *Principal, workspaceID, invitationID uint) error {
+	scoped, err := s.authorizeWorkspaceAdmin(p, workspaceID)
+	if err != nil {
+		return err
+	}
+
+	db := database.GetDB()
+	var invitation models.WorkspaceInvitation
+	if err := db.Where("workspace_id = ?", workspaceID).First(&invitation, invitationID).Error; err != nil {
+		return err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&invitation).Error; err != nil {
+			return err
+		}
+		return s.auditService.Record(tx, scoped, models.AuditActionRevoke, models.AuditEntityInvitation, invitation.ID, &invitation, nil)
+	})
+	if err != nil {
+		utils.Errorf("撤销工作区邀请失败: %v", err)
+		return fmt.Errorf("撤销工作区邀请失败: %w", err)
+	}
+	return nil
+}
+
+// AcceptInvitation 当前用户接受邀请并加入工作区
+func (s *WorkspaceService) AcceptInvitation(p *Principal, token string) (*models.WorkspaceMember, error) {
+	if p.IsAPIKey() {
+		return nil, ErrForbidden
+	}
+
+	db := database.GetDB()
+	var invitation models.WorkspaceInvitation
+	if err := db.Where("token_hash = ?", hashAPIKey(token)).First(&invitation).Error; err != nil {
+		return nil, ErrInvalidInvite
+	}
+	if !invitation.IsPending() {
+		return nil, ErrInvalidInvite
+	}
+
+	member := models.WorkspaceMember{WorkspaceID: invitation.WorkspaceID, UserID: p.UserID, Role: invitation.Role}
+	scoped := p.InWorkspace(invitation.WorkspaceID, invitation.Role)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := s.createMember(tx, scoped, &member); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&invitation).Updates(map[string]interface{}{
+			"accepted_by": p.UserID,
+			"accepted_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	utils.Infof("用户接受工作区邀请: UserID=%d, WorkspaceID=%d, Role=%s", p.UserID, invitation.WorkspaceID, invitation.Role)
+	return &member, nil
+}
+
+// GetUsage 获取工作区配额使用情况（需为成员）
+func (s *WorkspaceService) GetUsage(p *Principal, workspaceID uint) (*WorkspaceUsage, error) {
+	if _, err := s.memberRole(p, workspaceID); err != nil {
+		return nil, err
+	}
+	return s.usage(workspaceID)
+}
+
+// usage 统计工作区当日和当月的发送量
+func (s *WorkspaceService) usage(workspaceID uint) (*WorkspaceUsage, error) {
+	db := database.GetDB()
+
+	var workspace models.Workspace
+	if err := db.First(&workspace, workspaceID).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	usage := &WorkspaceUsage{DailyQuota: workspace.DailyQuota, MonthlyQuota: workspace.MonthlyQuota}
+	if err := db.Model(&models.EmailHistory{}).Where("workspace_id = ? AND created_at >= ?", workspaceID, startOfDay).Count(&usage.DailySent).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Model(&models.EmailHistory{}).Where("workspace_id = ? AND created_at >= ?", workspaceID, startOfMonth).Count(&usage.MonthlySent).Error; err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// CheckQuota 检查工作区是否还有发送配额
+func (s *WorkspaceService) CheckQuota(workspaceID uint) error {
+	usage, err := s.usage(workspaceID)
+	if err != nil {
+		return fmt.Errorf("获取工作区配额失败: %w", err)
+	}
+	if usage.DailyQuota > 0 && usage.DailySent >= int64(usage.DailyQuota) {
+		utils.Warnf("工作区 %d 已达到每日配额: %d", workspaceID, usage.DailyQuota)
+		return ErrQuotaExceeded
+	}
+	if usage.MonthlyQuota > 0 && usage.MonthlySent >= int64(usage.MonthlyQuota) {
+		utils.Warnf("工作区 %d 已达到每月配额: %d", workspaceID, usage.MonthlyQuota)
+		return ErrQuotaExceeded
+	}
+	return nil
+}
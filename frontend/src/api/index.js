@@ -16,6 +16,11 @@ service.interceptors.request.use(
     if (token) {
       config.headers['Authorization'] = `Bearer ${token}`
     }
+    // 当前工作区，未选择时由后端使用默认工作区
+    const workspaceId = localStorage.getItem('workspaceId')
+    if (workspaceId) {
+      config.headers['X-Workspace-ID'] = workspaceId
+    }
     return config
   },
   error => {
@@ -72,6 +77,22 @@ service.interceptors.response.use(
   }
 )
 
+// 认证相关API
+export const login = (data) => {
+  return service({
+    url: '/auth/login',
+    method: 'post',
+    data
+  })
+}
+
+export const getCurrentUser = () => {
+  return service({
+    url: '/auth/me',
+    method: 'get'
+  })
+}
+
 // SMTP配置相关API
 export const getSmtpConfigs = () => {
   return service({
@@ -166,6 +187,13 @@ export const getHistory = (params) => {
   })
 }
 
+export const getHistoryDetail = (id) => {
+  return service({
+    url: `/history/${id}`,
+    method: 'get'
+  })
+}
+
 export const getHistoryStatistics = () => {
   return service({
     url: '/history/statistics',
@@ -1,6 +1,12 @@
 import { createRouter, createWebHistory } from 'vue-router'
 
 const routes = [
+  {
+    path: '/login',
+    name: 'Login',
+    component: () => import('../views/Login.vue'),
+    meta: { title: '登录', public: true }
+  },
   {
     path: '/',
     name: 'Dashboard',
@@ -38,9 +44,13 @@ const router = createRouter({
   routes
 })
 
-// 路由守卫 - 设置页面标题
+// 路由守卫 - 设置页面标题并检查登录状态
 router.beforeEach((to, from, next) => {
   document.title = to.meta.title ? `${to.meta.title} - SMTP邮件管理系统` : 'SMTP邮件管理系统'
+  if (!to.meta.public && !localStorage.getItem('token')) {
+    next({ path: '/login', query: { redirect: to.fullPath } })
+    return
+  }
   next()
 })
 